@@ -0,0 +1,334 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dayLayout = "2006-01-02"
+
+// Transfer is an amount of traffic in bytes
+type Transfer struct {
+	Downloaded uint64 `json:"downloaded"`
+	Uploaded   uint64 `json:"uploaded"`
+}
+
+// Total returns downloaded + uploaded
+func (t Transfer) Total() uint64 {
+	return t.Downloaded + t.Uploaded
+}
+
+func (t *Transfer) add(o Transfer) {
+	t.Downloaded += o.Downloaded
+	t.Uploaded += o.Uploaded
+}
+
+// AccountingState is what an Accountant persists between samples
+type AccountingState struct {
+	Days       map[string]Transfer `json:"days"`       // keyed by local date, 2006-01-02
+	Last       Transfer            `json:"last"`       // cumulative counters at the last sample
+	LastSample time.Time           `json:"lastSample"` // zero before the first sample
+
+	// TrackerDays is the transfer of each day by tracker host, see
+	// TrackerHost
+	TrackerDays map[string]map[string]Transfer `json:"trackerDays,omitempty"`
+	// Torrents is the counters of each torrent at the last sample, by hash
+	Torrents map[string]Transfer `json:"torrents,omitempty"`
+}
+
+// AccountingStore persists the accounting state
+type AccountingStore interface {
+	// Load returns the saved state, or nil if there is none yet
+	Load() (*AccountingState, error)
+	Save(*AccountingState) error
+}
+
+// Accountant samples the daemon's cumulative stats and attributes the
+// transfer between two samples to the local calendar day of the second one.
+// Counters going backwards (stats reset, new daemon) are treated as a
+// restart from zero.
+//
+// It also diffs the counters of every torrent to attribute their transfer
+// to the host of their tracker, for ratio management on private trackers.
+// The transfer of torrents removed between two samples is lost for the
+// trackers, not for the daily totals.
+type Accountant struct {
+	client *TransmissionClient
+	store  AccountingStore
+
+	mu     sync.Mutex
+	state  *AccountingState
+	errors []func(error)
+}
+
+// NewAccountant returns an accountant resuming from the state in store
+func NewAccountant(client *TransmissionClient, store AccountingStore) (*Accountant, error) {
+	state, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = &AccountingState{}
+	}
+	if state.Days == nil {
+		state.Days = make(map[string]Transfer)
+	}
+	return &Accountant{client: client, store: store, state: state}, nil
+}
+
+// accountingFields are the torrent fields a sample reads
+var accountingFields = []string{"hashString", "downloadedEver", "uploadedEver", "trackers"}
+
+// Sample reads the cumulative stats and the counters of the torrents,
+// accounts the deltas and saves the state
+func (a *Accountant) Sample(ctx context.Context) error {
+	stats, err := a.client.GetStatsContext(ctx)
+	if err != nil {
+		return err
+	}
+	torrents := make(map[string]torrentTransfer)
+	err = a.client.ForEachTorrent(ctx, accountingFields, func(t *Torrent) error {
+		torrents[t.InfoHash] = torrentTransfer{
+			host:     TrackerHost(t),
+			transfer: Transfer{Downloaded: t.DownloadedEver, Uploaded: t.UploadedEver},
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return a.record(Transfer{
+		Downloaded: stats.CumulativeStats.DownloadedBytes,
+		Uploaded:   stats.CumulativeStats.UploadedBytes,
+	}, torrents, time.Now())
+}
+
+// torrentTransfer is the counters of a torrent at a sample
+type torrentTransfer struct {
+	host     string
+	transfer Transfer
+}
+
+func (a *Accountant) record(cur Transfer, torrents map[string]torrentTransfer, now time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	day := now.Format(dayLayout)
+	if !a.state.LastSample.IsZero() {
+		total := a.state.Days[day]
+		total.add(cur.since(a.state.Last))
+		a.state.Days[day] = total
+	}
+	if a.state.Torrents != nil {
+		for hash, t := range torrents {
+			// torrents added since the last sample started from zero
+			delta := t.transfer.since(a.state.Torrents[hash])
+			if delta.Total() == 0 {
+				continue
+			}
+			if a.state.TrackerDays == nil {
+				a.state.TrackerDays = make(map[string]map[string]Transfer)
+			}
+			hosts := a.state.TrackerDays[day]
+			if hosts == nil {
+				hosts = make(map[string]Transfer)
+				a.state.TrackerDays[day] = hosts
+			}
+			total := hosts[t.host]
+			total.add(delta)
+			hosts[t.host] = total
+		}
+	}
+	a.state.Torrents = make(map[string]Transfer, len(torrents))
+	for hash, t := range torrents {
+		a.state.Torrents[hash] = t.transfer
+	}
+	a.state.Last = cur
+	a.state.LastSample = now
+	return a.store.Save(a.state)
+}
+
+// since returns the transfer from the counters last to t, counters going
+// backwards restarting from zero
+func (t Transfer) since(last Transfer) Transfer {
+	delta := t
+	if t.Downloaded >= last.Downloaded {
+		delta.Downloaded -= last.Downloaded
+	}
+	if t.Uploaded >= last.Uploaded {
+		delta.Uploaded -= last.Uploaded
+	}
+	return delta
+}
+
+// TrackerHost returns the host of the torrent's first tracker, the one
+// its transfer is accounted to, or "" for a torrent without trackers
+func TrackerHost(t *Torrent) string {
+	if len(t.Trackers) == 0 {
+		return ""
+	}
+	u, err := url.Parse(t.Trackers[0].Announce)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// OnError registers fn to be called when a sample fails
+func (a *Accountant) OnError(fn func(error)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.errors = append(a.errors, fn)
+}
+
+// Run samples every interval until ctx is done; failed samples are reported
+// to the OnError handlers and retried at the next tick
+func (a *Accountant) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := a.Sample(ctx); err != nil && ctx.Err() == nil {
+			a.mu.Lock()
+			handlers := a.errors
+			a.mu.Unlock()
+			for _, fn := range handlers {
+				fn(err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Day returns the transfer accounted to the local day of t
+func (a *Accountant) Day(t time.Time) Transfer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state.Days[t.Format(dayLayout)]
+}
+
+// Month returns the transfer accounted to the local month of t
+func (a *Accountant) Month(t time.Time) Transfer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var total Transfer
+	month := t.Format("2006-01")
+	for day, tr := range a.state.Days {
+		if strings.HasPrefix(day, month) {
+			total.add(tr)
+		}
+	}
+	return total
+}
+
+// TrackerDay returns the transfer accounted to each tracker host on the
+// local day of t
+func (a *Accountant) TrackerDay(t time.Time) map[string]Transfer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	hosts := make(map[string]Transfer)
+	for host, tr := range a.state.TrackerDays[t.Format(dayLayout)] {
+		hosts[host] = tr
+	}
+	return hosts
+}
+
+// TrackerMonth returns the transfer accounted to each tracker host in the
+// local month of t
+func (a *Accountant) TrackerMonth(t time.Time) map[string]Transfer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	hosts := make(map[string]Transfer)
+	month := t.Format("2006-01")
+	for day, byHost := range a.state.TrackerDays {
+		if !strings.HasPrefix(day, month) {
+			continue
+		}
+		for host, tr := range byHost {
+			total := hosts[host]
+			total.add(tr)
+			hosts[host] = total
+		}
+	}
+	return hosts
+}
+
+// MemoryAccountingStore keeps the state in memory only
+type MemoryAccountingStore struct {
+	mu    sync.Mutex
+	state []byte
+}
+
+func (s *MemoryAccountingStore) Load() (*AccountingState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == nil {
+		return nil, nil
+	}
+	state := &AccountingState{}
+	return state, json.Unmarshal(s.state, state)
+}
+
+func (s *MemoryAccountingStore) Save(state *AccountingState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = b
+	return nil
+}
+
+// FileAccountingStore keeps the state as JSON in a file
+type FileAccountingStore struct {
+	Path string
+}
+
+func (s FileAccountingStore) Load() (*AccountingState, error) {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &AccountingState{}
+	return state, json.Unmarshal(b, state)
+}
+
+// Save writes the state to a temporary file renamed over Path, so a crash
+// never leaves a truncated file
+func (s FileAccountingStore) Save(state *AccountingState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
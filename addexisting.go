@@ -0,0 +1,144 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VerifyPollInterval is how often AddExisting checks the verification
+var VerifyPollInterval = time.Second
+
+// ExistingStage is a step of AddExisting
+type ExistingStage int
+
+const (
+	StageAdding ExistingStage = iota
+	StageLocating
+	StageVerifying
+	StageStarting
+	StageDone
+)
+
+func (s ExistingStage) String() string {
+	switch s {
+	case StageAdding:
+		return "adding"
+	case StageLocating:
+		return "locating"
+	case StageVerifying:
+		return "verifying"
+	case StageStarting:
+		return "starting"
+	case StageDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// ExistingProgress is reported by AddExisting
+type ExistingProgress struct {
+	Stage    ExistingStage
+	Torrent  TorrentAdded
+	Verified float64 // 0...1, during StageVerifying
+}
+
+// IncompleteDataError is returned by AddExisting when verification found
+// missing or corrupt data; the torrent is left paused
+type IncompleteDataError struct {
+	Torrent     TorrentAdded
+	PercentDone float32
+}
+
+func (e *IncompleteDataError) Error() string {
+	return fmt.Sprintf("%s: only %.1f%% of the data is valid", e.Torrent.Name, e.PercentDone*100)
+}
+
+// AddExisting adds the torrent of addCmd paused, points it at location, the
+// daemon directory already holding its complete data, verifies the data
+// and starts seeding once it checks out. progress, if not nil, is called as
+// the steps go.
+func (ac *TransmissionClient) AddExisting(ctx context.Context, addCmd *Command, location string, progress func(ExistingProgress)) (TorrentAdded, error) {
+	report := func(p ExistingProgress) {
+		if progress != nil {
+			progress(p)
+		}
+	}
+
+	report(ExistingProgress{Stage: StageAdding})
+	cmd := *addCmd
+	cmd.SetPaused(true)
+	cmd.SetDownloadDir(location)
+	added, result, err := ac.executeAdd(ctx, &cmd)
+	if err != nil {
+		return added, err
+	}
+	// the calls below need the added torrent
+	if result != "success" {
+		return added, fmt.Errorf("torrent-add: %s", result)
+	}
+	if added.HashString == "" {
+		return added, errors.New("torrent-add: the daemon returned no torrent")
+	}
+	id := added.HashString
+
+	report(ExistingProgress{Stage: StageLocating, Torrent: added})
+	if err := ac.SetLocation(ctx, id, location, false); err != nil {
+		return added, err
+	}
+
+	report(ExistingProgress{Stage: StageVerifying, Torrent: added})
+	if err := ac.torrentAction(ctx, "torrent-verify", []string{id}); err != nil {
+		return added, err
+	}
+	t, err := ac.waitVerified(ctx, id, func(verified float64) {
+		report(ExistingProgress{Stage: StageVerifying, Torrent: added, Verified: verified})
+	})
+	if err != nil {
+		return added, err
+	}
+	if !t.IsCompleted() {
+		return added, &IncompleteDataError{Torrent: added, PercentDone: t.PercentDone}
+	}
+
+	report(ExistingProgress{Stage: StageStarting, Torrent: added})
+	if err := ac.torrentAction(ctx, "torrent-start", []string{id}); err != nil {
+		return added, err
+	}
+	report(ExistingProgress{Stage: StageDone, Torrent: added, Verified: 1})
+	return added, nil
+}
+
+// waitVerified polls the torrent until it is no longer checking
+func (ac *TransmissionClient) waitVerified(ctx context.Context, id string, progress func(float64)) (*Torrent, error) {
+	ticker := time.NewTicker(VerifyPollInterval)
+	defer ticker.Stop()
+	fields := []string{"id", "hashString", "status", "recheckProgress", "percentDone", "error", "errorString"}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		t, err := ac.getTorrentFields(ctx, id, fields)
+		if err != nil {
+			return nil, err
+		}
+		if t.Status != TrChecking && t.Status != TrCheckPending {
+			return t, nil
+		}
+		progress(t.RecheckProgress)
+	}
+}
+
+// SetLocation moves the torrent's data to location, or with move false
+// makes the daemon look for it there
+func (ac *TransmissionClient) SetLocation(ctx context.Context, id string, location string, move bool) error {
+	return ac.rpc(ctx, "torrent-set-location", map[string]interface{}{
+		"ids":      []string{id},
+		"location": location,
+		"move":     move,
+	}, nil)
+}
@@ -0,0 +1,42 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestAddExistingFailedAdd checks that AddExisting stops when the daemon
+// rejects the torrent, before moving or verifying anything
+func TestAddExistingFailedAdd(t *testing.T) {
+	var methods []string
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cmd struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&cmd)
+		if cmd.Method == "" { // the session id fetch
+			io.WriteString(w, `{"result":"success","arguments":{}}`)
+			return
+		}
+		methods = append(methods, cmd.Method)
+		io.WriteString(w, `{"result":"invalid or corrupt torrent file","arguments":{}}`)
+	}))
+	defer daemon.Close()
+	c, err := New(daemon.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods = nil
+
+	_, err = c.AddExisting(context.Background(), NewAddCmdByURL("http://example.com/bad.torrent"), "/data", nil)
+	if err == nil {
+		t.Fatal("AddExisting succeeded with a rejected torrent")
+	}
+	if len(methods) != 1 || methods[0] != "torrent-add" {
+		t.Errorf("methods sent = %q, want only torrent-add", methods)
+	}
+}
@@ -0,0 +1,69 @@
+package transmission
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// IdempotencyLabelPrefix prefixes the label holding the idempotency key of
+// a torrent added WithIdempotencyKey
+const IdempotencyLabelPrefix = "addkey:"
+
+// AddOption configures a torrent-add command, see AddTorrent
+type AddOption func(*Command)
+
+// WithLabels adds labels to the torrent (Transmission 4.0+)
+func WithLabels(labels ...string) AddOption {
+	return func(cmd *Command) {
+		cmd.Arguments.Labels = append(cmd.Arguments.Labels, labels...)
+	}
+}
+
+// WithIdempotencyKey stores key in the labels of the torrent, so AddTorrent
+// retried after a network failure finds the torrent added by the earlier
+// attempt instead of adding it again (Transmission 4.0+)
+func WithIdempotencyKey(key string) AddOption {
+	return WithLabels(IdempotencyLabelPrefix + key)
+}
+
+// AddTorrent runs the torrent-add cmd with opts applied, as
+// ExecuteAddCommandContext does. When a torrent already carries the
+// idempotency key of opts, it is returned without adding anything.
+func (ac *TransmissionClient) AddTorrent(ctx context.Context, cmd *Command, opts ...AddOption) (TorrentAdded, error) {
+	c := *cmd
+	c.Arguments.Labels = slices.Clone(cmd.Arguments.Labels)
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	for _, label := range c.Arguments.Labels {
+		if !strings.HasPrefix(label, IdempotencyLabelPrefix) {
+			continue
+		}
+		t, err := ac.findByLabel(ctx, label)
+		if err != nil {
+			return TorrentAdded{}, err
+		}
+		if t != nil {
+			return TorrentAdded{HashString: t.InfoHash, ID: t.ID, Name: t.Name}, nil
+		}
+	}
+	return ac.ExecuteAddCommandContext(ctx, &c)
+}
+
+// findByLabel returns the first torrent carrying label, or nil
+func (ac *TransmissionClient) findByLabel(ctx context.Context, label string) (*Torrent, error) {
+	cmd := NewGetTorrentsCmd()
+	cmd.Arguments.Fields = []string{"id", "name", "hashString", "labels"}
+	out, err := ac.ExecuteCommandContext(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range out.Arguments.Torrents {
+		if slices.Contains(t.Labels, label) {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
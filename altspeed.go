@@ -0,0 +1,62 @@
+package transmission
+
+import (
+	"context"
+	"time"
+)
+
+// AltSpeedStatus describes the turtle mode of the session
+type AltSpeedStatus struct {
+	Enabled   bool
+	Scheduled bool      // turned on by the schedule rather than manually
+	Until     time.Time // when the daemon will turn it off, zero if it won't
+}
+
+// AltSpeedStatus returns the turtle mode status of the session, see
+// SessionSettings.AltSpeedStatus
+func (ac *TransmissionClient) AltSpeedStatus(ctx context.Context) (AltSpeedStatus, error) {
+	s, err := ac.GetSession(ctx)
+	if err != nil {
+		return AltSpeedStatus{}, err
+	}
+	return s.AltSpeedStatus(time.Now()), nil
+}
+
+// AltSpeedStatus returns the turtle mode status at now, whose location must
+// be the daemon's time zone as the schedule is in its local time. Turtle
+// mode turned on manually while the schedule is enabled lasts until the end
+// of the next scheduled window, when the daemon turns it off.
+func (s *SessionSettings) AltSpeedStatus(now time.Time) AltSpeedStatus {
+	st := AltSpeedStatus{Enabled: s.AltSpeedEnabled}
+	if !st.Enabled || !s.AltSpeedTimeEnabled || s.AltSpeedTimeBegin == s.AltSpeedTimeEnd {
+		return st
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for d := -1; d <= 7; d++ {
+		begin, end, ok := s.altSpeedWindow(today.AddDate(0, 0, d))
+		switch {
+		case !ok || !end.After(now):
+		case !begin.After(now):
+			st.Scheduled, st.Until = true, end
+			return st
+		default:
+			st.Until = end
+			return st
+		}
+	}
+	return st
+}
+
+// altSpeedWindow returns the scheduled window starting on day, if any
+func (s *SessionSettings) altSpeedWindow(day time.Time) (begin, end time.Time, ok bool) {
+	if s.AltSpeedTimeDay&(1<<day.Weekday()) == 0 {
+		return begin, end, false
+	}
+	begin = day.Add(time.Duration(s.AltSpeedTimeBegin) * time.Minute)
+	end = day.Add(time.Duration(s.AltSpeedTimeEnd) * time.Minute)
+	if s.AltSpeedTimeEnd < s.AltSpeedTimeBegin {
+		end = end.AddDate(0, 0, 1) // past midnight
+	}
+	return begin, end, true
+}
@@ -0,0 +1,155 @@
+package transmission
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// WithAnnounceRewrite passes the announce urls of the torrents added
+// through fn, e.g. to route all the announces through a private proxy; fn
+// returns the url unchanged for the trackers to leave alone.
+//
+// The urls are rewritten in the magnet links before they reach the daemon,
+// and in the .torrent files given as data with WithMetaInfoRewrite, e.g.
+// through local.WithAnnounceRewrite. The other torrents get their trackers
+// replaced right after the add.
+func WithAnnounceRewrite(fn func(announce string) string) Option {
+	return func(ac *TransmissionClient) {
+		ac.rewriteAnnounce = fn
+	}
+}
+
+// WithMetaInfoRewrite sets how WithAnnounceRewrite changes the .torrent
+// files given as data: fn returns data with its announce urls passed
+// through rewrite. The package doesn't parse .torrent files itself, see
+// metainfo.RewriteAnnounces.
+func WithMetaInfoRewrite(fn func(data []byte, rewrite func(announce string) string) ([]byte, error)) Option {
+	return func(ac *TransmissionClient) {
+		ac.rewriteMetaInfo = fn
+	}
+}
+
+// TrackerReplace gives the tracker ID of a torrent, see Torrent.Trackers,
+// a new announce url
+type TrackerReplace struct {
+	ID       int
+	Announce string
+}
+
+// TrackerReplacements are the trackerReplace argument of torrent-set,
+// sent as the flat list of id and url pairs the daemon expects
+type TrackerReplacements []TrackerReplace
+
+func (r TrackerReplacements) MarshalJSON() ([]byte, error) {
+	pairs := make([]interface{}, 0, 2*len(r))
+	for _, t := range r {
+		pairs = append(pairs, t.ID, t.Announce)
+	}
+	return json.Marshal(pairs)
+}
+
+// RewriteAnnounces replaces the announce urls of the torrents with the
+// given ids, all of them if there are none, by those returned by fn. The
+// torrents fn changes nothing of are skipped.
+func (ac *TransmissionClient) RewriteAnnounces(ctx context.Context, fn func(announce string) string, ids ...string) (*BulkResult, error) {
+	result := &BulkResult{}
+	var sets []*TorrentSetArgs
+	err := ac.ForEachTorrent(ctx, []string{"id", "hashString", "trackers"}, func(t *Torrent) error {
+		if len(ids) > 0 && !t.hasAnyID(ids) {
+			return nil
+		}
+		if r := trackerReplacements(t, fn); len(r) > 0 {
+			sets = append(sets, &TorrentSetArgs{Ids: []string{t.InfoHash}, TrackerReplace: r})
+		} else {
+			result.skip("no announce url to rewrite", t.InfoHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, args := range sets {
+		if err := ac.torrentSet(ctx, args); err != nil {
+			result.fail(err, args.Ids...)
+		} else {
+			result.succeed(args.Ids...)
+		}
+	}
+	return result, result.Err()
+}
+
+// trackerReplacements returns the trackers of t that fn changes
+func trackerReplacements(t *Torrent, fn func(string) string) TrackerReplacements {
+	var r TrackerReplacements
+	for _, tr := range t.Trackers {
+		if u := fn(tr.Announce); u != tr.Announce {
+			r = append(r, TrackerReplace{ID: tr.Id, Announce: u})
+		}
+	}
+	return r
+}
+
+// rewriteAdd rewrites the announce urls of a torrent-add command, telling
+// whether they are left to replace after the add: the daemon fetches the
+// file itself, or there is no WithMetaInfoRewrite for its data
+func (ac *TransmissionClient) rewriteAdd(cmd *Command) (replaceAfter bool, err error) {
+	if ac.rewriteAnnounce == nil {
+		return false, nil
+	}
+	if cmd.Arguments.MetaInfo != "" {
+		if ac.rewriteMetaInfo == nil {
+			return true, nil
+		}
+		data, err := base64.StdEncoding.DecodeString(cmd.Arguments.MetaInfo)
+		if err != nil {
+			return false, err
+		}
+		if data, err = ac.rewriteMetaInfo(data, ac.rewriteAnnounce); err != nil {
+			return false, err
+		}
+		cmd.Arguments.MetaInfo = base64.StdEncoding.EncodeToString(data)
+		return false, nil
+	}
+	if strings.HasPrefix(cmd.Arguments.Filename, "magnet:") {
+		cmd.Arguments.Filename = rewriteMagnet(cmd.Arguments.Filename, ac.rewriteAnnounce)
+		return false, nil
+	}
+	return cmd.Arguments.Filename != "", nil
+}
+
+// rewriteMagnet passes the tr parameters of a magnet link through fn,
+// leaving the others as they are written
+func rewriteMagnet(magnet string, fn func(string) string) string {
+	head, query, ok := strings.Cut(magnet, "?")
+	if !ok {
+		return magnet
+	}
+	params := strings.Split(query, "&")
+	for i, p := range params {
+		v, ok := strings.CutPrefix(p, "tr=")
+		if !ok {
+			continue
+		}
+		if u, err := url.QueryUnescape(v); err == nil {
+			params[i] = "tr=" + url.QueryEscape(fn(u))
+		}
+	}
+	return head + "?" + strings.Join(params, "&")
+}
+
+// replaceTrackers applies the rewrite to the trackers of a torrent just
+// added from a url
+func (ac *TransmissionClient) replaceTrackers(ctx context.Context, hash string) error {
+	t, err := ac.getTorrentFields(ctx, hash, []string{"id", "hashString", "trackers"})
+	if err != nil {
+		return err
+	}
+	r := trackerReplacements(t, ac.rewriteAnnounce)
+	if len(r) == 0 {
+		return nil
+	}
+	return ac.torrentSet(ctx, &TorrentSetArgs{Ids: []string{hash}, TrackerReplace: r})
+}
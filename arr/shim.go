@@ -0,0 +1,350 @@
+// Package arr serves the part of the Transmission RPC protocol Sonarr and
+// Radarr use as a download client, forwarding it to a daemon through this
+// package's client so policies apply in between: labels given to added
+// torrents, path translation between the daemon's and the *arr's view of
+// the storage, throttling of the daemon and hooks for custom rules.
+//
+// Point the *arr download client at the address of the Shim as if it were
+// Transmission. The methods forwarded are session-get, session-stats,
+// free-space, torrent-get, torrent-add, torrent-set, torrent-remove,
+// torrent-start, torrent-start-now, torrent-stop, torrent-verify and the
+// queue-move family; the others are refused. Like the daemon, the Shim
+// answers 409 with an X-Transmission-Session-Id header to requests without
+// that session id, so a page can't post to it from a browser.
+package arr
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// maxRequest bounds the body of a request, torrent files included
+const maxRequest = 32 << 20
+
+// Client is what a Shim needs from transmission.TransmissionClient
+type Client interface {
+	Call(ctx context.Context, method string, args, out interface{}) error
+	AddTorrent(ctx context.Context, cmd *transmission.Command, opts ...transmission.AddOption) (transmission.TorrentAdded, error)
+}
+
+// Shim is an http.Handler speaking the Transmission RPC protocol. Its zero
+// policy fields forward requests unchanged.
+type Shim struct {
+	Client Client
+
+	// Username and Password are the credentials the *arr must send; any
+	// username is accepted when Username is empty. Without a Password
+	// every request is refused, unless NoAuth is set.
+	Username string
+	Password string
+	NoAuth   bool // serve without credentials, e.g. on a socket only the *arr reaches
+
+	// Paths translates daemon paths, Remote, to the paths the *arr sees,
+	// Local; paths no rule matches are passed as is
+	Paths transmission.PathMapper
+
+	// Labels are given to every added torrent (Transmission 4.0+)
+	Labels []string
+
+	// Filter hides torrents from the *arr, e.g. those another tool
+	// manages: they are left out of torrent-get and the other methods
+	// ignore them. Id, name, hashString, labels and downloadDir are set.
+	Filter func(t *transmission.Torrent) bool
+
+	// BeforeAdd may change a torrent about to be added, or refuse it with
+	// an error the *arr reports; labels and the download dir, translated
+	// to the daemon's view, are already set
+	BeforeAdd func(ctx context.Context, cmd *transmission.Command) error
+
+	// BeforeCall sees the arguments of every other forwarded method and
+	// may change them, or refuse the call with an error
+	BeforeCall func(ctx context.Context, method string, args map[string]json.RawMessage) error
+
+	// Throttle, when set, delays requests to the daemon so the *arr
+	// polling doesn't load it, see NewThrottle
+	Throttle *Throttle
+
+	once      sync.Once
+	sessionID string // the X-Transmission-Session-Id expected
+}
+
+// request and response are the envelopes of the RPC protocol
+type request struct {
+	Method    string                     `json:"method"`
+	Arguments map[string]json.RawMessage `json:"arguments"`
+	Tag       json.RawMessage            `json:"tag,omitempty"`
+}
+
+type response struct {
+	Result    string          `json:"result"`
+	Arguments interface{}     `json:"arguments"`
+	Tag       json.RawMessage `json:"tag,omitempty"`
+}
+
+func (s *Shim) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.NoAuth {
+		user, pass, _ := r.BasicAuth()
+		if s.Password == "" ||
+			s.Username != "" && subtle.ConstantTimeCompare([]byte(user), []byte(s.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(s.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Transmission"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	s.once.Do(func() {
+		b := make([]byte, 24)
+		rand.Read(b)
+		s.sessionID = hex.EncodeToString(b)
+	})
+	if id := r.Header.Get("X-Transmission-Session-Id"); subtle.ConstantTimeCompare([]byte(id), []byte(s.sessionID)) != 1 {
+		w.Header().Set("X-Transmission-Session-Id", s.sessionID)
+		http.Error(w, "missing or stale X-Transmission-Session-Id", http.StatusConflict)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequest)).Decode(&req); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Arguments == nil {
+		req.Arguments = make(map[string]json.RawMessage)
+	}
+	res := response{Result: "success", Tag: req.Tag}
+	out, err := s.handle(r.Context(), req.Method, req.Arguments)
+	if err != nil {
+		res.Result = err.Error()
+	}
+	if out == nil {
+		out = struct{}{}
+	}
+	res.Arguments = out
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// handle applies the policies to one method call and returns the arguments
+// of the response
+func (s *Shim) handle(ctx context.Context, method string, args map[string]json.RawMessage) (interface{}, error) {
+	switch method {
+	case "torrent-add":
+		return s.add(ctx, args)
+	case "session-get", "session-stats", "free-space", "torrent-get",
+		"torrent-set", "torrent-remove", "torrent-start", "torrent-start-now", "torrent-stop", "torrent-verify",
+		"queue-move-top", "queue-move-up", "queue-move-down", "queue-move-bottom":
+	default:
+		return nil, fmt.Errorf("method %s not supported", method)
+	}
+
+	if method == "free-space" {
+		if err := s.mapArg(args, "path", s.toRemote); err != nil {
+			return nil, err
+		}
+	}
+	if method != "session-get" && method != "session-stats" && method != "free-space" && method != "torrent-get" {
+		visible, err := s.restrictIDs(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, nil
+		}
+	}
+	if method == "torrent-get" && s.Filter != nil {
+		var fields []string
+		if err := json.Unmarshal(args["fields"], &fields); err != nil {
+			return nil, fmt.Errorf("torrent-get: fields: %w", err)
+		}
+		for _, f := range filterFields {
+			if !slices.Contains(fields, f) {
+				fields = append(fields, f)
+			}
+		}
+		args["fields"], _ = json.Marshal(fields)
+	}
+	if s.BeforeCall != nil {
+		if err := s.BeforeCall(ctx, method, args); err != nil {
+			return nil, err
+		}
+	}
+
+	var out map[string]json.RawMessage
+	if err := s.call(ctx, method, args, &out); err != nil {
+		return nil, err
+	}
+	switch method {
+	case "session-get":
+		for _, key := range []string{"download-dir", "incomplete-dir"} {
+			if err := s.mapArg(out, key, s.toLocal); err != nil {
+				return nil, err
+			}
+		}
+	case "free-space":
+		if err := s.mapArg(out, "path", s.toLocal); err != nil {
+			return nil, err
+		}
+	case "torrent-get":
+		torrents, err := s.torrents(out["torrents"])
+		if err != nil {
+			return nil, err
+		}
+		out["torrents"], _ = json.Marshal(torrents)
+	}
+	return out, nil
+}
+
+// filterFields are the fields Filter may rely on
+var filterFields = []string{"id", "name", "hashString", "labels", "downloadDir"}
+
+// torrents translates the download dirs of a torrent-get response and
+// removes the torrents Filter hides
+func (s *Shim) torrents(raw json.RawMessage) ([]map[string]json.RawMessage, error) {
+	var torrents []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &torrents); err != nil {
+		return nil, fmt.Errorf("torrent-get: only the object format is supported: %w", err)
+	}
+	kept := torrents[:0]
+	for _, t := range torrents {
+		if s.Filter != nil {
+			var tt transmission.Torrent
+			b, _ := json.Marshal(t)
+			if err := json.Unmarshal(b, &tt); err != nil {
+				return nil, err
+			}
+			if !s.Filter(&tt) {
+				continue
+			}
+		}
+		if err := s.mapArg(t, "downloadDir", s.toLocal); err != nil {
+			return nil, err
+		}
+		kept = append(kept, t)
+	}
+	return kept, nil
+}
+
+// restrictIDs replaces the ids of a torrent method by those of the
+// torrents Filter shows, reporting whether there is any
+func (s *Shim) restrictIDs(ctx context.Context, args map[string]json.RawMessage) (bool, error) {
+	if s.Filter == nil {
+		return true, nil
+	}
+	get := map[string]interface{}{"fields": filterFields}
+	if ids, ok := args["ids"]; ok {
+		get["ids"] = ids
+	}
+	var out struct {
+		Torrents []*transmission.Torrent `json:"torrents"`
+	}
+	if err := s.call(ctx, "torrent-get", get, &out); err != nil {
+		return false, err
+	}
+	var ids []int
+	for _, t := range out.Torrents {
+		if s.Filter(t) {
+			ids = append(ids, t.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+	args["ids"], _ = json.Marshal(ids)
+	return true, nil
+}
+
+// addArgs are the torrent-add arguments the *arr send
+type addArgs struct {
+	Filename    string   `json:"filename"`
+	MetaInfo    string   `json:"metainfo"`
+	DownloadDir string   `json:"download-dir"`
+	Paused      *bool    `json:"paused"`
+	Labels      []string `json:"labels"`
+}
+
+func (s *Shim) add(ctx context.Context, args map[string]json.RawMessage) (interface{}, error) {
+	b, _ := json.Marshal(args)
+	var a addArgs
+	if err := json.Unmarshal(b, &a); err != nil {
+		return nil, fmt.Errorf("torrent-add: %w", err)
+	}
+	if a.Filename == "" && a.MetaInfo == "" {
+		return nil, errors.New("torrent-add: no filename or metainfo")
+	}
+	cmd := transmission.NewAddCmd()
+	cmd.Arguments.Filename = a.Filename
+	cmd.Arguments.MetaInfo = a.MetaInfo
+	if a.DownloadDir != "" {
+		cmd.SetDownloadDir(s.toRemote(a.DownloadDir))
+	}
+	if a.Paused != nil {
+		cmd.SetPaused(*a.Paused)
+	}
+	for _, l := range slices.Concat(a.Labels, s.Labels) {
+		if !slices.Contains(cmd.Arguments.Labels, l) {
+			cmd.Arguments.Labels = append(cmd.Arguments.Labels, l)
+		}
+	}
+	if s.BeforeAdd != nil {
+		if err := s.BeforeAdd(ctx, cmd); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Throttle.wait(ctx); err != nil {
+		return nil, err
+	}
+	added, err := s.Client.AddTorrent(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]transmission.TorrentAdded{"torrent-added": added}, nil
+}
+
+// call forwards a method to the daemon once the throttle allows it
+func (s *Shim) call(ctx context.Context, method string, args, out interface{}) error {
+	if err := s.Throttle.wait(ctx); err != nil {
+		return err
+	}
+	return s.Client.Call(ctx, method, args, out)
+}
+
+// mapArg translates the path held by the string key of m, if any
+func (s *Shim) mapArg(m map[string]json.RawMessage, key string, translate func(string) string) error {
+	raw, ok := m[key]
+	if !ok {
+		return nil
+	}
+	var p string
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	m[key], _ = json.Marshal(translate(p))
+	return nil
+}
+
+func (s *Shim) toLocal(p string) string {
+	if local, ok := s.Paths.ToLocal(p); ok {
+		return local
+	}
+	return p
+}
+
+func (s *Shim) toRemote(p string) string {
+	if remote, ok := s.Paths.ToRemote(p); ok {
+		return remote
+	}
+	return p
+}
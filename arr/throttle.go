@@ -0,0 +1,53 @@
+package arr
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Throttle spaces requests evenly, letting burst of them through at once
+// after a quiet period. Unlike the rate limit of transmission-proxy it
+// delays requests instead of refusing them, as the *arr mark a download
+// client failing on errors.
+type Throttle struct {
+	interval time.Duration
+	burst    time.Duration
+
+	mu   sync.Mutex
+	next time.Time // when the next request may go without a burst
+}
+
+// NewThrottle returns a Throttle allowing rate requests per second
+func NewThrottle(rate float64, burst int) *Throttle {
+	interval := time.Duration(float64(time.Second) / rate)
+	return &Throttle{interval: interval, burst: time.Duration(max(burst-1, 0)) * interval}
+}
+
+// wait blocks until a request may be sent; a nil Throttle never blocks
+func (t *Throttle) wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now.Add(-t.burst)) {
+		t.next = now.Add(-t.burst)
+	}
+	at := t.next
+	t.next = t.next.Add(t.interval)
+	t.mu.Unlock()
+
+	delay := at.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEntry describes one mutating call
+type AuditEntry struct {
+	Time      time.Time       `json:"time"`
+	Method    string          `json:"method"`
+	Ids       []string        `json:"ids,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result,omitempty"` // the daemon's result, "success" on success
+	Err       string          `json:"error,omitempty"`  // transport or decoding error
+	Reason    string          `json:"reason,omitempty"` // see WithReason
+	DryRun    bool            `json:"dryRun,omitempty"` // not sent, see WithDryRun
+}
+
+// AuditSink receives the audit entries of a client; it is called
+// synchronously after every mutating call, from the calling goroutine
+type AuditSink interface {
+	Audit(AuditEntry)
+}
+
+// AuditFunc adapts a function to an AuditSink
+type AuditFunc func(AuditEntry)
+
+func (f AuditFunc) Audit(e AuditEntry) {
+	f(e)
+}
+
+// WithAudit makes the client record every mutating call to sink
+func WithAudit(sink AuditSink) Option {
+	return func(ac *TransmissionClient) {
+		ac.audit = sink
+	}
+}
+
+type reasonKey struct{}
+
+// WithReason returns a context carrying the reason recorded in the audit
+// entries of the calls made with it
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// JSONAuditSink writes the entries as JSON lines
+type JSONAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditSink returns a sink writing one JSON object per line to w;
+// write errors are dropped
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{enc: json.NewEncoder(w)}
+}
+
+func (s *JSONAuditSink) Audit(e AuditEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(e)
+}
+
+// auditEntry returns the entry for a call of method with args
+func auditEntry(ctx context.Context, method string, args json.RawMessage) AuditEntry {
+	e := AuditEntry{Time: time.Now(), Method: method, Arguments: args}
+	e.Reason, _ = ctx.Value(reasonKey{}).(string)
+
+	var a struct {
+		Ids []json.RawMessage `json:"ids"`
+	}
+	if json.Unmarshal(args, &a) == nil {
+		for _, id := range a.Ids {
+			e.Ids = append(e.Ids, strings.Trim(string(id), `"`))
+		}
+	}
+	return e
+}
+
+// auditResult fills in the outcome of the call in e
+func (e *AuditEntry) auditResult(output []byte, err error) {
+	if err != nil {
+		e.Err = err.Error()
+		return
+	}
+	var resp struct {
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal(output, &resp); err != nil {
+		e.Err = err.Error()
+		return
+	}
+	e.Result = resp.Result
+}
@@ -0,0 +1,135 @@
+package transmission
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults of the cool-down of ApiClient after repeated rejections, see
+// SetLoginBackoff
+const (
+	DefaultLoginBackoff    = time.Second
+	DefaultMaxLoginBackoff = 5 * time.Minute
+)
+
+// TooManyAttemptsError is returned, without contacting the daemon, while
+// the client cools down after repeated rejections: 401, 403 or 429. Going
+// on would get its address banned by the daemon or by fail2ban-style
+// tooling watching its log.
+type TooManyAttemptsError struct {
+	Attempts int       // consecutive rejections
+	Until    time.Time // end of the cool-down
+	Last     error     // the last rejection, an *AuthError or a *ProtocolError
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("rejected %d times in a row, cooling down until %s: %v",
+		e.Attempts, e.Until.Format(time.TimeOnly), e.Last)
+}
+
+func (e *TooManyAttemptsError) Unwrap() error { return e.Last }
+
+// loginBackoff counts the consecutive rejections of the daemon and sets a
+// cool-down doubling with each of them from the second one, or from the
+// first one telling how long to wait with Retry-After
+type loginBackoff struct {
+	mu        sync.Mutex
+	base, max time.Duration // base is negative when disabled
+	attempts  int
+	until     time.Time
+	last      error
+}
+
+// SetLoginBackoff sets the first cool-down after repeated rejections and
+// its maximum, DefaultLoginBackoff and DefaultMaxLoginBackoff by default;
+// a base of 0 disables the cool-down
+func (ac *ApiClient) SetLoginBackoff(base, max time.Duration) {
+	b := &ac.backoff
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.base, b.max = base, max
+	if base == 0 {
+		b.base = -1
+	}
+}
+
+// WithLoginBackoff sets the cool-down after repeated rejections, see
+// ApiClient.SetLoginBackoff
+func WithLoginBackoff(base, max time.Duration) Option {
+	return func(ac *TransmissionClient) {
+		ac.apiclient.SetLoginBackoff(base, max)
+	}
+}
+
+// check returns a *TooManyAttemptsError during a cool-down
+func (b *loginBackoff) check(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.until) {
+		return &TooManyAttemptsError{Attempts: b.attempts, Until: b.until, Last: b.last}
+	}
+	return nil
+}
+
+// record counts err as a rejection, or resets the count on success; other
+// failures, e.g. of the network, leave it as it is
+func (b *loginBackoff) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.attempts, b.until, b.last = 0, time.Time{}, nil
+		return
+	}
+	var auth *AuthError
+	var proto *ProtocolError
+	var retryAfter time.Duration
+	switch {
+	case errors.As(err, &auth):
+	case errors.As(err, &proto) && proto.StatusCode == http.StatusTooManyRequests:
+		retryAfter = proto.RetryAfter
+	default:
+		return
+	}
+	b.attempts++
+	b.last = err
+	if b.base < 0 {
+		return
+	}
+	base, max := b.base, b.max
+	if base == 0 {
+		base = DefaultLoginBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxLoginBackoff
+	}
+	var wait time.Duration
+	if b.attempts >= 2 {
+		wait = base << min(b.attempts-2, 30)
+		if wait <= 0 || wait > max {
+			wait = max
+		}
+	}
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	b.until = now.Add(wait)
+}
+
+// retryAfter reads the Retry-After header, in seconds or as a date
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
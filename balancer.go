@@ -0,0 +1,167 @@
+package transmission
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"time"
+)
+
+// PriorityBalancer ranks the incomplete torrents by a score built from
+// weights and gives the best ones high bandwidth priority, the worst ones
+// low priority and, with Queue set, orders the download queue by score.
+// Feed it each poll's torrents, e.g. from a Watcher, through Balance.
+type PriorityBalancer struct {
+	client *TransmissionClient
+
+	// NewWeight is the score of a torrent added just now, decreasing to 0
+	// for those added NewWithin ago or earlier
+	NewWeight float64
+	NewWithin time.Duration
+
+	// SmallWeight is the score of the smallest torrent, decreasing to 0
+	// for the largest, by rank of sizeWhenDone
+	SmallWeight float64
+
+	// Labels add their weight to the score of the torrents carrying them,
+	// negative weights demote
+	Labels map[string]float64
+
+	// HighShare and LowShare are the fractions of the torrents given high
+	// and low priority, the rest get normal priority
+	HighShare float64
+	LowShare  float64
+
+	// Queue also moves the torrents in the download queue by score
+	Queue bool
+
+	// OnChange, if not nil, is called for each torrent whose priority
+	// changes
+	OnChange func(t *Torrent, p Priority)
+}
+
+// NewPriorityBalancer returns a balancer favouring new torrents, those of
+// the last day, and small ones equally, with a quarter of the torrents in
+// high and a quarter in low priority
+func NewPriorityBalancer(client *TransmissionClient) *PriorityBalancer {
+	return &PriorityBalancer{
+		client:      client,
+		NewWeight:   1,
+		NewWithin:   24 * time.Hour,
+		SmallWeight: 1,
+		HighShare:   0.25,
+		LowShare:    0.25,
+	}
+}
+
+// Score returns the score of t, one of torrents, at now
+func (b *PriorityBalancer) Score(t *Torrent, torrents Torrents, now time.Time) float64 {
+	incomplete := b.incomplete(torrents)
+	return b.scores(incomplete, now)[t.InfoHash]
+}
+
+// Balance scores the incomplete torrents among torrents, as fetched by
+// GetTorrents or a Watcher, and changes the priorities, and the queue
+// order with Queue set, that don't match their rank
+func (b *PriorityBalancer) Balance(ctx context.Context, torrents Torrents) error {
+	incomplete := b.incomplete(torrents)
+	if len(incomplete) == 0 {
+		return nil
+	}
+	scores := b.scores(incomplete, time.Now())
+	ranked := slices.Clone(incomplete)
+	slices.SortStableFunc(ranked, func(x, y *Torrent) int {
+		if c := cmp.Compare(scores[y.InfoHash], scores[x.InfoHash]); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.QueuePosition, y.QueuePosition)
+	})
+
+	n := len(ranked)
+	high := int(math.Round(b.HighShare * float64(n)))
+	low := min(int(math.Round(b.LowShare*float64(n))), n-high)
+	change := make(map[Priority][]*Torrent)
+	for i, t := range ranked {
+		p := PriorityNormal
+		switch {
+		case i < high:
+			p = PriorityHigh
+		case i >= n-low:
+			p = PriorityLow
+		}
+		if t.BandwidthPriority != p {
+			change[p] = append(change[p], t)
+		}
+	}
+	for _, p := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		if len(change[p]) == 0 {
+			continue
+		}
+		if err := b.client.torrentSet(ctx, &TorrentSetArgs{Ids: hashes(change[p]), BandwidthPriority: &p}); err != nil {
+			return err
+		}
+		if b.OnChange != nil {
+			for _, t := range change[p] {
+				b.OnChange(t, p)
+			}
+		}
+	}
+
+	if !b.Queue {
+		return nil
+	}
+	queued := slices.Clone(incomplete)
+	slices.SortFunc(queued, func(x, y *Torrent) int { return cmp.Compare(x.QueuePosition, y.QueuePosition) })
+	if slices.Equal(hashes(queued), hashes(ranked)) {
+		return nil
+	}
+	// moving each to the bottom in rank order leaves them in that order
+	for _, t := range ranked {
+		if err := b.client.torrentAction(ctx, "queue-move-bottom", []string{t.InfoHash}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// incomplete returns the torrents left to download, stopped ones excluded
+func (b *PriorityBalancer) incomplete(torrents Torrents) Torrents {
+	var incomplete Torrents
+	for _, t := range torrents {
+		if t.LeftUntilDone > 0 && t.Status != TrStopped {
+			incomplete = append(incomplete, t)
+		}
+	}
+	return incomplete
+}
+
+// scores returns the score of each of the incomplete torrents, by hash
+func (b *PriorityBalancer) scores(incomplete Torrents, now time.Time) map[string]float64 {
+	bySize := slices.Clone(incomplete)
+	slices.SortStableFunc(bySize, func(x, y *Torrent) int { return cmp.Compare(x.SizeWhenDone, y.SizeWhenDone) })
+
+	scores := make(map[string]float64, len(incomplete))
+	for rank, t := range bySize {
+		score := 0.0
+		if len(bySize) > 1 {
+			score += b.SmallWeight * (1 - float64(rank)/float64(len(bySize)-1))
+		}
+		if age := now.Sub(time.Unix(t.AddedDate, 0)); b.NewWithin > 0 && age < b.NewWithin {
+			score += b.NewWeight * (1 - float64(max(age, 0))/float64(b.NewWithin))
+		}
+		for _, l := range t.Labels {
+			score += b.Labels[l]
+		}
+		scores[t.InfoHash] = score
+	}
+	return scores
+}
+
+func hashes(torrents []*Torrent) []string {
+	ids := make([]string, len(torrents))
+	for i, t := range torrents {
+		ids[i] = t.InfoHash
+	}
+	return ids
+}
@@ -0,0 +1,147 @@
+// Package bencode decodes the BitTorrent serialization format into plain Go
+// values: int64, string, []interface{} and map[string]interface{}.
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// maxDepth bounds nesting, so hostile input can't exhaust the stack
+const maxDepth = 512
+
+var ErrSyntax = errors.New("bencode: syntax error")
+
+// Decode decodes data, which must hold exactly one value
+func Decode(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, d.errorf("trailing data")
+	}
+	return v, nil
+}
+
+// DecodeDictRaw decodes the top level dictionary of data without decoding
+// its values, returning the raw encoding of each one; used to hash the
+// "info" dictionary of a torrent exactly as it was encoded
+func DecodeDictRaw(data []byte) (map[string][]byte, error) {
+	d := decoder{data: data}
+	if !d.consume('d') {
+		return nil, d.errorf("not a dictionary")
+	}
+	raw := make(map[string][]byte)
+	for !d.consume('e') {
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		start := d.pos
+		if _, err := d.value(1); err != nil {
+			return nil, err
+		}
+		raw[key] = data[start:d.pos]
+	}
+	if d.pos != len(data) {
+		return nil, d.errorf("trailing data")
+	}
+	return raw, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrSyntax, d.pos, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) consume(c byte) bool {
+	if d.pos < len(d.data) && d.data[d.pos] == c {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, d.errorf("nested too deeply")
+	}
+	if d.pos >= len(d.data) {
+		return nil, d.errorf("unexpected end of data")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		d.pos++
+		return d.integer('e')
+	case c == 'l':
+		d.pos++
+		list := []interface{}{}
+		for !d.consume('e') {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case c == 'd':
+		d.pos++
+		dict := make(map[string]interface{})
+		for !d.consume('e') {
+			key, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = v
+		}
+		return dict, nil
+	case c >= '0' && c <= '9':
+		return d.string()
+	default:
+		return nil, d.errorf("unexpected %q", c)
+	}
+}
+
+// integer reads digits up to end
+func (d *decoder) integer(end byte) (int64, error) {
+	start := d.pos
+	for d.pos < len(d.data) && d.data[d.pos] != end {
+		d.pos++
+	}
+	if d.pos >= len(d.data) {
+		return 0, d.errorf("unterminated integer")
+	}
+	n, err := strconv.ParseInt(string(d.data[start:d.pos]), 10, 64)
+	if err != nil {
+		return 0, d.errorf("invalid integer %q", d.data[start:d.pos])
+	}
+	d.pos++
+	return n, nil
+}
+
+func (d *decoder) string() (string, error) {
+	if d.pos >= len(d.data) || d.data[d.pos] < '0' || d.data[d.pos] > '9' {
+		return "", d.errorf("expected string")
+	}
+	n, err := d.integer(':')
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > int64(len(d.data)-d.pos) {
+		return "", d.errorf("string length %d out of range", n)
+	}
+	s := string(d.data[d.pos : d.pos+int(n)])
+	d.pos += int(n)
+	return s, nil
+}
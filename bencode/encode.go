@@ -0,0 +1,82 @@
+package bencode
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Encode encodes v, which may be an integer, string, []byte, []string,
+// []interface{}, map[string]interface{} or a nesting of those. Dictionary
+// keys are sorted, as the format requires.
+func Encode(v interface{}) ([]byte, error) {
+	return appendValue(nil, v)
+}
+
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	var err error
+	switch v := v.(type) {
+	case int:
+		b = appendInt(b, int64(v))
+	case int64:
+		b = appendInt(b, v)
+	case bool:
+		if v {
+			b = appendInt(b, 1)
+		} else {
+			b = appendInt(b, 0)
+		}
+	case string:
+		b = appendString(b, v)
+	case []byte:
+		b = appendString(b, string(v))
+	case []string:
+		b = append(b, 'l')
+		for _, s := range v {
+			b = appendString(b, s)
+		}
+		b = append(b, 'e')
+	case []interface{}:
+		b = append(b, 'l')
+		for _, e := range v {
+			if b, err = appendValue(b, e); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, 'e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = append(b, 'd')
+		for _, k := range keys {
+			b = appendString(b, k)
+			if b, err = appendValue(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, 'e')
+	case RawMessage:
+		b = append(b, v...)
+	default:
+		return nil, fmt.Errorf("bencode: can't encode %T", v)
+	}
+	return b, nil
+}
+
+// RawMessage is an already encoded value, written as is by Encode
+type RawMessage []byte
+
+func appendInt(b []byte, n int64) []byte {
+	b = append(b, 'i')
+	b = strconv.AppendInt(b, n, 10)
+	return append(b, 'e')
+}
+
+func appendString(b []byte, s string) []byte {
+	b = strconv.AppendInt(b, int64(len(s)), 10)
+	b = append(b, ':')
+	return append(b, s...)
+}
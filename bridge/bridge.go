@@ -0,0 +1,209 @@
+// Package bridge pushes the torrents known by a transmission.Watcher to
+// WebSocket clients, so web UIs get live updates without each of them
+// polling the daemon.
+//
+// The document a client maintains is
+//
+//	{"torrents": {"<hash>": <torrent>, ...}}
+//
+// Right after connecting it receives one JSON Patch (RFC 6902) adding the
+// whole "/torrents" object, then one patch per change seen by the watcher.
+package bridge
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// clientBuffer is the number of pending patches after which a slow client
+// is disconnected
+const clientBuffer = 64
+
+// Operation is one JSON Patch operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Bridge is an http.Handler upgrading requests to WebSockets
+type Bridge struct {
+	// CheckOrigin decides whether a browser origin may connect; the
+	// default accepts requests without Origin or from the same host
+	CheckOrigin func(r *http.Request) bool
+
+	mu      sync.Mutex
+	doc     map[string]map[string]json.RawMessage // hash -> field -> value
+	clients map[chan []byte]struct{}
+}
+
+// New returns a bridge publishing the torrents of w
+func New(w *transmission.Watcher) *Bridge {
+	b := &Bridge{
+		doc:     make(map[string]map[string]json.RawMessage),
+		clients: make(map[chan []byte]struct{}),
+	}
+	for _, t := range w.Torrents() {
+		b.doc[t.InfoHash], _ = fields(t)
+	}
+	w.OnEvent(b.handle)
+	return b
+}
+
+// handle turns a watcher event into a patch for every client
+func (b *Bridge) handle(e transmission.Event) {
+	if e.Torrent == nil {
+		return // daemon events
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hash := e.Torrent.InfoHash
+	path := "/torrents/" + escape(hash)
+	var ops []Operation
+	switch e.Type {
+	case transmission.EventAdded:
+		cur, err := fields(e.Torrent)
+		if err != nil {
+			log.Printf("bridge: %v", err)
+			return
+		}
+		value, _ := json.Marshal(cur)
+		ops = append(ops, Operation{Op: "add", Path: path, Value: value})
+		b.doc[hash] = cur
+	case transmission.EventRemoved:
+		ops = append(ops, Operation{Op: "remove", Path: path})
+		delete(b.doc, hash)
+	case transmission.EventUpdated:
+		cur, err := fields(e.Torrent)
+		if err != nil {
+			log.Printf("bridge: %v", err)
+			return
+		}
+		prev := b.doc[hash]
+		for k, v := range cur {
+			if old, ok := prev[k]; !ok {
+				ops = append(ops, Operation{Op: "add", Path: path + "/" + escape(k), Value: v})
+			} else if string(old) != string(v) {
+				ops = append(ops, Operation{Op: "replace", Path: path + "/" + escape(k), Value: v})
+			}
+		}
+		for k := range prev {
+			if _, ok := cur[k]; !ok {
+				ops = append(ops, Operation{Op: "remove", Path: path + "/" + escape(k)})
+			}
+		}
+		b.doc[hash] = cur
+	}
+	if len(ops) == 0 {
+		return
+	}
+
+	msg, err := json.Marshal(ops)
+	if err != nil {
+		log.Printf("bridge: %v", err)
+		return
+	}
+	for c := range b.clients {
+		select {
+		case c <- msg:
+		default:
+			// too slow, the writer closes the connection
+			delete(b.clients, c)
+			close(c)
+		}
+	}
+}
+
+// ServeHTTP upgrades the request and streams patches until the client
+// disconnects
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	check := b.CheckOrigin
+	if check == nil {
+		check = sameOrigin
+	}
+	if !check(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	conn, err := upgrade(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	c := make(chan []byte, clientBuffer)
+	b.mu.Lock()
+	snapshot, err := json.Marshal([]Operation{{Op: "add", Path: "/torrents", Value: b.snapshot()}})
+	b.clients[c] = struct{}{}
+	b.mu.Unlock()
+	defer b.remove(c)
+	if err != nil || conn.writeFrame(opText, snapshot) != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		conn.readLoop()
+		close(done)
+	}()
+	for {
+		select {
+		case msg, ok := <-c:
+			if !ok {
+				return
+			}
+			if err := conn.writeFrame(opText, msg); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// snapshot marshals the whole torrents object; b.mu must be held
+func (b *Bridge) snapshot() json.RawMessage {
+	v, _ := json.Marshal(b.doc)
+	return v
+}
+
+func (b *Bridge) remove(c chan []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.clients[c]; ok {
+		delete(b.clients, c)
+		close(c)
+	}
+}
+
+// fields splits the JSON encoding of t in its top level fields
+func fields(t *transmission.Torrent) (map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]json.RawMessage)
+	return m, json.Unmarshal(raw, &m)
+}
+
+// escape encodes a JSON Pointer reference token
+func escape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
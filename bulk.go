@@ -0,0 +1,100 @@
+package transmission
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// BulkResult is the outcome of an operation on many torrents, which may
+// succeed for some of them only
+type BulkResult struct {
+	Succeeded []string // ids
+	Failed    []BulkFailure
+	Skipped   []BulkSkip
+
+	mu sync.Mutex // for the operations filling it concurrently
+}
+
+// BulkFailure is a torrent a bulk operation failed on
+type BulkFailure struct {
+	ID  string
+	Err error
+}
+
+// BulkSkip is a torrent a bulk operation left alone
+type BulkSkip struct {
+	ID     string
+	Reason string
+}
+
+// Total returns the number of torrents the operation considered
+func (r *BulkResult) Total() int {
+	return len(r.Succeeded) + len(r.Failed) + len(r.Skipped)
+}
+
+// Err returns a *BulkError if some torrents failed, nil otherwise
+func (r *BulkResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return &BulkError{Failed: r.Failed, Total: r.Total()}
+}
+
+func (r *BulkResult) succeed(ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Succeeded = append(r.Succeeded, ids...)
+}
+
+func (r *BulkResult) fail(err error, ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		r.Failed = append(r.Failed, BulkFailure{ID: id, Err: err})
+	}
+}
+
+func (r *BulkResult) skip(reason string, ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		r.Skipped = append(r.Skipped, BulkSkip{ID: id, Reason: reason})
+	}
+}
+
+// BulkError is returned by the bulk operations when some torrents failed;
+// the torrents of the operation not in Failed succeeded or were skipped
+type BulkError struct {
+	Failed []BulkFailure
+	Total  int
+}
+
+func (e *BulkError) Error() string {
+	var msgs []string
+	for _, err := range e.distinct() {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d of %d torrents failed: %s", len(e.Failed), e.Total, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failures, so errors.As finds their
+// typed errors
+func (e *BulkError) Unwrap() []error {
+	return e.distinct()
+}
+
+// distinct returns the errors of the failures, one per message: the
+// torrents of a chunk fail with the same error, told once. Messages are
+// compared rather than the errors, which may not be comparable.
+func (e *BulkError) distinct() []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, f := range e.Failed {
+		if msg := f.Err.Error(); !seen[msg] {
+			seen[msg] = true
+			errs = append(errs, f.Err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,31 @@
+package transmission
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// sliceError is not comparable: == on two of them panics
+type sliceError []string
+
+func (e sliceError) Error() string { return strings.Join(e, ", ") }
+
+// TestBulkErrorGroups checks that failures are told once per message, even
+// with errors that can't be compared
+func TestBulkErrorGroups(t *testing.T) {
+	r := &BulkResult{}
+	r.fail(sliceError{"chunk", "refused"}, "a", "b")
+	r.fail(sliceError{"chunk", "refused"}, "c")
+	r.fail(errors.New("timeout"), "d")
+	r.succeed("e")
+
+	err := r.Err()
+	if want := "4 of 5 torrents failed: chunk, refused; timeout"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+	var se sliceError
+	if !errors.As(err, &se) {
+		t.Error("errors.As doesn't find the sliceError")
+	}
+}
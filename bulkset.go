@@ -0,0 +1,58 @@
+package transmission
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultChunkSize is the number of torrents changed per request by
+// SetTorrents when ChunkOptions.Size is 0
+const DefaultChunkSize = 200
+
+// ChunkOptions splits a change of many torrents into several requests
+type ChunkOptions struct {
+	Size  int           // torrents per request, DefaultChunkSize if 0
+	Delay time.Duration // pause between two requests, so the daemon and its UIs keep up
+	// Progress, if set, is called after each request with the number of
+	// torrents handled so far
+	Progress func(done, total int)
+}
+
+// SetTorrents applies args to the torrents ids in chunks, e.g. to move
+// thousands of torrents to new labels without hitting the daemon's request
+// size limit or freezing it; the Ids of args are ignored. A failed chunk
+// doesn't stop the next ones: the result tells which torrents changed, and
+// the error is its Err. The torrents left when ctx is done are skipped.
+func (ac *TransmissionClient) SetTorrents(ctx context.Context, ids []string, args TorrentSetArgs, opts ChunkOptions) (*BulkResult, error) {
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	result := &BulkResult{}
+	for done := 0; done < len(ids); {
+		if done > 0 && opts.Delay > 0 {
+			timer := time.NewTimer(opts.Delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			result.skip("cancelled: "+ctx.Err().Error(), ids[done:]...)
+			break
+		}
+		chunk := ids[done:min(done+size, len(ids))]
+		args.Ids = chunk
+		if err := ac.torrentSet(ctx, &args); err != nil {
+			result.fail(err, chunk...)
+		} else {
+			result.succeed(chunk...)
+		}
+		done += len(chunk)
+		if opts.Progress != nil {
+			opts.Progress(done, len(ids))
+		}
+	}
+	return result, result.Err()
+}
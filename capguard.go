@@ -0,0 +1,259 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CapAction is what a CapGuard does once the quota is exceeded
+type CapAction int
+
+const (
+	CapStop  CapAction = iota // stop the active torrents
+	CapCrawl                  // lower the global speed limits to CapGuard.CrawlLimit
+)
+
+// DefaultCrawlLimit is the speed limit applied by CapCrawl, in KB/s
+const DefaultCrawlLimit = 5
+
+// CapEventType tells whether a CapGuard engaged or released its action
+type CapEventType int
+
+const (
+	CapEngaged CapEventType = iota
+	CapReleased
+)
+
+// CapEvent is emitted when a CapGuard acts
+type CapEvent struct {
+	Type   CapEventType
+	Action CapAction
+	Month  Transfer // transfer of the month when the guard acted
+	Quota  uint64
+	Time   time.Time
+}
+
+// CapGuard watches the monthly transfer of an Accountant and applies its
+// action once the total exceeds the quota, undoing it when the month rolls
+// over. The engaged state, with the torrents stopped or the speed limits
+// replaced, is kept in Store, so that a guard restarted mid-month restores
+// what it changed before rather than the limits it applied.
+type CapGuard struct {
+	client     *TransmissionClient
+	accountant *Accountant
+	quota      uint64
+	action     CapAction
+
+	CrawlLimit int   // KB/s, used by CapCrawl
+	Store      Store // keeps the engaged state; in memory only if nil
+
+	mu       sync.Mutex
+	loaded   bool   // the state was read from Store
+	engaged  string // month the action was applied in, empty if released
+	stopped  []string
+	limits   *speedLimits
+	handlers []func(CapEvent)
+	errors   []func(error)
+}
+
+// capState is the engaged state of a CapGuard in its Store
+type capState struct {
+	Engaged string       `json:"engaged"`
+	Stopped []string     `json:"stopped,omitempty"`
+	Limits  *speedLimits `json:"limits,omitempty"`
+}
+
+// NewCapGuard returns a guard applying action once the monthly total
+// (downloaded + uploaded) of accountant exceeds quota bytes
+func NewCapGuard(client *TransmissionClient, accountant *Accountant, quota uint64, action CapAction) *CapGuard {
+	return &CapGuard{
+		client:     client,
+		accountant: accountant,
+		quota:      quota,
+		action:     action,
+		CrawlLimit: DefaultCrawlLimit,
+	}
+}
+
+// OnEvent registers fn to be called when the guard acts
+func (g *CapGuard) OnEvent(fn func(CapEvent)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.handlers = append(g.handlers, fn)
+}
+
+// OnError registers fn to be called when a sample or a check of Run fails
+func (g *CapGuard) OnError(fn func(error)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errors = append(g.errors, fn)
+}
+
+// Engaged reports whether the action is currently applied
+func (g *CapGuard) Engaged() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.load()
+	return g.engaged != ""
+}
+
+// load reads the state saved by a previous guard, once
+func (g *CapGuard) load() error {
+	if g.loaded || g.Store == nil {
+		return nil
+	}
+	b, err := g.Store.Get("capguard", "state")
+	if errors.Is(err, ErrNotFound) {
+		g.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state capState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return err
+	}
+	g.engaged, g.stopped, g.limits = state.Engaged, state.Stopped, state.Limits
+	g.loaded = true
+	return nil
+}
+
+// save writes the state for the next guard
+func (g *CapGuard) save() error {
+	if g.Store == nil {
+		return nil
+	}
+	b, err := json.Marshal(capState{Engaged: g.engaged, Stopped: g.stopped, Limits: g.limits})
+	if err != nil {
+		return err
+	}
+	return g.Store.Set("capguard", "state", b)
+}
+
+// Check compares the transfer of the current month to the quota, engaging
+// or releasing the action as needed. The handlers are called after the
+// guard is unlocked, so they may use it.
+func (g *CapGuard) Check(ctx context.Context) error {
+	e, ok, err := g.check(ctx)
+	if !ok {
+		return err
+	}
+	g.mu.Lock()
+	handlers := g.handlers
+	g.mu.Unlock()
+	for _, fn := range handlers {
+		fn(e)
+	}
+	return err
+}
+
+// check engages or releases the action under the lock, returning the event
+// to emit, ok false if there is none. The event comes with the error of
+// saving the new state, if any.
+func (g *CapGuard) check(ctx context.Context) (e CapEvent, ok bool, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.load(); err != nil {
+		return e, false, err
+	}
+
+	now := time.Now()
+	month := g.accountant.Month(now)
+	current := now.Format("2006-01")
+
+	var event CapEventType
+	switch {
+	case g.engaged != "" && g.engaged != current:
+		if err := g.release(ctx); err != nil {
+			return e, false, err
+		}
+		event = CapReleased
+	case g.engaged == "" && month.Total() > g.quota:
+		if err := g.engage(ctx); err != nil {
+			return e, false, err
+		}
+		g.engaged = current
+		event = CapEngaged
+	default:
+		return e, false, nil
+	}
+	return CapEvent{Type: event, Action: g.action, Month: month, Quota: g.quota, Time: now}, true, g.save()
+}
+
+func (g *CapGuard) engage(ctx context.Context) error {
+	if g.action == CapCrawl {
+		limits, err := g.client.getSpeedLimits(ctx)
+		if err != nil {
+			return err
+		}
+		crawl := &speedLimits{Down: g.CrawlLimit, DownEnabled: true, Up: g.CrawlLimit, UpEnabled: true}
+		if err := g.client.setSpeedLimits(ctx, crawl); err != nil {
+			return err
+		}
+		g.limits = limits
+		return nil
+	}
+
+	torrents, err := g.client.GetTorrentsContext(ctx)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for _, t := range torrents {
+		if t.Status.IsStarted() {
+			ids = append(ids, t.InfoHash)
+		}
+	}
+	if err := g.client.torrentAction(ctx, "torrent-stop", ids); err != nil {
+		return err
+	}
+	g.stopped = ids
+	return nil
+}
+
+func (g *CapGuard) release(ctx context.Context) error {
+	if g.action == CapCrawl {
+		if err := g.client.setSpeedLimits(ctx, g.limits); err != nil {
+			return err
+		}
+		g.limits = nil
+	} else {
+		if err := g.client.torrentAction(ctx, "torrent-start", g.stopped); err != nil {
+			return err
+		}
+		g.stopped = nil
+	}
+	g.engaged = ""
+	return nil
+}
+
+// Run samples the accountant and checks the quota every interval until ctx
+// is done; failures are reported to the OnError handlers and retried at the
+// next tick
+func (g *CapGuard) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := g.accountant.Sample(ctx)
+		if err == nil {
+			err = g.Check(ctx)
+		}
+		if err != nil && ctx.Err() == nil {
+			g.mu.Lock()
+			handlers := g.errors
+			g.mu.Unlock()
+			for _, fn := range handlers {
+				fn(err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package transmission
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultChurnHalfLife is the half-life of the churn rates when
+// NewChurnTracker is given none
+const DefaultChurnHalfLife = 15 * time.Minute
+
+// PeerChurn is how fast the peers of a torrent come and go, as rates
+// decaying exponentially so recent samples weigh most
+type PeerChurn struct {
+	Hash        string
+	Name        string
+	Peers       float64 // mean number of connected peers
+	Connects    float64 // peers connecting per minute
+	Disconnects float64 // peers disconnecting per minute
+}
+
+// Lifetime returns the mean time a peer stays connected, 0 while no peer
+// was seen leaving
+func (c PeerChurn) Lifetime() time.Duration {
+	if c.Disconnects <= 0 {
+		return 0
+	}
+	return time.Duration(c.Peers / c.Disconnects * float64(time.Minute))
+}
+
+// Turnover returns the share of the peers replaced per minute. Values
+// near or above 1, peers lasting a minute or less, usually mean that
+// incoming connections are blocked by a firewall or a NAT, or that the
+// connections are cut by one.
+func (c PeerChurn) Turnover() float64 {
+	if c.Peers <= 0 {
+		return 0
+	}
+	return c.Disconnects / c.Peers
+}
+
+// ChurnTracker samples the peers of the running torrents and keeps their
+// churn, in the "churn" namespace of a Store so it survives restarts. The
+// state of every torrent is saved under one key after each sample.
+type ChurnTracker struct {
+	client   *TransmissionClient
+	halfLife time.Duration
+	store    Store
+
+	mu       sync.Mutex
+	torrents map[string]*churnState
+	errors   []func(error)
+}
+
+// churnState is what Store keeps of a torrent, in a map by hash
+type churnState struct {
+	Name        string    `json:"name"`
+	Peers       []string  `json:"peers"` // address:port connected at At
+	At          time.Time `json:"at"`    // of the last sample, zero while stopped
+	Mean        float64   `json:"mean"`
+	Connects    float64   `json:"connects"`
+	Disconnects float64   `json:"disconnects"`
+}
+
+// NewChurnTracker returns a tracker resuming from the state in store, a
+// MemoryStore if nil, with rates of the given half-life
+func NewChurnTracker(client *TransmissionClient, halfLife time.Duration, store Store) (*ChurnTracker, error) {
+	if halfLife <= 0 {
+		halfLife = DefaultChurnHalfLife
+	}
+	if store == nil {
+		store = &MemoryStore{}
+	}
+	c := &ChurnTracker{client: client, halfLife: halfLife, store: store, torrents: make(map[string]*churnState)}
+	b, err := store.Get("churn", "state")
+	if errors.Is(err, ErrNotFound) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.torrents); err != nil {
+		return nil, err
+	}
+	if c.torrents == nil {
+		c.torrents = make(map[string]*churnState)
+	}
+	return c, nil
+}
+
+// OnError registers fn to be called when a sample of Run fails
+func (c *ChurnTracker) OnError(fn func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors = append(c.errors, fn)
+}
+
+// churnFields are the torrent fields a sample reads
+var churnFields = []string{"hashString", "name", "status", "peers"}
+
+// Sample reads the peers of the torrents, updates their churn and saves
+// it; the state of the torrents removed is dropped
+func (c *ChurnTracker) Sample(ctx context.Context) error {
+	now := time.Now()
+	seen := make(map[string]bool)
+	err := c.client.ForEachTorrent(ctx, churnFields, func(t *Torrent) error {
+		seen[t.InfoHash] = true
+		c.observe(t, now)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for hash := range c.torrents {
+		if !seen[hash] {
+			delete(c.torrents, hash)
+		}
+	}
+	b, err := json.Marshal(c.torrents)
+	if err != nil {
+		return err
+	}
+	return c.store.Set("churn", "state", b)
+}
+
+// observe counts the peers of t that connected and disconnected since the
+// last sample. Stopping a torrent drops its peers without counting them,
+// and the next sample after it starts again only takes the peers in.
+func (c *ChurnTracker) observe(t *Torrent, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.torrents[t.InfoHash]
+	if !ok {
+		s = &churnState{}
+		c.torrents[t.InfoHash] = s
+	}
+	s.Name = t.Name
+	if !t.Status.IsStarted() {
+		s.Peers, s.At = nil, time.Time{}
+		return
+	}
+
+	peers := make([]string, 0, len(t.Peers))
+	for _, p := range t.Peers {
+		peers = append(peers, p.Address+":"+strconv.Itoa(p.Port))
+	}
+	slices.Sort(peers)
+	switch {
+	case !ok:
+		s.Mean = float64(len(peers))
+	case !s.At.IsZero() && now.After(s.At):
+		connects, disconnects := diffSorted(s.Peers, peers)
+		dt := now.Sub(s.At)
+		w := math.Exp(-math.Ln2 * float64(dt) / float64(c.halfLife)) // weight of the past
+		s.Connects = w*s.Connects + (1-w)*float64(connects)/dt.Minutes()
+		s.Disconnects = w*s.Disconnects + (1-w)*float64(disconnects)/dt.Minutes()
+		s.Mean = w*s.Mean + (1-w)*float64(len(peers))
+	}
+	s.Peers, s.At = peers, now
+}
+
+// diffSorted counts the elements only in b, added, and only in a, removed
+func diffSorted(a, b []string) (added, removed int) {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || i < len(a) && a[i] < b[j]:
+			removed++
+			i++
+		case i == len(a) || b[j] < a[i]:
+			added++
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
+
+// Churn returns the churn of the torrents, the highest Turnover first
+func (c *ChurnTracker) Churn() []PeerChurn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	churn := make([]PeerChurn, 0, len(c.torrents))
+	for hash, s := range c.torrents {
+		churn = append(churn, PeerChurn{
+			Hash:        hash,
+			Name:        s.Name,
+			Peers:       s.Mean,
+			Connects:    s.Connects,
+			Disconnects: s.Disconnects,
+		})
+	}
+	slices.SortFunc(churn, func(a, b PeerChurn) int {
+		if c := cmp.Compare(b.Turnover(), a.Turnover()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Hash, b.Hash)
+	})
+	return churn
+}
+
+// Run samples the peers every interval until ctx is done; failures are
+// reported to the OnError handlers and retried at the next tick
+func (c *ChurnTracker) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Sample(ctx); err != nil && ctx.Err() == nil {
+			c.mu.Lock()
+			handlers := c.errors
+			c.mu.Unlock()
+			for _, fn := range handlers {
+				fn(err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
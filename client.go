@@ -1,22 +1,39 @@
 package transmission
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
 	RequestTimeout = 10 * time.Second
+
+	// DefaultMaxResponseSize bounds the responses read by clients that
+	// don't set their own limit, see SetMaxResponseSize
+	DefaultMaxResponseSize int64 = 256 << 20
 )
 
 type ApiClient struct {
 	url      string
 	username string
 	password string
-	token    string
 	client   http.Client
+
+	maxResponse int64 // 0 for DefaultMaxResponseSize, negative for no limit
+
+	mu       sync.Mutex
+	token    string
+	coalesce map[string]bool // RPC methods whose concurrent calls are merged
+	flights  flightGroup
+	backoff  loginBackoff
+	signer   RequestSigner
 }
 
 func NewClient(url, username, password string) *ApiClient {
@@ -30,62 +47,282 @@ func (ac *ApiClient) CreateClient(apiToken string) {
 }
 
 func (ac *ApiClient) Post(body string) ([]byte, error) {
-	authRequest, err := ac.authRequest("POST", body)
+	return ac.PostContext(context.Background(), body)
+}
+
+// PostContext is like Post but binds the requests to ctx. If coalescing is
+// enabled for the RPC method of body, concurrent calls with the same body
+// share one request: each caller waits until its own ctx is done, and the
+// request is cancelled once no caller waits for it.
+func (ac *ApiClient) PostContext(ctx context.Context, body string) ([]byte, error) {
+	if ac.coalesced(body) {
+		return ac.flights.do(ctx, body, func(ctx context.Context) ([]byte, error) {
+			return ac.post(ctx, body)
+		})
+	}
+	return ac.post(ctx, body)
+}
+
+// SetCoalescing enables or disables merging of concurrent identical calls
+// of an RPC method, e.g. "torrent-get"
+func (ac *ApiClient) SetCoalescing(method string, enabled bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if ac.coalesce == nil {
+		ac.coalesce = make(map[string]bool)
+	}
+	ac.coalesce[method] = enabled
+}
+
+// SetMaxResponseSize bounds the size of the response bodies read, so a
+// misbehaving proxy or a huge response can't exhaust the memory; reading
+// more fails with a *ResponseTooLargeError. A negative n removes the limit.
+func (ac *ApiClient) SetMaxResponseSize(n int64) {
+	ac.maxResponse = n
+}
+
+// coalesced reports whether coalescing is enabled for the method of body
+func (ac *ApiClient) coalesced(body string) bool {
+	ac.mu.Lock()
+	enabled := len(ac.coalesce) > 0
+	ac.mu.Unlock()
+	if !enabled {
+		return false
+	}
+
+	var req struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal([]byte(body), &req) != nil {
+		return false
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.coalesce[req.Method]
+}
+
+func (ac *ApiClient) post(ctx context.Context, body string) ([]byte, error) {
+	res, err := ac.do(ctx, body)
 	if err != nil {
 		return make([]byte, 0), err
 	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	var tooLarge *ResponseTooLargeError
+	if errors.As(err, &tooLarge) {
+		return make([]byte, 0), err
+	}
+	if err != nil {
+		return make([]byte, 0), requestError(ctx, ac.url, err)
+	}
+	if !json.Valid(resBody) {
+		return make([]byte, 0), &ProtocolError{StatusCode: res.StatusCode, Message: "response is not JSON"}
+	}
+	return resBody, nil
+}
+
+// PostStream is like PostContext but returns the response body unread, for
+// the caller to decode incrementally and close; it is never coalesced
+func (ac *ApiClient) PostStream(ctx context.Context, body string) (io.ReadCloser, error) {
+	res, err := ac.do(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// do sends body, fetching a new session id and retrying once on 409. The
+// errors are AuthError, ConnectionError, TimeoutError, ProtocolError, or
+// TooManyAttemptsError after repeated rejections, unless ctx is cancelled.
+func (ac *ApiClient) do(ctx context.Context, body string) (*http.Response, error) {
+	if err := ac.backoff.check(time.Now()); err != nil {
+		return nil, err
+	}
+	res, err := ac.send(ctx, body)
+	ac.backoff.record(err, time.Now())
+	return res, err
+}
+
+func (ac *ApiClient) send(ctx context.Context, body string) (*http.Response, error) {
+	authRequest, err := ac.authRequest(ctx, "POST", body)
+	if err != nil {
+		return nil, err
+	}
 	res, err := ac.client.Do(authRequest)
 	if err != nil {
-		return make([]byte, 0), err
+		return nil, requestError(ctx, ac.url, err)
 	}
-	defer res.Body.Close()
-	if res.StatusCode == 409 {
-		ac.getToken()
-		authRequest, err = ac.authRequest("POST", body)
+	if res.StatusCode == http.StatusConflict {
+		res.Body.Close()
+		if err := ac.getToken(ctx); err != nil {
+			return nil, err
+		}
+		authRequest, err = ac.authRequest(ctx, "POST", body)
 		if err != nil {
-			return make([]byte, 0), err
+			return nil, err
 		}
 		res, err = ac.client.Do(authRequest)
 		if err != nil {
-			return make([]byte, 0), err
+			return nil, requestError(ctx, ac.url, err)
 		}
 	}
-	resBody, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return make([]byte, 0), err
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return nil, statusError(res, b)
 	}
-	return resBody, nil
+	limit := ac.maxResponse
+	if limit == 0 {
+		limit = DefaultMaxResponseSize
+	}
+	if limit > 0 {
+		res.Body = &limitedBody{ReadCloser: res.Body, limit: limit, left: limit}
+	}
+	return res, nil
+}
+
+// limitedBody fails with a *ResponseTooLargeError once more than limit
+// bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	left  int64
 }
 
-func (ac *ApiClient) getToken() error {
-	req, err := http.NewRequest("POST", ac.url, strings.NewReader(""))
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left < 0 {
+		return 0, &ResponseTooLargeError{Limit: b.limit}
+	}
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1] // one byte more tells the body goes on
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n + int(b.left), &ResponseTooLargeError{Limit: b.limit}
+	}
+	return n, err
+}
+
+func (ac *ApiClient) getToken(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", ac.url, strings.NewReader(""))
 	if err != nil {
 		return err
 	}
 
 	req.SetBasicAuth(ac.username, ac.password)
+	if err := ac.sign(req, ""); err != nil {
+		return err
+	}
 	res, err := ac.client.Do(req)
 	if err != nil {
-		return err
+		return requestError(ctx, ac.url, err)
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusConflict && res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return statusError(res, b)
+	}
+	ac.mu.Lock()
 	ac.token = res.Header.Get("X-Transmission-Session-Id")
+	ac.mu.Unlock()
 	return nil
 }
 
-func (ac *ApiClient) authRequest(method string, body string) (*http.Request, error) {
-	if ac.token == "" {
-		err := ac.getToken()
+func (ac *ApiClient) authRequest(ctx context.Context, method string, body string) (*http.Request, error) {
+	ac.mu.Lock()
+	token := ac.token
+	ac.mu.Unlock()
+	if token == "" {
+		err := ac.getToken(ctx)
 		if err != nil {
 			return &http.Request{}, err
 		}
+		ac.mu.Lock()
+		token = ac.token
+		ac.mu.Unlock()
 	}
-	req, err := http.NewRequest(method, ac.url, strings.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, method, ac.url, strings.NewReader(body))
 	if err != nil {
 		return &http.Request{}, err
 	}
-	req.Header.Add("X-Transmission-Session-Id", ac.token)
+	req.Header.Add("X-Transmission-Session-Id", token)
 
 	req.SetBasicAuth(ac.username, ac.password)
+	if err := ac.sign(req, body); err != nil {
+		return &http.Request{}, err
+	}
 	return req, nil
 }
+
+// ResetSession forgets the session id, so the next request fetches a new one
+func (ac *ApiClient) ResetSession() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.token = ""
+}
+
+// flightGroup merges concurrent calls sharing a key into one
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int // guarded by flightGroup.mu
+
+	res []byte
+	err error
+}
+
+// do runs fn once for all the callers waiting on key at the same time, on
+// a context none of them can cancel alone, see torrentFlights.do
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	f, ok := g.calls[key]
+	if ok {
+		f.waiters++
+	} else {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		if g.calls == nil {
+			g.calls = make(map[string]*flight)
+		}
+		g.calls[key] = f
+		go func() {
+			f.res, f.err = fn(fctx)
+			g.forget(key, f)
+			cancel()
+			close(f.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.res, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			g.forgetLocked(key, f) // later callers start a new request
+		}
+		g.mu.Unlock()
+		return make([]byte, 0), ctx.Err()
+	}
+}
+
+func (g *flightGroup) forget(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.forgetLocked(key, f)
+}
+
+func (g *flightGroup) forgetLocked(key string, f *flight) {
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+}
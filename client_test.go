@@ -0,0 +1,55 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+// TestFlightGroupCancel checks that the first caller of a shared call
+// giving up neither cancels it nor fails the other callers
+func TestFlightGroupCancel(t *testing.T) {
+	var g flightGroup
+	started, release := make(chan struct{}), make(chan struct{})
+	fn := func(ctx context.Context) ([]byte, error) {
+		close(started)
+		select {
+		case <-release:
+			return []byte("ok"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := g.do(first, "key", fn)
+		firstErr <- err
+	}()
+	<-started
+	second := make(chan []byte)
+	go func() {
+		res, _ := g.do(context.Background(), "key", fn)
+		second <- res
+	}()
+	for { // wait for the second caller to join
+		g.mu.Lock()
+		waiters := g.calls["key"].waiters
+		g.mu.Unlock()
+		if waiters == 2 {
+			break
+		}
+		runtime.Gosched()
+	}
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller err = %v, want context.Canceled", err)
+	}
+	close(release)
+	if res := <-second; string(res) != "ok" {
+		t.Errorf("second caller got %q, want ok", res)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+type app struct {
+	client *transmission.TransmissionClient
+	in     *bufio.Reader
+	out    io.Writer
+	output string // table, json or yaml
+
+	interactive bool    // stdin is a terminal, ambiguous names are asked about
+	fan         *fanOut // set when running on every server
+	server      *server // the daemon, when running on one
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(a *app, ctx context.Context, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"list", "[-view name]  list torrents", (*app).list},
+		{"info", "<id>  details of one torrent", (*app).info},
+		{"add", "<url|magnet|file>...  add torrents", (*app).add},
+		{"start", "<id>...  start torrents", torrentAction("torrent-start")},
+		{"stop", "<id>...  stop torrents", torrentAction("torrent-stop")},
+		{"verify", "<id>...  verify torrents", torrentAction("torrent-verify")},
+		{"remove", "[-data] <id>...  remove torrents, with their data with -data", (*app).remove},
+		{"stats", "session statistics", (*app).stats},
+		{"metrics", "[-push url] [-job name]  print or push Prometheus metrics", (*app).metrics},
+		{"logs", "[-journal unit | -file path] [-ssh host]  follow RPC and daemon errors", (*app).logs},
+		{"login", "[-delete]  store the password in the system keyring", (*app).login},
+		{"shell", "interactive mode", (*app).shell},
+		{"completion", "bash|zsh|fish  print a shell completion script", (*app).completion},
+	}
+}
+
+func lookup(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// exec runs one command line, cancelled by an interrupt; a -output flag
+// only applies to it
+func (a *app) exec(ctx context.Context, args []string) error {
+	defer func(output string) { a.output = output }(a.output)
+	c, ok := lookup(args[0])
+	if !ok {
+		return usagef("unknown command %q", args[0])
+	}
+	if c.name != "shell" {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+	}
+	return c.run(a, ctx, args[1:])
+}
+
+func (a *app) list(ctx context.Context, args []string) error {
+	fs := a.flagSet("list")
+	view := fs.String("view", "", "only the torrents of a view of the configuration file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var torrents transmission.Torrents
+	var err error
+	if *view != "" {
+		torrents, err = a.client.RunView(ctx, *view)
+		if errors.Is(err, transmission.ErrNoView) {
+			return usagef("unknown view %q", *view)
+		}
+	} else {
+		torrents, err = a.client.GetTorrentsContext(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.print(torrentViews(torrents), func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tDONE\tSIZE\tSTATUS\tRATIO\tNAME")
+		for _, t := range torrents {
+			fmt.Fprintf(w, "%d\t%.0f%%\t%s\t%s\t%s\t%s\n", t.ID, t.GetPercent(), formatBytes(t.SizeWhenDone), t.Status, t.Ratio(), t.Name)
+		}
+	})
+}
+
+func (a *app) info(ctx context.Context, args []string) error {
+	fs := a.flagSet("info")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	torrents, err := a.resolve(ctx, fs.Args())
+	if err != nil {
+		return err
+	}
+	return a.print(torrentViews(torrents), func(w io.Writer) {
+		for i, t := range torrents {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "Name:\t%s\n", t.Name)
+			fmt.Fprintf(w, "ID:\t%d\n", t.ID)
+			fmt.Fprintf(w, "Hash:\t%s\n", t.InfoHash)
+			fmt.Fprintf(w, "Status:\t%s\n", t.Status)
+			fmt.Fprintf(w, "Done:\t%.1f%% of %s\n", t.GetPercent(), formatBytes(t.SizeWhenDone))
+			fmt.Fprintf(w, "Ratio:\t%s\n", t.Ratio())
+			fmt.Fprintf(w, "Rates:\t%s/s down, %s/s up\n", formatBytes(t.DownloadRate()), formatBytes(t.UploadRate()))
+			if left, ok := t.TimeLeft(); ok {
+				fmt.Fprintf(w, "ETA:\t%s\n", left)
+			}
+			fmt.Fprintf(w, "Directory:\t%s\n", t.DownloadDir)
+			fmt.Fprintf(w, "Added:\t%s\n", time.Unix(t.AddedDate, 0).Format(time.DateTime))
+			if t.ErrorString != "" {
+				fmt.Fprintf(w, "Error:\t%s\n", t.ErrorString)
+			}
+		}
+	})
+}
+
+// addedView is the stable representation of an added torrent
+type addedView struct {
+	ID   int    `json:"id"`
+	Hash string `json:"hash"`
+	Name string `json:"name"`
+}
+
+func (a *app) add(ctx context.Context, args []string) error {
+	fs := a.flagSet("add")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("nothing to add")
+	}
+	var added []addedView
+	for _, arg := range fs.Args() {
+		cmd := transmission.NewAddCmdByURL(arg)
+		if _, err := os.Stat(arg); err == nil {
+			if cmd, err = transmission.NewAddCmdByFile(arg); err != nil {
+				return err
+			}
+		}
+		t, err := a.client.ExecuteAddCommandContext(ctx, cmd)
+		if err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+		added = append(added, addedView{ID: t.ID, Hash: t.HashString, Name: t.Name})
+	}
+	return a.print(added, func(w io.Writer) {
+		for _, t := range added {
+			fmt.Fprintf(w, "added\t%d\t%s\n", t.ID, t.Name)
+		}
+	})
+}
+
+// printAffected prints the torrents a command acted on
+func (a *app) printAffected(verb string, torrents transmission.Torrents) error {
+	views := make([]addedView, len(torrents))
+	for i, t := range torrents {
+		views[i] = addedView{ID: t.ID, Hash: t.InfoHash, Name: t.Name}
+	}
+	return a.print(views, func(w io.Writer) {
+		for _, t := range views {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", verb, t.ID, t.Name)
+		}
+	})
+}
+
+// torrentAction returns a command sending method for the designated torrents
+func torrentAction(method string) func(a *app, ctx context.Context, args []string) error {
+	return func(a *app, ctx context.Context, args []string) error {
+		fs := a.flagSet(strings.TrimPrefix(method, "torrent-"))
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		torrents, err := a.resolve(ctx, fs.Args())
+		if err != nil {
+			return err
+		}
+		cmd := &transmission.Command{Method: method}
+		cmd.Arguments.Ids = torrents.GetIDs()
+		out, err := a.client.ExecuteCommandContext(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		if out.Result != "success" {
+			return fmt.Errorf("%s: %s", method, out.Result)
+		}
+		return a.printAffected(strings.TrimPrefix(method, "torrent-"), torrents)
+	}
+}
+
+func (a *app) remove(ctx context.Context, args []string) error {
+	fs := a.flagSet("remove")
+	data := fs.Bool("data", false, "delete the downloaded data too")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	torrents, err := a.resolve(ctx, fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, t := range torrents {
+		if _, err := a.client.DeleteTorrent(t.InfoHash, *data); err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+	}
+	return a.printAffected("removed", torrents)
+}
+
+// statsView is the stable representation of the session statistics
+type statsView struct {
+	Torrents       int    `json:"torrents"`
+	Active         int    `json:"active"`
+	Paused         int    `json:"paused"`
+	RateDownload   uint64 `json:"rateDownload"`
+	RateUpload     uint64 `json:"rateUpload"`
+	SessionDown    uint64 `json:"sessionDownloaded"`
+	SessionUp      uint64 `json:"sessionUploaded"`
+	CumulativeDown uint64 `json:"totalDownloaded"`
+	CumulativeUp   uint64 `json:"totalUploaded"`
+}
+
+func (a *app) stats(ctx context.Context, args []string) error {
+	fs := a.flagSet("stats")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := a.client.GetStatsContext(ctx)
+	if err != nil {
+		return err
+	}
+	v := statsView{
+		Torrents:       s.TorrentCount,
+		Active:         s.ActiveTorrentCount,
+		Paused:         s.PausedTorrentCount,
+		RateDownload:   s.DownloadSpeed,
+		RateUpload:     s.UploadSpeed,
+		SessionDown:    s.CurrentStats.DownloadedBytes,
+		SessionUp:      s.CurrentStats.UploadedBytes,
+		CumulativeDown: s.CumulativeStats.DownloadedBytes,
+		CumulativeUp:   s.CumulativeStats.UploadedBytes,
+	}
+	return a.print(v, func(w io.Writer) {
+		fmt.Fprintf(w, "Torrents:\t%d (%d active, %d paused)\n", v.Torrents, v.Active, v.Paused)
+		fmt.Fprintf(w, "Rates:\t%s/s down, %s/s up\n", formatBytes(v.RateDownload), formatBytes(v.RateUpload))
+		fmt.Fprintf(w, "Session:\t%s down, %s up\n", formatBytes(v.SessionDown), formatBytes(v.SessionUp))
+		fmt.Fprintf(w, "Total:\t%s down, %s up\n", formatBytes(v.CumulativeDown), formatBytes(v.CumulativeUp))
+	})
+}
+
+// formatBytes formats n with binary units, e.g. "1.4 GiB"
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// completeCommand is the hidden command the completion scripts call with
+// the words of the command line, the one being completed last
+const completeCommand = "__complete"
+
+func (a *app) completion(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usagef("usage: completion bash|zsh|fish")
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		return usagef("no completion for %q, want bash, zsh or fish", args[0])
+	}
+	_, err := io.WriteString(a.out, script)
+	return err
+}
+
+// completeWords prints the candidates for the last of words, which are the
+// command and its arguments after the global flags, one per line
+func (a *app) completeWords(ctx context.Context, words []string) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	c := &completer{app: a, ctx: ctx}
+	for _, w := range c.candidates(words[:len(words)-1], words[len(words)-1], false) {
+		fmt.Fprintln(a.out, w)
+	}
+}
+
+// splitComplete separates the global flags from the words of a
+// __complete call, as the flag package would
+func splitComplete(args []string) (flags, words []string) {
+	for i := 0; i < len(args)-1; i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			return args[:i], args[i:]
+		}
+		if !strings.Contains(args[i], "=") && i+1 < len(args)-1 {
+			i++ // flag value
+		}
+		flags = args[:i+1]
+	}
+	return flags, args[len(flags):]
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for transmission-ctl
+_transmission_ctl() {
+	local IFS=$'\n'
+	COMPREPLY=($(transmission-ctl ` + completeCommand + ` "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+	COMPREPLY=("${COMPREPLY[@]// /\\ }")
+}
+complete -F _transmission_ctl transmission-ctl
+`,
+	"zsh": `#compdef transmission-ctl
+# zsh completion for transmission-ctl
+_transmission_ctl() {
+	local -a candidates
+	candidates=("${(@f)$(transmission-ctl ` + completeCommand + ` "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	compadd -a candidates
+}
+compdef _transmission_ctl transmission-ctl
+`,
+	"fish": `# fish completion for transmission-ctl
+complete -c transmission-ctl -f -a '(transmission-ctl ` + completeCommand + ` (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`,
+}
+
+// isCompletion reports whether the process was started by a completion
+// script
+func isCompletion() bool {
+	return len(os.Args) > 1 && os.Args[1] == completeCommand
+}
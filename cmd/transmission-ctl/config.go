@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/internal/yaml"
+)
+
+// allServers is the pseudo-server running a command on every configured
+// server
+const allServers = "all"
+
+// config is the content of the configuration file:
+//
+//	default: home
+//	servers:
+//	  home:
+//	    rpc: http://nas:9091/transmission/rpc
+//	    user: admin
+//	    password-env: NAS_PASSWORD
+//	    download-dir: /data/incoming
+//	  seedbox:
+//	    rpc: https://seedbox.example.com/transmission/rpc
+//	    user: me
+//	    password: secret
+//	    output: json
+//	views:
+//	  stuck:
+//	    any:
+//	      - errored: true
+//	      - stalled: true
+//	        inactiveFor: 24h
+//
+// The views are transmission.Query values named for list -view, the same for
+// every server.
+type config struct {
+	Default string                         `json:"default"` // server used without -server, the only one if unset
+	Servers map[string]serverConfig        `json:"servers"`
+	Views   map[string]*transmission.Query `json:"views"`
+}
+
+// serverConfig is a daemon and the defaults used with it; the command line
+// flags override them
+type serverConfig struct {
+	RPC         string `json:"rpc"`
+	User        string `json:"user"`
+	Password    string `json:"password"`
+	PasswordEnv string `json:"password-env"` // environment variable holding the password
+	Output      string `json:"output"`
+	DownloadDir string `json:"download-dir"` // for added torrents, see transmission.WithDefaultDownloadDir
+}
+
+// server is a daemon selected on the command line
+type server struct {
+	name string // "" without a configuration file
+	serverConfig
+	views map[string]*transmission.Query
+}
+
+// configPath returns the default configuration file,
+// $XDG_CONFIG_HOME/transmission-ctl.yaml or ~/.config/transmission-ctl.yaml
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "transmission-ctl.yaml")
+}
+
+// loadConfig reads the configuration file at path; a missing file is an
+// empty configuration unless required
+func loadConfig(path string, required bool) (*config, error) {
+	c := &config{}
+	if path == "" {
+		return c, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, s := range c.Servers {
+		if name == allServers {
+			return nil, fmt.Errorf("%s: %q is reserved for every server", path, allServers)
+		}
+		if s.RPC == "" {
+			return nil, fmt.Errorf("%s: server %s has no rpc url", path, name)
+		}
+	}
+	if c.Default != "" {
+		if _, ok := c.Servers[c.Default]; !ok {
+			return nil, fmt.Errorf("%s: default server %s is not defined", path, c.Default)
+		}
+	}
+	return c, nil
+}
+
+// servers returns the servers designated by name, the default one when name
+// is empty; without servers in the configuration the result is one unnamed
+// server the flags describe
+func (c *config) servers(name string) ([]server, error) {
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" && len(c.Servers) == 1 {
+		for n := range c.Servers {
+			name = n
+		}
+	}
+	switch {
+	case name == allServers:
+		if len(c.Servers) == 0 {
+			return nil, usagef("no servers configured")
+		}
+		var names []string
+		for n := range c.Servers {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		servers := make([]server, len(names))
+		for i, n := range names {
+			servers[i] = server{n, c.Servers[n], c.Views}
+		}
+		return servers, nil
+	case name != "":
+		s, ok := c.Servers[name]
+		if !ok {
+			return nil, usagef("unknown server %q", name)
+		}
+		return []server{{name, s, c.Views}}, nil
+	case len(c.Servers) > 0:
+		return nil, usagef("several servers configured and no default, use -server")
+	default:
+		return []server{{views: c.Views}}, nil
+	}
+}
+
+// connect returns a client for the server
+func (s server) connect() (*transmission.TransmissionClient, error) {
+	opts := []transmission.Option{transmission.WithViews(s.views)}
+	if s.DownloadDir != "" {
+		opts = append(opts, transmission.WithDefaultDownloadDir(s.DownloadDir))
+	}
+	return transmission.New(s.RPC, s.User, s.Password, opts...)
+}
+
+// fanOut collects the result of a command run on one of several servers
+type fanOut struct {
+	server string      // the server the command runs on
+	output string      // json or yaml once a result is collected
+	result interface{} // the json or yaml result
+}
+
+// runAll runs a command line on every server at once. Tables are printed
+// under the name of each server, in the order of the names, json and yaml
+// results are gathered in one object keyed by server name; the format is
+// the -output flag's, not the servers'. A failing server doesn't stop the
+// others. Ambiguous torrent names fail rather than being asked about.
+func runAll(ctx context.Context, base *app, servers []server, args []string) error {
+	// the output of each server is held until they all finish, which the
+	// commands running until interrupted never do
+	if c, ok := lookup(args[0]); ok && (c.name == "shell" || c.name == "login" || c.name == "logs") {
+		return usagef("%s runs on one server", c.name)
+	}
+	fans := make([]fanOut, len(servers))
+	outs := make([]bytes.Buffer, len(servers))
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		fans[i].server = s.name
+		client, err := s.connect()
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		a := *base
+		a.client, a.fan, a.out, a.interactive = client, &fans[i], &outs[i], false
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = a.exec(ctx, args)
+		}(i)
+	}
+	wg.Wait()
+
+	results := make(map[string]interface{})
+	output := ""
+	for i, s := range servers {
+		if _, err := base.out.Write(outs[i].Bytes()); err != nil {
+			return err
+		}
+		if fans[i].output != "" {
+			output = fans[i].output
+			results[s.name] = fans[i].result
+		}
+		var ue usageError
+		if errors.As(errs[i], &ue) {
+			return errs[i] // the same for every server
+		}
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("%s: %w", s.name, errs[i])
+		}
+	}
+	if output != "" {
+		out := *base
+		out.output = output
+		if err := out.print(results, nil); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
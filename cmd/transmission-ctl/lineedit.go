@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// lineEditor reads lines from a terminal with editing, history and tab
+// completion; when the input is not a terminal it reads plain lines
+type lineEditor struct {
+	in       *os.File
+	out      io.Writer
+	r        *bufio.Reader
+	complete func(line string) []string
+	history  []string
+}
+
+// newLineEditor returns an editor for the terminal in, read through r
+func newLineEditor(in *os.File, r *bufio.Reader, out io.Writer, complete func(string) []string) *lineEditor {
+	return &lineEditor{in: in, out: out, r: r, complete: complete}
+}
+
+// readLine prompts and reads a line, returning io.EOF on ^D
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(int(e.in.Fd()))
+	if err != nil {
+		fmt.Fprint(e.out, prompt)
+		line, err := e.r.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+
+	var buf []rune
+	pos := 0
+	hist := len(e.history)
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	set := func(s string) {
+		buf = []rune(s)
+		pos = len(buf)
+	}
+
+	redraw()
+	for {
+		r, _, err := e.r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case 3: // ^C drops the line
+			fmt.Fprint(e.out, "^C\r\n")
+			buf, pos = buf[:0], 0
+		case 4: // ^D quits on an empty line, deletes otherwise
+			if len(buf) == 0 {
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // ^A
+			pos = 0
+		case 5: // ^E
+			pos = len(buf)
+		case 21: // ^U
+			buf, pos = buf[pos:], 0
+		case '\t':
+			e.completeAt(&buf, &pos, prompt)
+		case 27: // escape sequences: arrows
+			if b, _ := e.r.ReadByte(); b != '[' {
+				continue
+			}
+			switch b, _ := e.r.ReadByte(); b {
+			case 'A':
+				if hist > 0 {
+					hist--
+					set(e.history[hist])
+				}
+			case 'B':
+				if hist < len(e.history)-1 {
+					hist++
+					set(e.history[hist])
+				} else {
+					hist = len(e.history)
+					set("")
+				}
+			case 'C':
+				pos = min(pos+1, len(buf))
+			case 'D':
+				pos = max(pos-1, 0)
+			}
+		default:
+			if r >= ' ' && r != utf8.RuneError {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// completeAt completes the word before the cursor: with one candidate it is
+// inserted, with several their common prefix is and they are listed
+func (e *lineEditor) completeAt(buf *[]rune, pos *int, prompt string) {
+	if e.complete == nil {
+		return
+	}
+	before := string((*buf)[:*pos])
+	candidates := e.complete(before)
+	if len(candidates) == 0 {
+		return
+	}
+	start := strings.LastIndexAny(before, " \t") + 1
+	word := before[start:]
+
+	insert := candidates[0]
+	for _, c := range candidates[1:] {
+		insert = commonPrefix(insert, c)
+	}
+	if len(candidates) == 1 {
+		insert += " "
+	} else if len(insert) <= len(word) {
+		fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+		return
+	}
+	rest := (*buf)[*pos:]
+	*buf = append([]rune(before[:start]+insert), rest...)
+	*pos = utf8.RuneCountInString(before[:start] + insert)
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && strings.EqualFold(a[i:i+1], b[i:i+1]) {
+		i++
+	}
+	return a[:i]
+}
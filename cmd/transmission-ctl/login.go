@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/unix2dos/go-transmission/v2/keyring"
+)
+
+// keyringService is the service of the passwords in the system keyring,
+// stored for "user@rpc-url"
+const keyringService = "transmission-ctl"
+
+func (s server) keyringUser() string {
+	return s.User + "@" + s.RPC
+}
+
+// keyringPassword returns the password of s stored in the system keyring,
+// "" if there is none
+func (s server) keyringPassword() string {
+	pw, err := keyring.System().Get(keyringService, s.keyringUser())
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) && !errors.Is(err, keyring.ErrUnsupported) {
+		fmt.Fprintln(os.Stderr, "transmission-ctl:", err)
+	}
+	return pw
+}
+
+// login asks for the password of the server, checks it and stores it in the
+// system keyring, where later runs find it when the user is set and no other
+// password is given
+func (a *app) login(ctx context.Context, args []string) error {
+	fs := a.flagSet("login")
+	del := fs.Bool("delete", false, "remove the stored password instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s := *a.server
+	if s.User == "" {
+		return usagef("login needs a user, see -user")
+	}
+	if *del {
+		if err := keyring.System().Delete(keyringService, s.keyringUser()); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "password for %s removed\n", s.keyringUser())
+		return nil
+	}
+
+	fmt.Fprintf(a.out, "password for %s: ", s.keyringUser())
+	restore := noEcho(0)
+	line, err := a.in.ReadString('\n')
+	restore()
+	fmt.Fprintln(a.out)
+	if err != nil {
+		return err
+	}
+	s.Password = strings.TrimRight(line, "\r\n")
+	if _, err := s.connect(); err != nil {
+		return err
+	}
+	if err := keyring.System().Set(keyringService, s.keyringUser(), s.Password); err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, "password stored in the system keyring")
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/daemonlog"
+)
+
+// logsInterval is the polling interval of the RPC part of logs
+const logsInterval = 5 * time.Second
+
+// logView is the stable representation of a log entry
+type logView struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Source  string    `json:"source"`
+	Torrent string    `json:"torrent,omitempty"`
+	Message string    `json:"message"`
+}
+
+// logs follows the failures RPC reports together with the daemon log, until
+// interrupted; each entry is printed as it comes, as a one element list in
+// json and yaml
+func (a *app) logs(ctx context.Context, args []string) error {
+	fs := a.flagSet("logs")
+	unit := fs.String("journal", "", "also follow the journal of this systemd unit, e.g. transmission-daemon")
+	file := fs.String("file", "", "also follow this daemon log file")
+	host := fs.String("ssh", "", "read the journal or the log file on this host")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *unit != "" && *file != "" {
+		return usagef("-journal and -file are exclusive")
+	}
+
+	w := transmission.NewWatcher(a.client, logsInterval)
+	sources := []transmission.LogSource{transmission.WatcherLog(w)}
+	var daemon *daemonlog.Command
+	switch {
+	case *unit != "":
+		daemon = daemonlog.Journal(*unit)
+	case *file != "":
+		daemon = daemonlog.File(*file)
+	case *host != "":
+		return usagef("-ssh needs -journal or -file")
+	}
+	if daemon != nil && *host != "" {
+		daemon = daemonlog.SSH(*host, daemon)
+	}
+	if daemon != nil {
+		sources = append(sources, daemon)
+	}
+
+	go w.Run(ctx)
+	err := transmission.TailLogs(ctx, func(e transmission.LogEntry) {
+		v := logView{e.Time, e.Level.String(), e.Source, e.Torrent, e.Message}
+		a.print([]logView{v}, func(w io.Writer) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Time.Format(time.DateTime), v.Level, v.Source, logMessage(v))
+		})
+	}, sources...)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func logMessage(v logView) string {
+	if v.Torrent != "" {
+		return v.Torrent + ": " + v.Message
+	}
+	return v.Message
+}
@@ -0,0 +1,174 @@
+// Command transmission-ctl manages a Transmission daemon from the command
+// line.
+//
+//	transmission-ctl [flags] list [-view name]   list torrents, views are set in the configuration
+//	transmission-ctl [flags] info <id>           details of one torrent
+//	transmission-ctl [flags] add <url|file>...   add torrents by url, magnet or .torrent file
+//	transmission-ctl [flags] start <id>...       also stop and verify
+//	transmission-ctl [flags] remove [-data] <id>...
+//	transmission-ctl [flags] stats               session statistics
+//	transmission-ctl [flags] metrics [-push url] OpenMetrics text, or pushed to a Pushgateway
+//	transmission-ctl [flags] logs [-journal unit | -file path] [-ssh host]
+//	transmission-ctl [flags] login [-delete]     store the password in the system keyring
+//	transmission-ctl [flags] shell               interactive mode
+//	transmission-ctl completion bash|zsh|fish    print a shell completion script
+//
+// Torrents are designated by id, infohash prefix or a fragment of their
+// name, matched exactly, as a substring or as a subsequence ("debnet" finds
+// "Debian netinst"); when a fragment matches several torrents the terminal
+// user is asked to pick one. Every command takes -output table|json|yaml;
+// the json and yaml field names are stable. The exit status is 0 on success,
+// 1 on failure, 2 for a bad command line, 3 when a designated torrent doesn't
+// exist, 4 when the daemon is unreachable, 5 when a fragment is ambiguous
+// and 6 when the daemon rejects the credentials.
+//
+// The daemons can be described in ~/.config/transmission-ctl.yaml, see
+// config, and picked with -server name; -server all runs the command on all
+// of them at once. The file also holds the views of list -view. The -rpc,
+// -user, -password and -output flags override the configuration. Without a
+// password from them or $TRANSMISSION_PASSWORD, the one stored in the
+// system keyring by the login command is used.
+//
+// To enable completion add to the shell startup file
+//
+//	source <(transmission-ctl completion bash)   # or zsh
+//	transmission-ctl completion fish | source
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+const defaultRPC = "http://127.0.0.1:9091/transmission/rpc"
+
+var (
+	configFile = flag.String("config", configPath(), "configuration file")
+	serverName = flag.String("server", "", `configured server, "all" for every one; defaults to the configured default`)
+	rpcURL     = flag.String("rpc", defaultRPC, "daemon RPC url")
+	user       = flag.String("user", "", "daemon RPC username")
+	password   = flag.String("password", "", "daemon RPC password, defaults to $TRANSMISSION_PASSWORD")
+	output     = flag.String("output", "table", "output format: table, json or yaml; also accepted after the command")
+)
+
+func main() {
+	flag.Usage = usage
+	if isCompletion() {
+		flags, words := splitComplete(os.Args[2:])
+		flag.CommandLine.Init("", flag.ContinueOnError)
+		flag.CommandLine.SetOutput(io.Discard)
+		flag.CommandLine.Parse(flags)
+		// commands complete without a daemon, torrents only with one
+		a := &app{out: os.Stdout}
+		if servers, err := selectServers(); err == nil {
+			s := servers[0]
+			a.client = transmission.NewLazy(s.RPC, s.User, s.Password)
+		}
+		a.completeWords(context.Background(), words)
+		return
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	if err := run(flag.Args()); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintln(os.Stderr, "transmission-ctl:", line)
+			}
+		}
+		os.Exit(exitCode(err))
+	}
+}
+
+func run(args []string) error {
+	ctx := context.Background()
+	a := &app{
+		in:          bufio.NewReader(os.Stdin),
+		out:         os.Stdout,
+		output:      *output,
+		interactive: isTerminal(0),
+	}
+	if args[0] == "completion" {
+		return a.completion(ctx, args[1:]) // no daemon needed
+	}
+	servers, err := selectServers()
+	if err != nil {
+		return err
+	}
+	if len(servers) > 1 {
+		return runAll(ctx, a, servers, args)
+	}
+	if !isSet("output") && servers[0].Output != "" {
+		a.output = servers[0].Output
+	}
+	a.server = &servers[0]
+	if args[0] == "login" {
+		return a.exec(ctx, args) // checks the password it asks for
+	}
+	if a.client, err = servers[0].connect(); err != nil {
+		return err
+	}
+	return a.exec(ctx, args)
+}
+
+// selectServers returns the servers designated by the flags and the
+// configuration file, the flags overriding the file
+func selectServers() ([]server, error) {
+	c, err := loadConfig(*configFile, isSet("config"))
+	if err != nil {
+		return nil, err
+	}
+	servers, err := c.servers(*serverName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range servers {
+		s := &servers[i]
+		if isSet("rpc") || s.RPC == "" {
+			s.RPC = *rpcURL
+		}
+		if isSet("user") {
+			s.User = *user
+		}
+		switch {
+		case isSet("password"):
+			s.Password = *password
+		case s.PasswordEnv != "":
+			s.Password = os.Getenv(s.PasswordEnv)
+		case s.Password != "":
+		case os.Getenv("TRANSMISSION_PASSWORD") != "":
+			s.Password = os.Getenv("TRANSMISSION_PASSWORD")
+		case s.User != "":
+			s.Password = s.keyringPassword()
+		}
+	}
+	return servers, nil
+}
+
+// isSet reports whether the flag name was given on the command line
+func isSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: transmission-ctl [flags] <command> [args]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// errAmbiguous is returned when a name fragment matches several torrents and
+// nobody can be asked which one is meant
+var errAmbiguous = errors.New("ambiguous")
+
+// resolve returns the torrents designated by args: ids, infohash prefixes
+// of at least 4 characters or fuzzy name fragments
+func (a *app) resolve(ctx context.Context, args []string) (transmission.Torrents, error) {
+	if len(args) == 0 {
+		return nil, usagef("no torrent given")
+	}
+	torrents, err := a.client.GetTorrentsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var selected transmission.Torrents
+	for _, arg := range args {
+		match := matchTorrents(torrents, arg)
+		switch {
+		case len(match) == 0:
+			return nil, fmt.Errorf("%s: %w", arg, transmission.ErrNoTorrent)
+		case len(match) == 1:
+			selected = append(selected, match[0])
+		case a.interactive:
+			t, err := a.choose(arg, match)
+			if err != nil {
+				return nil, err
+			}
+			selected = append(selected, t)
+		default:
+			return nil, fmt.Errorf("%s: %w, matches %d torrents", arg, errAmbiguous, len(match))
+		}
+	}
+	return selected, nil
+}
+
+// matchTorrents returns the torrents designated by arg, trying in turn the
+// id, a hash prefix, the exact name, a name substring and the characters of
+// arg appearing in order in the name, all case-insensitive
+func matchTorrents(torrents transmission.Torrents, arg string) transmission.Torrents {
+	lower := strings.ToLower(arg)
+	tests := []func(t *transmission.Torrent) bool{
+		func(t *transmission.Torrent) bool { return arg == strconv.Itoa(t.ID) },
+		func(t *transmission.Torrent) bool { return len(arg) >= 4 && strings.HasPrefix(t.InfoHash, lower) },
+		func(t *transmission.Torrent) bool { return strings.EqualFold(t.Name, arg) },
+		func(t *transmission.Torrent) bool { return strings.Contains(strings.ToLower(t.Name), lower) },
+		func(t *transmission.Torrent) bool { return subsequence(strings.ToLower(t.Name), lower) },
+	}
+	for _, test := range tests {
+		var match transmission.Torrents
+		for _, t := range torrents {
+			if test(t) {
+				match = append(match, t)
+			}
+		}
+		if len(match) > 0 {
+			return match
+		}
+	}
+	return nil
+}
+
+// subsequence reports whether the characters of sub appear in order in s
+func subsequence(s, sub string) bool {
+	for _, r := range sub {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
+
+// choose asks which of the matching torrents arg designates
+func (a *app) choose(arg string, match transmission.Torrents) (*transmission.Torrent, error) {
+	fmt.Fprintf(a.out, "%q matches several torrents:\n", arg)
+	for i, t := range match {
+		fmt.Fprintf(a.out, "  %d) %s (id %d)\n", i+1, t.Name, t.ID)
+	}
+	for {
+		fmt.Fprintf(a.out, "which one [1-%d]? ", len(match))
+		line, err := a.in.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, errAmbiguous)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(line))
+		if err == nil && n >= 1 && n <= len(match) {
+			return match[n-1], nil
+		}
+	}
+}
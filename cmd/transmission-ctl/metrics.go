@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/unix2dos/go-transmission/v2/metrics"
+)
+
+// metrics prints the daemon's metrics in the OpenMetrics text format, or
+// pushes them to a Prometheus Pushgateway, for cron jobs; the metrics of a
+// configured server carry its name as the instance label
+func (a *app) metrics(ctx context.Context, args []string) error {
+	fs := a.flagSet("metrics")
+	push := fs.String("push", "", "Pushgateway url to push to instead of printing")
+	job := fs.String("job", "transmission", "job label of pushed metrics")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	families, err := metrics.Collect(ctx, a.client)
+	if *push == "" {
+		if a.fan != nil {
+			fmt.Fprintf(a.out, "# server %s\n", a.fan.server)
+		}
+		if werr := metrics.WriteOpenMetrics(a.out, families); werr != nil {
+			return werr
+		}
+		return err
+	}
+	p := &metrics.Pusher{URL: *push, Job: *job}
+	if name := a.serverName(); name != "" {
+		p.Group = map[string]string{"instance": name}
+	}
+	// a down daemon is pushed too, with transmission_up at 0
+	if perr := p.Push(ctx, families); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// serverName returns the configured name of the server the command runs
+// on, "" without a configuration
+func (a *app) serverName() string {
+	switch {
+	case a.fan != nil:
+		return a.fan.server
+	case a.server != nil:
+		return a.server.name
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/internal/yaml"
+)
+
+// Exit codes, stable for scripts
+const (
+	exitOK        = 0
+	exitError     = 1 // any other failure
+	exitUsage     = 2 // bad command line
+	exitNotFound  = 3 // a designated torrent doesn't exist
+	exitConnect   = 4 // the daemon is unreachable
+	exitAmbiguous = 5 // a name fragment designates several torrents
+	exitAuth      = 6 // the daemon rejects the credentials or the address
+)
+
+// usageError is a bad command line
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// exitCode returns the exit code for err
+func exitCode(err error) int {
+	var (
+		ue usageError
+		ce *transmission.ConnectionError
+		te *transmission.TimeoutError
+		ae *transmission.AuthError
+	)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &ue), errors.Is(err, flag.ErrHelp):
+		return exitUsage
+	case errors.As(err, &ce), errors.As(err, &te):
+		return exitConnect
+	case errors.As(err, &ae):
+		return exitAuth
+	case errors.Is(err, transmission.ErrNoTorrent):
+		return exitNotFound
+	case errors.Is(err, errAmbiguous):
+		return exitAmbiguous
+	default:
+		return exitError
+	}
+}
+
+// flagSet returns the flag set of a command, with the -output flag
+func (a *app) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&a.output, "output", a.output, "output format: table, json or yaml")
+	return fs
+}
+
+// print writes v as JSON or YAML, or as a table with the table function.
+// Running on several servers, JSON and YAML results are collected instead
+// and tables get a heading.
+func (a *app) print(v interface{}, table func(w io.Writer)) error {
+	if a.fan != nil {
+		switch a.output {
+		case "json", "yaml":
+			a.fan.output, a.fan.result = a.output, v
+			return nil
+		case "table", "":
+			fmt.Fprintf(a.out, "== %s ==\n", a.fan.server)
+			defer fmt.Fprintln(a.out)
+		}
+	}
+	switch a.output {
+	case "json":
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = a.out.Write(b)
+		return err
+	case "table", "":
+		w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	default:
+		return usagef("unknown output format %q", a.output)
+	}
+}
+
+// torrentView is the stable representation of a torrent in the json and
+// yaml outputs
+type torrentView struct {
+	ID           int      `json:"id"`
+	Hash         string   `json:"hash"`
+	Name         string   `json:"name"`
+	Status       string   `json:"status"`
+	PercentDone  float64  `json:"percentDone"` // 0...1
+	SizeWhenDone uint64   `json:"sizeWhenDone"`
+	Ratio        float64  `json:"ratio"` // -1 not available, -2 infinite
+	RateDownload uint64   `json:"rateDownload"`
+	RateUpload   uint64   `json:"rateUpload"`
+	ETA          int64    `json:"eta"` // seconds, -1 unknown
+	DownloadDir  string   `json:"downloadDir"`
+	AddedDate    int64    `json:"addedDate"`
+	Labels       []string `json:"labels"`
+	Error        string   `json:"error"`
+}
+
+func newTorrentView(t *transmission.Torrent) torrentView {
+	eta := int64(-1)
+	if left, ok := t.TimeLeft(); ok {
+		eta = int64(left.Seconds())
+	}
+	labels := t.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	return torrentView{
+		ID:           t.ID,
+		Hash:         t.InfoHash,
+		Name:         t.Name,
+		Status:       t.Status.String(),
+		PercentDone:  math.Round(float64(t.PercentDone)*1e4) / 1e4,
+		SizeWhenDone: t.SizeWhenDone,
+		Ratio:        t.UploadRatio,
+		RateDownload: t.DownloadRate(),
+		RateUpload:   t.UploadRate(),
+		ETA:          eta,
+		DownloadDir:  t.DownloadDir,
+		AddedDate:    t.AddedDate,
+		Labels:       labels,
+		Error:        t.ErrorString,
+	}
+}
+
+func torrentViews(torrents transmission.Torrents) []torrentView {
+	views := make([]torrentView, len(torrents))
+	for i, t := range torrents {
+		views[i] = newTorrentView(t)
+	}
+	return views
+}
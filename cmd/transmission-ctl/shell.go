@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHistory is the number of lines kept in the history file
+const maxHistory = 1000
+
+// completionTTL is how long the torrent list used for completion is reused
+const completionTTL = 5 * time.Second
+
+func (a *app) shell(ctx context.Context, args []string) error {
+	history := loadHistory()
+	c := &completer{app: a, ctx: ctx}
+	ed := newLineEditor(os.Stdin, a.in, a.out, c.complete)
+	ed.history = history
+
+	fmt.Fprintln(a.out, `transmission-ctl shell, "help" lists the commands, "exit" or ^D quits`)
+	for {
+		line, err := ed.readLine("transmission> ")
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(a.out)
+			break
+		}
+		if err != nil {
+			return err
+		}
+		fields, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		ed.history = append(ed.history, line)
+
+		switch fields[0] {
+		case "exit", "quit":
+			return saveHistory(ed.history)
+		case "help":
+			for _, c := range commands {
+				if c.name != "shell" && c.name != "completion" {
+					fmt.Fprintf(a.out, "  %-8s %s\n", c.name, c.usage)
+				}
+			}
+			continue
+		case "shell", "completion":
+			continue
+		}
+		if err := a.exec(ctx, fields); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		c.invalidate()
+	}
+	return saveHistory(ed.history)
+}
+
+// splitArgs splits a line into words, honoring single and double quotes and
+// backslash escapes
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord, quote, escaped := false, rune(0), false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// completer completes command names, then torrent ids, hashes and names
+type completer struct {
+	app *app
+	ctx context.Context
+
+	mu      sync.Mutex
+	words   []string
+	fetched time.Time
+}
+
+func (c *completer) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetched = time.Time{}
+}
+
+// complete returns the candidates for the last word of a shell line
+func (c *completer) complete(line string) []string {
+	words := strings.Fields(line)
+	prefix := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		prefix = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	return c.candidates(words, strings.Trim(prefix, `"'`), true)
+}
+
+// candidates returns the completions of prefix following words: command
+// names first, then torrent ids and names, quoted for splitArgs if quote is
+// set
+func (c *completer) candidates(words []string, prefix string, quote bool) []string {
+	var all []string
+	if len(words) == 0 {
+		for _, cmd := range commands {
+			all = append(all, cmd.name)
+		}
+		if quote {
+			all = append(all, "help", "exit")
+		}
+	} else if words[0] == "completion" {
+		all = []string{"bash", "zsh", "fish"}
+	} else {
+		for _, w := range c.torrentWords() {
+			if quote {
+				w = quoteArg(w)
+			}
+			all = append(all, w)
+		}
+	}
+
+	var matches []string
+	for _, w := range all {
+		if strings.HasPrefix(strings.ToLower(strings.Trim(w, `"`)), strings.ToLower(prefix)) {
+			matches = append(matches, w)
+		}
+	}
+	return matches
+}
+
+// torrentWords returns the ids and names of the torrents
+func (c *completer) torrentWords() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.fetched) < completionTTL {
+		return c.words
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, 2*time.Second)
+	defer cancel()
+	torrents, err := c.app.client.GetTorrentsContext(ctx)
+	if err != nil {
+		return nil
+	}
+	c.words = c.words[:0]
+	for _, t := range torrents {
+		c.words = append(c.words, strconv.Itoa(t.ID))
+	}
+	for _, t := range torrents {
+		c.words = append(c.words, t.Name)
+	}
+	c.fetched = time.Now()
+	return c.words
+}
+
+// quoteArg quotes s for splitArgs if needed
+func quoteArg(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// historyPath returns $XDG_STATE_HOME/transmission-ctl/history, or its
+// default under the home directory
+func historyPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "transmission-ctl", "history")
+}
+
+func loadHistory() []string {
+	f, err := os.Open(historyPath())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func saveHistory(lines []string) error {
+	path := historyPath()
+	if path == "" {
+		return nil
+	}
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+}
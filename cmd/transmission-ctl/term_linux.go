@@ -0,0 +1,53 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw puts the terminal fd in raw mode, failing if it is not a terminal
+func makeRaw(fd int) (restore func(), err error) {
+	var old syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, &old); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.BRKINT | syscall.INPCK | syscall.ISTRIP
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return func() { ioctl(fd, syscall.TCSETS, &old) }, nil
+}
+
+func ioctl(fd int, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isTerminal reports whether fd is a terminal
+func isTerminal(fd int) bool {
+	var t syscall.Termios
+	return ioctl(fd, syscall.TCGETS, &t) == nil
+}
+
+// noEcho turns off the echo of the terminal fd, if it is one
+func noEcho(fd int) (restore func()) {
+	var old syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, &old); err != nil {
+		return func() {}
+	}
+	t := old
+	t.Lflag &^= syscall.ECHO
+	if err := ioctl(fd, syscall.TCSETS, &t); err != nil {
+		return func() {}
+	}
+	return func() { ioctl(fd, syscall.TCSETS, &old) }
+}
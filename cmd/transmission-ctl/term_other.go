@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// makeRaw is only implemented on Linux; elsewhere the shell reads plain lines
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode not supported")
+}
+
+// isTerminal is only implemented on Linux; elsewhere prompts are disabled
+func isTerminal(fd int) bool {
+	return false
+}
+
+// noEcho is only implemented on Linux; elsewhere passwords are echoed
+func noEcho(fd int) (restore func()) {
+	return func() {}
+}
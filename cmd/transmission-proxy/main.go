@@ -0,0 +1,91 @@
+// Command transmission-proxy exposes a Transmission daemon through a small
+// authenticated REST API with field filtering and per-client rate limiting,
+// so browser frontends and other languages can reuse this package.
+//
+//	GET    /torrents?fields=id,name   list torrents
+//	GET    /torrents/{id}             one torrent, by hash or id
+//	POST   /torrents/{id}/start       also stop and verify
+//	DELETE /torrents/{id}?data=true   remove, with data when data=true
+//	GET    /summary                   see transmission.Summary
+//	GET    /stats                     session-stats
+//
+// Every request needs an "Authorization: Bearer <token>" header.
+//
+// With -arr the daemon is also served to Sonarr and Radarr, see package arr,
+// on another address: configure them with a Transmission download client
+// whose password is the token, paths translated by the -arr-path rules and
+// the -arr-label labels given to the torrents they add.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/arr"
+)
+
+func main() {
+	var (
+		listen   = flag.String("listen", ":9092", "address to listen on")
+		rpcURL   = flag.String("rpc", "http://127.0.0.1:9091/transmission/rpc", "daemon RPC url")
+		user     = flag.String("user", "", "daemon RPC username")
+		password = flag.String("password", os.Getenv("TRANSMISSION_PASSWORD"), "daemon RPC password, defaults to $TRANSMISSION_PASSWORD")
+		token    = flag.String("token", os.Getenv("PROXY_TOKEN"), "bearer token clients must send, defaults to $PROXY_TOKEN")
+		rate     = flag.Float64("rate", 5, "requests per second allowed per client")
+		burst    = flag.Int("burst", 20, "request burst allowed per client")
+		wait     = flag.Duration("wait", 0, "how long to wait for the daemon to start")
+		arrAddr  = flag.String("arr", "", "address to serve Sonarr and Radarr on, none by default")
+		arrLabel = flag.String("arr-label", "", "comma separated labels given to the torrents the *arr add")
+		arrRate  = flag.Float64("arr-rate", 0, "daemon requests per second allowed to the *arr, unlimited if 0")
+		arrPaths transmission.PathMapper
+	)
+	flag.Func("arr-path", "daemon=arr path translation, repeatable", func(v string) error {
+		remote, local, ok := strings.Cut(v, "=")
+		if !ok || remote == "" || local == "" {
+			return fmt.Errorf("want daemon-path=arr-path")
+		}
+		arrPaths = append(arrPaths, transmission.PathRule{Remote: remote, Local: local})
+		return nil
+	})
+	flag.Parse()
+
+	if *token == "" {
+		log.Fatal("a token is required, see -token")
+	}
+
+	var client *transmission.TransmissionClient
+	var err error
+	if *wait > 0 {
+		client = transmission.NewLazy(*rpcURL, *user, *password)
+		err = client.WaitReady(context.Background(), *wait)
+	} else {
+		client, err = transmission.New(*rpcURL, *user, *password)
+	}
+	if err != nil {
+		log.Fatalf("connecting to %s: %v", *rpcURL, err)
+	}
+
+	if *arrAddr != "" {
+		shim := &arr.Shim{Client: client, Password: *token, Paths: arrPaths}
+		if *arrLabel != "" {
+			shim.Labels = strings.Split(*arrLabel, ",")
+		}
+		if *arrRate > 0 {
+			shim.Throttle = arr.NewThrottle(*arrRate, *burst)
+		}
+		go func() {
+			log.Printf("serving the *arr on %s", *arrAddr)
+			log.Fatal(http.ListenAndServe(*arrAddr, shim))
+		}()
+	}
+
+	srv := newServer(client, *token, newLimiter(*rate, *burst))
+	log.Printf("listening on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, srv))
+}
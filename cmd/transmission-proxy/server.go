@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+type server struct {
+	client  transmission.TorrentService
+	token   string
+	limiter *limiter
+	mux     *http.ServeMux
+}
+
+func newServer(client transmission.TorrentService, token string, l *limiter) *server {
+	s := &server{client: client, token: token, limiter: l, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /torrents", s.listTorrents)
+	s.mux.HandleFunc("GET /torrents/{id}", s.getTorrent)
+	s.mux.HandleFunc("POST /torrents/{id}/{action}", s.torrentAction)
+	s.mux.HandleFunc("DELETE /torrents/{id}", s.deleteTorrent)
+	s.mux.HandleFunc("GET /summary", s.summary)
+	s.mux.HandleFunc("GET /stats", s.stats)
+	return s
+}
+
+// ServeHTTP rate limits every request, authenticated or not, so that the
+// token can't be brute-forced
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !s.limiter.allow(host) {
+		writeError(w, http.StatusTooManyRequests, errors.New("rate limit exceeded"))
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// torrentID returns the hash of the torrent designated by the id path
+// value, a hash or a numeric id: the daemon reads a string id as a hash,
+// numeric ones are looked up
+func (s *server) torrentID(r *http.Request) (string, error) {
+	id := r.PathValue("id")
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return id, nil
+	}
+	cmd := transmission.NewGetTorrentsCmd()
+	cmd.Arguments.Fields = []string{"id", "hashString"}
+	out, err := s.client.ExecuteCommandContext(r.Context(), cmd)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range out.Arguments.Torrents {
+		if t.ID == n {
+			return t.InfoHash, nil
+		}
+	}
+	return "", transmission.ErrNoTorrent
+}
+
+func (s *server) listTorrents(w http.ResponseWriter, r *http.Request) {
+	torrents, err := s.client.GetTorrentsContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	fields := parseFields(r)
+	out := make([]map[string]json.RawMessage, 0, len(torrents))
+	for _, t := range torrents {
+		m, err := filterFields(t, fields)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		out = append(out, m)
+	}
+	writeJSON(w, out)
+}
+
+func (s *server) getTorrent(w http.ResponseWriter, r *http.Request) {
+	id, err := s.torrentID(r)
+	var t *transmission.Torrent
+	if err == nil {
+		t, err = s.client.GetTorrentContext(r.Context(), id)
+	}
+	if errors.Is(err, transmission.ErrNoTorrent) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	m, err := filterFields(t, parseFields(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, m)
+}
+
+func (s *server) torrentAction(w http.ResponseWriter, r *http.Request) {
+	var act func(...string) (string, error)
+	switch r.PathValue("action") {
+	case "start":
+		act = s.client.StartTorrent
+	case "stop":
+		act = s.client.StopTorrent
+	case "verify":
+		act = s.client.VerifyTorrent
+	default:
+		writeError(w, http.StatusNotFound, errors.New("unknown action"))
+		return
+	}
+	id, err := s.torrentID(r)
+	if errors.Is(err, transmission.ErrNoTorrent) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var result string
+	if err == nil {
+		result, err = act(id)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, map[string]string{"result": result})
+}
+
+func (s *server) deleteTorrent(w http.ResponseWriter, r *http.Request) {
+	withData, _ := strconv.ParseBool(r.URL.Query().Get("data"))
+	id, err := s.torrentID(r)
+	var name string
+	if err == nil {
+		name, err = s.client.DeleteTorrent(id, withData)
+	}
+	if errors.Is(err, transmission.ErrNoTorrent) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, map[string]string{"name": name})
+}
+
+func (s *server) summary(w http.ResponseWriter, r *http.Request) {
+	summary, err := s.client.Summary(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, summary)
+}
+
+func (s *server) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.client.GetStatsContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, stats)
+}
+
+// parseFields returns the comma separated "fields" query parameter
+func parseFields(r *http.Request) []string {
+	f := r.URL.Query().Get("fields")
+	if f == "" {
+		return nil
+	}
+	return strings.Split(f, ",")
+}
+
+// filterFields returns the JSON fields of t named in fields, or all of them
+// when fields is empty
+func filterFields(t *transmission.Torrent, fields []string) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return all, nil
+	}
+	m := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		if v, ok := all[f]; ok {
+			m[f] = v
+		}
+	}
+	return m, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,105 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+)
+
+// IPFamily is an IP protocol the daemon's peer port can be tested over
+type IPFamily string
+
+const (
+	IPv4 IPFamily = "ipv4"
+	IPv6 IPFamily = "ipv6"
+)
+
+// ErrFamilyUnsupported is returned by PortTest when the daemon can't test
+// over the requested family, before Transmission 4.1
+var ErrFamilyUnsupported = errors.New("the daemon can't test the port over a given IP family")
+
+// PortTest asks the daemon whether its peer port is reachable from the
+// internet, over family when it isn't empty
+func (ac *TransmissionClient) PortTest(ctx context.Context, family IPFamily) (open bool, err error) {
+	var args map[string]string
+	if family != "" {
+		args = map[string]string{"ipProtocol": string(family)}
+	}
+	var out struct {
+		PortIsOpen bool     `json:"port-is-open"`
+		IPProtocol IPFamily `json:"ipProtocol"` // echoed by the daemons testing per family
+	}
+	if err := ac.rpc(ctx, "port-test", args, &out); err != nil {
+		return false, err
+	}
+	if family != "" && out.IPProtocol != family {
+		return false, ErrFamilyUnsupported
+	}
+	return out.PortIsOpen, nil
+}
+
+// FamilyStatus is the reachability of the peer port over one IP family
+type FamilyStatus struct {
+	Family      IPFamily
+	BindAddress string // as set in the session, empty if not reported
+	Tested      bool   // the port was tested over the family
+	Open        bool
+	Err         error // why the test failed, ErrFamilyUnsupported if it couldn't run
+}
+
+// Connectivity is the reachability of the daemon over IPv4 and IPv6
+type Connectivity struct {
+	PeerPort int
+	IPv4     FamilyStatus
+	IPv6     FamilyStatus
+}
+
+// DualStack reports whether the peer port is open over both families
+func (c *Connectivity) DualStack() bool {
+	return c.IPv4.Open && c.IPv6.Open
+}
+
+// CheckConnectivity tests the peer port over each IP family. A daemon
+// that can't test per family is tested once, the result going to IPv4,
+// what such daemons test; IPv6 is then left untested with
+// ErrFamilyUnsupported. A family whose bind address is invalid for it is
+// reported as an error without testing.
+func (ac *TransmissionClient) CheckConnectivity(ctx context.Context) (*Connectivity, error) {
+	session, err := ac.GetSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := &Connectivity{
+		PeerPort: session.PeerPort,
+		IPv4:     FamilyStatus{Family: IPv4, BindAddress: session.BindAddressIPv4},
+		IPv6:     FamilyStatus{Family: IPv6, BindAddress: session.BindAddressIPv6},
+	}
+	for _, s := range []*FamilyStatus{&c.IPv4, &c.IPv6} {
+		if err := checkBindAddress(s.Family, s.BindAddress); err != nil {
+			s.Err = err
+			continue
+		}
+		s.Open, s.Err = ac.PortTest(ctx, s.Family)
+		s.Tested = s.Err == nil
+	}
+	if errors.Is(c.IPv4.Err, ErrFamilyUnsupported) {
+		c.IPv4.Open, c.IPv4.Err = ac.PortTest(ctx, "")
+		c.IPv4.Tested = c.IPv4.Err == nil
+	}
+	return c, nil
+}
+
+// checkBindAddress tells what is wrong with a bind address for family
+func checkBindAddress(family IPFamily, bind string) error {
+	if bind == "" {
+		return nil
+	}
+	addr, err := netip.ParseAddr(bind)
+	if err != nil {
+		return err
+	}
+	if addr.Unmap().Is4() != (family == IPv4) {
+		return errors.New("bind address " + bind + " is not an " + string(family) + " address")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Package daemonlog reads the log of the Transmission daemon, from the
+// systemd journal, a log file or either of them over SSH, as a
+// transmission.LogSource.
+package daemonlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// Command is a LogSource running a command that follows the daemon log,
+// one message per line, in the journalctl JSON format or in the text format
+// of transmission-daemon
+type Command struct {
+	Name string // Source of the entries
+	Path string
+	Args []string
+}
+
+// Journal follows the messages of the systemd unit, usually
+// "transmission-daemon", with journalctl
+func Journal(unit string) *Command {
+	return &Command{
+		Name: "journal",
+		Path: "journalctl",
+		Args: []string{"--follow", "--lines=0", "--output=json", "--unit=" + unit},
+	}
+}
+
+// File follows the log file written by transmission-daemon --logfile
+func File(path string) *Command {
+	return &Command{Name: "file", Path: "tail", Args: []string{"-F", "-n", "0", path}}
+}
+
+// SSH runs c on host through ssh, which must log in without prompting
+func SSH(host string, c *Command) *Command {
+	args := []string{"-o", "BatchMode=yes", host, "--", shellQuote(c.Path)}
+	for _, a := range c.Args {
+		args = append(args, shellQuote(a))
+	}
+	return &Command{Name: c.Name + "@" + host, Path: "ssh", Args: args}
+}
+
+// shellQuote quotes s for the remote shell of ssh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Tail runs the command until ctx is done or the command exits
+func (c *Command) Tail(ctx context.Context, fn func(transmission.LogEntry)) error {
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(out)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		if e, ok := ParseLine(sc.Text()); ok {
+			e.Source = c.Name
+			fn(e)
+		}
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("daemonlog: %s: %v: %s", c.Path, err, strings.TrimSpace(stderr.String()))
+	}
+	return sc.Err()
+}
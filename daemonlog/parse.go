@@ -0,0 +1,102 @@
+package daemonlog
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// textTime is the time format of transmission-daemon log lines
+const textTime = "2006-01-02 15:04:05.000"
+
+// textLevels are the levels of transmission-daemon 4 log lines; version 3
+// doesn't write them
+var textLevels = map[string]transmission.LogLevel{
+	"CRT": transmission.LogError,
+	"ERR": transmission.LogError,
+	"WRN": transmission.LogWarning,
+	"INF": transmission.LogInfo,
+	"DBG": transmission.LogDebug,
+	"TRC": transmission.LogDebug,
+}
+
+// sourceLocation is the "(file.cc:123)" ending daemon messages
+var sourceLocation = regexp.MustCompile(` \([\w.-]+:\d+\)$`)
+
+// ParseLine parses a line of the journalctl JSON output or of the
+// transmission-daemon log, like
+//
+//	[2024-03-01 10:02:03.456] ERR ubuntu.iso: Tracker error: "unregistered torrent" (tr-announcer.cc:855)
+//
+// Lines in neither format are info messages timed now.
+func ParseLine(line string) (transmission.LogEntry, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return transmission.LogEntry{}, false
+	}
+	if strings.HasPrefix(line, "{") {
+		if e, ok := parseJournal(line); ok {
+			return e, true
+		}
+	}
+	e := transmission.LogEntry{Time: time.Now(), Level: transmission.LogInfo}
+	parseText(&e, line)
+	return e, true
+}
+
+// parseText fills e from a transmission-daemon message, which may lack the
+// time when it comes from the journal
+func parseText(e *transmission.LogEntry, s string) {
+	if strings.HasPrefix(s, "[") {
+		if end := strings.IndexByte(s, ']'); end > 0 {
+			if t, err := time.ParseInLocation(textTime, s[1:end], time.Local); err == nil {
+				e.Time = t
+				s = strings.TrimSpace(s[end+1:])
+			}
+		}
+	}
+	if word, rest, ok := strings.Cut(s, " "); ok {
+		if level, ok := textLevels[word]; ok {
+			e.Level, s = level, rest
+		}
+	}
+	e.Message = sourceLocation.ReplaceAllString(s, "")
+}
+
+// journalEntry is the part of a journalctl JSON line used
+type journalEntry struct {
+	Message  interface{} `json:"MESSAGE"` // a string, or bytes as numbers when not UTF-8
+	Realtime string      `json:"__REALTIME_TIMESTAMP"`
+	Priority string      `json:"PRIORITY"`
+}
+
+func parseJournal(line string) (transmission.LogEntry, bool) {
+	var j journalEntry
+	if json.Unmarshal([]byte(line), &j) != nil {
+		return transmission.LogEntry{}, false
+	}
+	msg, ok := j.Message.(string)
+	if !ok {
+		return transmission.LogEntry{}, false
+	}
+	e := transmission.LogEntry{Time: time.Now(), Level: transmission.LogInfo}
+	if us, err := strconv.ParseInt(j.Realtime, 10, 64); err == nil {
+		e.Time = time.UnixMicro(us)
+	}
+	if p, err := strconv.Atoi(j.Priority); err == nil {
+		switch {
+		case p <= 3:
+			e.Level = transmission.LogError
+		case p == 4:
+			e.Level = transmission.LogWarning
+		case p == 7:
+			e.Level = transmission.LogDebug
+		}
+	}
+	parseText(&e, msg)
+	return e, true
+}
@@ -0,0 +1,120 @@
+package transmission
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// DedupeStats counts the GetTorrent calls of a client created with
+// WithTorrentDedupe
+type DedupeStats struct {
+	Hits   uint64 // calls served by the request of another call
+	Misses uint64 // calls that sent their own request
+}
+
+// HitRatio returns the share of the calls served by another's request
+func (s DedupeStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// WithTorrentDedupe merges the concurrent GetTorrent calls for the same id,
+// e.g. of goroutines polling the torrent they wait for, into one request
+// whose result goes to all of them. As with SetCoalescing, the request
+// isn't bound to the ctx of the first caller: each caller waits until its
+// own ctx is done, and the request is cancelled once no caller waits for
+// it.
+func WithTorrentDedupe() Option {
+	return func(ac *TransmissionClient) {
+		ac.torrentFlights = &torrentFlights{}
+	}
+}
+
+// DedupeStats returns the counts of the GetTorrent calls merged, zero
+// without WithTorrentDedupe
+func (ac *TransmissionClient) DedupeStats() DedupeStats {
+	if ac.torrentFlights == nil {
+		return DedupeStats{}
+	}
+	return DedupeStats{
+		Hits:   ac.torrentFlights.hits.Load(),
+		Misses: ac.torrentFlights.misses.Load(),
+	}
+}
+
+// torrentFlights merges the concurrent fetches of a torrent by id
+type torrentFlights struct {
+	hits, misses atomic.Uint64
+
+	mu    sync.Mutex
+	calls map[string]*torrentFlight
+}
+
+type torrentFlight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int // guarded by torrentFlights.mu
+
+	t   *Torrent
+	err error
+}
+
+// do returns the result of fetch for id, run once for the callers waiting
+// at the same time; each caller gets its own copy of the Torrent, its
+// slices shared
+func (g *torrentFlights) do(ctx context.Context, id string, fetch func(context.Context) (*Torrent, error)) (*Torrent, error) {
+	g.mu.Lock()
+	f, ok := g.calls[id]
+	if ok {
+		g.hits.Add(1)
+		f.waiters++
+	} else {
+		g.misses.Add(1)
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &torrentFlight{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		if g.calls == nil {
+			g.calls = make(map[string]*torrentFlight)
+		}
+		g.calls[id] = f
+		go func() {
+			f.t, f.err = fetch(fctx)
+			g.forget(id, f)
+			cancel()
+			close(f.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return &Torrent{}, f.err
+		}
+		t := *f.t
+		return &t, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			g.forgetLocked(id, f) // later callers start a new request
+		}
+		g.mu.Unlock()
+		return &Torrent{}, ctx.Err()
+	}
+}
+
+func (g *torrentFlights) forget(id string, f *torrentFlight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.forgetLocked(id, f)
+}
+
+func (g *torrentFlights) forgetLocked(id string, f *torrentFlight) {
+	if g.calls[id] == f {
+		delete(g.calls, id)
+	}
+}
@@ -0,0 +1,157 @@
+package transmission
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// TrackerList is the text form of trackers used by the default-trackers
+// setting and the trackerList field: tiers of announce urls, one url per
+// line and a blank line between tiers
+type TrackerList [][]string
+
+// ParseTrackerList reads the text form of a tracker list
+func ParseTrackerList(text string) TrackerList {
+	var l TrackerList
+	var tier []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			tier = append(tier, line)
+			continue
+		}
+		if len(tier) > 0 {
+			l = append(l, tier)
+			tier = nil
+		}
+	}
+	if len(tier) > 0 {
+		l = append(l, tier)
+	}
+	return l
+}
+
+func (l TrackerList) String() string {
+	tiers := make([]string, 0, len(l))
+	for _, tier := range l {
+		if len(tier) > 0 {
+			tiers = append(tiers, strings.Join(tier, "\n"))
+		}
+	}
+	return strings.Join(tiers, "\n\n")
+}
+
+// Contains reports whether the list has the announce url in any tier
+func (l TrackerList) Contains(announce string) bool {
+	n := normalizeAnnounce(announce)
+	for _, tier := range l {
+		for _, u := range tier {
+			if normalizeAnnounce(u) == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Append returns the list with each of the urls it lacks added in a tier
+// of its own
+func (l TrackerList) Append(urls ...string) TrackerList {
+	l = l.clone()
+	for _, u := range urls {
+		if u = strings.TrimSpace(u); u != "" && !l.Contains(u) {
+			l = append(l, []string{u})
+		}
+	}
+	return l
+}
+
+// Remove returns the list without the urls, dropping the tiers left empty
+func (l TrackerList) Remove(urls ...string) TrackerList {
+	removed := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		removed[normalizeAnnounce(u)] = true
+	}
+	var out TrackerList
+	for _, tier := range l {
+		tier = slices.DeleteFunc(slices.Clone(tier), func(u string) bool {
+			return removed[normalizeAnnounce(u)]
+		})
+		if len(tier) > 0 {
+			out = append(out, tier)
+		}
+	}
+	return out
+}
+
+// Dedupe returns the list keeping only the first occurrence of each url,
+// dropping the tiers left empty
+func (l TrackerList) Dedupe() TrackerList {
+	seen := make(map[string]bool)
+	var out TrackerList
+	for _, tier := range l {
+		var kept []string
+		for _, u := range tier {
+			if n := normalizeAnnounce(u); n != "" && !seen[n] {
+				seen[n] = true
+				kept = append(kept, u)
+			}
+		}
+		if len(kept) > 0 {
+			out = append(out, kept)
+		}
+	}
+	return out
+}
+
+func (l TrackerList) clone() TrackerList {
+	out := make(TrackerList, len(l))
+	for i, tier := range l {
+		out[i] = slices.Clone(tier)
+	}
+	return out
+}
+
+// DefaultTrackers returns the trackers the daemon adds to the public
+// torrents, Transmission 4.0+
+func (ac *TransmissionClient) DefaultTrackers(ctx context.Context) (TrackerList, error) {
+	var out struct {
+		DefaultTrackers string `json:"default-trackers"`
+	}
+	args := map[string][]string{"fields": {"default-trackers"}}
+	if err := ac.rpc(ctx, "session-get", args, &out); err != nil {
+		return nil, err
+	}
+	return ParseTrackerList(out.DefaultTrackers), nil
+}
+
+// SetDefaultTrackers replaces the default trackers of the daemon
+func (ac *TransmissionClient) SetDefaultTrackers(ctx context.Context, l TrackerList) error {
+	text := l.String()
+	return ac.SetSession(ctx, &SessionSetArgs{DefaultTrackers: &text})
+}
+
+// AddDefaultTrackers adds the urls the default trackers lack, each in a
+// tier of its own
+func (ac *TransmissionClient) AddDefaultTrackers(ctx context.Context, urls ...string) error {
+	return ac.updateDefaultTrackers(ctx, func(l TrackerList) TrackerList { return l.Append(urls...) })
+}
+
+// RemoveDefaultTrackers removes the urls from the default trackers
+func (ac *TransmissionClient) RemoveDefaultTrackers(ctx context.Context, urls ...string) error {
+	return ac.updateDefaultTrackers(ctx, func(l TrackerList) TrackerList { return l.Remove(urls...) })
+}
+
+// updateDefaultTrackers applies fn to the default trackers, writing them
+// back only when they change
+func (ac *TransmissionClient) updateDefaultTrackers(ctx context.Context, fn func(TrackerList) TrackerList) error {
+	l, err := ac.DefaultTrackers(ctx)
+	if err != nil {
+		return err
+	}
+	updated := fn(l)
+	if updated.String() == l.String() {
+		return nil
+	}
+	return ac.SetDefaultTrackers(ctx, updated)
+}
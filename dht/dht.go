@@ -0,0 +1,219 @@
+// Package dht looks up the peers of an infohash on the BitTorrent DHT
+// (BEP 5), to estimate the health of a swarm before adding a torrent.
+package dht
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/unix2dos/go-transmission/v2/bencode"
+)
+
+// DefaultBootstrap are well-known DHT routers used when Lookup has none
+var DefaultBootstrap = []string{
+	"router.bittorrent.com:6881",
+	"dht.transmissionbt.com:6881",
+	"router.utorrent.com:6881",
+}
+
+const (
+	closest      = 8               // nodes around the target a lookup converges on, asked per round
+	roundTimeout = 2 * time.Second // wait for the answers of one round
+	maxQueries   = 256             // per lookup
+	compactNode  = 20 + 6          // id, IPv4 address and port
+	compactPeer  = 6               // IPv4 address and port
+	maxPacket    = 1500
+)
+
+// Lookup is a get_peers query on the DHT
+type Lookup struct {
+	Bootstrap []string // host:port of the first nodes, DefaultBootstrap if empty
+	MaxPeers  int      // stop after finding that many peers, 0 for no limit
+}
+
+// Result is the outcome of a lookup
+type Result struct {
+	Peers   []netip.AddrPort // distinct peers announced for the infohash
+	Queried int              // nodes queried
+	Replied int              // nodes that answered
+}
+
+type node struct {
+	id       [20]byte
+	addr     netip.AddrPort
+	queried  bool
+	distance [20]byte // to the target
+}
+
+// GetPeers walks the DHT towards hash, a hex infohash like Torrent.InfoHash,
+// collecting the peers nodes return for it, until the closest nodes have
+// all been asked, MaxPeers is reached or ctx is done. A lookup ended by ctx
+// returns what it found with the ctx error.
+func (l *Lookup) GetPeers(ctx context.Context, hash string) (*Result, error) {
+	b, err := hex.DecodeString(hash)
+	if err != nil || len(b) != 20 {
+		return nil, fmt.Errorf("dht: bad infohash %q", hash)
+	}
+	var target, self [20]byte
+	copy(target[:], b)
+	rand.Read(self[:])
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	bootstrap := l.Bootstrap
+	if len(bootstrap) == 0 {
+		bootstrap = DefaultBootstrap
+	}
+	var nodes []*node
+	seen := make(map[netip.AddrPort]bool)
+	for _, hostport := range bootstrap {
+		ua, err := net.ResolveUDPAddr("udp4", hostport)
+		if err != nil {
+			continue
+		}
+		addr := ua.AddrPort()
+		addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+		if !seen[addr] {
+			seen[addr] = true
+			n := &node{addr: addr}
+			for i := range n.distance {
+				n.distance[i] = 0xff // unknown id, sorted after every found node
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("dht: no bootstrap node resolved")
+	}
+
+	res := &Result{}
+	peers := make(map[netip.AddrPort]bool)
+	for res.Queried < maxQueries {
+		slices.SortFunc(nodes, func(a, b *node) int { return bytes.Compare(a.distance[:], b.distance[:]) })
+		var round []*node
+		for _, n := range nodes[:min(len(nodes), closest)] {
+			if !n.queried {
+				round = append(round, n)
+			}
+		}
+		if len(round) == 0 {
+			break // the closest nodes have all been asked
+		}
+
+		pending := make(map[string]*node, len(round))
+		for _, n := range round {
+			n.queried = true
+			res.Queried++
+			tid := make([]byte, 2)
+			rand.Read(tid)
+			msg, err := bencode.Encode(map[string]interface{}{
+				"t": string(tid),
+				"y": "q",
+				"q": "get_peers",
+				"a": map[string]interface{}{"id": string(self[:]), "info_hash": string(target[:])},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if _, err := conn.WriteToUDPAddrPort(msg, n.addr); err == nil {
+				pending[string(tid)] = n
+			}
+		}
+
+		conn.SetReadDeadline(time.Now().Add(roundTimeout))
+		buf := make([]byte, maxPacket)
+		for len(pending) > 0 {
+			size, from, err := conn.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				break // round timeout or ctx done
+			}
+			tid, reply, ok := parseReply(buf[:size])
+			n := pending[tid]
+			if !ok || n == nil || n.addr.Addr() != from.Addr().Unmap() {
+				continue
+			}
+			delete(pending, tid)
+			res.Replied++
+			for _, p := range reply.peers {
+				if !peers[p] {
+					peers[p] = true
+					res.Peers = append(res.Peers, p)
+				}
+			}
+			for _, nn := range reply.nodes {
+				if !seen[nn.addr] {
+					seen[nn.addr] = true
+					for i := range nn.distance {
+						nn.distance[i] = nn.id[i] ^ target[i]
+					}
+					nodes = append(nodes, nn)
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if l.MaxPeers > 0 && len(res.Peers) >= l.MaxPeers {
+			break
+		}
+	}
+	return res, nil
+}
+
+type reply struct {
+	peers []netip.AddrPort
+	nodes []*node
+}
+
+// parseReply decodes a get_peers response, returning its transaction id
+func parseReply(b []byte) (tid string, r reply, ok bool) {
+	v, err := bencode.Decode(b)
+	if err != nil {
+		return "", r, false
+	}
+	msg, _ := v.(map[string]interface{})
+	tid, _ = msg["t"].(string)
+	if y, _ := msg["y"].(string); y != "r" {
+		return tid, r, false
+	}
+	args, _ := msg["r"].(map[string]interface{})
+	if args == nil {
+		return tid, r, false
+	}
+
+	values, _ := args["values"].([]interface{})
+	for _, v := range values {
+		if s, ok := v.(string); ok && len(s) == compactPeer {
+			r.peers = append(r.peers, compactAddr(s))
+		}
+	}
+	nodes, _ := args["nodes"].(string)
+	for ; len(nodes) >= compactNode; nodes = nodes[compactNode:] {
+		n := &node{addr: compactAddr(nodes[20:compactNode])}
+		copy(n.id[:], nodes[:20])
+		if n.addr.Port() != 0 {
+			r.nodes = append(r.nodes, n)
+		}
+	}
+	return tid, r, true
+}
+
+// compactAddr decodes a 6 byte IPv4 address and port
+func compactAddr(s string) netip.AddrPort {
+	addr := netip.AddrFrom4([4]byte{s[0], s[1], s[2], s[3]})
+	return netip.AddrPortFrom(addr, uint16(s[4])<<8|uint16(s[5]))
+}
@@ -0,0 +1,25 @@
+// Package transmission is a client for the Transmission RPC API.
+//
+// The package only depends on the standard library. Features that are not
+// needed to talk RPC live in sub-packages, so tools embedding the client
+// only pay for what they import:
+//
+//	bencode, metainfo  .torrent files: parsing, creation, piece verification
+//	local              torrent data on the local filesystem
+//	notify             email, exec, Telegram, Slack, NATS, MQTT and Plex/Jellyfin event sinks
+//	indexer            search through Torznab indexers and add the results
+//	bridge             live torrent state over WebSocket
+//	tracker            direct HTTP and UDP tracker scrapes
+//	dht                peer lookups on the BitTorrent DHT
+//	daemonlog          the daemon's own log, from journald or a file, over SSH too
+//	keyring            RPC passwords in the keychain of the operating system
+//	transmissiontest   record and replay daemon exchanges, a simulated daemon
+//	wire               versioned JSON encodings for message queues
+//	arr                a Transmission RPC front for Sonarr and Radarr with policy hooks
+//	metrics            Prometheus metrics: scrape handler, OpenMetrics text, Pushgateway
+//	reconcile          declarative state: keep the daemon's torrents as a document lists them
+//
+// The cmd directory holds transmission-ctl, a command line client with an
+// interactive shell, and transmission-proxy, a REST facade also serving
+// the arr shim.
+package transmission
@@ -0,0 +1,32 @@
+package transmission
+
+import (
+	"context"
+	"path"
+)
+
+// SessionDownloadDir returns the session's default download-dir
+func (ac *TransmissionClient) SessionDownloadDir(ctx context.Context) (string, error) {
+	var session struct {
+		DownloadDir string `json:"download-dir"`
+	}
+	args := map[string][]string{"fields": {"download-dir"}}
+	if err := ac.rpc(ctx, "session-get", args, &session); err != nil {
+		return "", err
+	}
+	return session.DownloadDir, nil
+}
+
+// ResolveDownloadDir returns dir joined to the session's download-dir if it
+// is relative, or dir itself. Daemon paths use forward slashes, a leading
+// drive letter ("C:") counts as absolute.
+func (ac *TransmissionClient) ResolveDownloadDir(ctx context.Context, dir string) (string, error) {
+	if path.IsAbs(dir) || len(dir) > 1 && dir[1] == ':' {
+		return dir, nil
+	}
+	base, err := ac.SessionDownloadDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(base, dir), nil
+}
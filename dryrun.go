@@ -0,0 +1,137 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// mutatingMethods are the RPC methods changing the daemon's state, which
+// are not sent under WithDryRun
+var mutatingMethods = map[string]bool{
+	"torrent-start":        true,
+	"torrent-start-now":    true,
+	"torrent-stop":         true,
+	"torrent-verify":       true,
+	"torrent-reannounce":   true,
+	"torrent-set":          true,
+	"torrent-add":          true,
+	"torrent-remove":       true,
+	"torrent-set-location": true,
+	"torrent-rename-path":  true,
+	"session-set":          true,
+	"queue-move-top":       true,
+	"queue-move-up":        true,
+	"queue-move-down":      true,
+	"queue-move-bottom":    true,
+	"blocklist-update":     true,
+}
+
+// dryRunResponse is what the daemon is pretended to answer under WithDryRun
+const dryRunResponse = `{"result":"success","arguments":{}}`
+
+// PlannedAction is a mutating call that was not sent because of WithDryRun
+type PlannedAction struct {
+	Method    string          `json:"method"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Time      time.Time       `json:"time"`
+}
+
+// dryRun records the planned actions of a client created WithDryRun
+type dryRun struct {
+	mu      sync.Mutex
+	planned []PlannedAction
+}
+
+// WithDryRun makes the client log and record the mutating calls (remove,
+// set, set-location, session-set...) instead of sending them, each one
+// pretending to succeed; reads are still sent. See PlannedActions.
+func WithDryRun() Option {
+	return func(ac *TransmissionClient) {
+		ac.dryRun = &dryRun{}
+	}
+}
+
+// DryRun reports whether the client was created WithDryRun
+func (ac *TransmissionClient) DryRun() bool {
+	return ac.dryRun != nil
+}
+
+// PlannedActions returns the calls not sent because of WithDryRun, oldest
+// first, and forgets them
+func (ac *TransmissionClient) PlannedActions() []PlannedAction {
+	if ac.dryRun == nil {
+		return nil
+	}
+	ac.dryRun.mu.Lock()
+	defer ac.dryRun.mu.Unlock()
+	planned := ac.dryRun.planned
+	ac.dryRun.planned = nil
+	return planned
+}
+
+// succeeded reports whether the daemon answered success
+func succeeded(output []byte) bool {
+	var res struct {
+		Result string `json:"result"`
+	}
+	return json.Unmarshal(output, &res) == nil && res.Result == "success"
+}
+
+// send sends the marshalled request body, unless the client is in dry-run
+// mode and the request would change the daemon's state; mutating calls are
+// recorded to the audit sink, if any, and to the undo log
+func (ac *TransmissionClient) send(ctx context.Context, body []byte) ([]byte, error) {
+	if ac.dryRun == nil && ac.audit == nil && ac.undoLog == nil {
+		return ac.apiclient.PostContext(ctx, string(body))
+	}
+
+	var req struct {
+		Method    string          `json:"method"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if !mutatingMethods[req.Method] {
+		return ac.apiclient.PostContext(ctx, string(body))
+	}
+
+	var undo *undoRecord
+	if ac.undoLog != nil && ac.dryRun == nil {
+		var err error
+		if undo, err = ac.undoLog.before(ctx, ac, req.Method, req.Arguments); err != nil {
+			return nil, fmt.Errorf("undo log: reading the values before %s: %w", req.Method, err)
+		}
+	}
+
+	var output []byte
+	var err error
+	if ac.dryRun != nil {
+		log.Printf("dry-run: %s %s", req.Method, req.Arguments)
+		ac.dryRun.mu.Lock()
+		ac.dryRun.planned = append(ac.dryRun.planned, PlannedAction{
+			Method:    req.Method,
+			Arguments: req.Arguments,
+			Time:      time.Now(),
+		})
+		ac.dryRun.mu.Unlock()
+		output = []byte(dryRunResponse)
+	} else {
+		output, err = ac.apiclient.PostContext(ctx, string(body))
+	}
+
+	if undo != nil && err == nil && succeeded(output) {
+		ac.undoLog.add(undo)
+	}
+	if ac.audit != nil {
+		e := auditEntry(ctx, req.Method, req.Arguments)
+		e.DryRun = ac.dryRun != nil
+		e.auditResult(output, err)
+		ac.audit.Audit(e)
+	}
+	return output, err
+}
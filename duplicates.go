@@ -0,0 +1,99 @@
+package transmission
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// duplicateFields are the fields fetched by FindDuplicates
+var duplicateFields = []string{"id", "name", "hashString", "totalSize", "sizeWhenDone",
+	"downloadDir", "trackers"}
+
+// DuplicateKind tells how the torrents of a Duplicate match
+type DuplicateKind int
+
+const (
+	DuplicateHash    DuplicateKind = iota // same infohash on different daemons
+	DuplicateContent                      // same name and size, different infohash, on one daemon
+)
+
+func (k DuplicateKind) String() string {
+	switch k {
+	case DuplicateHash:
+		return "hash"
+	case DuplicateContent:
+		return "content"
+	default:
+		return "unknown"
+	}
+}
+
+// DuplicateTorrent is a torrent of a Duplicate with the daemon holding it
+type DuplicateTorrent struct {
+	Client  *TransmissionClient
+	Torrent *Torrent
+}
+
+// Duplicate is a group of torrents likely holding the same data
+type Duplicate struct {
+	Kind     DuplicateKind
+	Torrents []DuplicateTorrent
+}
+
+// FindDuplicates looks for torrents with the same infohash on ac and the
+// other daemons, and for torrents with the same name and size but different
+// infohashes, e.g. from different trackers, on each daemon. Groups are
+// sorted by name.
+func (ac *TransmissionClient) FindDuplicates(ctx context.Context, others ...*TransmissionClient) ([]Duplicate, error) {
+	clients := append([]*TransmissionClient{ac}, others...)
+	byHash := make(map[string][]DuplicateTorrent)
+	var hashes []string
+	var dups []Duplicate
+
+	for _, c := range clients {
+		cmd := NewGetTorrentsCmd()
+		cmd.Arguments.Fields = duplicateFields
+		out, err := c.ExecuteCommandContext(ctx, cmd)
+		if err != nil {
+			return nil, err
+		}
+		out.Arguments.Torrents.validate()
+
+		type content struct {
+			name string
+			size uint64
+		}
+		byContent := make(map[content][]DuplicateTorrent)
+		var contents []content
+		for _, t := range out.Arguments.Torrents {
+			dt := DuplicateTorrent{Client: c, Torrent: t}
+			hash := strings.ToLower(t.InfoHash)
+			if _, ok := byHash[hash]; !ok {
+				hashes = append(hashes, hash)
+			}
+			byHash[hash] = append(byHash[hash], dt)
+
+			key := content{t.Name, t.TotalSize}
+			if _, ok := byContent[key]; !ok {
+				contents = append(contents, key)
+			}
+			byContent[key] = append(byContent[key], dt)
+		}
+		for _, key := range contents {
+			if len(byContent[key]) > 1 {
+				dups = append(dups, Duplicate{Kind: DuplicateContent, Torrents: byContent[key]})
+			}
+		}
+	}
+	for _, hash := range hashes {
+		if len(byHash[hash]) > 1 {
+			dups = append(dups, Duplicate{Kind: DuplicateHash, Torrents: byHash[hash]})
+		}
+	}
+
+	slices.SortStableFunc(dups, func(a, b Duplicate) int {
+		return strings.Compare(a.Torrents[0].Torrent.Name, b.Torrents[0].Torrent.Name)
+	})
+	return dups, nil
+}
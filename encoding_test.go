@@ -0,0 +1,224 @@
+package transmission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the request encodings")
+
+// recorder is a daemon keeping the bodies of the requests it receives and
+// answering with the canned arguments of their method
+type recorder struct {
+	mu       sync.Mutex
+	requests [][]byte
+}
+
+// recorderAnswers are the arguments answered by method, success with no
+// arguments for the others
+var recorderAnswers = map[string]string{
+	"torrent-get": `{"torrents":[{"id":1,"hashString":"6ec8cd4ebc1c01ab54e3b1ba6f5b4e61beb09b4b","name":"debian.iso","downloadDir":"/downloads/linux","labels":["linux","trash:1697431102:started"],"trackers":[{"id":0,"announce":"http://tracker.example.com/announce","tier":0}]}]}`,
+	"session-get": `{"version":"4.0.5","rpc-version":17,"download-dir":"/downloads","default-trackers":"http://a.example.com/announce\n\nhttp://b.example.com/announce"}`,
+	"torrent-add": `{"torrent-added":{"hashString":"6ec8cd4ebc1c01ab54e3b1ba6f5b4e61beb09b4b","id":1,"name":"debian.iso"}}`,
+	"port-test":   `{"port-is-open":true,"ipProtocol":"ipv6"}`,
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > 0 { // not the session id fetch
+		r.mu.Lock()
+		r.requests = append(r.requests, body)
+		r.mu.Unlock()
+	}
+
+	var cmd struct {
+		Method string `json:"method"`
+	}
+	json.Unmarshal(body, &cmd)
+	args := recorderAnswers[cmd.Method]
+	if args == "" {
+		args = "{}"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"result":"success","arguments":`+args+`}`)
+}
+
+// reset forgets the requests received so far
+func (r *recorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = nil
+}
+
+// encodingCases are the requests sent by the command constructors and the
+// client methods, a golden file each in testdata. The requests don't
+// depend on the RPC version of the daemon, so one file covers them all.
+var encodingCases = []struct {
+	name string
+	run  func(ctx context.Context, c *TransmissionClient) error
+}{
+	{"get-torrents-cmd", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.ExecuteCommandContext(ctx, NewGetTorrentsCmd())
+		return err
+	}},
+	{"add-cmd-by-url", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.ExecuteAddCommandContext(ctx, NewAddCmdByURL("magnet:?xt=urn:btih:6ec8cd4ebc1c01ab54e3b1ba6f5b4e61beb09b4b"))
+		return err
+	}},
+	{"add-cmd-by-filename", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.ExecuteAddCommandContext(ctx, NewAddCmdByFilename("/watch/debian.torrent"))
+		return err
+	}},
+	{"add-cmd-by-bytes", func(ctx context.Context, c *TransmissionClient) error {
+		cmd, err := NewAddCmdByBytes([]byte("d8:announce31:http://tracker.example.com/annee"))
+		if err != nil {
+			return err
+		}
+		cmd.SetDownloadDir("/downloads/linux")
+		cmd.SetPaused(true)
+		_, err = c.ExecuteAddCommandContext(ctx, cmd)
+		return err
+	}},
+	{"add-torrent-options", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.AddTorrent(ctx, NewAddCmdByURL("http://example.com/debian.torrent"), WithLabels("linux", "iso"))
+		return err
+	}},
+	{"get-torrents", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.GetTorrentsContext(ctx)
+		return err
+	}},
+	{"get-torrent", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.GetTorrentContext(ctx, "1")
+		return err
+	}},
+	{"get-stats", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.GetStatsContext(ctx)
+		return err
+	}},
+	{"delete-torrent", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.DeleteTorrent("6ec8cd4ebc1c01ab54e3b1ba6f5b4e61beb09b4b", true)
+		return err
+	}},
+	{"start-stop-verify", func(ctx context.Context, c *TransmissionClient) error {
+		if _, err := c.StartTorrent("1", "2"); err != nil {
+			return err
+		}
+		if _, err := c.StopTorrent("1"); err != nil {
+			return err
+		}
+		_, err := c.VerifyTorrent("1")
+		return err
+	}},
+	{"start-all", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.StartAllContext(ctx)
+		return err
+	}},
+	{"set-location", func(ctx context.Context, c *TransmissionClient) error {
+		return c.SetLocation(ctx, "1", "/downloads/moved", true)
+	}},
+	{"torrent-set", func(ctx context.Context, c *TransmissionClient) error {
+		args := NewTorrentSet("1").
+			DownloadLimit(500).NoUploadLimit().SeedRatio(2).SeedIdleMode(GlobalLimit).
+			Wanted(0).Unwanted(1, 2).FilePriority(PriorityHigh, 0).
+			AddTrackers("http://tracker.example.com/announce").RemoveTrackers(3).ReplaceTracker(0, "https://tracker.example.com/announce").
+			Group("slow").Location("/downloads/linux").PeerLimit(40).QueuePosition(0).SequentialDownload(true).
+			Args()
+		return c.TorrentSet(ctx, args)
+	}},
+	{"torrent-set-clear-labels", func(ctx context.Context, c *TransmissionClient) error {
+		return c.TorrentSet(ctx, NewTorrentSet("1").Labels().Args())
+	}},
+	{"set-bandwidth-priority", func(ctx context.Context, c *TransmissionClient) error {
+		return c.SetBandwidthPriority(ctx, "1", PriorityLow)
+	}},
+	{"set-torrents-chunked", func(ctx context.Context, c *TransmissionClient) error {
+		honors := false
+		_, err := c.SetTorrents(ctx, []string{"1", "2", "3"}, TorrentSetArgs{HonorsSessionLimits: &honors}, ChunkOptions{Size: 2})
+		return err
+	}},
+	{"get-session", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.GetSession(ctx)
+		return err
+	}},
+	{"set-session", func(ctx context.Context, c *TransmissionClient) error {
+		enc, off, ratio := EncryptionRequired, false, 0.0
+		return c.SetSession(ctx, &SessionSetArgs{Encryption: &enc, DHTEnabled: &off, SeedRatioLimit: &ratio})
+	}},
+	{"add-default-trackers", func(ctx context.Context, c *TransmissionClient) error {
+		return c.AddDefaultTrackers(ctx, "http://c.example.com/announce")
+	}},
+	{"port-test", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.PortTest(ctx, IPv6)
+		return err
+	}},
+	{"restore-torrent", func(ctx context.Context, c *TransmissionClient) error {
+		return c.RestoreTorrent(ctx, "1")
+	}},
+	{"stop-group", func(ctx context.Context, c *TransmissionClient) error {
+		_, err := c.StopGroup(ctx, "/downloads/linux")
+		return err
+	}},
+}
+
+// TestRequestEncoding compares the requests of every case with its golden
+// file; go test -run TestRequestEncoding -update rewrites the files
+func TestRequestEncoding(t *testing.T) {
+	rec := &recorder{}
+	daemon := httptest.NewServer(rec)
+	defer daemon.Close()
+
+	for _, tc := range encodingCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(daemon.URL, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec.reset() // the session-get checking the daemon
+			if err := tc.run(context.Background(), c); err != nil {
+				t.Fatal(err)
+			}
+
+			var got bytes.Buffer
+			for i, body := range rec.requests {
+				if i > 0 {
+					got.WriteByte('\n')
+				}
+				if err := json.Indent(&got, body, "", "  "); err != nil {
+					t.Fatalf("request %d isn't JSON: %v: %s", i, err, body)
+				}
+				got.WriteByte('\n')
+			}
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				if err := os.MkdirAll("testdata", 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v, run with -update to create it", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("requests differ from %s:\n--- got\n%s--- want\n%s", golden, got.Bytes(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AuthError is returned when the daemon rejects the client: 401 for wrong
+// credentials, 403 for an address missing from rpc-whitelist or
+// rpc-host-whitelist
+type AuthError struct {
+	StatusCode int
+	Message    string // text of the response body
+}
+
+func (e *AuthError) Error() string {
+	if e.StatusCode == http.StatusForbidden {
+		return fmt.Sprintf("access denied by the daemon whitelist (%d): %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("wrong username or password (%d)", e.StatusCode)
+}
+
+// ConnectionError is returned when the daemon can't be reached: connection
+// refused or reset, unknown host, TLS failure
+type ConnectionError struct {
+	URL string
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("daemon unreachable at %s: %v", e.URL, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error { return e.Err }
+
+// TimeoutError is returned when the daemon doesn't answer in time, within
+// RequestTimeout or the deadline of the context
+type TimeoutError struct {
+	URL string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("daemon at %s timed out: %v", e.URL, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// ProtocolError is returned when the answer is not Transmission RPC: an
+// unexpected HTTP status, or a body that isn't JSON, typically from a proxy
+// or a wrong url
+type ProtocolError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // asked by a 429 response, 0 if not told
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("unexpected answer from the daemon (%d): %s", e.StatusCode, e.Message)
+}
+
+// ResponseTooLargeError is returned when a response is larger than the
+// limit set with ApiClient.SetMaxResponseSize or WithMaxResponseSize
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response from the daemon larger than %d bytes", e.Limit)
+}
+
+// requestError classifies a failure to get a response from rpcURL; a
+// cancelled ctx is returned as is, it isn't the daemon's fault
+func requestError(ctx context.Context, rpcURL string, err error) error {
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		return err
+	}
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err // the url is in the message already
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout() {
+		return &TimeoutError{URL: rpcURL, Err: err}
+	}
+	return &ConnectionError{URL: rpcURL, Err: err}
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// statusError returns the error for a response status other than 200
+func statusError(res *http.Response, body []byte) error {
+	code := res.StatusCode
+	// the daemon answers with a line of HTML
+	msg := strings.Join(strings.Fields(htmlTag.ReplaceAllString(string(body), " ")), " ")
+	if len(msg) > 200 {
+		msg = msg[:200]
+	}
+	if code == http.StatusUnauthorized || code == http.StatusForbidden {
+		return &AuthError{StatusCode: code, Message: msg}
+	}
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+	return &ProtocolError{StatusCode: code, Message: msg, RetryAfter: retryAfter(res.Header, time.Now())}
+}
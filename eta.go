@@ -0,0 +1,85 @@
+package transmission
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultEtaAlpha is the weight of a new rate sample used when
+// NewEtaEstimator is given an alpha out of (0, 1]
+const DefaultEtaAlpha = 0.2
+
+// EtaEstimator keeps an exponentially weighted moving average of each
+// torrent's download rate and derives an ETA from it, which jitters far less
+// than the daemon's instantaneous eta
+type EtaEstimator struct {
+	alpha float64
+
+	mu      sync.Mutex
+	samples map[string]*etaSample
+}
+
+type etaSample struct {
+	rate float64 // smoothed B/s
+	left int64
+}
+
+// NewEtaEstimator returns an estimator giving alpha weight to each new sample
+func NewEtaEstimator(alpha float64) *EtaEstimator {
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultEtaAlpha
+	}
+	return &EtaEstimator{alpha: alpha, samples: make(map[string]*etaSample)}
+}
+
+// Observe feeds the current rate and leftUntilDone of t into the estimator
+func (e *EtaEstimator) Observe(t *Torrent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rate := float64(t.DownloadRate())
+	s, ok := e.samples[t.InfoHash]
+	if !ok {
+		e.samples[t.InfoHash] = &etaSample{rate: rate, left: t.LeftUntilDone}
+		return
+	}
+	s.rate = e.alpha*rate + (1-e.alpha)*s.rate
+	s.left = t.LeftUntilDone
+}
+
+// ETA returns the smoothed time left for the torrent with the given hash;
+// ok is false if it was never observed or isn't downloading
+func (e *EtaEstimator) ETA(hash string) (d time.Duration, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, found := e.samples[hash]
+	switch {
+	case !found || s.left < 0:
+		return 0, false
+	case s.left == 0:
+		return 0, true
+	case s.rate < 1:
+		return 0, false
+	}
+	return time.Duration(float64(s.left) / s.rate * float64(time.Second)), true
+}
+
+// Forget drops the history of the torrent with the given hash
+func (e *EtaEstimator) Forget(hash string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.samples, hash)
+}
+
+// Rate returns the smoothed download rate in B/s of the torrent with the
+// given hash; ok is false if it was never observed
+func (e *EtaEstimator) Rate(hash string) (rate float64, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, found := e.samples[hash]
+	if !found {
+		return 0, false
+	}
+	return s.rate, true
+}
@@ -0,0 +1,117 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ForEachTorrent fetches the torrents with the given fields, or the default
+// ones if fields is nil, and calls fn for each as it is decoded from the
+// response, never holding the whole list in memory. Torrents are in the
+// daemon's order and validated. It stops at the first error of fn and
+// returns it, and when ctx is done, checked between two torrents so that
+// decoding a huge response doesn't outlive its caller.
+func (ac *TransmissionClient) ForEachTorrent(ctx context.Context, fields []string, fn func(*Torrent) error) error {
+	cmd := NewGetTorrentsCmd()
+	if fields != nil {
+		cmd.Arguments.Fields = fields
+	}
+	body, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	r, err := ac.apiclient.PostStream(ctx, string(body))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	result := ""
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "result":
+			if err := dec.Decode(&result); err != nil {
+				return err
+			}
+		case "arguments":
+			if err := decodeTorrentsArg(ctx, dec, fn); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	if result != "success" {
+		return fmt.Errorf("torrent-get: %s", result)
+	}
+	return nil
+}
+
+// decodeTorrentsArg decodes the arguments object, streaming its torrents
+// array to fn until ctx is done
+func decodeTorrentsArg(ctx context.Context, dec *json.Decoder, fn func(*Torrent) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "torrents" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var t *Torrent
+			if err := dec.Decode(&t); err != nil {
+				return err
+			}
+			if t == nil {
+				continue
+			}
+			t.Validate()
+			if err := fn(t); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// expectDelim reads the next token, which must be d
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != d {
+		return errors.New("unexpected " + fmt.Sprint(tok) + ", want " + d.String())
+	}
+	return nil
+}
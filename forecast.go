@@ -0,0 +1,135 @@
+package transmission
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// TorrentForecast is the outlook of one torrent of a Forecast
+type TorrentForecast struct {
+	Hash string
+	Name string
+	Left int64         // bytes still to download
+	Rate float64       // B/s, smoothed when an EtaEstimator observed it
+	ETA  time.Duration // at Rate, when not Stalled
+	// Stalled is set for a torrent left with nothing to download it
+	// from: stopped, erroring, or with no transfer rate
+	Stalled bool
+}
+
+// Forecast predicts when a group of torrents completes and what bandwidth
+// it needs, e.g. to decide which group the scheduler favours
+type Forecast struct {
+	At       time.Time
+	Torrents []TorrentForecast // incomplete ones, soonest done first, stalled last
+	Left     int64             // bytes still to download, stalled torrents included
+	Rate     float64           // B/s, sum of the rates
+
+	// Shared is the time to download Left at Rate, if the bandwidth freed
+	// by finished torrents goes to the others, as when the link is the
+	// bottleneck; Slowest the time until the last torrent finishes at its
+	// own rate, as when the peers are. Both are only meaningful when no
+	// torrent is stalled.
+	Shared  time.Duration
+	Slowest time.Duration
+}
+
+// Stalled returns the number of stalled torrents, which keep the group
+// from ever completing at the current rates
+func (f *Forecast) Stalled() int {
+	n := 0
+	for _, t := range f.Torrents {
+		if t.Stalled {
+			n++
+		}
+	}
+	return n
+}
+
+// Done returns the predicted completion time of the group, taking the
+// shared estimate; ok is false when a torrent is stalled
+func (f *Forecast) Done() (at time.Time, ok bool) {
+	if f.Stalled() > 0 {
+		return time.Time{}, false
+	}
+	return f.At.Add(f.Shared), true
+}
+
+// RateFor returns the download rate in B/s the group needs to complete
+// within d
+func (f *Forecast) RateFor(d time.Duration) float64 {
+	if f.Left == 0 {
+		return 0
+	}
+	if d <= 0 {
+		return float64(f.Left)
+	}
+	return float64(f.Left) / d.Seconds()
+}
+
+// NewForecast predicts the completion of torrents at now. Their rates are
+// the smoothed ones of est when it observed them; est may be nil. Needs
+// the hashString, name, status, error, leftUntilDone and rateDownload
+// fields.
+func NewForecast(torrents []*Torrent, est *EtaEstimator, now time.Time) *Forecast {
+	f := &Forecast{At: now}
+	for _, t := range torrents {
+		left, ok := t.BytesLeft()
+		if !ok || left == 0 {
+			continue
+		}
+		tf := TorrentForecast{Hash: t.InfoHash, Name: t.Name, Left: int64(left), Rate: float64(t.DownloadRate())}
+		if est != nil {
+			if rate, ok := est.Rate(t.InfoHash); ok {
+				tf.Rate = rate
+			}
+		}
+		if t.Status == TrStopped || t.Error == 3 || tf.Rate < 1 { // 3, local error
+			tf.Stalled = true
+			tf.Rate = 0
+		} else {
+			tf.ETA = time.Duration(float64(tf.Left) / tf.Rate * float64(time.Second))
+			f.Slowest = max(f.Slowest, tf.ETA)
+		}
+		f.Left += tf.Left
+		f.Rate += tf.Rate
+		f.Torrents = append(f.Torrents, tf)
+	}
+	if f.Rate >= 1 {
+		f.Shared = time.Duration(float64(f.Left) / f.Rate * float64(time.Second))
+	}
+	slices.SortStableFunc(f.Torrents, func(a, b TorrentForecast) int {
+		switch {
+		case a.Stalled != b.Stalled:
+			if a.Stalled {
+				return 1
+			}
+			return -1
+		case a.ETA < b.ETA:
+			return -1
+		case a.ETA > b.ETA:
+			return 1
+		}
+		return 0
+	})
+	return f
+}
+
+// forecastFields are the torrent fields NewForecast and Query need
+var forecastFields = []string{"id", "name", "hashString", "status", "error", "errorString",
+	"leftUntilDone", "rateDownload", "labels", "trackers", "isStalled", "isPrivate",
+	"percentDone", "sizeWhenDone", "uploadRatio", "activityDate", "addedDate"}
+
+// Forecast predicts the completion of the torrents q selects, e.g.
+// {"label": "tv"}; est may be nil
+func (ac *TransmissionClient) Forecast(ctx context.Context, q *Query, est *EtaEstimator) (*Forecast, error) {
+	cmd := NewGetTorrentsCmd()
+	cmd.Arguments.Fields = forecastFields
+	out, err := ac.ExecuteCommandContext(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return NewForecast(out.Arguments.Torrents.Filter(q, now), est, now), nil
+}
@@ -0,0 +1,137 @@
+package transmission
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// The seeds are parts of the responses of a Transmission 4.0 daemon, plus
+// broken answers seen from proxies and older daemons. Each response is cut
+// into small pieces: the fuzzer minimizes every interesting input, which
+// takes minutes for a response of some kilobytes.
+
+// seedTorrents are torrents of a torrent-get response, a field group each
+var seedTorrents = []string{
+	`{"id":1,"name":"debian-12.2.0-amd64-DVD-1.iso","hashString":"6ec8cd4ebc1c01ab54e3b1ba6f5b4e61beb09b4b","status":6,"addedDate":1697431102,"doneDate":1697433380,"activityDate":1697521301,"downloadDir":"/downloads/complete","labels":["linux","iso"],"error":0,"errorString":""}`,
+	`{"id":1,"leftUntilDone":0,"sizeWhenDone":1488977920,"totalSize":1488977920,"haveValid":1488977920,"haveUnchecked":0,"percentDone":1,"percentComplete":1,"eta":-1,"etaIdle":-1,"rateDownload":0,"rateUpload":65536,"uploadRatio":3.4081,"downloadedEver":1488977920,"uploadedEver":5074634752}`,
+	`{"id":1,"seedIdleLimit":30,"seedIdleMode":0,"seedRatioLimit":2,"seedRatioMode":0,"bandwidthPriority":0,"honorsSessionLimits":true,"queuePosition":0,"secondsDownloading":2278,"secondsSeeding":88121}`,
+	`{"id":1,"files":[{"bytesCompleted":1488977920,"length":1488977920,"name":"debian-12.2.0-amd64-DVD-1.iso"}],"wanted":[1],"priorities":[0]}`,
+	`{"id":1,"peers":[{"address":"203.0.113.7","clientName":"qBittorrent 4.5.5","flagStr":"UEI","isEncrypted":true,"isIncoming":true,"isUTP":true,"port":51413,"progress":0.42,"rateToClient":0,"rateToPeer":65536}]}`,
+	`{"id":1,"trackers":[{"announce":"http://bttracker.debian.org:6969/announce","id":0,"scrape":"http://bttracker.debian.org:6969/scrape","sitename":"debian","tier":0}],"trackerStats":[{"announce":"http://bttracker.debian.org:6969/announce","host":"http://bttracker.debian.org:6969","id":0,"lastAnnounceResult":"Success","lastAnnounceSucceeded":true,"leecherCount":12,"seederCount":460,"tier":0}]}`,
+	`{"id":2,"name":"a1b2c3d4e5f60718293a4b5c6d7e8f9012345678","status":4,"eta":-2,"metadataPercentComplete":0.25,"sizeWhenDone":0,"totalSize":0,"files":[],"wanted":[],"uploadRatio":-1}`,
+}
+
+// seedResponses are whole responses, small enough
+var seedResponses = []string{
+	`{"arguments":{"torrent-added":{"hashString":"6ec8cd4ebc1c01ab54e3b1ba6f5b4e61beb09b4b","id":1,"name":"debian-12.2.0-amd64-DVD-1.iso"}},"result":"success"}`,
+	`{"arguments":{"activeTorrentCount":1,"cumulative-stats":{"downloadedBytes":1488977920,"filesAdded":1,"secondsActive":90399,"sessionCount":3,"uploadedBytes":5074634752},"downloadSpeed":0,"pausedTorrentCount":0,"torrentCount":2,"uploadSpeed":65536},"result":"success"}`,
+}
+
+// seedSessions are parts of a session-get response
+var seedSessions = []string{
+	`{"version":"4.0.5 (a6fe2a64aa)","rpc-version":17,"rpc-version-minimum":14,"config-dir":"/config","download-dir":"/downloads/complete","incomplete-dir":"/downloads/incomplete","incomplete-dir-enabled":true,"cache-size-mb":4}`,
+	`{"speed-limit-down":100,"speed-limit-down-enabled":false,"speed-limit-up":100,"speed-limit-up-enabled":false,"alt-speed-down":50,"alt-speed-enabled":false,"alt-speed-time-begin":540,"alt-speed-time-day":127,"alt-speed-time-end":1020}`,
+	`{"peer-port":51413,"peer-limit-global":200,"peer-limit-per-torrent":50,"encryption":"preferred","dht-enabled":true,"pex-enabled":true,"lpd-enabled":false,"utp-enabled":true,"blocklist-size":0}`,
+	`{"seedRatioLimit":2,"seedRatioLimited":true,"idle-seeding-limit":30,"idle-seeding-limit-enabled":true,"download-queue-size":5,"seed-queue-size":10,"queue-stalled-minutes":30,"default-trackers":""}`,
+}
+
+// seedBroken are answers that must fail or decode to something sane
+var seedBroken = []string{
+	`{"arguments":{"torrents":[null,{"id":3}]},"result":"success"}`,
+	`{"arguments":{"torrents":null},"result":"success"}`,
+	`{"arguments":{"torrents":[{"id":1,"percentDone":1.7,"eta":-9,"uploadRatio":-5,"leftUntilDone":-1,"sizeWhenDone":10,"files":[{"bytesCompleted":-4,"length":-1,"name":"x"}],"wanted":[true,0,1]}]},"result":"success"}`,
+	`{"arguments":{},"result":"no method name"}`,
+	`<html><body>502 Bad Gateway</body></html>`,
+	`{"result":"success","arguments":{"torrents":[{"seedRatioMode":"Override","bandwidthPriority":"high","status":6}]}}`,
+}
+
+func addSeeds(f *testing.F, seeds ...string) {
+	for _, s := range seeds {
+		f.Add([]byte(s))
+	}
+}
+
+// exercise runs the helpers reading every part of a torrent, which must
+// not panic on anything Validate lets through
+func exercise(t *Torrent, session *SessionSettings) {
+	t.Validate()
+	t.GetPercent()
+	t.Ratio()
+	t.ETA()
+	t.TimeLeft()
+	t.IdleTimeLeft()
+	t.BytesLeft()
+	t.Have()
+	t.GetTrackers()
+	t.PeerBreakdown()
+	t.WantedBytes()
+	t.UnwantedBytes()
+	t.IsCompleted()
+	_ = t.Status.String()
+	for _, file := range t.Files {
+		file.PercentDone()
+	}
+	t.EffectiveSeedRatioLimit(session)
+	t.EffectiveIdleLimit(session)
+	t.SeedingTimeRemaining(session)
+}
+
+func FuzzUnmarshalCommand(f *testing.F) {
+	for _, tor := range seedTorrents {
+		addSeeds(f, `{"arguments":{"torrents":[`+tor+`]},"result":"success"}`)
+	}
+	addSeeds(f, seedResponses...)
+	addSeeds(f, seedBroken...)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var cmd Command
+		if json.Unmarshal(data, &cmd) != nil {
+			return
+		}
+		for _, tor := range cmd.Arguments.Torrents {
+			if tor == nil {
+				t.Fatal("nil torrent in the decoded list")
+			}
+			exercise(tor, &SessionSettings{})
+		}
+		cmd.Arguments.Torrents.Sort(ByID)
+		cmd.Arguments.Torrents.GetIDs()
+		if _, err := json.Marshal(cmd); err != nil {
+			t.Fatalf("decoded command doesn't encode: %v", err)
+		}
+	})
+}
+
+func FuzzUnmarshalTorrent(f *testing.F) {
+	addSeeds(f, seedTorrents...)
+	f.Add([]byte(`{"id":1,"percentDone":"NaN","files":[{"length":5}],"wanted":[1,1]}`))
+	f.Add([]byte(`{"status":99,"seedRatioMode":7,"seedIdleMode":1,"seedIdleLimit":-3}`))
+	f.Add([]byte(`{"sizeWhenDone":-1,"totalSize":-4096,"haveValid":-1,"uploadedEver":18446744073709551615,"leftUntilDone":7}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var tor Torrent
+		if json.Unmarshal(data, &tor) != nil {
+			return
+		}
+		session := &SessionSettings{SeedRatioLimit: 2, SeedRatioLimited: true, IdleSeedingLimit: 30, IdleSeedingLimitEnabled: true}
+		exercise(&tor, session)
+		if err := tor.Validate(); err != nil {
+			t.Fatalf("Validate doesn't normalize in one pass: %v", err)
+		}
+	})
+}
+
+func FuzzUnmarshalSession(f *testing.F) {
+	addSeeds(f, seedSessions...)
+	f.Add([]byte(`{"version":"2.94 (d8e60ee44f)","rpc-version":15,"encryption":"required","alt-speed-time-day":-1}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var session SessionSettings
+		if json.Unmarshal(data, &session) != nil {
+			return
+		}
+		var tor Torrent
+		json.Unmarshal([]byte(`{"status":6,"seedRatioMode":0,"seedIdleMode":0,"uploadedEver":10,"downloadedEver":5,"rateUpload":1}`), &tor)
+		exercise(&tor, &session)
+		if _, err := json.Marshal(session); err != nil {
+			t.Fatalf("decoded session doesn't encode: %v", err)
+		}
+	})
+}
@@ -0,0 +1,3 @@
+module github.com/unix2dos/go-transmission/v2
+
+go 1.22
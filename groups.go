@@ -0,0 +1,136 @@
+package transmission
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// groupFields are the torrent fields a TorrentGroup needs
+var groupFields = []string{"id", "hashString", "name", "status", "downloadDir",
+	"sizeWhenDone", "leftUntilDone", "percentDone", "rateDownload", "eta"}
+
+// TorrentGroup is the torrents whose downloadDir is Dir or one of its
+// subdirectories, e.g. the seasons of a TV show under /downloads/tv/Show.
+// Prefixes only match whole path elements.
+type TorrentGroup struct {
+	Dir      string
+	Torrents Torrents
+}
+
+// GroupProgress is the aggregate progress of a TorrentGroup
+type GroupProgress struct {
+	Torrents     int
+	Completed    int    // torrents with percentDone 1
+	SizeWhenDone uint64 // bytes wanted by the group
+	Left         uint64 // bytes left to download
+	Rate         uint64 // aggregate download rate in B/s
+}
+
+// PercentDone returns the share of the wanted bytes downloaded, 0...1; a
+// group with nothing wanted is done
+func (p GroupProgress) PercentDone() float64 {
+	if p.SizeWhenDone == 0 {
+		return 1
+	}
+	return float64(p.SizeWhenDone-min(p.Left, p.SizeWhenDone)) / float64(p.SizeWhenDone)
+}
+
+// inGroup reports whether downloadDir is dir or under it
+func inGroup(downloadDir, dir string) bool {
+	return hasPathPrefix(path.Clean(downloadDir), path.Clean(dir), "/")
+}
+
+// GroupByFolder returns the group of the torrents under dir
+func GroupByFolder(torrents Torrents, dir string) *TorrentGroup {
+	g := &TorrentGroup{Dir: dir}
+	for _, t := range torrents {
+		if inGroup(t.DownloadDir, dir) {
+			g.Torrents = append(g.Torrents, t)
+		}
+	}
+	return g
+}
+
+// GroupsUnder returns a group per direct subdirectory of root holding
+// torrents, keyed by that subdirectory, e.g. a group per show for
+// /downloads/tv. Torrents right in root belong to no group.
+func GroupsUnder(torrents Torrents, root string) map[string]*TorrentGroup {
+	root = path.Clean(root)
+	groups := make(map[string]*TorrentGroup)
+	for _, t := range torrents {
+		dir := path.Clean(t.DownloadDir)
+		if dir == root || !inGroup(dir, root) {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(dir, root), "/"), "/")
+		key := path.Join(root, name)
+		if groups[key] == nil {
+			groups[key] = &TorrentGroup{Dir: key}
+		}
+		groups[key].Torrents = append(groups[key].Torrents, t)
+	}
+	return groups
+}
+
+// Progress returns the aggregate progress of the group
+func (g *TorrentGroup) Progress() GroupProgress {
+	p := GroupProgress{Torrents: len(g.Torrents)}
+	for _, t := range g.Torrents {
+		if t.IsCompleted() {
+			p.Completed++
+		}
+		left, _ := t.BytesLeft()
+		p.SizeWhenDone += t.SizeWhenDone
+		p.Left += left
+		p.Rate += t.DownloadRate()
+	}
+	return p
+}
+
+// IsCompleted reports whether the group has torrents, all of them
+// complete
+func (g *TorrentGroup) IsCompleted() bool {
+	p := g.Progress()
+	return p.Torrents > 0 && p.Completed == p.Torrents
+}
+
+// TorrentGroup fetches the torrents under dir
+func (ac *TransmissionClient) TorrentGroup(ctx context.Context, dir string) (*TorrentGroup, error) {
+	g := &TorrentGroup{Dir: dir}
+	err := ac.ForEachTorrent(ctx, groupFields, func(t *Torrent) error {
+		if inGroup(t.DownloadDir, dir) {
+			g.Torrents = append(g.Torrents, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// StartGroup starts the torrents under dir
+func (ac *TransmissionClient) StartGroup(ctx context.Context, dir string) (*BulkResult, error) {
+	return ac.groupAction(ctx, "torrent-start", dir)
+}
+
+// StopGroup stops the torrents under dir
+func (ac *TransmissionClient) StopGroup(ctx context.Context, dir string) (*BulkResult, error) {
+	return ac.groupAction(ctx, "torrent-stop", dir)
+}
+
+// groupAction sends method for the torrents under dir, like allAction
+func (ac *TransmissionClient) groupAction(ctx context.Context, method, dir string) (*BulkResult, error) {
+	var ids []string
+	err := ac.ForEachTorrent(ctx, []string{"hashString", "downloadDir"}, func(t *Torrent) error {
+		if inGroup(t.DownloadDir, dir) {
+			ids = append(ids, t.InfoHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ac.chunkedAction(ctx, method, ids)
+}
@@ -0,0 +1,50 @@
+package transmission
+
+import "math"
+
+// HealthScore rates the torrent from 0 (dead) to 100 (healthy), for UIs to
+// color-code torrents consistently:
+//
+//	availability = 1 when the data is complete, else
+//	               desiredAvailable / leftUntilDone, capped to 1
+//	swarm        = min(seeders, 10) / 10, with seeders the highest
+//	               seederCount of trackerStats; 0.5 when no tracker knows
+//	score        = 60 × availability + 40 × swarm
+//	               - 25 when stalled and incomplete
+//	               - 10 on a tracker warning, - 30 on a tracker error
+//	score        = 0 on a local error
+//
+// The result is rounded and clamped to 0...100. It needs the
+// "trackerStats", "desiredAvailable", "leftUntilDone", "percentDone",
+// "isStalled" and "error" fields.
+func (t *Torrent) HealthScore() int {
+	if t.Error == 3 {
+		return 0
+	}
+
+	availability := 1.0
+	if !t.IsCompleted() && t.LeftUntilDone > 0 {
+		availability = math.Min(float64(max(t.DesiredAvailable, 0))/float64(t.LeftUntilDone), 1)
+	}
+
+	swarm := 0.5
+	seeders := -1
+	for _, ts := range t.TrackerStats {
+		seeders = max(seeders, ts.SeederCount)
+	}
+	if seeders >= 0 {
+		swarm = float64(min(seeders, 10)) / 10
+	}
+
+	score := 60*availability + 40*swarm
+	if t.IsStalled && !t.IsCompleted() {
+		score -= 25
+	}
+	switch t.Error {
+	case 1:
+		score -= 10
+	case 2:
+		score -= 30
+	}
+	return int(math.Round(math.Max(0, math.Min(100, score))))
+}
@@ -0,0 +1,116 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthOption configures the checks of HealthHandler
+type HealthOption func(*healthConfig)
+
+type healthConfig struct {
+	minFreeSpace int64
+	maxErrored   int
+	timeout      time.Duration
+}
+
+// WithMinFreeSpace makes the daemon unhealthy when its download dir has
+// less than bytes free
+func WithMinFreeSpace(bytes int64) HealthOption {
+	return func(c *healthConfig) {
+		c.minFreeSpace = bytes
+	}
+}
+
+// WithMaxErrored makes the daemon unhealthy when more than n torrents are
+// in error; by default errored torrents are only reported
+func WithMaxErrored(n int) HealthOption {
+	return func(c *healthConfig) {
+		c.maxErrored = n
+	}
+}
+
+// WithHealthTimeout bounds the time of a check, 5s by default
+func WithHealthTimeout(d time.Duration) HealthOption {
+	return func(c *healthConfig) {
+		c.timeout = d
+	}
+}
+
+// Health is the result of a health check, the body of HealthHandler's
+// responses
+type Health struct {
+	Healthy    bool     `json:"healthy"`
+	Reachable  bool     `json:"reachable"`
+	Version    string   `json:"version,omitempty"`
+	RPCVersion int      `json:"rpcVersion,omitempty"`
+	Torrents   int      `json:"torrents"`
+	Errored    int      `json:"errored"`
+	FreeSpace  *int64   `json:"freeSpace,omitempty"` // bytes free in the download dir, if the daemon tells
+	Problems   []string `json:"problems,omitempty"`  // why the daemon is unhealthy
+}
+
+// CheckHealth reports whether the daemon answers and passes the checks of
+// opts
+func (ac *TransmissionClient) CheckHealth(ctx context.Context, opts ...HealthOption) *Health {
+	c := healthConfig{maxErrored: -1, timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	h := &Health{}
+	session, err := ac.GetSession(ctx)
+	if err != nil {
+		h.Problems = append(h.Problems, err.Error())
+		return h
+	}
+	h.Reachable = true
+	h.Version, h.RPCVersion = session.Version, session.RPCVersion
+
+	summary, err := ac.Summary(ctx)
+	if err != nil {
+		h.Problems = append(h.Problems, "listing torrents: "+err.Error())
+	} else {
+		h.Torrents, h.Errored = summary.Torrents, summary.Errored()
+		if c.maxErrored >= 0 && h.Errored > c.maxErrored {
+			h.Problems = append(h.Problems, fmt.Sprintf("%d torrents in error, at most %d allowed", h.Errored, c.maxErrored))
+		}
+	}
+
+	var free struct {
+		SizeBytes int64 `json:"size-bytes"`
+	}
+	err = ac.rpc(ctx, "free-space", map[string]string{"path": session.DownloadDir}, &free)
+	switch {
+	case err == nil:
+		h.FreeSpace = &free.SizeBytes
+		if c.minFreeSpace > 0 && free.SizeBytes < c.minFreeSpace {
+			h.Problems = append(h.Problems, fmt.Sprintf("%d bytes free in %s, %d required", free.SizeBytes, session.DownloadDir, c.minFreeSpace))
+		}
+	case c.minFreeSpace > 0:
+		h.Problems = append(h.Problems, "free space unknown: "+err.Error())
+	}
+
+	h.Healthy = len(h.Problems) == 0
+	return h
+}
+
+// HealthHandler returns a handler for liveness and readiness probes, e.g.
+// of Kubernetes: it answers 200 when CheckHealth finds the daemon healthy,
+// 503 otherwise, with the Health as JSON
+func (ac *TransmissionClient) HealthHandler(opts ...HealthOption) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := ac.CheckHealth(r.Context(), opts...)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,120 @@
+// Package indexer defines how torrent search engines plug into this
+// library and an Orchestrator going from a query to a torrent downloading
+// in Transmission.
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+var ErrNoSource = errors.New("indexer: result has neither magnet nor url")
+
+// Result is a search candidate
+type Result struct {
+	Indexer     string // name of the indexer that found it
+	Title       string
+	InfoHash    string // hex, lowercase, if known
+	Magnet      string
+	URL         string // link to the .torrent file
+	Size        int64
+	Seeders     int
+	Leechers    int
+	PublishDate time.Time
+}
+
+// source returns what to hand to torrent-add, preferring the magnet
+func (r Result) source() string {
+	if r.Magnet != "" {
+		return r.Magnet
+	}
+	return r.URL
+}
+
+// Indexer is a torrent search engine
+type Indexer interface {
+	Name() string
+	Search(ctx context.Context, query string) ([]Result, error)
+}
+
+// Orchestrator searches several indexers and adds the chosen result
+type Orchestrator struct {
+	Client   *transmission.TransmissionClient
+	Indexers []Indexer
+}
+
+// Search queries all the indexers concurrently and returns their results
+// merged, without duplicate info hashes, most seeded first. Indexers
+// failing don't prevent the others' results from being returned, along
+// with an error describing the failures.
+func (o *Orchestrator) Search(ctx context.Context, query string) ([]Result, error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []Result
+		errs    []error
+	)
+	for _, idx := range o.Indexers {
+		wg.Add(1)
+		go func(idx Indexer) {
+			defer wg.Done()
+			res, err := idx.Search(ctx, query)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", idx.Name(), err))
+				return
+			}
+			results = append(results, res...)
+		}(idx)
+	}
+	wg.Wait()
+
+	slices.SortStableFunc(results, func(a, b Result) int { return b.Seeders - a.Seeders })
+	seen := make(map[string]bool)
+	merged := results[:0]
+	for _, r := range results {
+		hash := strings.ToLower(r.InfoHash)
+		if hash != "" && seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		merged = append(merged, r)
+	}
+	return merged, errors.Join(errs...)
+}
+
+// Add adds the result to the daemon, with its data in downloadDir if not
+// empty. With a Client created WithTorrentCache, .torrent urls polled
+// again are revalidated rather than downloaded.
+func (o *Orchestrator) Add(ctx context.Context, r Result, downloadDir string) (transmission.TorrentAdded, error) {
+	src := r.source()
+	if src == "" {
+		return transmission.TorrentAdded{}, ErrNoSource
+	}
+	cmd := transmission.NewAddCmdByURL(src)
+	cmd.SetDownloadDir(downloadDir)
+	return o.Client.ExecuteAddCommandContext(ctx, cmd)
+}
+
+// SearchAndAdd searches and adds the result picked by choose, which reports
+// false to add nothing
+func (o *Orchestrator) SearchAndAdd(ctx context.Context, query string, downloadDir string, choose func([]Result) (Result, bool)) (transmission.TorrentAdded, bool, error) {
+	results, err := o.Search(ctx, query)
+	if len(results) == 0 {
+		return transmission.TorrentAdded{}, false, err
+	}
+	r, ok := choose(results)
+	if !ok {
+		return transmission.TorrentAdded{}, false, nil
+	}
+	added, err := o.Add(ctx, r, downloadDir)
+	return added, err == nil, err
+}
@@ -0,0 +1,156 @@
+package indexer
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Torznab searches a Torznab API, as served by Jackett and Prowlarr
+type Torznab struct {
+	name       string
+	endpoint   string
+	apiKey     string
+	Categories []int // restrict searches to these categories, if any
+	Client     *http.Client
+}
+
+// NewTorznab returns an indexer named name querying endpoint, the url of
+// the API up to and including "/api", e.g.
+// http://localhost:9117/api/v2.0/indexers/all/results/torznab/api
+func NewTorznab(name, endpoint, apiKey string) *Torznab {
+	return &Torznab{name: name, endpoint: endpoint, apiKey: apiKey, Client: http.DefaultClient}
+}
+
+func (t *Torznab) Name() string {
+	return t.name
+}
+
+// Search runs a t=search query
+func (t *Torznab) Search(ctx context.Context, query string) ([]Result, error) {
+	u, err := url.Parse(t.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("t", "search")
+	q.Set("q", query)
+	q.Set("apikey", t.apiKey)
+	if len(t.Categories) > 0 {
+		cats := make([]string, 0, len(t.Categories))
+		for _, c := range t.Categories {
+			cats = append(cats, strconv.Itoa(c))
+		}
+		q.Set("cat", strings.Join(cats, ","))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.Client.Do(req)
+	if err != nil {
+		return nil, withoutQuery(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("torznab: %s", res.Status)
+	}
+	return ParseTorznab(res.Body, t.name)
+}
+
+// withoutQuery removes the query, holding the api key, from the url of a
+// request error, which would otherwise end in logs
+func withoutQuery(err error) error {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return err
+	}
+	if u, perr := url.Parse(ue.URL); perr == nil {
+		u.RawQuery, u.Fragment = "", ""
+		ue.URL = u.String()
+	} else {
+		ue.URL = "" // unparseable, can't tell where the key is
+	}
+	return err
+}
+
+type torznabFeed struct {
+	XMLName xml.Name
+	Code    string        `xml:"code,attr"`        // <error code="" description="">
+	Desc    string        `xml:"description,attr"` // <error code="" description="">
+	Items   []torznabItem `xml:"channel>item"`
+}
+
+type torznabItem struct {
+	Title     string `xml:"title"`
+	Link      string `xml:"link"`
+	Size      int64  `xml:"size"`
+	PubDate   string `xml:"pubDate"`
+	Enclosure struct {
+		URL string `xml:"url,attr"`
+	} `xml:"enclosure"`
+	Attrs []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"attr"`
+}
+
+// ParseTorznab parses a Torznab search response, naming the results'
+// indexer after name
+func ParseTorznab(r io.Reader, name string) ([]Result, error) {
+	var feed torznabFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, err
+	}
+	if feed.XMLName.Local == "error" {
+		return nil, fmt.Errorf("torznab: error %s: %s", feed.Code, feed.Desc)
+	}
+
+	results := make([]Result, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		res := Result{Indexer: name, Title: item.Title, Size: item.Size}
+		link := item.Enclosure.URL
+		if link == "" {
+			link = item.Link
+		}
+		if strings.HasPrefix(link, "magnet:") {
+			res.Magnet = link
+		} else {
+			res.URL = link
+		}
+		if d, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
+			res.PublishDate = d
+		}
+		peers := -1
+		for _, a := range item.Attrs {
+			switch a.Name {
+			case "seeders":
+				res.Seeders, _ = strconv.Atoi(a.Value)
+			case "peers":
+				peers, _ = strconv.Atoi(a.Value)
+			case "infohash":
+				res.InfoHash = strings.ToLower(a.Value)
+			case "magneturl":
+				res.Magnet = a.Value
+			case "size":
+				if res.Size == 0 {
+					res.Size, _ = strconv.ParseInt(a.Value, 10, 64)
+				}
+			}
+		}
+		if peers >= res.Seeders {
+			res.Leechers = peers - res.Seeders
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
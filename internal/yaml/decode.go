@@ -0,0 +1,380 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Unmarshal decodes YAML into v, through its JSON conversion, so v uses
+// json struct tags
+func Unmarshal(data []byte, v interface{}) error {
+	b, err := ToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ToJSON converts a YAML document to JSON, keeping the order of keys. An
+// empty document is null.
+func ToJSON(data []byte) ([]byte, error) {
+	p := &parser{lines: splitLines(data)}
+	n := &node{}
+	if len(p.lines) > 0 {
+		var err error
+		if n, err = p.block(p.lines[0].indent); err != nil {
+			return nil, err
+		}
+		if p.i < len(p.lines) {
+			return nil, p.errorf("unexpected %q", p.lines[p.i].text)
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// line is a non-empty line of YAML with its comment removed
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+func splitLines(data []byte) []line {
+	var lines []line
+	for i, s := range strings.Split(string(data), "\n") {
+		s = strings.TrimRight(stripComment(s), " \t\r")
+		text := strings.TrimLeft(s, " ")
+		if text == "" || text == "---" {
+			continue
+		}
+		lines = append(lines, line{num: i + 1, indent: len(s) - len(text), text: text})
+	}
+	return lines
+}
+
+// stripComment removes a "#" comment that is not inside quotes
+func stripComment(s string) string {
+	var q byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case q != 0:
+			if c == '\\' && q == '"' {
+				i++
+			} else if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'':
+			q = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+type parser struct {
+	lines []line
+	i     int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	num := 0
+	if p.i < len(p.lines) {
+		num = p.lines[p.i].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+	return fmt.Errorf("yaml: line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// block parses the mapping, sequence or lone scalar starting at the
+// current line, whose entries are at indent
+func (p *parser) block(indent int) (*node, error) {
+	text := p.lines[p.i].text
+	switch {
+	case isItem(text):
+		return p.sequence(indent)
+	case isKey(text):
+		return p.mapping(indent)
+	default:
+		n, err := p.scalar(text)
+		p.i++
+		return n, err
+	}
+}
+
+func (p *parser) sequence(indent int) (*node, error) {
+	n := &node{kind: '['}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isItem(p.lines[p.i].text) {
+		l := &p.lines[p.i]
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var v *node
+		var err error
+		switch {
+		case rest == "":
+			v, err = p.nested(indent, false)
+		case isKey(rest):
+			// "- key: value" starts a mapping indented like the key
+			l.indent += len(l.text) - len(rest)
+			l.text = rest
+			v, err = p.mapping(l.indent)
+		default:
+			v, err = p.scalar(rest)
+			p.i++
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.values = append(n.values, v)
+	}
+	return n, nil
+}
+
+func (p *parser) mapping(indent int) (*node, error) {
+	n := &node{kind: '{'}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		text := p.lines[p.i].text
+		if isItem(text) {
+			return nil, p.errorf("sequence item in a mapping")
+		}
+		key, rest, err := splitKey(text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		for _, k := range n.keys {
+			if k == key {
+				return nil, p.errorf("duplicate key %q", key)
+			}
+		}
+		var v *node
+		if rest == "" {
+			// a sequence may be at the indentation of its key
+			v, err = p.nested(indent, true)
+		} else {
+			v, err = p.scalar(rest)
+			p.i++
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, key)
+		n.values = append(n.values, v)
+	}
+	if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return n, nil
+}
+
+// nested parses the block following a line ending with ":" or "-", null if
+// there is none
+func (p *parser) nested(indent int, sameLevelItems bool) (*node, error) {
+	p.i++
+	if p.i < len(p.lines) {
+		next := p.lines[p.i]
+		if next.indent > indent || sameLevelItems && next.indent == indent && isItem(next.text) {
+			return p.block(next.indent)
+		}
+	}
+	return &node{}, nil
+}
+
+// scalar parses a scalar or a flow collection of scalars
+func (p *parser) scalar(s string) (*node, error) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		return p.flow(s)
+	}
+	v, err := parseScalar(s)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return &node{scalar: v}, nil
+}
+
+// flow parses "[a, b]" or "{k: v}" holding scalars only
+func (p *parser) flow(s string) (*node, error) {
+	open, closing := s[0], map[byte]byte{'[': ']', '{': '}'}[s[0]]
+	if s[len(s)-1] != closing {
+		return nil, p.errorf("unterminated flow collection")
+	}
+	n := &node{kind: open}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" {
+		return n, nil
+	}
+	items, err := splitFlow(inner)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	for _, item := range items {
+		if open == '{' {
+			key, rest, err := splitKey(item)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			n.keys = append(n.keys, key)
+			item = rest
+		}
+		v, err := parseScalar(item)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		n.values = append(n.values, &node{scalar: v})
+	}
+	return n, nil
+}
+
+// splitFlow splits the items of a flow collection on the commas outside
+// quotes
+func splitFlow(s string) ([]string, error) {
+	var items []string
+	var q byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case q != 0:
+			if c == '\\' && q == '"' {
+				i++
+			} else if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'':
+			q = c
+		case c == '[' || c == '{':
+			return nil, fmt.Errorf("nested flow collections are not supported")
+		case c == ',':
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items, nil
+}
+
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isKey(text string) bool {
+	_, _, err := splitKey(text)
+	return err == nil
+}
+
+// splitKey splits "key: value" into the unquoted key and the value
+func splitKey(text string) (key, rest string, err error) {
+	if text == "" {
+		return "", "", fmt.Errorf("expected \"key: value\"")
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		k, err := parseScalar(text[:end+1])
+		if err != nil {
+			return "", "", err
+		}
+		rest, ok := strings.CutPrefix(text[end+1:], ":")
+		if !ok || rest != "" && rest[0] != ' ' {
+			return "", "", fmt.Errorf("missing \":\" after key")
+		}
+		return k.(string), strings.TrimSpace(rest), nil
+	}
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", fmt.Errorf("expected \"key: value\"")
+		}
+		i = len(text) - 1
+	}
+	return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), nil
+}
+
+// closingQuote returns the index of the quote closing the string starting
+// s, -1 if there is none
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++ // escaped quote
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseScalar returns s as a string, json.Number, bool or nil
+func parseScalar(s string) (interface{}, error) {
+	if s == "" {
+		return nil, nil
+	}
+	switch s[0] {
+	case '"':
+		if closingQuote(s) != len(s)-1 {
+			return nil, fmt.Errorf("bad quoted string %s", s)
+		}
+		return strconv.Unquote(s)
+	case '\'':
+		if closingQuote(s) != len(s)-1 {
+			return nil, fmt.Errorf("bad quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case '&', '*', '!', '|', '>', '%', '@', '`':
+		return nil, fmt.Errorf("unsupported syntax %q", s)
+	}
+	switch strings.ToLower(s) {
+	case "null", "~":
+		return nil, nil
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off":
+		return false, nil
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil && json.Valid([]byte(s)) {
+		return json.Number(s), nil
+	}
+	return s, nil
+}
+
+// writeJSON writes n as JSON
+func writeJSON(buf *bytes.Buffer, n *node) error {
+	switch n.kind {
+	case '{', '[':
+		buf.WriteByte(n.kind)
+		for i, v := range n.values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if n.kind == '{' {
+				k, _ := json.Marshal(n.keys[i])
+				buf.Write(k)
+				buf.WriteByte(':')
+			}
+			if err := writeJSON(buf, v); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(map[byte]byte{'{': '}', '[': ']'}[n.kind])
+		return nil
+	default:
+		b, err := json.Marshal(n.scalar)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		return nil
+	}
+}
@@ -0,0 +1,164 @@
+// Package yaml converts between JSON and the subset of YAML used by the
+// command line tools: block mappings and sequences of scalars, with
+// comments and flow-style empty collections. It exists so the module keeps
+// depending on the standard library only.
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Marshal encodes v as YAML, through its JSON encoding, keeping the order
+// of struct fields
+func Marshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return FromJSON(b)
+}
+
+// FromJSON converts a JSON document to YAML, keeping the order of keys
+func FromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := parseJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writeNode(&buf, n, 0, false)
+	return buf.Bytes(), nil
+}
+
+// node is a JSON value with the order of object keys kept
+type node struct {
+	kind   byte // '{', '[' or 0 for scalars
+	keys   []string
+	values []*node
+	scalar interface{} // string, json.Number, bool or nil
+}
+
+func parseJSON(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		return &node{scalar: tok}, nil
+	}
+	n := &node{kind: byte(d)}
+	for dec.More() {
+		if d == '{' {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+		}
+		v, err := parseJSON(dec)
+		if err != nil {
+			return nil, err
+		}
+		n.values = append(n.values, v)
+	}
+	if _, err := dec.Token(); err != nil { // closing delimiter
+		return nil, err
+	}
+	return n, nil
+}
+
+// writeNode writes n at the indentation level; inline is set when n follows
+// a "key:" or "- " on the current line
+func writeNode(buf *bytes.Buffer, n *node, level int, inline bool) {
+	indent := strings.Repeat("  ", level)
+	switch {
+	case n.kind == '{' && len(n.values) == 0:
+		buf.WriteString(sep(inline) + "{}\n")
+	case n.kind == '[' && len(n.values) == 0:
+		buf.WriteString(sep(inline) + "[]\n")
+	case n.kind == '{':
+		if inline {
+			buf.WriteString("\n")
+		}
+		for i, k := range n.keys {
+			buf.WriteString(indent + quote(k) + ":")
+			writeNode(buf, n.values[i], level+1, true)
+		}
+	case n.kind == '[':
+		if inline {
+			buf.WriteString("\n")
+		}
+		for _, v := range n.values {
+			buf.WriteString(indent + "-")
+			if v.kind == '{' && len(v.values) > 0 {
+				// the first key goes on the "- " line
+				var item bytes.Buffer
+				writeNode(&item, v, level+1, false)
+				buf.WriteString(" " + strings.TrimPrefix(item.String(), indent+"  "))
+				continue
+			}
+			writeNode(buf, v, level+1, true)
+		}
+	default:
+		buf.WriteString(sep(inline) + scalar(n.scalar) + "\n")
+	}
+}
+
+func sep(inline bool) string {
+	if inline {
+		return " "
+	}
+	return ""
+}
+
+func scalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		return quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// quote returns s as a plain scalar when it can't be mistaken for anything
+// else, double-quoted otherwise
+func quote(s string) string {
+	if s == "" || needsQuotes(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func needsQuotes(s string) bool {
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` \t") || strings.HasSuffix(s, " ") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package transmission
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalKind is a torrent lifecycle transition recorded by a Journal
+type JournalKind string
+
+const (
+	JournalAdded     JournalKind = "added"
+	JournalStarted   JournalKind = "started"
+	JournalCompleted JournalKind = "completed"
+	JournalRemoved   JournalKind = "removed"
+)
+
+// JournalEntry is one recorded transition
+type JournalEntry struct {
+	Time time.Time   `json:"time"`
+	Kind JournalKind `json:"kind"`
+	Hash string      `json:"hash"`
+	Name string      `json:"name"`
+	Size uint64      `json:"size"`
+}
+
+// JournalQuery selects journal entries; zero fields match everything
+type JournalQuery struct {
+	Name  string // case-insensitive substring of the name
+	Hash  string
+	Kind  JournalKind
+	Since time.Time // inclusive
+	Until time.Time // exclusive
+}
+
+// Match reports whether e is selected by q
+func (q JournalQuery) Match(e JournalEntry) bool {
+	return (q.Name == "" || strings.Contains(strings.ToLower(e.Name), strings.ToLower(q.Name))) &&
+		(q.Hash == "" || strings.EqualFold(q.Hash, e.Hash)) &&
+		(q.Kind == "" || q.Kind == e.Kind) &&
+		(q.Since.IsZero() || !e.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || e.Time.Before(q.Until))
+}
+
+// JournalStore persists journal entries
+type JournalStore interface {
+	Append(JournalEntry) error
+	// Query returns the matching entries in the order they were appended
+	Query(JournalQuery) ([]JournalEntry, error)
+}
+
+// Journal records the lifecycle of torrents from watcher events, answering
+// questions like "when did I download X?"
+type Journal struct {
+	store JournalStore
+
+	mu    sync.Mutex
+	added map[string]bool // hashes journaled as added and not removed since, nil until read
+}
+
+// NewJournal returns a journal recording to store
+func NewJournal(store JournalStore) *Journal {
+	return &Journal{store: store}
+}
+
+// Attach records the events of w; store failures are passed to onError if
+// it is not nil
+func (j *Journal) Attach(w *Watcher, onError func(error)) {
+	w.OnEvent(func(e Event) {
+		if err := j.Record(e); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// Record appends the transition described by e, if any. A torrent seen for
+// the first time is journaled as added at its addedDate, once until it is
+// removed: the watcher reports every existing torrent as added when it
+// starts. The torrents already added are read from the store on the first
+// call.
+func (j *Journal) Record(e Event) error {
+	if e.Torrent == nil {
+		return nil
+	}
+	t := e.Torrent
+	entry := JournalEntry{Time: e.Time, Hash: t.InfoHash, Name: t.Name, Size: t.SizeWhenDone}
+	switch e.Type {
+	case EventAdded:
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if err := j.loadAdded(); err != nil || j.added[t.InfoHash] {
+			return err
+		}
+		entry.Kind = JournalAdded
+		if t.AddedDate > 0 {
+			entry.Time = time.Unix(t.AddedDate, 0)
+		}
+	case EventStatusChanged:
+		if e.Previous == nil || e.Previous.Status.IsStarted() || !t.Status.IsStarted() {
+			return nil
+		}
+		entry.Kind = JournalStarted
+	case EventCompleted:
+		entry.Kind = JournalCompleted
+	case EventRemoved:
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		entry.Kind = JournalRemoved
+	default:
+		return nil
+	}
+	if err := j.store.Append(entry); err != nil {
+		return err
+	}
+	switch entry.Kind {
+	case JournalAdded:
+		j.added[t.InfoHash] = true
+	case JournalRemoved:
+		delete(j.added, t.InfoHash)
+	}
+	return nil
+}
+
+// loadAdded replays the journal into added, once
+func (j *Journal) loadAdded() error {
+	if j.added != nil {
+		return nil
+	}
+	entries, err := j.store.Query(JournalQuery{})
+	if err != nil {
+		return err
+	}
+	added := make(map[string]bool)
+	for _, e := range entries {
+		switch e.Kind {
+		case JournalAdded:
+			added[e.Hash] = true
+		case JournalRemoved:
+			delete(added, e.Hash)
+		}
+	}
+	j.added = added
+	return nil
+}
+
+// Query returns the entries selected by q, oldest first
+func (j *Journal) Query(q JournalQuery) ([]JournalEntry, error) {
+	return j.store.Query(q)
+}
+
+// MemoryJournalStore keeps the journal in memory only
+type MemoryJournalStore struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+}
+
+func (s *MemoryJournalStore) Append(e JournalEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *MemoryJournalStore) Query(q JournalQuery) ([]JournalEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []JournalEntry
+	for _, e := range s.entries {
+		if q.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// FileJournalStore keeps the journal as JSON lines appended to a file
+type FileJournalStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileJournalStore) Append(e JournalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	// end the truncated line a crash may have left, so that it is skipped
+	// alone rather than with this entry
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			b = append([]byte{'\n'}, b...)
+		}
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Query scans the file; a truncated last line, left by a crash, is skipped
+func (s *FileJournalStore) Query(q JournalQuery) ([]JournalEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e JournalEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if q.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
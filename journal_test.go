@@ -0,0 +1,43 @@
+package transmission
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestJournalFile checks that an entry appended after a truncated line is
+// kept, and that a torrent is journaled as added again once removed
+func TestJournalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	if err := os.WriteFile(path, []byte(`{"kind":"added","hash":"a"}`+"\n"+`{"kind":"rem`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	j := NewJournal(&FileJournalStore{Path: path})
+	torrent := &Torrent{InfoHash: "a", Name: "debian.iso"}
+	now := time.Now()
+	for _, e := range []Event{
+		{Type: EventAdded, Torrent: torrent, Time: now}, // already journaled
+		{Type: EventRemoved, Torrent: torrent, Time: now},
+		{Type: EventAdded, Torrent: torrent, Time: now},
+	} {
+		if err := j.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := j.Query(JournalQuery{Hash: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []JournalKind
+	for _, e := range entries {
+		kinds = append(kinds, e.Kind)
+	}
+	want := []JournalKind{JournalAdded, JournalRemoved, JournalAdded}
+	if !slices.Equal(kinds, want) {
+		t.Errorf("kinds = %v, want %v", kinds, want)
+	}
+}
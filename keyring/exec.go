@@ -0,0 +1,33 @@
+//go:build linux || darwin
+
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// run runs a keychain tool with stdin, returning its output, its error
+// output and its exit status; a missing tool is ErrUnsupported
+func run(stdin, name string, args ...string) (stdout, stderr string, code int, err error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err = cmd.Run()
+	stdout, stderr = strings.TrimSuffix(out.String(), "\n"), strings.TrimSpace(errOut.String())
+	var ee *exec.ExitError
+	switch {
+	case err == nil:
+		return stdout, stderr, 0, nil
+	case errors.Is(err, exec.ErrNotFound):
+		return "", "", -1, fmt.Errorf("%w: %s not installed", ErrUnsupported, name)
+	case errors.As(err, &ee):
+		return stdout, stderr, ee.ExitCode(), fmt.Errorf("keyring: %s: %v: %s", name, err, stderr)
+	default:
+		return "", "", -1, fmt.Errorf("keyring: %s: %w", name, err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package keyring keeps secrets, such as RPC passwords, in the keychain of
+// the operating system instead of plaintext files: the Secret Service on
+// Linux (through secret-tool), the Keychain on macOS (through security) and
+// the Credential Manager on Windows.
+package keyring
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned by Get and Delete when no secret is stored
+	ErrNotFound = errors.New("keyring: secret not found")
+	// ErrUnsupported is returned when the system has no usable keychain
+	ErrUnsupported = errors.New("keyring: no keychain available")
+)
+
+// Keyring stores secrets by service and user
+type Keyring interface {
+	Get(service, user string) (string, error)
+	Set(service, user, secret string) error
+	Delete(service, user string) error
+}
+
+// System returns the keychain of the operating system; its methods return
+// ErrUnsupported when there is none
+func System() Keyring {
+	return system{}
+}
+
+// Memory is a Keyring keeping the secrets in memory, for tests and for
+// programs that get their secrets elsewhere
+type Memory struct {
+	mu      sync.Mutex
+	secrets map[[2]string]string
+}
+
+func (m *Memory) Get(service, user string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.secrets[[2]string{service, user}]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return s, nil
+}
+
+func (m *Memory) Set(service, user, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.secrets == nil {
+		m.secrets = make(map[[2]string]string)
+	}
+	m.secrets[[2]string{service, user}] = secret
+	return nil
+}
+
+func (m *Memory) Delete(service, user string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.secrets[[2]string{service, user}]; !ok {
+		return ErrNotFound
+	}
+	delete(m.secrets, [2]string{service, user})
+	return nil
+}
@@ -0,0 +1,50 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of security for a missing item
+const errSecItemNotFound = 44
+
+// system uses the login Keychain through security. Set writes its command
+// to the standard input of security -i, keeping the secret off the command
+// line where other local users could see it.
+type system struct{}
+
+func (system) Get(service, user string) (string, error) {
+	out, _, code, err := run("", "security", "find-generic-password", "-s", service, "-a", user, "-w")
+	if code == errSecItemNotFound {
+		return "", ErrNotFound
+	}
+	return out, err
+}
+
+func (system) Set(service, user, secret string) error {
+	if strings.ContainsAny(service+user+secret, "\n\r") {
+		return errors.New("keyring: line breaks aren't supported by the Keychain backend")
+	}
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", quote(service), quote(user), quote(secret))
+	_, stderr, _, err := run(cmd, "security", "-i")
+	if err == nil && stderr != "" {
+		// security -i reports the failures of its commands without
+		// exiting with an error
+		err = fmt.Errorf("keyring: security: %s", stderr)
+	}
+	return err
+}
+
+// quote quotes s as one argument of a security -i command
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (system) Delete(service, user string) error {
+	_, _, code, err := run("", "security", "delete-generic-password", "-s", service, "-a", user)
+	if code == errSecItemNotFound {
+		return ErrNotFound
+	}
+	return err
+}
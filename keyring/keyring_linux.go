@@ -0,0 +1,28 @@
+package keyring
+
+// system uses the Secret Service (GNOME Keyring, KWallet) through
+// secret-tool, from libsecret
+type system struct{}
+
+func (system) Get(service, user string) (string, error) {
+	out, stderr, code, err := run("", "secret-tool", "lookup", "service", service, "username", user)
+	// a missing secret is an exit status of 1, or 0 for older versions,
+	// without any message
+	if (code == 0 || code == 1) && out == "" && stderr == "" {
+		return "", ErrNotFound
+	}
+	return out, err
+}
+
+func (system) Set(service, user, secret string) error {
+	_, _, _, err := run(secret, "secret-tool", "store", "--label", service+" "+user, "service", service, "username", user)
+	return err
+}
+
+func (s system) Delete(service, user string) error {
+	if _, err := s.Get(service, user); err != nil {
+		return err
+	}
+	_, _, _, err := run("", "secret-tool", "clear", "service", service, "username", user)
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package keyring
+
+// system has no keychain to use
+type system struct{}
+
+func (system) Get(service, user string) (string, error) { return "", ErrUnsupported }
+func (system) Set(service, user, secret string) error   { return ErrUnsupported }
+func (system) Delete(service, user string) error        { return ErrUnsupported }
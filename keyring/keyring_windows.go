@@ -0,0 +1,105 @@
+package keyring
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential is CREDENTIALW
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// system uses the Credential Manager, with generic credentials named
+// "service:user"
+type system struct{}
+
+func target(service, user string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(service + ":" + user)
+}
+
+func (system) Get(service, user string) (string, error) {
+	name, err := target(service, user)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	ok, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ok == 0 {
+		return "", credError(err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (system) Set(service, user, secret string) error {
+	name, err := target(service, user)
+	if err != nil {
+		return err
+	}
+	userName, err := syscall.UTF16PtrFromString(user)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         name,
+		CredentialBlobSize: uint32(len(secret)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(secret) > 0 {
+		blob := []byte(secret)
+		cred.CredentialBlob = &blob[0]
+	}
+	ok, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ok == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+func (system) Delete(service, user string) error {
+	name, err := target(service, user)
+	if err != nil {
+		return err
+	}
+	ok, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0)
+	if ok == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+func credError(err error) error {
+	if err == errorNotFound {
+		return ErrNotFound
+	}
+	return fmt.Errorf("keyring: credential manager: %w", err)
+}
@@ -0,0 +1,106 @@
+package transmission
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Leak is a finding of a LeakChecker
+type Leak struct {
+	Reason string
+	Addr   netip.Addr // the offending address, invalid when there is none
+}
+
+func (l Leak) String() string {
+	if l.Addr.IsValid() {
+		return fmt.Sprintf("%s: %s", l.Reason, l.Addr)
+	}
+	return l.Reason
+}
+
+// LeakChecker verifies that the daemon's traffic goes through a VPN: its
+// bind addresses and external address must be in the Allowed ranges
+type LeakChecker struct {
+	client *TransmissionClient
+
+	Allowed []netip.Prefix // expected VPN ranges
+	// Resolve, if not nil, returns the external address of the daemon, e.g.
+	// by asking an echo service through the daemon's network namespace
+	Resolve func(ctx context.Context) (netip.Addr, error)
+	// OnLeak, if not nil, is called by Run with the findings of each check
+	// having some
+	OnLeak func([]Leak)
+}
+
+// NewLeakChecker returns a checker expecting the daemon's addresses in
+// allowed
+func NewLeakChecker(client *TransmissionClient, allowed ...netip.Prefix) *LeakChecker {
+	return &LeakChecker{client: client, Allowed: allowed}
+}
+
+// Check compares the session's bind addresses, when the daemon reports them,
+// and the resolved external address against the allowed ranges. A bind
+// address listening on all interfaces is a leak too, as the daemon would keep
+// working over the regular link if the VPN went down.
+func (c *LeakChecker) Check(ctx context.Context) ([]Leak, error) {
+	session, err := c.client.GetSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaks []Leak
+	for _, bind := range []string{session.BindAddressIPv4, session.BindAddressIPv6} {
+		if bind == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(bind)
+		switch {
+		case err != nil:
+			leaks = append(leaks, Leak{Reason: "unparsable bind address " + bind})
+		case addr.IsUnspecified():
+			leaks = append(leaks, Leak{Reason: "bound to all interfaces", Addr: addr})
+		case !c.allowed(addr):
+			leaks = append(leaks, Leak{Reason: "bind address outside the VPN", Addr: addr})
+		}
+	}
+
+	if c.Resolve != nil {
+		addr, err := c.Resolve(ctx)
+		if err != nil {
+			return leaks, err
+		}
+		if !c.allowed(addr) {
+			leaks = append(leaks, Leak{Reason: "external address outside the VPN", Addr: addr})
+		}
+	}
+	return leaks, nil
+}
+
+func (c *LeakChecker) allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range c.Allowed {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run checks every interval until ctx is done, calling OnLeak when leaks are
+// found; failed checks are retried at the next tick
+func (c *LeakChecker) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if leaks, _ := c.Check(ctx); len(leaks) > 0 && c.OnLeak != nil {
+			c.OnLeak(leaks)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
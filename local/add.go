@@ -0,0 +1,61 @@
+package local
+
+import (
+	"context"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/metainfo"
+)
+
+// WithAnnounceRewrite is transmission.WithAnnounceRewrite, also rewriting
+// the .torrent files added as data before they reach the daemon
+func WithAnnounceRewrite(fn func(announce string) string) transmission.Option {
+	announces := transmission.WithAnnounceRewrite(fn)
+	files := transmission.WithMetaInfoRewrite(metainfo.RewriteAnnounces)
+	return func(ac *transmission.TransmissionClient) {
+		announces(ac)
+		files(ac)
+	}
+}
+
+// AddMetaInfo adds the torrent described by mi, with its data in
+// downloadDir on the daemon; an empty downloadDir uses the defaults of
+// ExecuteAddCommandContext
+func AddMetaInfo(ctx context.Context, client *transmission.TransmissionClient, mi *metainfo.MetaInfo, downloadDir string) (transmission.TorrentAdded, error) {
+	cmd, err := addCmd(mi, downloadDir)
+	if err != nil {
+		return transmission.TorrentAdded{}, err
+	}
+	return client.ExecuteAddCommandContext(ctx, cmd)
+}
+
+func addCmd(mi *metainfo.MetaInfo, downloadDir string) (*transmission.Command, error) {
+	b, err := mi.Encode()
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := transmission.NewAddCmdByBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetDownloadDir(downloadDir)
+	return cmd, nil
+}
+
+// CreateAndSeed builds a torrent from localPath (see metainfo.Create) and
+// adds it unpaused to the daemon, which verifies the existing data and
+// starts seeding. daemonDir is the directory holding the content as seen by the
+// daemon, i.e. the parent of localPath mapped to the daemon's filesystem.
+func CreateAndSeed(ctx context.Context, client *transmission.TransmissionClient, localPath string, opts metainfo.CreateOptions, daemonDir string) (*metainfo.MetaInfo, transmission.TorrentAdded, error) {
+	mi, err := metainfo.Create(localPath, opts)
+	if err != nil {
+		return nil, transmission.TorrentAdded{}, err
+	}
+	cmd, err := addCmd(mi, daemonDir)
+	if err != nil {
+		return mi, transmission.TorrentAdded{}, err
+	}
+	cmd.SetPaused(false)
+	added, err := client.ExecuteAddCommandContext(ctx, cmd)
+	return mi, added, err
+}
@@ -0,0 +1,89 @@
+// Package local works with the data and .torrent files of torrents on the
+// local filesystem. It is kept out of the core package, with the metainfo
+// and bencode packages it needs, so that clients only talking RPC stay
+// small.
+package local
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/metainfo"
+)
+
+// FileCheck is the result of checking one file of a torrent locally
+type FileCheck struct {
+	Name     string // as in transmission.File.Name
+	Path     string // local path
+	Expected int64  // length according to the daemon
+	Actual   int64  // local size, 0 when missing
+	Missing  bool
+	Complete bool // the daemon has all the bytes of the file
+}
+
+// OK reports whether the file is complete and has the expected size locally
+func (c FileCheck) OK() bool {
+	return !c.Missing && c.Actual == c.Expected
+}
+
+// Report is the result of VerifyLocalFiles
+type Report struct {
+	Files         []FileCheck
+	PiecesChecked bool  // piece hashes were verified
+	BadPieces     []int // missing or corrupt pieces, if PiecesChecked
+}
+
+// OK reports whether every file the daemon has completed is present with
+// the right size locally, and no checked piece is bad
+func (r *Report) OK() bool {
+	for _, f := range r.Files {
+		if f.Complete && !f.OK() {
+			return false
+		}
+	}
+	return len(r.BadPieces) == 0
+}
+
+// VerifyLocalFiles checks the torrent's files on a locally mounted copy of
+// its download dir, fsRoot (see transmission.PathMapper), comparing their
+// sizes. When mi, the parsed metainfo of the torrent, is not nil, the piece
+// hashes are verified too. t needs the "files" field.
+func VerifyLocalFiles(t *transmission.Torrent, fsRoot string, mi *metainfo.MetaInfo) (*Report, error) {
+	if mi != nil && t.InfoHash != "" && mi.HashString() != t.InfoHash {
+		return nil, fmt.Errorf("metainfo %s doesn't match torrent %s", mi.HashString(), t.InfoHash)
+	}
+
+	report := &Report{}
+	for _, f := range t.Files {
+		check := FileCheck{
+			Name:     f.Name,
+			Path:     filepath.Join(fsRoot, filepath.FromSlash(f.Name)),
+			Expected: f.Size,
+			Complete: f.Completed == f.Size,
+		}
+		info, err := os.Stat(check.Path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			check.Missing = true
+		case err != nil:
+			return nil, err
+		default:
+			check.Actual = info.Size()
+		}
+		report.Files = append(report.Files, check)
+	}
+
+	if mi != nil {
+		bad, err := mi.VerifyPieces(fsRoot)
+		if err != nil {
+			return nil, err
+		}
+		report.PiecesChecked = true
+		report.BadPieces = bad
+	}
+	return report, nil
+}
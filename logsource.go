@@ -0,0 +1,126 @@
+package transmission
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LogLevel is the severity of a LogEntry
+type LogLevel int
+
+const (
+	LogError LogLevel = iota
+	LogWarning
+	LogInfo
+	LogDebug
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogError:
+		return "error"
+	case LogWarning:
+		return "warning"
+	case LogInfo:
+		return "info"
+	case LogDebug:
+		return "debug"
+	default:
+		return "unknown"
+	}
+}
+
+// LogEntry is a message about the daemon, seen through RPC or read from the
+// daemon's own log
+type LogEntry struct {
+	Time    time.Time
+	Level   LogLevel
+	Source  string // "rpc", or where the daemon log is read, e.g. "journal"
+	Message string
+	Torrent string // name of the torrent concerned, when known
+}
+
+// LogSource follows messages about the daemon, calling fn for each one
+// until ctx is done or the source fails. The daemonlog package reads the
+// daemon's log through journalctl, a log file or SSH.
+type LogSource interface {
+	Tail(ctx context.Context, fn func(LogEntry)) error
+}
+
+// WatcherLog returns the LogSource of what RPC tells about failures, as seen
+// by w: torrent and tracker errors, including those present at the first
+// poll, and the daemon becoming unreachable and reachable again.
+// Transmission has no RPC method returning its log; this is the part of it
+// the API exposes. w must be run separately.
+func WatcherLog(w *Watcher) LogSource {
+	return watcherLog{w}
+}
+
+type watcherLog struct{ w *Watcher }
+
+func (l watcherLog) Tail(ctx context.Context, fn func(LogEntry)) error {
+	entries := make(chan LogEntry, 64)
+	l.w.OnEvent(func(e Event) {
+		entry, ok := eventLogEntry(e)
+		if !ok {
+			return
+		}
+		select {
+		case entries <- entry:
+		case <-ctx.Done():
+		}
+	})
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case entry := <-entries:
+			fn(entry)
+		}
+	}
+}
+
+// eventLogEntry returns the log entry for the watcher events about failures
+func eventLogEntry(e Event) (LogEntry, bool) {
+	entry := LogEntry{Time: e.Time, Source: "rpc"}
+	switch {
+	case e.Type == EventErrored, e.Type == EventAdded && e.Torrent.Error != 0:
+		entry.Level = LogError
+		if e.Torrent.Error == 1 { // tracker warning
+			entry.Level = LogWarning
+		}
+		entry.Message, entry.Torrent = e.Torrent.ErrorString, e.Torrent.Name
+	case e.Type == EventDisconnected:
+		entry.Level, entry.Message = LogError, "daemon unreachable: "+e.Err.Error()
+	case e.Type == EventReconnected:
+		entry.Level, entry.Message = LogInfo, "daemon reachable again"
+	default:
+		return LogEntry{}, false
+	}
+	return entry, true
+}
+
+// TailLogs follows the sources together, calling fn for each entry from one
+// goroutine at a time, until ctx is done or one of them stops; it returns
+// the error of that source
+func TailLogs(ctx context.Context, fn func(LogEntry), sources ...LogSource) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src LogSource) {
+			defer wg.Done()
+			err := src.Tail(ctx, func(e LogEntry) {
+				mu.Lock()
+				defer mu.Unlock()
+				fn(e)
+			})
+			cancel(err)
+		}(src)
+	}
+	wg.Wait()
+	return context.Cause(ctx)
+}
@@ -0,0 +1,238 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/unix2dos/go-transmission/v2/bencode"
+)
+
+// piece lengths picked by PieceLengthFor
+const (
+	minPieceLength    = 16 << 10
+	maxPieceLength    = 16 << 20
+	targetPieceNumber = 1500
+)
+
+// CreateOptions configure Create
+type CreateOptions struct {
+	Name        string     // defaults to the base name of the path
+	PieceLength int64      // defaults to PieceLengthFor the content size
+	Private     bool       // disable DHT and PEX
+	Trackers    [][]string // tiers of announce urls
+	WebSeeds    []string
+	Comment     string
+	CreatedBy   string
+}
+
+// PieceLengthFor returns a power of two piece length giving about 1500
+// pieces for total bytes, between 16 KiB and 16 MiB
+func PieceLengthFor(total int64) int64 {
+	n := int64(minPieceLength)
+	for n < maxPieceLength && total/n > targetPieceNumber {
+		n *= 2
+	}
+	return n
+}
+
+// Create builds the metainfo of the file or directory at path, hashing its
+// content. Directories become multi file torrents holding every regular
+// file below them, in lexical order.
+func Create(path string, opts CreateOptions) (*MetaInfo, error) {
+	path = filepath.Clean(path)
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := Info{Name: opts.Name, Private: opts.Private}
+	if info.Name == "" {
+		info.Name = filepath.Base(path)
+	}
+	var files []string
+	if st.IsDir() {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(path, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, p)
+			info.Files = append(info.Files, FileInfo{
+				Length: fi.Size(),
+				Path:   strings.Split(filepath.ToSlash(rel), "/"),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, errors.New("metainfo: no files in " + path)
+		}
+	} else {
+		files = []string{path}
+		info.Length = st.Size()
+	}
+
+	info.PieceLength = opts.PieceLength
+	if info.PieceLength <= 0 {
+		info.PieceLength = PieceLengthFor(info.TotalLength())
+	}
+	if info.Pieces, err = hashFiles(files, info.PieceLength); err != nil {
+		return nil, err
+	}
+
+	mi := &MetaInfo{
+		AnnounceList: opts.Trackers,
+		URLList:      opts.WebSeeds,
+		Comment:      opts.Comment,
+		CreatedBy:    opts.CreatedBy,
+		CreationDate: time.Now().Unix(),
+		Info:         info,
+	}
+	if len(opts.Trackers) > 0 && len(opts.Trackers[0]) > 0 {
+		mi.Announce = opts.Trackers[0][0]
+	}
+	raw, err := bencode.Encode(info.dict())
+	if err != nil {
+		return nil, err
+	}
+	mi.rawInfo = raw
+	mi.InfoHash = sha1.Sum(raw)
+	return mi, nil
+}
+
+// hashFiles returns the concatenated piece hashes of the files' content
+func hashFiles(files []string, pieceLength int64) ([]byte, error) {
+	r := &fileChain{files: files}
+	defer r.Close()
+
+	var pieces []byte
+	buf := make([]byte, pieceLength)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			sum := sha1.Sum(buf[:n])
+			pieces = append(pieces, sum[:]...)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return pieces, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fileChain reads files one after the other, opening each only when the
+// previous one is read to the end, so that a torrent of thousands of files
+// doesn't hit the limit of open files
+type fileChain struct {
+	files []string
+	cur   *os.File
+}
+
+func (c *fileChain) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if len(c.files) == 0 {
+				return 0, io.EOF
+			}
+			file, err := os.Open(c.files[0])
+			if err != nil {
+				return 0, err
+			}
+			c.cur, c.files = file, c.files[1:]
+		}
+		n, err := c.cur.Read(p)
+		if err == io.EOF {
+			err = c.Close()
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// Close closes the file being read
+func (c *fileChain) Close() error {
+	if c.cur == nil {
+		return nil
+	}
+	err := c.cur.Close()
+	c.cur = nil
+	return err
+}
+
+// dict returns the info dictionary in encodable form
+func (i *Info) dict() map[string]interface{} {
+	d := map[string]interface{}{
+		"name":         i.Name,
+		"piece length": i.PieceLength,
+		"pieces":       i.Pieces,
+	}
+	if i.Private {
+		d["private"] = 1
+	}
+	if len(i.Files) == 0 {
+		d["length"] = i.Length
+		return d
+	}
+	files := make([]interface{}, 0, len(i.Files))
+	for _, f := range i.Files {
+		files = append(files, map[string]interface{}{"length": f.Length, "path": f.Path})
+	}
+	d["files"] = files
+	return d
+}
+
+// Encode returns the .torrent file content of mi. The info dictionary of a
+// parsed torrent is written as it was read, so the info hash is preserved.
+func (mi *MetaInfo) Encode() ([]byte, error) {
+	info := bencode.RawMessage(mi.rawInfo)
+	if info == nil {
+		raw, err := bencode.Encode(mi.Info.dict())
+		if err != nil {
+			return nil, err
+		}
+		info = raw
+	}
+
+	d := map[string]interface{}{"info": info}
+	if mi.Announce != "" {
+		d["announce"] = mi.Announce
+	}
+	if len(mi.AnnounceList) > 0 {
+		tiers := make([]interface{}, 0, len(mi.AnnounceList))
+		for _, tier := range mi.AnnounceList {
+			tiers = append(tiers, tier)
+		}
+		d["announce-list"] = tiers
+	}
+	if len(mi.URLList) > 0 {
+		d["url-list"] = mi.URLList
+	}
+	if mi.Comment != "" {
+		d["comment"] = mi.Comment
+	}
+	if mi.CreatedBy != "" {
+		d["created by"] = mi.CreatedBy
+	}
+	if mi.CreationDate != 0 {
+		d["creation date"] = mi.CreationDate
+	}
+	return bencode.Encode(d)
+}
@@ -0,0 +1,246 @@
+// Package metainfo parses .torrent files.
+package metainfo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unix2dos/go-transmission/v2/bencode"
+)
+
+var ErrInvalid = errors.New("metainfo: invalid torrent")
+
+// MaxPieceLength is the largest piece length accepted, far above what
+// clients create, so that verifying a torrent allocates a bounded buffer
+const MaxPieceLength = 64 << 20
+
+// MetaInfo is the content of a .torrent file
+type MetaInfo struct {
+	Announce     string
+	AnnounceList [][]string // tiers of announce urls
+	URLList      []string   // webseeds
+	Comment      string
+	CreatedBy    string
+	CreationDate int64 // unix timestamp
+	Info         Info
+	InfoHash     [20]byte // SHA-1 of the encoded info dictionary
+
+	rawInfo []byte // info dictionary as parsed, re-encoded as is
+}
+
+// Info is the info dictionary of a torrent
+type Info struct {
+	Name        string
+	PieceLength int64
+	Pieces      []byte // concatenated 20 byte SHA-1 hashes
+	Private     bool
+	Length      int64      // single file torrents
+	Files       []FileInfo // multi file torrents
+}
+
+// FileInfo is a file of a multi file torrent
+type FileInfo struct {
+	Length int64
+	Path   []string
+}
+
+// Load reads and parses a .torrent file
+func Load(file string) (*MetaInfo, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses the content of a .torrent file
+func Parse(data []byte) (*MetaInfo, error) {
+	raw, err := bencode.DecodeDictRaw(data)
+	if err != nil {
+		return nil, err
+	}
+	rawInfo, ok := raw["info"]
+	if !ok {
+		return nil, ErrInvalid
+	}
+	v, err := bencode.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	top := v.(map[string]interface{})
+	info, ok := top["info"].(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	mi := &MetaInfo{
+		Announce:     str(top["announce"]),
+		Comment:      str(top["comment"]),
+		CreatedBy:    str(top["created by"]),
+		CreationDate: integer(top["creation date"]),
+		InfoHash:     sha1.Sum(rawInfo),
+		rawInfo:      rawInfo,
+		Info: Info{
+			Name:        str(info["name"]),
+			PieceLength: integer(info["piece length"]),
+			Pieces:      []byte(str(info["pieces"])),
+			Private:     integer(info["private"]) == 1,
+			Length:      integer(info["length"]),
+		},
+	}
+	for _, tier := range list(top["announce-list"]) {
+		var urls []string
+		for _, u := range list(tier) {
+			urls = append(urls, str(u))
+		}
+		mi.AnnounceList = append(mi.AnnounceList, urls)
+	}
+	switch u := top["url-list"].(type) {
+	case string:
+		mi.URLList = []string{u}
+	case []interface{}:
+		for _, s := range u {
+			mi.URLList = append(mi.URLList, str(s))
+		}
+	}
+	for _, f := range list(info["files"]) {
+		fd, _ := f.(map[string]interface{})
+		fi := FileInfo{Length: integer(fd["length"])}
+		for _, p := range list(fd["path"]) {
+			fi.Path = append(fi.Path, str(p))
+		}
+		mi.Info.Files = append(mi.Info.Files, fi)
+	}
+
+	if err := mi.Info.validate(); err != nil {
+		return nil, err
+	}
+	return mi, nil
+}
+
+func (i *Info) validate() error {
+	switch {
+	case !validPathPart(i.Name) || len(i.Pieces)%sha1.Size != 0:
+		return ErrInvalid
+	case i.PieceLength <= 0 || i.PieceLength > MaxPieceLength || i.Length < 0:
+		return ErrInvalid
+	}
+	for _, f := range i.Files {
+		if f.Length < 0 || len(f.Path) == 0 {
+			return ErrInvalid
+		}
+		for _, p := range f.Path {
+			if !validPathPart(p) {
+				return ErrInvalid
+			}
+		}
+	}
+	if int64(i.NumPieces()) != (i.TotalLength()+i.PieceLength-1)/i.PieceLength {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// validPathPart reports whether p is one file name, keeping the content
+// inside the directory it is verified or created in
+func validPathPart(p string) bool {
+	return p != "" && p != "." && p != ".." && filepath.Base(p) == p && !strings.ContainsAny(p, `/\`)
+}
+
+// HashString returns the info hash in hex, as in Torrent.InfoHash
+func (mi *MetaInfo) HashString() string {
+	return hex.EncodeToString(mi.InfoHash[:])
+}
+
+// NumPieces returns the number of pieces
+func (i *Info) NumPieces() int {
+	return len(i.Pieces) / sha1.Size
+}
+
+// PieceHash returns the SHA-1 hash of piece n
+func (i *Info) PieceHash(n int) []byte {
+	return i.Pieces[n*sha1.Size : (n+1)*sha1.Size]
+}
+
+// TotalLength returns the size of the content
+func (i *Info) TotalLength() int64 {
+	if len(i.Files) == 0 {
+		return i.Length
+	}
+	var n int64
+	for _, f := range i.Files {
+		n += f.Length
+	}
+	return n
+}
+
+// FileList returns the files of the torrent, a single file torrent being a
+// list of one file named after the torrent
+func (i *Info) FileList() []FileInfo {
+	if len(i.Files) == 0 {
+		return []FileInfo{{Length: i.Length, Path: []string{i.Name}}}
+	}
+	files := make([]FileInfo, 0, len(i.Files))
+	for _, f := range i.Files {
+		files = append(files, FileInfo{Length: f.Length, Path: append([]string{i.Name}, f.Path...)})
+	}
+	return files
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func integer(v interface{}) int64 {
+	n, _ := v.(int64)
+	return n
+}
+
+func list(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// RewriteAnnounces returns the .torrent file data with its announce and
+// announce-list urls passed through fn. The other keys, the info
+// dictionary first, are kept byte for byte, so the info hash is the same.
+func RewriteAnnounces(data []byte, fn func(announce string) string) ([]byte, error) {
+	raw, err := bencode.DecodeDictRaw(data)
+	if err != nil {
+		return nil, err
+	}
+	d := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		d[k] = bencode.RawMessage(v)
+	}
+	if b, ok := raw["announce"]; ok {
+		v, err := bencode.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := v.(string); ok {
+			d["announce"] = fn(s)
+		}
+	}
+	if b, ok := raw["announce-list"]; ok {
+		v, err := bencode.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		var tiers []interface{}
+		for _, tier := range list(v) {
+			var urls []string
+			for _, u := range list(tier) {
+				urls = append(urls, fn(str(u)))
+			}
+			tiers = append(tiers, urls)
+		}
+		d["announce-list"] = tiers
+	}
+	return bencode.Encode(d)
+}
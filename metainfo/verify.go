@@ -0,0 +1,186 @@
+package metainfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// storage reads pieces from the files of a torrent below a root directory
+type storage struct {
+	info  *Info
+	files []storageFile
+}
+
+type storageFile struct {
+	path   string
+	offset int64 // of the file in the torrent's content
+	length int64
+}
+
+func newStorage(info *Info, root string) *storage {
+	s := &storage{info: info}
+	var offset int64
+	for _, f := range info.FileList() {
+		p := filepath.Join(append([]string{root}, f.Path...)...)
+		s.files = append(s.files, storageFile{path: p, offset: offset, length: f.Length})
+		offset += f.Length
+	}
+	return s
+}
+
+// pieceLength returns the length of piece n, the last one being shorter
+func (s *storage) pieceLength(n int) int64 {
+	start := int64(n) * s.info.PieceLength
+	return min(s.info.PieceLength, s.info.TotalLength()-start)
+}
+
+// readPiece reads piece n into buf; missing or short files make it fail
+func (s *storage) readPiece(n int, buf []byte) error {
+	start := int64(n) * s.info.PieceLength
+	end := start + int64(len(buf))
+	for _, f := range s.files {
+		if f.offset+f.length <= start || f.offset >= end || f.length == 0 {
+			continue
+		}
+		from := max(start, f.offset)
+		to := min(end, f.offset+f.length)
+		file, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		_, err = file.ReadAt(buf[from-start:to-start], from-f.offset)
+		file.Close()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPiece reports whether piece n is present and matches its hash
+func (s *storage) checkPiece(n int, buf []byte) bool {
+	buf = buf[:s.pieceLength(n)]
+	if s.readPiece(n, buf) != nil {
+		return false
+	}
+	sum := sha1.Sum(buf)
+	return bytes.Equal(sum[:], s.info.PieceHash(n))
+}
+
+// VerifyPieces hashes the content found below root, laid out as the daemon
+// stores it (root/name for single file torrents, root/name/path... for the
+// others), and returns the indexes of the missing or corrupt pieces
+func (mi *MetaInfo) VerifyPieces(root string) (bad []int, err error) {
+	res, err := (&Verifier{}).Verify(context.Background(), mi, root)
+	if err != nil {
+		return nil, err
+	}
+	return res.Bad, nil
+}
+
+// Verifier hashes the pieces of a torrent's local data with a pool of
+// workers, independently of any daemon
+type Verifier struct {
+	Workers  int                   // defaults to the number of CPUs
+	Progress func(done, total int) // called after each piece, from one goroutine
+}
+
+// Result is the outcome of Verifier.Verify
+type Result struct {
+	Pieces int   // number of pieces checked
+	Bad    []int // missing or corrupt pieces, ascending
+}
+
+// OK reports whether every piece matched
+func (r *Result) OK() bool {
+	return len(r.Bad) == 0
+}
+
+// Verify hashes the content of mi found below root, see VerifyPieces; it
+// stops early when ctx is done. An mi Parse would reject, such as a piece
+// length above MaxPieceLength or a path leaving root, is ErrInvalid.
+func (v *Verifier) Verify(ctx context.Context, mi *MetaInfo, root string) (*Result, error) {
+	if err := mi.Info.validate(); err != nil {
+		return nil, err
+	}
+	workers := v.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	s := newStorage(&mi.Info, root)
+	total := mi.Info.NumPieces()
+
+	type result struct {
+		n  int
+		ok bool
+	}
+	jobs := make(chan int)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, mi.Info.PieceLength)
+			for n := range jobs {
+				results <- result{n, s.checkPiece(n, buf)}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for n := 0; n < total; n++ {
+			select {
+			case jobs <- n:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	res := &Result{}
+	for r := range results {
+		res.Pieces++
+		if !r.ok {
+			res.Bad = append(res.Bad, r.n)
+		}
+		if v.Progress != nil {
+			v.Progress(res.Pieces, total)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(res.Bad)
+	return res, nil
+}
+
+// PieceFiles returns the indexes, in FileList, of the files piece n spans
+func (i *Info) PieceFiles(n int) []int {
+	start := int64(n) * i.PieceLength
+	end := start + i.PieceLength
+	var files []int
+	var offset int64
+	for j, f := range i.FileList() {
+		if offset < end && offset+f.Length > start {
+			files = append(files, j)
+		}
+		offset += f.Length
+	}
+	return files
+}
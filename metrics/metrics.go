@@ -0,0 +1,234 @@
+// Package metrics exposes the state of a Transmission daemon as Prometheus
+// metrics: scraped from Handler, written in the OpenMetrics text format by
+// cron-style jobs, or pushed to a Pushgateway by a Pusher.
+//
+//	transmission_up                          1 if the daemon answered
+//	transmission_torrents{status}            torrents per status
+//	transmission_torrent_errors{kind}        tracker_warning, tracker_error, local
+//	transmission_rate_bytes{direction}       current speed, down or up
+//	transmission_left_until_done_bytes       data still to download
+//	transmission_size_when_done_bytes        data wanted
+//	transmission_transferred_bytes_total{direction}  since the daemon's first start
+//	transmission_active_seconds_total        time the daemon has been active
+//	transmission_peers{transport}            connected peers over utp or tcp
+//	transmission_encrypted_peers             connected peers with encryption
+//
+// ChurnFamilies adds the churn of the peers, per torrent:
+//
+//	transmission_peer_churn_per_minute{hash,name,direction}  peers connecting or disconnecting
+//	transmission_peers_mean{hash,name}                       mean number of connected peers
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// Type is the type of a metric family
+type Type string
+
+const (
+	Gauge   Type = "gauge"
+	Counter Type = "counter"
+)
+
+// Family is a metric with its samples; the names of counters don't carry
+// the _total suffix, the formats add it
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Sample is one value of a family
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Source is what Collect reads from, e.g. a transmission.TransmissionClient
+type Source interface {
+	GetStatsContext(ctx context.Context) (*transmission.Stats, error)
+	Summary(ctx context.Context) (*transmission.Summary, error)
+}
+
+// statusNames are the label values of the torrent statuses
+var statusNames = map[transmission.Status]string{
+	transmission.TrStopped:         "stopped",
+	transmission.TrCheckPending:    "check_pending",
+	transmission.TrChecking:        "checking",
+	transmission.TrDownloadPending: "download_pending",
+	transmission.TrDownloading:     "downloading",
+	transmission.TrSeedPending:     "seed_pending",
+	transmission.TrSeeding:         "seeding",
+}
+
+// Collect reads the metrics of the daemon. When the daemon can't be read
+// the error is returned along with transmission_up at 0.
+func Collect(ctx context.Context, src Source) ([]Family, error) {
+	up := Family{Name: "transmission_up", Help: "Whether the daemon answered.", Type: Gauge, Samples: []Sample{{Value: 0}}}
+	stats, err := src.GetStatsContext(ctx)
+	if err != nil {
+		return []Family{up}, err
+	}
+	summary, err := src.Summary(ctx)
+	if err != nil {
+		return []Family{up}, err
+	}
+	up.Samples[0].Value = 1
+
+	torrents := Family{Name: "transmission_torrents", Help: "Torrents per status.", Type: Gauge}
+	for status := transmission.TrStopped; status <= transmission.TrSeeding; status++ {
+		torrents.Samples = append(torrents.Samples, Sample{
+			Labels: map[string]string{"status": statusNames[status]},
+			Value:  float64(summary.ByStatus[status]),
+		})
+	}
+	return []Family{
+		up,
+		torrents,
+		{Name: "transmission_torrent_errors", Help: "Torrents in error per kind of error.", Type: Gauge, Samples: []Sample{
+			{Labels: map[string]string{"kind": "tracker_warning"}, Value: float64(summary.TrackerWarnings)},
+			{Labels: map[string]string{"kind": "tracker_error"}, Value: float64(summary.TrackerErrors)},
+			{Labels: map[string]string{"kind": "local"}, Value: float64(summary.LocalErrors)},
+		}},
+		{Name: "transmission_rate_bytes", Help: "Current transfer speed in bytes per second.", Type: Gauge, Samples: []Sample{
+			{Labels: map[string]string{"direction": "down"}, Value: float64(stats.DownloadSpeed)},
+			{Labels: map[string]string{"direction": "up"}, Value: float64(stats.UploadSpeed)},
+		}},
+		{Name: "transmission_left_until_done_bytes", Help: "Data still to download.", Type: Gauge, Samples: []Sample{
+			{Value: float64(summary.LeftUntilDone)},
+		}},
+		{Name: "transmission_size_when_done_bytes", Help: "Data wanted by the torrents.", Type: Gauge, Samples: []Sample{
+			{Value: float64(summary.SizeWhenDone)},
+		}},
+		{Name: "transmission_peers", Help: "Connected peers per transport.", Type: Gauge, Samples: []Sample{
+			{Labels: map[string]string{"transport": "utp"}, Value: float64(summary.Peers.UTP)},
+			{Labels: map[string]string{"transport": "tcp"}, Value: float64(summary.Peers.TCP())},
+		}},
+		{Name: "transmission_encrypted_peers", Help: "Connected peers with an encrypted connection.", Type: Gauge, Samples: []Sample{
+			{Value: float64(summary.Peers.Encrypted)},
+		}},
+		{Name: "transmission_transferred_bytes", Help: "Data transferred since the daemon's first start.", Type: Counter, Samples: []Sample{
+			{Labels: map[string]string{"direction": "down"}, Value: float64(stats.CumulativeStats.DownloadedBytes)},
+			{Labels: map[string]string{"direction": "up"}, Value: float64(stats.CumulativeStats.UploadedBytes)},
+		}},
+		{Name: "transmission_active_seconds", Help: "Time the daemon has been active since its first start.", Type: Counter, Samples: []Sample{
+			{Value: float64(stats.CumulativeStats.SecondsActive)}, // holds seconds, see Stats.CumulativeActiveTime
+		}},
+	}, nil
+}
+
+// ChurnFamilies returns the churn of the peers of the torrents, e.g. from
+// transmission.ChurnTracker.Churn, to serve along with those of Collect
+func ChurnFamilies(churn []transmission.PeerChurn) []Family {
+	rates := Family{Name: "transmission_peer_churn_per_minute", Help: "Peers connecting or disconnecting per minute, decayed exponentially.", Type: Gauge}
+	peers := Family{Name: "transmission_peers_mean", Help: "Mean number of connected peers, decayed exponentially.", Type: Gauge}
+	for _, c := range churn {
+		rates.Samples = append(rates.Samples,
+			Sample{Labels: map[string]string{"hash": c.Hash, "name": c.Name, "direction": "connect"}, Value: c.Connects},
+			Sample{Labels: map[string]string{"hash": c.Hash, "name": c.Name, "direction": "disconnect"}, Value: c.Disconnects})
+		peers.Samples = append(peers.Samples, Sample{Labels: map[string]string{"hash": c.Hash, "name": c.Name}, Value: c.Peers})
+	}
+	return []Family{rates, peers}
+}
+
+// Content types of the formats
+const (
+	OpenMetricsType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	TextType        = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// WriteOpenMetrics writes the families in the OpenMetrics text format,
+// terminated by "# EOF"
+func WriteOpenMetrics(w io.Writer, families []Family) error {
+	if err := write(w, families, true); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "# EOF\n")
+	return err
+}
+
+// WriteText writes the families in the Prometheus text format 0.0.4, the
+// one Pushgateway takes
+func WriteText(w io.Writer, families []Family) error {
+	return write(w, families, false)
+}
+
+func write(w io.Writer, families []Family, openMetrics bool) error {
+	var b strings.Builder
+	for _, f := range families {
+		name := f.Name
+		sampleName := f.Name
+		if f.Type == Counter {
+			sampleName += "_total"
+			if !openMetrics {
+				name = sampleName // the family is named after its sample
+			}
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, escape(f.Help, false))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.Type)
+		for _, s := range f.Samples {
+			b.WriteString(sampleName)
+			writeLabels(&b, s.Labels)
+			b.WriteByte(' ')
+			b.WriteString(formatValue(s.Value))
+			b.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeLabels(b *strings.Builder, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, name := range sortedKeys(labels) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(b, `%s="%s"`, name, escape(labels[name], true))
+	}
+	b.WriteByte('}')
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// escape escapes a help text, or a label value with quotes set
+func escape(s string, quotes bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quotes {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
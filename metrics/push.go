@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Handler serves the metrics of src to Prometheus scrapes, in the
+// OpenMetrics format when the scraper accepts it
+func Handler(src Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families, _ := Collect(r.Context(), src) // transmission_up tells the failure
+		var buf bytes.Buffer
+		contentType := TextType
+		if strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text") {
+			contentType = OpenMetricsType
+			WriteOpenMetrics(&buf, families)
+		} else {
+			WriteText(&buf, families)
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+// Pusher sends metrics to a Prometheus Pushgateway
+type Pusher struct {
+	URL    string            // of the gateway, e.g. http://pushgateway:9091
+	Job    string            // job label of the pushed metrics
+	Group  map[string]string // other grouping labels, e.g. {"instance": "seedbox"}
+	Client *http.Client      // http.DefaultClient if nil
+}
+
+// Push replaces the metrics of the pusher's group with families
+func (p *Pusher) Push(ctx context.Context, families []Family) error {
+	if p.Job == "" {
+		return fmt.Errorf("metrics: push: no job")
+	}
+	endpoint := strings.TrimSuffix(p.URL, "/") + "/metrics/job/" + url.PathEscape(p.Job)
+	for _, name := range sortedKeys(p.Group) {
+		endpoint += "/" + url.PathEscape(name) + "/" + url.PathEscape(p.Group[name])
+	}
+	var buf bytes.Buffer
+	if err := WriteText(&buf, families); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", TextType)
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("metrics: push: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,527 @@
+package transmission
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MigrationStage is a step of moving a torrent between daemons
+type MigrationStage int
+
+const (
+	MigrateStopping  MigrationStage = iota // stopping the torrent on the source
+	MigrateAdding                          // adding it paused to the destination
+	MigrateVerifying                       // verifying its data on the destination
+	MigrateStarting                        // starting it on the destination
+	MigrateRemoving                        // removing it from the source, data kept
+	MigrateDone
+)
+
+func (s MigrationStage) String() string {
+	switch s {
+	case MigrateStopping:
+		return "stopping"
+	case MigrateAdding:
+		return "adding"
+	case MigrateVerifying:
+		return "verifying"
+	case MigrateStarting:
+		return "starting"
+	case MigrateRemoving:
+		return "removing"
+	case MigrateDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// MigrationProgress is reported by a Migration as the torrents go through
+// the stages
+type MigrationProgress struct {
+	Hash     string
+	Name     string
+	Stage    MigrationStage
+	Verified float64 // 0...1, during MigrateVerifying
+	Err      error   // the torrent failed at Stage
+}
+
+// Migration moves torrents from the daemon From to the daemon To, which
+// must see their data: shared storage, or a copy made beforehand. Each
+// torrent is stopped on From, added paused to To with its settings,
+// verified there, started if it was running and, with RemoveSource,
+// removed from From without its data.
+//
+// The progress of each torrent is kept in Store, so a Migration run again
+// after a crash or a failure resumes where it stopped.
+type Migration struct {
+	From, To *TransmissionClient
+
+	// Dirs translates the download dirs of From, as Remote, to those of
+	// To, as Local; dirs no rule matches are kept
+	Dirs PathMapper
+
+	// MetaInfo, if not nil, returns the .torrent file of a torrent of From.
+	// By default the file at its torrentFile is read, through TorrentFiles
+	// when set, e.g. with the config dir of From mounted locally, and the
+	// magnet link is used when the file can't be read.
+	MetaInfo     func(ctx context.Context, t *Torrent) ([]byte, error)
+	TorrentFiles PathMapper
+
+	// Parallel is the number of torrents moved at once, 1 if 0, and
+	// MaxVerifying bounds the verifications running at once on To, the
+	// heaviest stage on its disks, Parallel if 0
+	Parallel     int
+	MaxVerifying int
+
+	// FromSpeed and ToSpeed cap the global speed limits of the daemons
+	// during Migrate, e.g. to leave the disks to the verifications; lower
+	// limits of the daemons are kept, and all are restored at the end
+	FromSpeed, ToSpeed MigrationSpeed
+
+	RemoveSource bool
+	Store        Store // a MemoryStore if nil, not surviving the process
+
+	// Progress and Summary are called, never concurrently, as each torrent
+	// goes through the stages: Progress with the torrent, Summary with the
+	// state of the whole Migrate call
+	Progress func(MigrationProgress)
+	Summary  func(MigrationSummary)
+}
+
+// migrationRecord is what Store keeps of a torrent, in the "migration"
+// namespace by hash
+type migrationRecord struct {
+	Stage   MigrationStage `json:"stage"` // the next one to run
+	Name    string         `json:"name"`
+	Started bool           `json:"started"` // on From before the migration
+}
+
+// migrationFields are the fields read of the torrents of From
+var migrationFields = []string{"id", "name", "hashString", "status", "percentDone", "downloadDir",
+	"labels", "bandwidthPriority", "honorsSessionLimits", "downloadLimit", "downloadLimited",
+	"uploadLimit", "uploadLimited", "seedRatioLimit", "seedRatioMode", "seedIdleLimit", "seedIdleMode",
+	"wanted", "priorities", "torrentFile", "magnetLink"}
+
+// Migrate moves the torrents of From with the given ids or hashes, all of
+// them when there are none, Parallel at a time. A failing torrent doesn't
+// stop the others: it is restarted on From if it was running and its error
+// is returned, joined with the others'. When ctx is done before every
+// torrent was started, its error is joined too.
+func (m *Migration) Migrate(ctx context.Context, ids ...string) error {
+	if m.Store == nil {
+		m.Store = &MemoryStore{}
+	}
+	hashes, err := m.hashes(ctx, ids)
+	if err != nil {
+		return err
+	}
+	restore, err := m.capSpeeds(ctx)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	parallel := max(m.Parallel, 1)
+	r := &migrationRun{
+		Migration: m,
+		verifying: make(chan struct{}, cmp.Or(max(m.MaxVerifying, 0), parallel)),
+		last:      make(map[string]MigrationProgress),
+		summary:   MigrationSummary{Total: len(hashes), Started: time.Now()},
+	}
+	errs := make([]error, len(hashes))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(parallel, len(hashes)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = r.migrate(ctx, hashes[i])
+			}
+		}()
+	}
+	var undispatched error
+	for i := range hashes {
+		if err := ctx.Err(); err != nil {
+			undispatched = fmt.Errorf("%d torrents not migrated: %w", len(hashes)-i, err)
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return errors.Join(append(errs, undispatched)...)
+}
+
+// MigrationSummary is the state of a whole Migrate call, reported to
+// Migration.Summary whenever a torrent goes through a stage
+type MigrationSummary struct {
+	Total     int // torrents to migrate
+	Moving    int // being migrated
+	Verifying int // of Moving, verifying on To
+	Done      int
+	Failed    int
+	Started   time.Time
+}
+
+// Remaining returns the number of torrents not yet started
+func (s MigrationSummary) Remaining() int {
+	return s.Total - s.Moving - s.Done - s.Failed
+}
+
+// MigrationSpeed caps the global speed limits of a daemon while a
+// migration runs, in KB/s; 0 leaves a direction as it is
+type MigrationSpeed struct {
+	Down, Up int
+}
+
+// capSpeeds applies FromSpeed and ToSpeed, keeping the limits of the
+// daemons that are already lower, and returns the function restoring them
+func (m *Migration) capSpeeds(ctx context.Context) (restore func(), err error) {
+	var undo []func()
+	restore = func() {
+		for _, fn := range undo {
+			fn()
+		}
+	}
+	for _, c := range []struct {
+		client *TransmissionClient
+		speed  MigrationSpeed
+	}{{m.From, m.FromSpeed}, {m.To, m.ToSpeed}} {
+		if c.speed == (MigrationSpeed{}) {
+			continue
+		}
+		saved, err := c.client.getSpeedLimits(ctx)
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		capped := *saved
+		capped.Down, capped.DownEnabled = capSpeed(saved.Down, saved.DownEnabled, c.speed.Down)
+		capped.Up, capped.UpEnabled = capSpeed(saved.Up, saved.UpEnabled, c.speed.Up)
+		if err := c.client.setSpeedLimits(ctx, &capped); err != nil {
+			restore()
+			return nil, err
+		}
+		client := c.client
+		undo = append(undo, func() {
+			client.setSpeedLimits(context.WithoutCancel(ctx), saved)
+		})
+	}
+	return restore, nil
+}
+
+// capSpeed returns the lower of a speed limit and a cap, 0 being no cap
+func capSpeed(limit int, enabled bool, cap int) (int, bool) {
+	if cap <= 0 || (enabled && limit <= cap) {
+		return limit, enabled
+	}
+	return cap, true
+}
+
+// migrationRun is the state of one Migrate call shared by its workers
+type migrationRun struct {
+	*Migration
+	verifying chan struct{} // a slot per verification allowed at once
+
+	mu      sync.Mutex // serializes the callbacks too
+	last    map[string]MigrationProgress
+	summary MigrationSummary
+}
+
+// report passes p to Progress and the updated summary to Summary
+func (r *migrationRun) report(p MigrationProgress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Progress != nil {
+		r.Progress(p)
+	}
+	if prev, ok := r.last[p.Hash]; ok {
+		r.count(prev, -1)
+	}
+	r.last[p.Hash] = p
+	r.count(p, 1)
+	if r.Summary != nil {
+		r.Summary(r.summary)
+	}
+}
+
+func (r *migrationRun) count(p MigrationProgress, n int) {
+	switch {
+	case p.Err != nil:
+		r.summary.Failed += n
+	case p.Stage == MigrateDone:
+		r.summary.Done += n
+	default:
+		r.summary.Moving += n
+		if p.Stage == MigrateVerifying {
+			r.summary.Verifying += n
+		}
+	}
+}
+
+// hashes returns the hashes of the torrents of From to migrate
+func (m *Migration) hashes(ctx context.Context, ids []string) ([]string, error) {
+	var hashes []string
+	if len(ids) == 0 {
+		err := m.From.ForEachTorrent(ctx, []string{"hashString"}, func(t *Torrent) error {
+			hashes = append(hashes, t.InfoHash)
+			return nil
+		})
+		return hashes, err
+	}
+	for _, id := range ids {
+		t, err := m.From.getTorrentFields(ctx, id, []string{"hashString"})
+		if errors.Is(err, ErrNoTorrent) && len(id) == 40 {
+			hashes = append(hashes, id) // may be migrated already
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+		hashes = append(hashes, t.InfoHash)
+	}
+	return hashes, nil
+}
+
+// migrate runs the stages of one torrent left to run
+func (r *migrationRun) migrate(ctx context.Context, hash string) error {
+	rec, err := r.load(hash)
+	if err != nil {
+		r.report(MigrationProgress{Hash: hash, Err: err})
+		return err
+	}
+	report := func(p MigrationProgress) {
+		p.Hash, p.Name = hash, rec.Name
+		r.report(p)
+	}
+	if rec.Stage == MigrateDone {
+		report(MigrationProgress{Stage: MigrateDone, Verified: 1})
+		return nil
+	}
+	t, err := r.From.getTorrentFields(ctx, hash, migrationFields)
+	switch {
+	case errors.Is(err, ErrNoTorrent) && rec.Stage >= MigrateRemoving:
+		rec.Stage = MigrateDone // removed before the record was saved
+		report(MigrationProgress{Stage: MigrateDone, Verified: 1})
+		return r.save(hash, rec)
+	case err != nil:
+		report(MigrationProgress{Stage: rec.Stage, Err: err})
+		return fmt.Errorf("%s: %w", hash, err)
+	}
+	rec.Name = t.Name
+
+	for rec.Stage < MigrateDone {
+		if rec.Stage == MigrateStopping {
+			rec.Started = t.Status != TrStopped
+		}
+		if err := r.run(ctx, t, rec, report); err != nil {
+			stage := rec.Stage
+			report(MigrationProgress{Stage: stage, Err: err})
+			if stage < MigrateRemoving {
+				// the stage may have failed because ctx is done
+				if rec.Started {
+					r.From.torrentAction(context.WithoutCancel(ctx), "torrent-start", []string{hash})
+				}
+				rec.Stage = MigrateStopping // stop it again when resuming
+				r.save(hash, rec)
+			}
+			return fmt.Errorf("%s: %s: %w", t.Name, stage, err)
+		}
+		rec.Stage++
+		if err := r.save(hash, rec); err != nil {
+			report(MigrationProgress{Stage: rec.Stage, Err: err})
+			return err
+		}
+	}
+	report(MigrationProgress{Stage: MigrateDone, Verified: 1})
+	return nil
+}
+
+// run runs the stage of rec, once one of the verify slots is free for
+// MigrateVerifying
+func (r *migrationRun) run(ctx context.Context, t *Torrent, rec *migrationRecord, report func(MigrationProgress)) error {
+	if rec.Stage == MigrateVerifying {
+		select {
+		case r.verifying <- struct{}{}:
+			defer func() { <-r.verifying }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	report(MigrationProgress{Stage: rec.Stage})
+
+	ids := []string{t.InfoHash}
+	switch rec.Stage {
+	case MigrateStopping:
+		return r.From.torrentAction(ctx, "torrent-stop", ids)
+	case MigrateAdding:
+		return r.add(ctx, t)
+	case MigrateVerifying:
+		return r.verify(ctx, t, func(verified float64) {
+			report(MigrationProgress{Stage: MigrateVerifying, Verified: verified})
+		})
+	case MigrateStarting:
+		if !rec.Started {
+			return nil
+		}
+		return r.To.torrentAction(ctx, "torrent-start", ids)
+	case MigrateRemoving:
+		if !r.RemoveSource {
+			return nil
+		}
+		return r.From.rpc(ctx, "torrent-remove", map[string]interface{}{"ids": ids, "delete-local-data": false}, nil)
+	}
+	return nil
+}
+
+// add adds t paused to To, in its translated download dir and with its
+// settings
+func (m *Migration) add(ctx context.Context, t *Torrent) error {
+	cmd, err := m.addCmd(ctx, t)
+	if err != nil {
+		return err
+	}
+	dir := t.DownloadDir
+	if d, ok := m.Dirs.ToLocal(dir); ok {
+		dir = d
+	}
+	cmd.SetDownloadDir(dir)
+	cmd.SetPaused(true)
+	if _, err := m.To.AddTorrent(ctx, cmd, WithLabels(t.Labels...)); err != nil {
+		return err
+	}
+	return m.To.torrentSet(ctx, settings(t))
+}
+
+// addCmd returns a torrent-add command for t
+func (m *Migration) addCmd(ctx context.Context, t *Torrent) (*Command, error) {
+	if m.MetaInfo != nil {
+		b, err := m.MetaInfo(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		return NewAddCmdByBytes(b)
+	}
+	file, ok := t.TorrentFile, t.TorrentFile != ""
+	if ok && len(m.TorrentFiles) > 0 {
+		file, ok = m.TorrentFiles.ToLocal(t.TorrentFile)
+	}
+	if ok {
+		if cmd, err := NewAddCmdByFile(file); err == nil {
+			return cmd, nil
+		}
+	}
+	if t.MagnetLink == "" {
+		return nil, fmt.Errorf("no readable .torrent file nor magnet link")
+	}
+	return NewAddCmdByURL(t.MagnetLink), nil
+}
+
+// settings returns the torrent-set arguments giving a torrent the
+// settings of t
+func settings(t *Torrent) *TorrentSetArgs {
+	args := &TorrentSetArgs{
+		Ids:                 []string{t.InfoHash},
+		BandwidthPriority:   &t.BandwidthPriority,
+		HonorsSessionLimits: &t.HonorsSessionLimits,
+		DownloadLimit:       &t.DownloadLimit,
+		DownloadLimited:     &t.DownloadLimited,
+		UploadLimit:         &t.UploadLimit,
+		UploadLimited:       &t.UploadLimited,
+		SeedRatioLimit:      &t.SeedRatioLimit,
+		SeedRatioMode:       &t.SeedRatioMode,
+		SeedIdleLimit:       &t.SeedIdleLimit,
+		SeedIdleMode:        &t.SeedIdleMode,
+	}
+	for i, wanted := range t.Wanted {
+		if !wanted {
+			args.FilesUnwanted = append(args.FilesUnwanted, i)
+		}
+	}
+	for i, p := range t.Priorities {
+		switch p {
+		case PriorityHigh:
+			args.PriorityHigh = append(args.PriorityHigh, i)
+		case PriorityLow:
+			args.PriorityLow = append(args.PriorityLow, i)
+		}
+	}
+	return args
+}
+
+// verify verifies the data of t on To, which must be as complete as on
+// From. A torrent added from a magnet link is started until it has its
+// metadata.
+func (m *Migration) verify(ctx context.Context, t *Torrent, progress func(float64)) error {
+	id := t.InfoHash
+	if err := m.To.waitMetadata(ctx, id); err != nil {
+		return err
+	}
+	if err := m.To.torrentAction(ctx, "torrent-verify", []string{id}); err != nil {
+		return err
+	}
+	verified, err := m.To.waitVerified(ctx, id, progress)
+	if err != nil {
+		return err
+	}
+	if verified.PercentDone < t.PercentDone-1e-4 {
+		return &IncompleteDataError{
+			Torrent:     TorrentAdded{HashString: id, ID: verified.ID, Name: t.Name},
+			PercentDone: verified.PercentDone,
+		}
+	}
+	return nil
+}
+
+// waitMetadata returns once the torrent has its metadata, starting it
+// meanwhile if it lacks it
+func (ac *TransmissionClient) waitMetadata(ctx context.Context, id string) error {
+	fields := []string{"id", "status", "metadataPercentComplete"}
+	t, err := ac.getTorrentFields(ctx, id, fields)
+	if err != nil || t.MetadataPercentComplete >= 1 {
+		return err
+	}
+	if err := ac.torrentAction(ctx, "torrent-start", []string{id}); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(VerifyPollInterval)
+	defer ticker.Stop()
+	for t.MetadataPercentComplete < 1 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if t, err = ac.getTorrentFields(ctx, id, fields); err != nil {
+			return err
+		}
+	}
+	return ac.torrentAction(ctx, "torrent-stop", []string{id})
+}
+
+func (m *Migration) load(hash string) (*migrationRecord, error) {
+	b, err := m.Store.Get("migration", hash)
+	if errors.Is(err, ErrNotFound) {
+		return &migrationRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &migrationRecord{}
+	return rec, json.Unmarshal(b, rec)
+}
+
+func (m *Migration) save(hash string, rec *migrationRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return m.Store.Set("migration", hash, b)
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"sync"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/wire"
+)
+
+// DefaultTopicPrefix is the first element of the NATS subjects and MQTT
+// topics events are published on
+const DefaultTopicPrefix = "transmission"
+
+// eventTopic returns prefix, "torrent" or "daemon" and the event type
+// joined by sep, e.g. "transmission/torrent/completed"
+func eventTopic(prefix, sep string, e transmission.Event) string {
+	if prefix == "" {
+		prefix = DefaultTopicPrefix
+	}
+	scope := "torrent"
+	if e.Torrent == nil {
+		scope = "daemon"
+	}
+	return prefix + sep + scope + sep + e.Type.String()
+}
+
+// encodeEvent encodes e with codec, wire.JSON if nil
+func encodeEvent(codec wire.Codec, e transmission.Event) ([]byte, error) {
+	if codec == nil {
+		codec = wire.JSON
+	}
+	return codec.Marshal(e)
+}
+
+// brokerConn is a connection to a message broker, opened on first use and
+// reopened once when a publication fails on a connection the broker
+// dropped
+type brokerConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// publish runs send on the connection, dialing with open when there is
+// none, retrying once on a new connection if send fails on an old one
+func (b *brokerConn) publish(ctx context.Context, open func(ctx context.Context) (net.Conn, error), send func(c net.Conn) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		fresh := b.conn == nil
+		if fresh {
+			c, err := open(ctx)
+			if err != nil {
+				return err
+			}
+			b.conn = c
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			b.conn.SetDeadline(deadline)
+		}
+		err := send(b.conn)
+		if err == nil {
+			return nil
+		}
+		b.conn.Close()
+		b.conn = nil
+		if fresh || attempt > 0 || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the connection, if any
+func (b *brokerConn) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
+
+// dialBroker connects to the host of u, with TLS when tlsSchemes holds its
+// scheme
+func dialBroker(ctx context.Context, u *url.URL, defaultPort string, tlsSchemes ...string) (net.Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	var d net.Dialer
+	for _, s := range tlsSchemes {
+		if u.Scheme == s {
+			td := tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: u.Hostname()}}
+			return td.DialContext(ctx, "tcp", host)
+		}
+	}
+	return d.DialContext(ctx, "tcp", host)
+}
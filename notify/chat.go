@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// TelegramAPI is the base URL of the Telegram bot API
+var TelegramAPI = "https://api.telegram.org"
+
+// Telegram posts events to a chat through a bot, usually wrapped with
+// Only(t, transmission.EventCompleted, transmission.EventErrored)
+type Telegram struct {
+	Token    string // bot token from @BotFather
+	ChatID   string
+	Template *Template    // DefaultTemplate if nil
+	Client   *http.Client // http.DefaultClient if nil
+}
+
+func (t *Telegram) Notify(ctx context.Context, e transmission.Event) error {
+	subject, body, err := render(t.Template, e)
+	if err != nil {
+		return err
+	}
+	token := url.PathEscape(t.Token)
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", TelegramAPI, token)
+	err = postJSON(ctx, t.Client, endpoint, map[string]string{
+		"chat_id": t.ChatID,
+		"text":    subject + "\n\n" + body,
+	})
+	return redact(err, token)
+}
+
+// redact replaces secret in the url of a request error, which would
+// otherwise end in logs
+func redact(err error, secret string) error {
+	var ue *url.Error
+	if secret != "" && errors.As(err, &ue) {
+		ue.URL = strings.ReplaceAll(ue.URL, secret, "REDACTED")
+	}
+	return err
+}
+
+// Slack posts events to a Slack incoming webhook
+type Slack struct {
+	WebhookURL string
+	Template   *Template    // DefaultTemplate if nil
+	Client     *http.Client // http.DefaultClient if nil
+}
+
+func (s *Slack) Notify(ctx context.Context, e transmission.Event) error {
+	subject, body, err := render(s.Template, e)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, map[string]string{
+		"text": "*" + subject + "*\n" + body,
+	})
+}
+
+// postJSON posts v as JSON to endpoint, failing on a non 2xx status
+func postJSON(ctx context.Context, client *http.Client, endpoint string, v any) error {
+	return postJSONWith(ctx, client, endpoint, v, nil)
+}
+
+// postJSONWith is postJSON with a function completing the request, e.g.
+// with authentication headers
+func postJSONWith(ctx context.Context, client *http.Client, endpoint string, v any, prepare func(*http.Request)) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if prepare != nil {
+		prepare(req)
+	}
+	return doRequest(client, req)
+}
+
+// doRequest sends req, failing on a non 2xx status
+func doRequest(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("notify: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// Email sends events by mail through an SMTP server
+type Email struct {
+	Addr string    // host:port of the server
+	Auth smtp.Auth // may be nil, see smtp.PlainAuth
+	From string
+	To   []string
+
+	Template *Template // DefaultTemplate if nil
+}
+
+// Notify sends one mail; net/smtp has no cancellation, so ctx is only
+// checked before sending
+func (m *Email) Notify(ctx context.Context, e transmission.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	subject, body, err := render(m.Template, e)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Addr, m.Auth, m.From, m.To, m.message(subject, body))
+}
+
+func (m *Email) message(subject, body string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	// encoded when not plain ASCII, which also keeps line breaks of torrent
+	// names out of the headers
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
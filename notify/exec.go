@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// Exec runs a local command for every event, describing it with the
+// environment variables of Transmission's own scripts where they exist:
+//
+//	TR_EVENT            event type, e.g. "completed"
+//	TR_TIME_LOCALTIME   time of the event
+//	TR_TORRENT_ID       \
+//	TR_TORRENT_HASH      |
+//	TR_TORRENT_NAME      | not set for daemon events
+//	TR_TORRENT_DIR       |
+//	TR_TORRENT_STATUS    |
+//	TR_TORRENT_ERROR    /  error string, if any
+type Exec struct {
+	Path string
+	Args []string
+	Dir  string // working directory, the current one if empty
+}
+
+// Notify runs the command and waits for it, failing if it exits non-zero
+func (x *Exec) Notify(ctx context.Context, e transmission.Event) error {
+	cmd := exec.CommandContext(ctx, x.Path, x.Args...)
+	cmd.Dir = x.Dir
+	cmd.Env = append(os.Environ(), eventEnv(e)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", x.Path, err, out)
+	}
+	return nil
+}
+
+func eventEnv(e transmission.Event) []string {
+	env := []string{
+		"TR_EVENT=" + e.Type.String(),
+		"TR_TIME_LOCALTIME=" + e.Time.Format(time.ANSIC),
+	}
+	if t := e.Torrent; t != nil {
+		env = append(env,
+			"TR_TORRENT_ID="+strconv.Itoa(t.ID),
+			"TR_TORRENT_HASH="+t.InfoHash,
+			"TR_TORRENT_NAME="+t.Name,
+			"TR_TORRENT_DIR="+t.DownloadDir,
+			"TR_TORRENT_STATUS="+t.Status.String(),
+			"TR_TORRENT_ERROR="+t.ErrorString,
+		)
+	}
+	return env
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// Refresher rescans the part of a media library holding dir
+type Refresher interface {
+	Refresh(ctx context.Context, dir string) error
+}
+
+// LibraryRefresh is a Sink asking a media server to scan the directory a
+// torrent landed in when it completes, so it shows up without waiting for
+// the periodic scan. The refresher is chosen by label, e.g. "tv" to a Plex
+// TV Shows section and "movies" to the Movies one.
+type LibraryRefresh struct {
+	// Paths translates daemon paths to the paths the media server sees;
+	// paths no rule matches are passed as is
+	Paths   transmission.PathMapper
+	ByLabel map[string]Refresher // the first label of the torrent with an entry wins
+	Default Refresher            // for the other torrents, nil to skip them
+}
+
+func (l *LibraryRefresh) Notify(ctx context.Context, e transmission.Event) error {
+	if e.Type != transmission.EventCompleted {
+		return nil
+	}
+	r := l.Default
+	for _, label := range e.Torrent.Labels {
+		if lr, ok := l.ByLabel[label]; ok {
+			r = lr
+			break
+		}
+	}
+	if r == nil {
+		return nil
+	}
+	dir := torrentDir(e.Torrent)
+	if local, ok := l.Paths.ToLocal(dir); ok {
+		dir = local
+	}
+	return r.Refresh(ctx, dir)
+}
+
+// torrentDir returns the directory holding the data of t: its top folder
+// for multi-file torrents when the files are known, its download dir
+// otherwise
+func torrentDir(t *transmission.Torrent) string {
+	if len(t.Files) > 0 {
+		if top, _, ok := strings.Cut(t.Files[0].Name, "/"); ok {
+			return path.Join(t.DownloadDir, top)
+		}
+	}
+	return t.DownloadDir
+}
+
+// Plex scans a directory of a Plex library section
+type Plex struct {
+	URL     string       // e.g. http://plex:32400
+	Token   string       // X-Plex-Token
+	Section string       // library section id, see /library/sections
+	Client  *http.Client // http.DefaultClient if nil
+}
+
+func (p *Plex) Refresh(ctx context.Context, dir string) error {
+	q := url.Values{"path": {dir}, "X-Plex-Token": {p.Token}}
+	endpoint := fmt.Sprintf("%s/library/sections/%s/refresh?%s", strings.TrimSuffix(p.URL, "/"), url.PathEscape(p.Section), q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return doRequest(p.Client, req)
+}
+
+// Jellyfin reports a new directory to Jellyfin, or Emby, which scans the
+// libraries holding it
+type Jellyfin struct {
+	URL    string       // e.g. http://jellyfin:8096
+	APIKey string       // from Dashboard > API Keys
+	Client *http.Client // http.DefaultClient if nil
+}
+
+func (j *Jellyfin) Refresh(ctx context.Context, dir string) error {
+	endpoint := strings.TrimSuffix(j.URL, "/") + "/Library/Media/Updated"
+	body := map[string]any{
+		"Updates": []map[string]string{{"Path": dir, "UpdateType": "Created"}},
+	}
+	return postJSONWith(ctx, j.Client, endpoint, body, func(req *http.Request) {
+		req.Header.Set("X-Emby-Token", j.APIKey)
+	})
+}
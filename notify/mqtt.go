@@ -0,0 +1,221 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/wire"
+)
+
+// MQTT packet types, MQTT 3.1.1
+const (
+	mqttConnect = 1 << 4
+	mqttConnack = 2 << 4
+	mqttPublish = 3 << 4
+	mqttPuback  = 4 << 4
+)
+
+// MQTT publishes events to an MQTT 3.1.1 broker, encoded with the wire
+// package, on the topics Prefix/torrent/<event> and Prefix/daemon/<event>,
+// e.g. "transmission/torrent/completed" to trigger a media library scan
+// from home automation. The session is clean and without keep-alive, so
+// idle connections are only reopened when a publication fails.
+type MQTT struct {
+	Broker   string // tcp://host[:1883], or ssl:// or mqtts:// for TLS on 8883
+	ClientID string // random if empty
+	Username string
+	Password string
+	Prefix   string     // DefaultTopicPrefix if empty
+	QoS      byte       // 0 or 1; with 1 each publication waits for the broker's acknowledgement
+	Retain   bool       // the broker keeps the last event of each topic for new subscribers
+	Codec    wire.Codec // wire.JSON if nil
+
+	conn     brokerConn
+	packetID uint16
+}
+
+func (m *MQTT) Notify(ctx context.Context, e transmission.Event) error {
+	if m.QoS > 1 {
+		return fmt.Errorf("notify: mqtt: unsupported QoS %d", m.QoS)
+	}
+	payload, err := encodeEvent(m.Codec, e)
+	if err != nil {
+		return err
+	}
+	topic := eventTopic(m.Prefix, "/", e)
+	return m.conn.publish(ctx, m.open, func(c net.Conn) error {
+		flags := m.QoS << 1
+		if m.Retain {
+			flags |= 1
+		}
+		var body []byte
+		body = appendMQTTString(body, topic)
+		var id uint16
+		if m.QoS == 1 {
+			m.packetID++ // under the lock of m.conn
+			if m.packetID == 0 {
+				m.packetID = 1
+			}
+			id = m.packetID
+			body = binary.BigEndian.AppendUint16(body, id)
+		}
+		body = append(body, payload...)
+		if _, err := c.Write(mqttPacket(mqttPublish|flags, body)); err != nil {
+			return err
+		}
+		if m.QoS == 0 {
+			return nil
+		}
+		typ, ack, err := readMQTTPacket(c.(*mqttConn).r)
+		if err != nil {
+			return err
+		}
+		if typ&0xf0 != mqttPuback || len(ack) != 2 || binary.BigEndian.Uint16(ack) != id {
+			return errors.New("notify: mqtt: unexpected answer to publish")
+		}
+		return nil
+	})
+}
+
+// Close closes the connection to the broker
+func (m *MQTT) Close() error {
+	return m.conn.Close()
+}
+
+// mqttConn keeps the reader of a connection, which may hold data read
+// ahead
+type mqttConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (m *MQTT) open(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(m.Broker)
+	if err != nil {
+		return nil, err
+	}
+	port := "1883"
+	if u.Scheme == "ssl" || u.Scheme == "mqtts" || u.Scheme == "tls" {
+		port = "8883"
+	}
+	c, err := dialBroker(ctx, u, port, "ssl", "mqtts", "tls")
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+
+	clientID := m.ClientID
+	if clientID == "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		clientID = "go-transmission-" + hex.EncodeToString(b)
+	}
+	flags := byte(0x02) // clean session
+	var body []byte
+	body = appendMQTTString(body, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	if m.Username != "" {
+		flags |= 0x80
+	}
+	if m.Password != "" {
+		flags |= 0x40
+	}
+	body = append(body, flags, 0, 0) // keep-alive off
+	body = appendMQTTString(body, clientID)
+	if m.Username != "" {
+		body = appendMQTTString(body, m.Username)
+	}
+	if m.Password != "" {
+		body = appendMQTTString(body, m.Password)
+	}
+	if _, err := c.Write(mqttPacket(mqttConnect, body)); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(c)
+	typ, ack, err := readMQTTPacket(r)
+	if err == nil && (typ != mqttConnack || len(ack) != 2) {
+		err = errors.New("notify: mqtt: unexpected answer to connect")
+	}
+	if err == nil && ack[1] != 0 {
+		err = fmt.Errorf("notify: mqtt: connection refused, %s", mqttRefusals[min(int(ack[1]), len(mqttRefusals)-1)])
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &mqttConn{c, r}, nil
+}
+
+// mqttRefusals are the CONNACK return codes
+var mqttRefusals = []string{
+	"accepted",
+	"unacceptable protocol version",
+	"client id rejected",
+	"server unavailable",
+	"bad username or password",
+	"not authorized",
+	"unknown reason",
+}
+
+// mqttPacket returns the packet of type and flags typ with body
+func mqttPacket(typ byte, body []byte) []byte {
+	p := []byte{typ}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		p = append(p, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readMQTTPacket reads a packet, returning its type and flags and its body
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("notify: mqtt: bad packet length")
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return typ, body, nil
+}
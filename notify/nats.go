@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/wire"
+)
+
+// NATS publishes events on a NATS server, encoded with the wire package, on
+// the subjects Prefix.torrent.<event> and Prefix.daemon.<event>, e.g.
+// "transmission.torrent.completed". Each publication waits for the server
+// to acknowledge it with a PONG.
+type NATS struct {
+	URL    string     // nats://[user:password@]host[:4222], or tls:// for TLS
+	Token  string     // authentication token, if the server uses them
+	Prefix string     // DefaultTopicPrefix if empty
+	Codec  wire.Codec // wire.JSON if nil
+
+	conn brokerConn
+}
+
+func (n *NATS) Notify(ctx context.Context, e transmission.Event) error {
+	payload, err := encodeEvent(n.Codec, e)
+	if err != nil {
+		return err
+	}
+	subject := eventTopic(n.Prefix, ".", e)
+	return n.conn.publish(ctx, n.open, func(c net.Conn) error {
+		if _, err := fmt.Fprintf(c, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload); err != nil {
+			return err
+		}
+		return natsPong(c.(*natsConn).r, c)
+	})
+}
+
+// natsConn keeps the reader of a connection, which may hold data read
+// ahead
+type natsConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Close closes the connection to the server
+func (n *NATS) Close() error {
+	return n.conn.Close()
+}
+
+// natsInfo is the part of the server INFO used
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+func (n *NATS) open(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(n.URL)
+	if err != nil {
+		return nil, err
+	}
+	c, err := dialBroker(ctx, u, "4222")
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(c)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	var info natsInfo
+	rest, ok := strings.CutPrefix(line, "INFO ")
+	if !ok || json.Unmarshal([]byte(rest), &info) != nil {
+		c.Close()
+		return nil, fmt.Errorf("notify: nats: unexpected greeting %q", strings.TrimSpace(line))
+	}
+	if info.TLSRequired || u.Scheme == "tls" {
+		tc := tls.Client(c, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c, r = tc, bufio.NewReader(tc)
+	}
+
+	opts := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "go-transmission",
+		"lang":     "go",
+		"protocol": 0,
+	}
+	if u.User != nil {
+		opts["user"] = u.User.Username()
+		opts["pass"], _ = u.User.Password()
+	}
+	if n.Token != "" {
+		opts["auth_token"] = n.Token
+	}
+	b, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(c, "CONNECT %s\r\nPING\r\n", b); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := natsPong(r, c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &natsConn{c, r}, nil
+}
+
+// natsPong reads until the PONG answering our PING, answering the server's
+// PINGs and failing on -ERR
+func natsPong(r *bufio.Reader, w net.Conn) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := w.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("notify: nats: " + strings.Trim(strings.TrimPrefix(line, "-ERR "), "'"))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Package notify delivers transmission.Watcher events to external systems.
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// DefaultTimeout bounds the delivery of one event by Attach
+var DefaultTimeout = 30 * time.Second
+
+// Sink delivers events somewhere
+type Sink interface {
+	Notify(ctx context.Context, e transmission.Event) error
+}
+
+// SinkFunc adapts a function to a Sink
+type SinkFunc func(ctx context.Context, e transmission.Event) error
+
+func (f SinkFunc) Notify(ctx context.Context, e transmission.Event) error {
+	return f(ctx, e)
+}
+
+// Only returns a sink passing to s the events of the given types only
+func Only(s Sink, types ...transmission.EventType) Sink {
+	return SinkFunc(func(ctx context.Context, e transmission.Event) error {
+		for _, t := range types {
+			if e.Type == t {
+				return s.Notify(ctx, e)
+			}
+		}
+		return nil
+	})
+}
+
+// Attach delivers the events of w to s in order, from a goroutine of its
+// own so a slow sink doesn't hold up polling: events queue while one is
+// delivered. Failures are passed to onError if it is not nil.
+func Attach(w *transmission.Watcher, s Sink, onError func(error)) {
+	var mu sync.Mutex
+	var queue []transmission.Event
+	wake := make(chan struct{}, 1)
+	go func() {
+		for range wake {
+			for {
+				mu.Lock()
+				if len(queue) == 0 {
+					mu.Unlock()
+					break
+				}
+				e := queue[0]
+				queue = queue[1:]
+				mu.Unlock()
+				deliver(s, e, onError)
+			}
+		}
+	}()
+	w.OnEvent(func(e transmission.Event) {
+		mu.Lock()
+		queue = append(queue, e)
+		mu.Unlock()
+		select {
+		case wake <- struct{}{}:
+		default: // the worker is awake already
+		}
+	})
+}
+
+// deliver sends one event to s within DefaultTimeout
+func deliver(s Sink, e transmission.Event, onError func(error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	if err := s.Notify(ctx, e); err != nil && onError != nil {
+		onError(err)
+	}
+}
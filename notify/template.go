@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// Data is what message templates are executed with. The humanized string
+// fields are empty for daemon events, which have no torrent.
+type Data struct {
+	Event    string                // event type, e.g. "completed", see transmission.EventType
+	Time     time.Time             // time of the event
+	Error    string                // torrent error string, or the poll error of a daemon event
+	Torrent  *transmission.Torrent // raw torrent, nil for daemon events
+	Previous *transmission.Torrent // state before the change, may be nil
+
+	Name         string
+	Hash         string
+	Dir          string
+	Status       string // e.g. "Downloading"
+	Percent      string // e.g. "42.0%"
+	Size         string // e.g. "1.4 GiB"
+	Downloaded   string
+	Uploaded     string
+	Ratio        string
+	ETA          string // e.g. "1h2m0s", empty when unknown
+	DownloadRate string // e.g. "2.0 MiB/s"
+	UploadRate   string
+}
+
+// Funcs are available in templates: bytes formats a byte count, rate a
+// B/s speed and duration a number of seconds
+var Funcs = template.FuncMap{
+	"bytes": formatBytes,
+	"rate":  func(n uint64) string { return formatBytes(n) + "/s" },
+	"duration": func(s int64) string {
+		return (time.Duration(s) * time.Second).String()
+	},
+}
+
+// Template renders events to a subject line and a body
+type Template struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// DefaultTemplate is used by sinks without a Template
+var DefaultTemplate = MustParseTemplate(
+	`transmission: {{if .Torrent}}{{.Name}}{{else}}daemon{{end}} {{.Event}}`,
+	`{{if .Torrent -}}
+Torrent: {{.Name}}
+Event: {{.Event}}
+Time: {{.Time.Format "Mon, 02 Jan 2006 15:04:05 MST"}}
+Status: {{.Status}}
+Progress: {{.Percent}} of {{.Size}}
+Directory: {{.Dir}}
+{{if .Error}}Error: {{.Error}}
+{{end}}{{else -}}
+Daemon {{.Event}} at {{.Time.Format "Mon, 02 Jan 2006 15:04:05 MST"}}{{if .Error}}: {{.Error}}{{end}}
+{{end}}`)
+
+// ParseTemplate parses a subject and a body template, with Funcs
+func ParseTemplate(subject, body string) (*Template, error) {
+	s, err := template.New("subject").Funcs(Funcs).Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+	b, err := template.New("body").Funcs(Funcs).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{Subject: s, Body: b}, nil
+}
+
+// MustParseTemplate is like ParseTemplate but panics on error
+func MustParseTemplate(subject, body string) *Template {
+	t, err := ParseTemplate(subject, body)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render executes the templates for e; a newline in the subject is
+// replaced by a space
+func (t *Template) Render(e transmission.Event) (subject, body string, err error) {
+	data := NewData(e)
+	var sb, bb strings.Builder
+	if err := t.Subject.Execute(&sb, data); err != nil {
+		return "", "", err
+	}
+	if err := t.Body.Execute(&bb, data); err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(strings.NewReplacer("\r", "", "\n", " ").Replace(sb.String()))
+	return subject, bb.String(), nil
+}
+
+// NewData returns the template data describing e
+func NewData(e transmission.Event) *Data {
+	d := &Data{Event: e.Type.String(), Time: e.Time, Torrent: e.Torrent, Previous: e.Previous}
+	if e.Err != nil {
+		d.Error = e.Err.Error()
+	}
+	t := e.Torrent
+	if t == nil {
+		return d
+	}
+	d.Name = t.Name
+	d.Hash = t.InfoHash
+	d.Dir = t.DownloadDir
+	d.Status = t.Status.String()
+	d.Percent = fmt.Sprintf("%.1f%%", t.GetPercent())
+	d.Size = formatBytes(t.TotalSize)
+	d.Downloaded = formatBytes(t.DownloadedEver)
+	d.Uploaded = formatBytes(t.UploadedEver)
+	d.Ratio = t.Ratio()
+	if left, ok := t.TimeLeft(); ok {
+		d.ETA = left.String()
+	}
+	d.DownloadRate = formatBytes(t.DownloadRate()) + "/s"
+	d.UploadRate = formatBytes(t.UploadRate()) + "/s"
+	if t.ErrorString != "" {
+		d.Error = t.ErrorString
+	}
+	return d
+}
+
+// render renders e with t, or DefaultTemplate if t is nil
+func render(t *Template, e transmission.Event) (subject, body string, err error) {
+	if t == nil {
+		t = DefaultTemplate
+	}
+	return t.Render(e)
+}
+
+// formatBytes formats n with binary units, e.g. "1.4 GiB"
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,143 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Operations runs client calls concurrently, the way errgroup does: the
+// first failure cancels the context of the others, unless KeepGoing is
+// set, and Wait returns the failures. At most limit calls run at once.
+//
+//	ops := transmission.NewOperations(ctx, 4)
+//	for _, id := range ids {
+//		ops.Go(id, func(ctx context.Context) error { return client.SetLocation(ctx, id, dir, true) })
+//	}
+//	err := ops.Wait()
+type Operations struct {
+	// KeepGoing runs all the calls despite failures; set it before Go
+	KeepGoing bool
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	slots  chan struct{}
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	errs      []*OperationError
+	cancelled int // errs before the cancellation by a failure, -1 if none
+}
+
+// NewOperations returns a group running at most limit calls at once, no
+// limit if limit <= 0, with contexts derived from ctx
+func NewOperations(ctx context.Context, limit int) *Operations {
+	o := &Operations{cancelled: -1}
+	o.ctx, o.cancel = context.WithCancelCause(ctx)
+	if limit > 0 {
+		o.slots = make(chan struct{}, limit)
+	}
+	return o
+}
+
+// errOperationFailed is the cause of the cancellation after a failure
+var errOperationFailed = errors.New("another operation failed")
+
+// Go runs fn in a goroutine once a slot is free; name identifies the call
+// in the errors, e.g. the id of the torrent. A call whose turn comes after
+// the group is cancelled fails with the cause without running.
+func (o *Operations) Go(name string, fn func(ctx context.Context) error) {
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		if o.slots != nil {
+			select {
+			case o.slots <- struct{}{}:
+				defer func() { <-o.slots }()
+			case <-o.ctx.Done():
+				o.fail(name, context.Cause(o.ctx))
+				return
+			}
+		}
+		if err := o.ctx.Err(); err != nil {
+			o.fail(name, context.Cause(o.ctx))
+			return
+		}
+		if err := fn(o.ctx); err != nil {
+			o.fail(name, err)
+		}
+	}()
+}
+
+func (o *Operations) fail(name string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.errs = append(o.errs, &OperationError{Name: name, Err: err})
+	if !o.KeepGoing && o.cancelled < 0 {
+		o.cancelled = len(o.errs)
+		o.cancel(errOperationFailed)
+	}
+}
+
+// Wait waits for the calls and returns an *OperationsError holding their
+// failures, nil if none; the calls cancelled by the first failure aren't
+// counted as failures
+func (o *Operations) Wait() error {
+	o.wg.Wait()
+	defer o.cancel(nil)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var errs []*OperationError
+	for i, e := range o.errs {
+		if o.cancelled >= 0 && i >= o.cancelled &&
+			(errors.Is(e.Err, errOperationFailed) || errors.Is(e.Err, context.Canceled)) {
+			continue
+		}
+		errs = append(errs, e)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &OperationsError{Errors: errs}
+}
+
+// OperationError is the failure of one call of Operations
+type OperationError struct {
+	Name string
+	Err  error
+}
+
+func (e *OperationError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *OperationError) Unwrap() error { return e.Err }
+
+// OperationsError holds the failures of the calls of Operations, in the
+// order they happened; errors.As finds the typed errors of any of them,
+// e.g. an *AuthError
+type OperationsError struct {
+	Errors []*OperationError
+}
+
+func (e *OperationsError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d operations failed:", len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n\t" + err.Error())
+	}
+	return b.String()
+}
+
+func (e *OperationsError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
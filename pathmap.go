@@ -0,0 +1,78 @@
+package transmission
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PathRule maps the daemon directory Remote to the local directory Local
+type PathRule struct {
+	Remote string // daemon path, forward slashes
+	Local  string // local path, in the OS's format
+}
+
+// PathMapper translates paths between the daemon and a machine mounting
+// its storage somewhere else, e.g. /downloads -> /mnt/seedbox. The rule with
+// the longest matching prefix wins; prefixes only match whole path elements.
+type PathMapper []PathRule
+
+// ToLocal translates a daemon path; ok is false if no rule matches
+func (m PathMapper) ToLocal(remote string) (local string, ok bool) {
+	best := -1
+	for i, r := range m {
+		if hasPathPrefix(path.Clean(remote), path.Clean(r.Remote), "/") &&
+			(best < 0 || len(r.Remote) > len(m[best].Remote)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	rest := strings.TrimPrefix(path.Clean(remote), path.Clean(m[best].Remote))
+	return filepath.Join(m[best].Local, filepath.FromSlash(rest)), true
+}
+
+// ToRemote translates a local path back; ok is false if no rule matches
+func (m PathMapper) ToRemote(local string) (remote string, ok bool) {
+	sep := string(filepath.Separator)
+	best := -1
+	for i, r := range m {
+		if hasPathPrefix(filepath.Clean(local), filepath.Clean(r.Local), sep) &&
+			(best < 0 || len(r.Local) > len(m[best].Local)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	rest := strings.TrimPrefix(filepath.Clean(local), filepath.Clean(m[best].Local))
+	return path.Join(m[best].Remote, filepath.ToSlash(rest)), true
+}
+
+// DownloadDir returns the local path of the torrent's downloadDir
+func (m PathMapper) DownloadDir(t *Torrent) (string, bool) {
+	return m.ToLocal(t.DownloadDir)
+}
+
+// FilePaths returns the local paths of the torrent's files, in the order of
+// t.Files
+func (m PathMapper) FilePaths(t *Torrent) ([]string, bool) {
+	dir, ok := m.DownloadDir(t)
+	if !ok {
+		return nil, false
+	}
+	paths := make([]string, 0, len(t.Files))
+	for _, f := range t.Files {
+		paths = append(paths, filepath.Join(dir, filepath.FromSlash(f.Name)))
+	}
+	return paths, true
+}
+
+// hasPathPrefix reports whether prefix is p or one of its parents
+func hasPathPrefix(p, prefix, sep string) bool {
+	if p == prefix || strings.HasSuffix(prefix, sep) && strings.HasPrefix(p, prefix) {
+		return true
+	}
+	return strings.HasPrefix(p, prefix+sep)
+}
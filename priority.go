@@ -0,0 +1,66 @@
+package transmission
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Priority is a bandwidth or file priority
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "Low"
+	case PriorityNormal:
+		return "Normal"
+	case PriorityHigh:
+		return "High"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalJSON encodes the priority as the daemon does, as a number
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(p), 10), nil
+}
+
+// UnmarshalJSON decodes a number, or a name as String returns it in any
+// case, e.g. "high" in a configuration file
+func (p *Priority) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalEnum(b, "priority", PriorityLow, PriorityNormal, PriorityHigh)
+	if err == nil {
+		*p = n
+	}
+	return err
+}
+
+// unmarshalEnum decodes a number, or one of the names of values
+func unmarshalEnum[T interface {
+	~int
+	fmt.Stringer
+}](b []byte, what string, values ...T) (T, error) {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		var n int
+		if err := json.Unmarshal(b, &n); err != nil {
+			return 0, fmt.Errorf("%s: %s is neither a number nor a name", what, b)
+		}
+		return T(n), nil
+	}
+	for _, v := range values {
+		if strings.EqualFold(name, v.String()) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: unknown name %q", what, name)
+}
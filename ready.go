@@ -0,0 +1,56 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Delays between the probes of WaitReady
+const (
+	readyMinDelay = 250 * time.Millisecond
+	readyMaxDelay = 5 * time.Second
+)
+
+// WithProbe replaces the request New and WaitReady send to check that the
+// daemon answers, a session-get by default
+func WithProbe(probe func(ctx context.Context, c *TransmissionClient) error) Option {
+	return func(ac *TransmissionClient) {
+		ac.probe = probe
+	}
+}
+
+// probeOnce checks once that the daemon answers
+func (ac *TransmissionClient) probeOnce(ctx context.Context) error {
+	if ac.probe != nil {
+		return ac.probe(ctx, ac)
+	}
+	_, err := ac.ExecuteCommandContext(ctx, &Command{Method: "session-get"})
+	return err
+}
+
+// WaitReady probes the daemon until it answers, backing off between
+// attempts, for at most timeout, or without limit but ctx if timeout is 0.
+// An AuthError is returned at once, retrying wouldn't help; otherwise the
+// error is the one of the last probe.
+func (ac *TransmissionClient) WaitReady(ctx context.Context, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	delay := readyMinDelay
+	for {
+		err := ac.probeOnce(ctx)
+		var ae *AuthError
+		if err == nil || errors.As(err, &ae) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, readyMaxDelay)
+	}
+}
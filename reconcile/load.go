@@ -0,0 +1,327 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/unix2dos/go-transmission/v2/internal/yaml"
+)
+
+// SchemaVersion is the version of the document format this package reads
+const SchemaVersion = 1
+
+// maxDocument bounds the size of a document fetched from a url
+const maxDocument = 8 << 20
+
+// Problem is something wrong at a place of a document, e.g.
+// torrents[2].limits.download
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// ValidationError lists all the problems of a document
+type ValidationError struct {
+	Source   string // file or url of the document, if any
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	prefix := "invalid document"
+	if e.Source != "" {
+		prefix = e.Source
+	}
+	if len(e.Problems) == 1 {
+		return prefix + ": " + e.Problems[0].String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d problems:", prefix, len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n\t" + p.String())
+	}
+	return b.String()
+}
+
+// Validate reports every problem of the document as a *ValidationError
+func (doc *Document) Validate() error {
+	var problems []Problem
+	problem := func(path, format string, args ...interface{}) {
+		problems = append(problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if doc.Version != 0 && doc.Version != SchemaVersion {
+		problem("version", "unsupported version %d, this build reads version %d", doc.Version, SchemaVersion)
+	}
+	if doc.DeleteData && !doc.Prune {
+		problem("deleteData", "has no effect without prune")
+	}
+	names := make(map[string]int)
+	for i, spec := range doc.Torrents {
+		at := fmt.Sprintf("torrents[%d]", i)
+		switch first, dup := names[spec.Name]; {
+		case spec.Name == "":
+			problem(at+".name", "missing, every torrent needs a unique name")
+		case strings.Contains(spec.Name, ","):
+			problem(at+".name", "%q has a comma, which labels can't hold", spec.Name)
+		case dup:
+			problem(at+".name", "%q is already the name of torrents[%d]", spec.Name, first)
+		default:
+			names[spec.Name] = i
+		}
+		if err := checkSource(spec.Source); err != nil {
+			problem(at+".source", "%v", err)
+		}
+		if spec.DownloadDir != "" && !path.IsAbs(spec.DownloadDir) {
+			problem(at+".downloadDir", "%q is not absolute, the daemon resolves it against its own working dir", spec.DownloadDir)
+		}
+		for j, l := range spec.Labels {
+			lat := fmt.Sprintf("%s.labels[%d]", at, j)
+			switch {
+			case strings.TrimSpace(l) == "":
+				problem(lat, "empty label")
+			case strings.Contains(l, ","):
+				problem(lat, "%q has a comma, which labels can't hold", l)
+			case strings.HasPrefix(l, ManagedLabelPrefix), strings.HasPrefix(l, SpecLabelPrefix):
+				problem(lat, "%q is reserved, the %s and %s prefixes mark managed torrents", l, ManagedLabelPrefix, SpecLabelPrefix)
+			}
+		}
+		if l := spec.Limits.Download; l != nil && *l < 0 {
+			problem(at+".limits.download", "%d is negative, use 0 for no limit", *l)
+		}
+		if l := spec.Limits.Upload; l != nil && *l < 0 {
+			problem(at+".limits.upload", "%d is negative, use 0 for no limit", *l)
+		}
+		if r := spec.Limits.SeedRatio; r != nil && *r < 0 {
+			problem(at+".limits.seedRatio", "%g is negative, use 0 to seed forever", *r)
+		}
+		if spec.State != "" && spec.State != Started && spec.State != Stopped {
+			problem(at+".state", "%q is not %q or %q", spec.State, Started, Stopped)
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// checkSource tells what is wrong with the source of a torrent
+func checkSource(source string) error {
+	switch {
+	case source == "":
+		return errors.New("missing, a magnet link, url or path of a .torrent file is needed")
+	case strings.HasPrefix(source, "magnet:"):
+		u, err := url.Parse(source)
+		if err != nil {
+			return fmt.Errorf("bad magnet link: %v", err)
+		}
+		for _, xt := range u.Query()["xt"] {
+			if strings.HasPrefix(xt, "urn:btih:") {
+				return nil
+			}
+		}
+		return errors.New("magnet link without an xt=urn:btih: info hash")
+	case strings.Contains(source, "://"):
+		u, err := url.Parse(source)
+		if err != nil {
+			return fmt.Errorf("bad url: %v", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("unsupported url scheme %q, use http or https", u.Scheme)
+		}
+		if u.Host == "" {
+			return errors.New("url without a host")
+		}
+	}
+	return nil
+}
+
+// Parse decodes a document, in JSON or YAML, and validates it. Unknown
+// fields are problems, so typos don't go unnoticed.
+func Parse(data []byte) (*Document, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		var err error
+		if data, err = yaml.ToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var doc Document
+	if err := dec.Decode(&doc); err != nil {
+		return nil, decodeError(err)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// decodeError turns the errors of encoding/json into problems
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &ValidationError{Problems: []Problem{{
+			Path:    fieldPath(typeErr.Field),
+			Message: fmt.Sprintf("expected %s, got %s", kindName(typeErr.Type), typeErr.Value),
+		}}}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &ValidationError{Problems: []Problem{{Message: "unknown field " + field}}}
+	}
+	return err
+}
+
+// fieldPath writes the json path of a field, torrents.0.name, the way
+// Validate does, torrents[0].name
+func fieldPath(field string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Struct, reflect.Map:
+		return "a mapping"
+	}
+	return t.String()
+}
+
+// LoadFile reads the document in the file name. Relative .torrent paths
+// in it are relative to the directory of the file.
+func LoadFile(name string) (*Document, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := Parse(b)
+	if err != nil {
+		return nil, sourced(name, err)
+	}
+	for i := range doc.Torrents {
+		s := &doc.Torrents[i].Source
+		if !isURL(*s) && !filepath.IsAbs(*s) {
+			*s = filepath.Join(filepath.Dir(name), *s)
+		}
+	}
+	return doc, nil
+}
+
+// FromFile returns a Load function reading the document in the file name
+// on every pass
+func FromFile(name string) func(context.Context) (*Document, error) {
+	return func(context.Context) (*Document, error) {
+		return LoadFile(name)
+	}
+}
+
+// FromURL returns a Load function fetching the document at rawURL, e.g.
+// the raw view of a file in a git forge; client is http.DefaultClient if
+// nil. The document is downloaded again only when the server tells it
+// changed, through its ETag. Relative sources in the document are urls
+// relative to rawURL.
+func FromURL(rawURL string, client *http.Client) func(context.Context) (*Document, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var (
+		mu   sync.Mutex
+		etag string
+		last *Document
+	)
+	return func(ctx context.Context) (*Document, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil && etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusNotModified && last != nil {
+			return last, nil
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", rawURL, res.Status)
+		}
+		b, err := io.ReadAll(io.LimitReader(res.Body, maxDocument+1))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rawURL, err)
+		}
+		if len(b) > maxDocument {
+			return nil, fmt.Errorf("%s: document larger than %d bytes", rawURL, maxDocument)
+		}
+		doc, err := Parse(b)
+		if err != nil {
+			return nil, sourced(rawURL, err)
+		}
+		base := res.Request.URL // after redirects
+		for i := range doc.Torrents {
+			s := &doc.Torrents[i].Source
+			if !isURL(*s) {
+				ref, err := url.Parse(*s)
+				if err != nil {
+					return nil, sourced(rawURL, &ValidationError{Problems: []Problem{{
+						Path:    fmt.Sprintf("torrents[%d].source", i),
+						Message: fmt.Sprintf("bad relative url: %v", err),
+					}}})
+				}
+				*s = base.ResolveReference(ref).String()
+			}
+		}
+		etag, last = res.Header.Get("ETag"), doc
+		return doc, nil
+	}
+}
+
+// sourced names the file or url of a document in its error
+func sourced(source string, err error) error {
+	var v *ValidationError
+	if errors.As(err, &v) {
+		v.Source = source
+		return v
+	}
+	return fmt.Errorf("%s: %w", source, err)
+}
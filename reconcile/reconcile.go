@@ -0,0 +1,524 @@
+// Package reconcile keeps a daemon in the state a document declares, the
+// way Kubernetes controllers do: a Reconciler compares the torrents the
+// document lists with those of the daemon and adds, changes, starts, stops
+// and removes torrents until they match, on every pass.
+//
+// The torrents a Reconciler manages carry the label ManagedLabelPrefix
+// followed by the name of their spec; the others are left alone. A spec is
+// known by its name only: changing the source of a spec doesn't replace the
+// torrent, renaming the spec does when the document prunes.
+//
+// Managed torrents also carry the label SpecLabelPrefix followed by the
+// hash of the spec last applied to them, so a pass only reads the labels
+// and status of torrents whose spec didn't change. Changes made to them by
+// hand are undone at the full comparisons Reconciler.Resync asks for.
+//
+// Documents are JSON or YAML, so they can live in version control and be
+// read by FromFile or FromURL on every pass:
+//
+//	version: 1
+//	prune: true
+//	torrents:
+//	  - name: debian
+//	    source: magnet:?xt=urn:btih:...
+//	    downloadDir: /data/iso
+//	    labels: [linux]
+//	    limits: {upload: 500, seedRatio: 2}
+//	  - name: archive
+//	    source: torrents/archive.torrent
+//	    state: stopped
+package reconcile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// ManagedLabelPrefix prefixes the label naming the spec of a managed
+// torrent
+const ManagedLabelPrefix = "managed:"
+
+// SpecLabelPrefix prefixes the label holding the hash of the spec last
+// applied to a managed torrent, see TorrentSpec.Hash
+const SpecLabelPrefix = "spec:"
+
+// State is the desired state of a torrent
+type State string
+
+const (
+	Started State = "started"
+	Stopped State = "stopped"
+)
+
+// Limits are the per-torrent limits of a spec; nil fields are left as the
+// daemon has them
+type Limits struct {
+	Download  *int     `json:"download,omitempty"`  // KB/s, 0 for no limit
+	Upload    *int     `json:"upload,omitempty"`    // KB/s, 0 for no limit
+	SeedRatio *float64 `json:"seedRatio,omitempty"` // 0 to seed forever
+}
+
+// TorrentSpec is the desired state of one torrent
+type TorrentSpec struct {
+	Name        string   `json:"name"`   // identifies the torrent in the document
+	Source      string   `json:"source"` // magnet link, url or path of a .torrent file
+	DownloadDir string   `json:"downloadDir,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Limits      Limits   `json:"limits,omitempty"`
+	State       State    `json:"state,omitempty"` // Started when empty
+}
+
+// Hash returns a digest of the attributes of the spec, the same for equal
+// specs whatever the order of their labels
+func (spec *TorrentSpec) Hash() string {
+	state := spec.State
+	if state == "" {
+		state = Started
+	}
+	dir := spec.DownloadDir
+	if dir != "" {
+		dir = path.Clean(dir)
+	}
+	b, _ := json.Marshal(TorrentSpec{
+		Name:        spec.Name,
+		Source:      spec.Source,
+		DownloadDir: dir,
+		Labels:      sortedLabels(spec.Labels),
+		Limits:      spec.Limits,
+		State:       state,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Document is the desired state of the daemon, see Parse for its formats
+type Document struct {
+	Version  int           `json:"version,omitempty"` // SchemaVersion, the current one when 0
+	Torrents []TorrentSpec `json:"torrents"`
+
+	// Prune removes the managed torrents the document doesn't list, with
+	// their data when DeleteData is set
+	Prune      bool `json:"prune,omitempty"`
+	DeleteData bool `json:"deleteData,omitempty"`
+}
+
+// Kind is the kind of an action of a pass
+type Kind string
+
+const (
+	Add    Kind = "add"
+	Update Kind = "update" // labels or limits
+	Move   Kind = "move"
+	Start  Kind = "start"
+	Stop   Kind = "stop"
+	Remove Kind = "remove"
+)
+
+// Action is a change a pass makes to the daemon
+type Action struct {
+	Kind   Kind
+	Name   string // of the spec
+	Hash   string // of the torrent, empty for Add
+	Detail string
+	Err    error // of the change, once applied
+
+	spec *TorrentSpec
+	set  transmission.TorrentSetArgs
+}
+
+func (a Action) String() string {
+	s := string(a.Kind) + " " + a.Name
+	if a.Detail != "" {
+		s += ": " + a.Detail
+	}
+	if a.Err != nil {
+		s += ": " + a.Err.Error()
+	}
+	return s
+}
+
+// Client is what a Reconciler needs from transmission.TransmissionClient
+type Client interface {
+	ForEachTorrent(ctx context.Context, fields []string, fn func(*transmission.Torrent) error) error
+	AddTorrent(ctx context.Context, cmd *transmission.Command, opts ...transmission.AddOption) (transmission.TorrentAdded, error)
+	SetTorrents(ctx context.Context, ids []string, args transmission.TorrentSetArgs, opts transmission.ChunkOptions) (*transmission.BulkResult, error)
+	SetLocation(ctx context.Context, id string, location string, move bool) error
+	Call(ctx context.Context, method string, args, out interface{}) error
+}
+
+// Reconciler makes the daemon of Client match the document Load returns
+type Reconciler struct {
+	Client Client
+
+	// Load returns the desired state; it is called on every pass, so the
+	// document may change between them
+	Load func(ctx context.Context) (*Document, error)
+
+	// OnPass, if not nil, is called after every pass of Run with the
+	// actions taken and the error of the pass
+	OnPass func(actions []Action, err error)
+
+	// Resync is how often a pass compares every attribute of the managed
+	// torrents, undoing the changes made by hand; in between only the
+	// torrents whose spec hash changed are. Zero never compares the others.
+	Resync time.Duration
+
+	mu       sync.Mutex
+	lastFull time.Time
+}
+
+// Static returns a Load function always returning doc
+func Static(doc *Document) func(context.Context) (*Document, error) {
+	return func(context.Context) (*Document, error) {
+		return doc, nil
+	}
+}
+
+// listFields are the torrent fields a pass reads of every torrent, fields
+// those it reads of the torrents it compares
+var (
+	listFields = []string{"id", "name", "hashString", "labels", "status"}
+	fields     = append(slices.Clone(listFields),
+		"downloadDir", "downloadLimit", "downloadLimited", "uploadLimit", "uploadLimited",
+		"seedRatioLimit", "seedRatioMode")
+)
+
+// Plan returns the actions making the daemon match doc, without taking them
+func (r *Reconciler) Plan(ctx context.Context, doc *Document) ([]Action, error) {
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	now := time.Now()
+	full := r.Resync > 0 && now.Sub(r.lastFull) >= r.Resync
+	r.mu.Unlock()
+
+	managed := make(map[string]*transmission.Torrent)
+	var extra []*transmission.Torrent // other torrents claiming a managed name
+	err := r.Client.ForEachTorrent(ctx, listFields, func(t *transmission.Torrent) error {
+		name, ok := managedName(t)
+		if !ok {
+			return nil
+		}
+		if _, dup := managed[name]; dup {
+			extra = append(extra, t)
+		} else {
+			managed[name] = t
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var compare []string // hashes of the torrents whose attributes are compared
+	for i := range doc.Torrents {
+		spec := &doc.Torrents[i]
+		if t, ok := managed[spec.Name]; ok && (full || specHash(t) != spec.Hash()) {
+			compare = append(compare, t.InfoHash)
+		}
+	}
+	if len(compare) > 0 {
+		var out struct {
+			Torrents []*transmission.Torrent `json:"torrents"`
+		}
+		if err := r.Client.Call(ctx, "torrent-get", map[string]interface{}{"ids": compare, "fields": fields}, &out); err != nil {
+			return nil, err
+		}
+		for _, t := range out.Torrents {
+			if name, ok := managedName(t); ok && managed[name] != nil && managed[name].InfoHash == t.InfoHash {
+				managed[name] = t
+			}
+		}
+	}
+	if full {
+		r.mu.Lock()
+		r.lastFull = now
+		r.mu.Unlock()
+	}
+
+	var actions []Action
+	listed := make(map[string]bool)
+	for i := range doc.Torrents {
+		spec := &doc.Torrents[i]
+		listed[spec.Name] = true
+		t, ok := managed[spec.Name]
+		if !ok {
+			actions = append(actions, Action{Kind: Add, Name: spec.Name, Detail: spec.Source, spec: spec})
+			continue
+		}
+		actions = append(actions, diff(spec, t, slices.Contains(compare, t.InfoHash))...)
+	}
+	if doc.Prune {
+		for name, t := range managed {
+			if !listed[name] {
+				extra = append(extra, t)
+			}
+		}
+		for _, t := range extra {
+			name, _ := managedName(t)
+			actions = append(actions, Action{Kind: Remove, Name: name, Hash: t.InfoHash, Detail: t.Name})
+		}
+	}
+	return actions, nil
+}
+
+// Reconcile loads the document and takes the actions making the daemon
+// match it. The actions are returned with their errors; once an action of
+// a torrent fails, the next ones of the torrent are skipped, so its spec
+// hash isn't updated and the next pass compares it again.
+func (r *Reconciler) Reconcile(ctx context.Context) ([]Action, error) {
+	doc, err := r.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: loading the document: %w", err)
+	}
+	actions, err := r.Plan(ctx, doc)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: %w", err)
+	}
+	var errs []error
+	failed := make(map[string]bool)
+	for i := range actions {
+		a := &actions[i]
+		if failed[a.Name] {
+			a.Err = errSkipped
+			continue
+		}
+		a.Err = r.apply(ctx, a, doc)
+		if a.Err != nil {
+			failed[a.Name] = true
+			errs = append(errs, fmt.Errorf("%s %s: %w", a.Kind, a.Name, a.Err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return actions, fmt.Errorf("reconcile: %w", err)
+	}
+	return actions, nil
+}
+
+// errSkipped is the error of the actions following a failed one
+var errSkipped = errors.New("skipped after a failed change")
+
+// Run reconciles every interval until ctx is done. Failed passes are
+// reported to OnPass and retried at the next interval.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		actions, err := r.Reconcile(ctx)
+		if r.OnPass != nil && ctx.Err() == nil {
+			r.OnPass(actions, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Reconciler) apply(ctx context.Context, a *Action, doc *Document) error {
+	switch a.Kind {
+	case Add:
+		return r.add(ctx, a)
+	case Update:
+		_, err := r.Client.SetTorrents(ctx, []string{a.Hash}, a.set, transmission.ChunkOptions{})
+		return err
+	case Move:
+		return r.Client.SetLocation(ctx, a.Hash, a.Detail, true)
+	case Start:
+		return r.Client.Call(ctx, "torrent-start", map[string]interface{}{"ids": []string{a.Hash}}, nil)
+	case Stop:
+		return r.Client.Call(ctx, "torrent-stop", map[string]interface{}{"ids": []string{a.Hash}}, nil)
+	case Remove:
+		return r.Client.Call(ctx, "torrent-remove", map[string]interface{}{
+			"ids":               []string{a.Hash},
+			"delete-local-data": doc.DeleteData,
+		}, nil)
+	}
+	return fmt.Errorf("unknown action %q", a.Kind)
+}
+
+// add adds the torrent of a spec, then sets its labels and limits, which
+// also adopts a torrent the daemon already had
+func (r *Reconciler) add(ctx context.Context, a *Action) error {
+	spec := a.spec
+	var cmd *transmission.Command
+	if isURL(spec.Source) {
+		cmd = transmission.NewAddCmdByURL(spec.Source)
+	} else {
+		var err error
+		if cmd, err = transmission.NewAddCmdByFile(spec.Source); err != nil {
+			return err
+		}
+	}
+	if spec.DownloadDir != "" {
+		cmd.SetDownloadDir(spec.DownloadDir)
+	}
+	cmd.SetPaused(spec.State == Stopped)
+	added, err := r.Client.AddTorrent(ctx, cmd, transmission.WithLabels(addLabels(spec)...))
+	if err != nil {
+		return err
+	}
+	a.Hash = added.HashString
+	set := limitArgs(spec, nil)
+	want := sortedLabels(addLabels(spec))
+	set.Labels = &want
+	_, err = r.Client.SetTorrents(ctx, []string{a.Hash}, set, transmission.ChunkOptions{})
+	return err
+}
+
+// diff returns the actions making t match spec, comparing only its state
+// unless full is set. The labels, spec hash included, are changed after
+// the location.
+func diff(spec *TorrentSpec, t *transmission.Torrent, full bool) []Action {
+	var actions []Action
+	action := func(kind Kind, detail string) *Action {
+		actions = append(actions, Action{Kind: kind, Name: spec.Name, Hash: t.InfoHash, Detail: detail, spec: spec})
+		return &actions[len(actions)-1]
+	}
+
+	if full {
+		if spec.DownloadDir != "" && path.Clean(spec.DownloadDir) != path.Clean(t.DownloadDir) {
+			action(Move, spec.DownloadDir)
+		}
+		set := limitArgs(spec, t)
+		want := labels(spec)
+		if !slices.Equal(want, sortedLabels(t.Labels)) {
+			set.Labels = &want
+		}
+		if changes := describe(set); changes != "" {
+			action(Update, changes).set = set
+		}
+	}
+	switch {
+	case spec.State == Stopped && t.Status != transmission.TrStopped:
+		action(Stop, "")
+	case spec.State != Stopped && t.Status == transmission.TrStopped:
+		action(Start, "")
+	}
+	return actions
+}
+
+// limitArgs returns the torrent-set arguments changing the limits of t to
+// those of spec, all of those spec sets when t is nil
+func limitArgs(spec *TorrentSpec, t *transmission.Torrent) transmission.TorrentSetArgs {
+	var set transmission.TorrentSetArgs
+	if l := spec.Limits.Download; l != nil {
+		limited := *l > 0
+		if t == nil || limited != t.DownloadLimited || limited && *l != t.DownloadLimit {
+			set.DownloadLimited = &limited
+			if limited {
+				set.DownloadLimit = l
+			}
+		}
+	}
+	if l := spec.Limits.Upload; l != nil {
+		limited := *l > 0
+		if t == nil || limited != t.UploadLimited || limited && *l != t.UploadLimit {
+			set.UploadLimited = &limited
+			if limited {
+				set.UploadLimit = l
+			}
+		}
+	}
+	if r := spec.Limits.SeedRatio; r != nil {
+		mode := transmission.UnlimitedMode
+		if *r > 0 {
+			mode = transmission.OverrideLimit
+		}
+		if t == nil || mode != t.SeedRatioMode || mode == transmission.OverrideLimit && *r != t.SeedRatioLimit {
+			set.SeedRatioMode = &mode
+			if mode == transmission.OverrideLimit {
+				set.SeedRatioLimit = r
+			}
+		}
+	}
+	return set
+}
+
+// describe lists the changes of set, empty if there are none
+func describe(set transmission.TorrentSetArgs) string {
+	var changes []string
+	if set.DownloadLimited != nil {
+		changes = append(changes, "download limit "+limitString(set.DownloadLimit))
+	}
+	if set.UploadLimited != nil {
+		changes = append(changes, "upload limit "+limitString(set.UploadLimit))
+	}
+	if set.SeedRatioMode != nil {
+		if set.SeedRatioLimit != nil {
+			changes = append(changes, fmt.Sprintf("seed ratio %g", *set.SeedRatioLimit))
+		} else {
+			changes = append(changes, "seed ratio none")
+		}
+	}
+	if set.Labels != nil {
+		changes = append(changes, "labels "+strings.Join(*set.Labels, ","))
+	}
+	return strings.Join(changes, ", ")
+}
+
+func limitString(l *int) string {
+	if l == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%d KB/s", *l)
+}
+
+// labels returns the labels a torrent of spec carries, sorted
+func labels(spec *TorrentSpec) []string {
+	return sortedLabels(append(addLabels(spec), SpecLabelPrefix+spec.Hash()))
+}
+
+// addLabels returns the labels of an added torrent: without the spec hash,
+// the next pass compares the torrent, which may be one the daemon had
+func addLabels(spec *TorrentSpec) []string {
+	return append(slices.Clone(spec.Labels), ManagedLabelPrefix+spec.Name)
+}
+
+func sortedLabels(labels []string) []string {
+	labels = slices.Clone(labels)
+	slices.Sort(labels)
+	return slices.Compact(labels)
+}
+
+// managedName returns the name of the spec of t, if t is managed
+func managedName(t *transmission.Torrent) (string, bool) {
+	for _, l := range t.Labels {
+		if name, ok := strings.CutPrefix(l, ManagedLabelPrefix); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// specHash returns the hash of the spec last applied to t, if any
+func specHash(t *transmission.Torrent) string {
+	for _, l := range t.Labels {
+		if hash, ok := strings.CutPrefix(l, SpecLabelPrefix); ok {
+			return hash
+		}
+	}
+	return ""
+}
+
+func isURL(source string) bool {
+	for _, scheme := range []string{"magnet:", "http://", "https://"} {
+		if strings.HasPrefix(source, scheme) {
+			return true
+		}
+	}
+	return false
+}
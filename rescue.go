@@ -0,0 +1,212 @@
+package transmission
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RescueStep is an action a StallRescuer takes on a stalled torrent
+type RescueStep int
+
+const (
+	RescueReannounce  RescueStep = iota // ask the trackers for peers again
+	RescueAddTrackers                   // add StallRescuer.BackupTrackers
+	RescueRestart                       // stop and start the torrent
+	RescueNotify                        // call StallRescuer.Notify and flag the torrent
+)
+
+func (s RescueStep) String() string {
+	switch s {
+	case RescueReannounce:
+		return "reannounce"
+	case RescueAddTrackers:
+		return "add-trackers"
+	case RescueRestart:
+		return "restart"
+	case RescueNotify:
+		return "notify"
+	default:
+		return "unknown"
+	}
+}
+
+// StallRescuer is a policy taking increasingly drastic steps on torrents
+// stalled for longer than Threshold, one step every StepInterval, until they
+// recover or the steps run out. A downloading torrent is stalled when the
+// daemon says so or when it receives nothing; it recovers once it made
+// progress, verified data or a download rate, over RecoverChecks checks in a
+// row. Until then a torrent stalling again resumes its steps where they
+// stopped.
+type StallRescuer struct {
+	client *TransmissionClient
+
+	Threshold      time.Duration
+	StepInterval   time.Duration
+	RecoverChecks  int          // 3 by default
+	Steps          []RescueStep // in order, all of them by default
+	BackupTrackers []string     // announce urls for RescueAddTrackers
+	Notify         func(t *Torrent)
+
+	// OnStep, if not nil, is called after each step taken
+	OnStep func(t *Torrent, step RescueStep, err error)
+	// OnError, if not nil, is called with the errors of the checks of Run
+	OnError func(error)
+
+	mu     sync.Mutex
+	stalls map[string]*stall
+}
+
+type stall struct {
+	since    time.Time
+	last     time.Time // of the last step
+	next     int       // index in Steps
+	have     uint64    // haveValid at the previous check
+	progress int       // checks in a row with progress
+}
+
+// NewStallRescuer returns a rescuer acting on torrents stalled for longer
+// than threshold, with a step every threshold/2
+func NewStallRescuer(client *TransmissionClient, threshold time.Duration) *StallRescuer {
+	return &StallRescuer{
+		client:        client,
+		Threshold:     threshold,
+		StepInterval:  threshold / 2,
+		RecoverChecks: 3,
+		Steps:         []RescueStep{RescueReannounce, RescueAddTrackers, RescueRestart, RescueNotify},
+		stalls:        make(map[string]*stall),
+	}
+}
+
+// Check updates the stall times with the current state of the torrents, as
+// fetched by GetTorrents or a Watcher, and takes the steps that are due
+func (r *StallRescuer) Check(ctx context.Context, torrents Torrents) error {
+	now := time.Now()
+	var due []*Torrent
+	var steps []RescueStep
+
+	r.mu.Lock()
+	seen := make(map[string]bool, len(torrents))
+	for _, t := range torrents {
+		s, ok := r.stalls[t.InfoHash]
+		if t.Status != TrDownloading && t.Status != TrDownloadPending {
+			if ok && !t.IsCompleted() {
+				seen[t.InfoHash] = true // stopped or checking, e.g. restarting
+			}
+			continue
+		}
+		stalled := t.IsStalled || t.Status == TrDownloading && t.DownloadRate() == 0
+		if !ok {
+			if stalled {
+				seen[t.InfoHash] = true
+				r.stalls[t.InfoHash] = &stall{since: now, have: t.HaveValid}
+			}
+			continue
+		}
+		seen[t.InfoHash] = true
+		if t.HaveValid > s.have || !stalled {
+			s.progress++
+		} else {
+			s.progress = 0
+		}
+		s.have = t.HaveValid
+		if s.progress >= max(r.RecoverChecks, 1) {
+			delete(r.stalls, t.InfoHash)
+			continue
+		}
+		if !stalled || s.next >= len(r.Steps) || now.Sub(s.since) < r.Threshold ||
+			!s.last.IsZero() && now.Sub(s.last) < r.StepInterval {
+			continue
+		}
+		due = append(due, t)
+		steps = append(steps, r.Steps[s.next])
+		s.next++
+		s.last = now
+	}
+	for hash := range r.stalls {
+		if !seen[hash] {
+			delete(r.stalls, hash) // done or gone
+		}
+	}
+	r.mu.Unlock()
+
+	var firstErr error
+	for i, t := range due {
+		err := r.take(ctx, t, steps[i])
+		if r.OnStep != nil {
+			r.OnStep(t, steps[i], err)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *StallRescuer) take(ctx context.Context, t *Torrent, step RescueStep) error {
+	ids := []string{t.InfoHash}
+	switch step {
+	case RescueReannounce:
+		return r.client.torrentAction(ctx, "torrent-reannounce", ids)
+	case RescueAddTrackers:
+		known := make(map[string]bool, len(t.Trackers))
+		for _, tr := range t.Trackers {
+			known[tr.Announce] = true
+		}
+		var add []string
+		for _, u := range r.BackupTrackers {
+			if !known[u] {
+				add = append(add, u)
+			}
+		}
+		if len(add) == 0 {
+			return nil
+		}
+		return r.client.torrentSet(ctx, &TorrentSetArgs{Ids: ids, TrackerAdd: add})
+	case RescueRestart:
+		if err := r.client.torrentAction(ctx, "torrent-stop", ids); err != nil {
+			return err
+		}
+		return r.client.torrentAction(ctx, "torrent-start", ids)
+	case RescueNotify:
+		if r.Notify != nil {
+			r.Notify(t)
+		}
+	}
+	return nil
+}
+
+// Flagged returns the hashes of the stalled torrents that went through all
+// the steps without recovering
+func (r *StallRescuer) Flagged() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var hashes []string
+	for hash, s := range r.stalls {
+		if s.next >= len(r.Steps) {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// Run checks the torrents known by w every interval until ctx is done,
+// skipping stale snapshots; the errors go to OnError
+func (r *StallRescuer) Run(ctx context.Context, w *Watcher, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		snap := w.Snapshot()
+		if snap.Stale {
+			continue
+		}
+		if err := r.Check(ctx, snap.Torrents); err != nil && ctx.Err() == nil && r.OnError != nil {
+			r.OnError(err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package transmission
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// TestStallRescuerKeepsSteps checks that a torrent moving for a moment, or
+// stopped by a restart, resumes its steps rather than starting over
+func TestStallRescuerKeepsSteps(t *testing.T) {
+	daemon := httptest.NewServer(&recorder{})
+	defer daemon.Close()
+	c, err := New(daemon.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewStallRescuer(c, 0)
+	r.Steps = []RescueStep{RescueReannounce, RescueRestart, RescueNotify}
+	var taken []RescueStep
+	r.OnStep = func(_ *Torrent, step RescueStep, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		taken = append(taken, step)
+	}
+
+	stalled := &Torrent{InfoHash: "a", Status: TrDownloading}
+	moving := &Torrent{InfoHash: "a", Status: TrDownloading, RateDownload: 1000}
+	stopped := &Torrent{InfoHash: "a", Status: TrStopped, SizeWhenDone: 10, LeftUntilDone: 10}
+	for _, tr := range []*Torrent{stalled, stalled, moving, stopped, stalled, stalled} {
+		if err := r.Check(context.Background(), Torrents{tr}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := r.Steps; !slices.Equal(taken, want) {
+		t.Errorf("steps = %v, want %v", taken, want)
+	}
+	if flagged := r.Flagged(); !slices.Equal(flagged, []string{"a"}) {
+		t.Errorf("flagged = %q, want [a]", flagged)
+	}
+
+	for range r.RecoverChecks {
+		r.Check(context.Background(), Torrents{moving})
+	}
+	if flagged := r.Flagged(); len(flagged) != 0 {
+		t.Errorf("flagged after recovering = %q, want none", flagged)
+	}
+}
@@ -0,0 +1,114 @@
+package transmission
+
+import (
+	"strconv"
+	"time"
+)
+
+// LimitMode tells which seeding limit applies to a torrent
+type LimitMode int
+
+const (
+	GlobalLimit   LimitMode = 0 // the session's default
+	OverrideLimit LimitMode = 1 // the torrent's own
+	UnlimitedMode LimitMode = 2 // none
+)
+
+func (m LimitMode) String() string {
+	switch m {
+	case GlobalLimit:
+		return "Global"
+	case OverrideLimit:
+		return "Override"
+	case UnlimitedMode:
+		return "Unlimited"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalJSON encodes the mode as the daemon does, as a number
+func (m LimitMode) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(m), 10), nil
+}
+
+// UnmarshalJSON decodes a number, or a name as String returns it in any
+// case
+func (m *LimitMode) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalEnum(b, "limit mode", GlobalLimit, OverrideLimit, UnlimitedMode)
+	if err == nil {
+		*m = n
+	}
+	return err
+}
+
+// EffectiveSeedRatioLimit returns the upload ratio at which the torrent
+// stops seeding, resolving its seedRatioMode against the defaults of the
+// session; ok is false when no ratio stops it. Both the torrent's
+// seedRatioMode and seedRatioLimit fields are needed.
+func (t *Torrent) EffectiveSeedRatioLimit(session *SessionSettings) (limit float64, ok bool) {
+	switch t.SeedRatioMode {
+	case GlobalLimit:
+		if !session.SeedRatioLimited {
+			return 0, false
+		}
+		return session.SeedRatioLimit, true
+	case OverrideLimit:
+		return t.SeedRatioLimit, true
+	default:
+		return 0, false
+	}
+}
+
+// EffectiveIdleLimit returns how long the torrent may seed without
+// transferring before it stops, resolving its seedIdleMode against the
+// defaults of the session; ok is false when idleness never stops it. Both
+// the torrent's seedIdleMode and seedIdleLimit fields are needed.
+func (t *Torrent) EffectiveIdleLimit(session *SessionSettings) (d time.Duration, ok bool) {
+	switch t.SeedIdleMode {
+	case GlobalLimit:
+		if !session.IdleSeedingLimitEnabled {
+			return 0, false
+		}
+		return time.Duration(session.IdleSeedingLimit) * time.Minute, true
+	case OverrideLimit:
+		return time.Duration(t.SeedIdleLimit) * time.Minute, true
+	default:
+		return 0, false
+	}
+}
+
+// SeedingTimeRemaining estimates how long the torrent seeds before one of
+// its limits, resolved against the session, stops it: the time to reach
+// the ratio limit at the current upload rate, or the etaIdle of the daemon
+// for the idle limit, whichever comes first. A reached ratio gives 0. ok is
+// false while the torrent downloads or is stopped, and when no limit applies
+// or no estimate is possible, e.g. with no upload to extrapolate. The
+// fields of the limits, downloadedEver, uploadedEver, sizeWhenDone,
+// rateUpload and etaIdle are needed.
+func (t *Torrent) SeedingTimeRemaining(session *SessionSettings) (d time.Duration, ok bool) {
+	if t.Status != TrSeeding && t.Status != TrSeedPending {
+		return 0, false
+	}
+	if limit, limited := t.EffectiveSeedRatioLimit(session); limited {
+		// the daemon measures the ratio against the size when nothing was
+		// downloaded, e.g. for a torrent added with its data
+		base := t.DownloadedEver
+		if base == 0 {
+			base = t.SizeWhenDone
+		}
+		need := limit*float64(base) - float64(t.UploadedEver)
+		switch rate := t.UploadRate(); {
+		case need <= 0:
+			return 0, true
+		case rate > 0:
+			d, ok = time.Duration(need/float64(rate)*float64(time.Second)), true
+		}
+	}
+	if _, limited := t.EffectiveIdleLimit(session); limited {
+		if idle, known := t.IdleTimeLeft(); known && (!ok || idle < d) {
+			d, ok = idle, true
+		}
+	}
+	return d, ok
+}
@@ -0,0 +1,68 @@
+package transmission
+
+import (
+	"context"
+	"time"
+)
+
+// Lister reads torrents, statistics and settings
+type Lister interface {
+	GetTorrents() (Torrents, error)
+	GetTorrentsContext(ctx context.Context) (Torrents, error)
+	GetTorrent(id string) (*Torrent, error)
+	GetTorrentContext(ctx context.Context, id string) (*Torrent, error)
+	ForEachTorrent(ctx context.Context, fields []string, fn func(*Torrent) error) error
+	GetStats() (*Stats, error)
+	GetStatsContext(ctx context.Context) (*Stats, error)
+	GetSession(ctx context.Context) (*SessionSettings, error)
+	Summary(ctx context.Context) (*Summary, error)
+	TorrentGroup(ctx context.Context, dir string) (*TorrentGroup, error)
+}
+
+// Adder adds torrents
+type Adder interface {
+	ExecuteAddCommand(addCmd *Command) (TorrentAdded, error)
+	ExecuteAddCommandContext(ctx context.Context, addCmd *Command) (TorrentAdded, error)
+	AddTorrent(ctx context.Context, cmd *Command, opts ...AddOption) (TorrentAdded, error)
+	AddExisting(ctx context.Context, addCmd *Command, location string, progress func(ExistingProgress)) (TorrentAdded, error)
+}
+
+// Mutator changes, starts, stops and removes torrents
+type Mutator interface {
+	StartTorrent(ids ...string) (string, error)
+	StopTorrent(ids ...string) (string, error)
+	VerifyTorrent(ids ...string) (string, error)
+	StartAll() error
+	StopAll() error
+	VerifyAll() error
+	DeleteTorrent(id string, withData bool) (string, error)
+	SetLocation(ctx context.Context, id string, location string, move bool) error
+	SetBandwidthPriority(ctx context.Context, id string, p Priority) error
+	SetHonorsSessionLimits(ctx context.Context, id string, honors bool) error
+	SetTorrents(ctx context.Context, ids []string, args TorrentSetArgs, opts ChunkOptions) (*BulkResult, error)
+	TrashTorrent(ctx context.Context, id string) error
+	RestoreTorrent(ctx context.Context, id string) error
+	StartGroup(ctx context.Context, dir string) (*BulkResult, error)
+	StopGroup(ctx context.Context, dir string) (*BulkResult, error)
+	TorrentSet(ctx context.Context, args *TorrentSetArgs) error
+}
+
+// TorrentService is what TransmissionClient does against the daemon, for
+// code that wants to be tested with a mock; depend on the smaller Lister,
+// Adder and Mutator when possible
+type TorrentService interface {
+	Lister
+	Adder
+	Mutator
+	ExecuteCommand(cmd *Command) (*Command, error)
+	ExecuteCommandContext(ctx context.Context, cmd *Command) (*Command, error)
+	InjectTrackers(ctx context.Context, urls []string, dryRun bool, ids ...string) ([]TrackerInjection, error)
+	RemoveFailingTrackers(ctx context.Context, dryRun bool, ids ...string) ([]TrackerRemoval, error)
+	AltSpeedStatus(ctx context.Context) (AltSpeedStatus, error)
+	SetSession(ctx context.Context, args *SessionSetArgs) error
+	RunView(ctx context.Context, name string) (Torrents, error)
+	WaitReady(ctx context.Context, timeout time.Duration) error
+	Version() string
+}
+
+var _ TorrentService = (*TransmissionClient)(nil)
@@ -0,0 +1,177 @@
+package transmission
+
+import "context"
+
+// SessionSetArgs are the arguments of session-set, the writable fields of
+// SessionSettings; nil fields are not sent, so the daemon leaves them
+// untouched
+type SessionSetArgs struct {
+	DownloadDir               *string `json:"download-dir,omitempty"`
+	IncompleteDir             *string `json:"incomplete-dir,omitempty"`
+	IncompleteDirEnabled      *bool   `json:"incomplete-dir-enabled,omitempty"`
+	RenamePartialFiles        *bool   `json:"rename-partial-files,omitempty"`
+	StartAddedTorrents        *bool   `json:"start-added-torrents,omitempty"`
+	TrashOriginalTorrentFiles *bool   `json:"trash-original-torrent-files,omitempty"`
+	CacheSizeMB               *int    `json:"cache-size-mb,omitempty"`
+
+	SpeedLimitDown        *int  `json:"speed-limit-down,omitempty"` // KB/s
+	SpeedLimitDownEnabled *bool `json:"speed-limit-down-enabled,omitempty"`
+	SpeedLimitUp          *int  `json:"speed-limit-up,omitempty"` // KB/s
+	SpeedLimitUpEnabled   *bool `json:"speed-limit-up-enabled,omitempty"`
+
+	AltSpeedEnabled     *bool `json:"alt-speed-enabled,omitempty"`
+	AltSpeedDown        *int  `json:"alt-speed-down,omitempty"` // KB/s
+	AltSpeedUp          *int  `json:"alt-speed-up,omitempty"`   // KB/s
+	AltSpeedTimeEnabled *bool `json:"alt-speed-time-enabled,omitempty"`
+	AltSpeedTimeBegin   *int  `json:"alt-speed-time-begin,omitempty"` // minutes after midnight
+	AltSpeedTimeEnd     *int  `json:"alt-speed-time-end,omitempty"`   // minutes after midnight
+	AltSpeedTimeDay     *int  `json:"alt-speed-time-day,omitempty"`   // bitmask, Sunday = 1 ... Saturday = 64
+
+	PeerPort              *int        `json:"peer-port,omitempty"`
+	PeerPortRandomOnStart *bool       `json:"peer-port-random-on-start,omitempty"`
+	PortForwardingEnabled *bool       `json:"port-forwarding-enabled,omitempty"`
+	PeerLimitGlobal       *int        `json:"peer-limit-global,omitempty"`
+	PeerLimitPerTorrent   *int        `json:"peer-limit-per-torrent,omitempty"`
+	Encryption            *Encryption `json:"encryption,omitempty"`
+	DHTEnabled            *bool       `json:"dht-enabled,omitempty"`
+	PEXEnabled            *bool       `json:"pex-enabled,omitempty"`
+	LPDEnabled            *bool       `json:"lpd-enabled,omitempty"`
+	UTPEnabled            *bool       `json:"utp-enabled,omitempty"`
+	BlocklistEnabled      *bool       `json:"blocklist-enabled,omitempty"`
+	BlocklistURL          *string     `json:"blocklist-url,omitempty"`
+
+	DownloadQueueEnabled *bool `json:"download-queue-enabled,omitempty"`
+	DownloadQueueSize    *int  `json:"download-queue-size,omitempty"`
+	SeedQueueEnabled     *bool `json:"seed-queue-enabled,omitempty"`
+	SeedQueueSize        *int  `json:"seed-queue-size,omitempty"`
+	QueueStalledEnabled  *bool `json:"queue-stalled-enabled,omitempty"`
+	QueueStalledMinutes  *int  `json:"queue-stalled-minutes,omitempty"`
+
+	SeedRatioLimit          *float64 `json:"seedRatioLimit,omitempty"`
+	SeedRatioLimited        *bool    `json:"seedRatioLimited,omitempty"`
+	IdleSeedingLimit        *int     `json:"idle-seeding-limit,omitempty"` // minutes
+	IdleSeedingLimitEnabled *bool    `json:"idle-seeding-limit-enabled,omitempty"`
+
+	ScriptTorrentDoneEnabled  *bool   `json:"script-torrent-done-enabled,omitempty"`
+	ScriptTorrentDoneFilename *string `json:"script-torrent-done-filename,omitempty"`
+
+	DefaultTrackers *string `json:"default-trackers,omitempty"` // Transmission 4.0+, see TrackerList
+}
+
+// SetSession changes the settings of the session to the non-nil fields of
+// args, e.g.
+//
+//	enabled := true
+//	err := client.SetSession(ctx, &SessionSetArgs{DHTEnabled: &enabled})
+func (ac *TransmissionClient) SetSession(ctx context.Context, args *SessionSetArgs) error {
+	return ac.rpc(ctx, "session-set", args, nil)
+}
+
+// speedLimits are the global speed limits of the session
+type speedLimits struct {
+	Down        int  `json:"speed-limit-down"`
+	DownEnabled bool `json:"speed-limit-down-enabled"`
+	Up          int  `json:"speed-limit-up"`
+	UpEnabled   bool `json:"speed-limit-up-enabled"`
+}
+
+func (ac *TransmissionClient) getSpeedLimits(ctx context.Context) (*speedLimits, error) {
+	limits := &speedLimits{}
+	args := map[string][]string{"fields": {"speed-limit-down", "speed-limit-down-enabled",
+		"speed-limit-up", "speed-limit-up-enabled"}}
+	if err := ac.rpc(ctx, "session-get", args, limits); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func (ac *TransmissionClient) setSpeedLimits(ctx context.Context, l *speedLimits) error {
+	return ac.SetSession(ctx, &SessionSetArgs{
+		SpeedLimitDown:        &l.Down,
+		SpeedLimitDownEnabled: &l.DownEnabled,
+		SpeedLimitUp:          &l.Up,
+		SpeedLimitUpEnabled:   &l.UpEnabled,
+	})
+}
+
+// Encryption is the preference of the daemon for encrypted peer
+// connections
+type Encryption string
+
+const (
+	EncryptionRequired  Encryption = "required"  // only encrypted peers
+	EncryptionPreferred Encryption = "preferred" // encrypted peers first
+	EncryptionTolerated Encryption = "tolerated" // plain connections first
+)
+
+// SessionSettings are the settings reported by session-get; fields the
+// daemon doesn't know, e.g. those of a newer version, are left zero
+type SessionSettings struct {
+	Version           string `json:"version"`
+	RPCVersion        int    `json:"rpc-version"`
+	RPCVersionMinimum int    `json:"rpc-version-minimum"` // oldest RPC version the daemon still serves
+	ConfigDir         string `json:"config-dir"`
+
+	DownloadDir               string `json:"download-dir"`
+	IncompleteDir             string `json:"incomplete-dir"`
+	IncompleteDirEnabled      bool   `json:"incomplete-dir-enabled"`
+	RenamePartialFiles        bool   `json:"rename-partial-files"` // .part suffix on incomplete files
+	StartAddedTorrents        bool   `json:"start-added-torrents"`
+	TrashOriginalTorrentFiles bool   `json:"trash-original-torrent-files"`
+	CacheSizeMB               int    `json:"cache-size-mb"`
+
+	SpeedLimitDown        int  `json:"speed-limit-down"` // KB/s
+	SpeedLimitDownEnabled bool `json:"speed-limit-down-enabled"`
+	SpeedLimitUp          int  `json:"speed-limit-up"` // KB/s
+	SpeedLimitUpEnabled   bool `json:"speed-limit-up-enabled"`
+
+	PeerPort              int        `json:"peer-port"`
+	PeerPortRandomOnStart bool       `json:"peer-port-random-on-start"`
+	PortForwardingEnabled bool       `json:"port-forwarding-enabled"` // UPnP and NAT-PMP
+	BindAddressIPv4       string     `json:"bind-address-ipv4"`       // empty if the daemon doesn't report it
+	BindAddressIPv6       string     `json:"bind-address-ipv6"`       // empty if the daemon doesn't report it
+	PeerLimitGlobal       int        `json:"peer-limit-global"`
+	PeerLimitPerTorrent   int        `json:"peer-limit-per-torrent"`
+	Encryption            Encryption `json:"encryption"`
+	DHTEnabled            bool       `json:"dht-enabled"`
+	PEXEnabled            bool       `json:"pex-enabled"`
+	LPDEnabled            bool       `json:"lpd-enabled"` // local peer discovery
+	UTPEnabled            bool       `json:"utp-enabled"`
+	BlocklistEnabled      bool       `json:"blocklist-enabled"`
+	BlocklistURL          string     `json:"blocklist-url"`
+	BlocklistSize         int        `json:"blocklist-size"` // rules loaded
+
+	DownloadQueueEnabled bool `json:"download-queue-enabled"`
+	DownloadQueueSize    int  `json:"download-queue-size"`
+	SeedQueueEnabled     bool `json:"seed-queue-enabled"`
+	SeedQueueSize        int  `json:"seed-queue-size"`
+	QueueStalledEnabled  bool `json:"queue-stalled-enabled"`
+	QueueStalledMinutes  int  `json:"queue-stalled-minutes"` // inactivity after which a torrent no longer counts in the queue
+
+	ScriptTorrentDoneEnabled  bool   `json:"script-torrent-done-enabled"`
+	ScriptTorrentDoneFilename string `json:"script-torrent-done-filename"`
+
+	SeedRatioLimit          float64 `json:"seedRatioLimit"` // default of the torrents, see Torrent.EffectiveSeedRatioLimit
+	SeedRatioLimited        bool    `json:"seedRatioLimited"`
+	IdleSeedingLimit        int     `json:"idle-seeding-limit"` // minutes, see Torrent.EffectiveIdleLimit
+	IdleSeedingLimitEnabled bool    `json:"idle-seeding-limit-enabled"`
+
+	DefaultTrackers string `json:"default-trackers"` // Transmission 4.0+, see ParseTrackerList
+
+	AltSpeedEnabled     bool `json:"alt-speed-enabled"` // turtle mode is on
+	AltSpeedDown        int  `json:"alt-speed-down"`    // KB/s
+	AltSpeedUp          int  `json:"alt-speed-up"`      // KB/s
+	AltSpeedTimeEnabled bool `json:"alt-speed-time-enabled"`
+	AltSpeedTimeBegin   int  `json:"alt-speed-time-begin"` // minutes after midnight
+	AltSpeedTimeEnd     int  `json:"alt-speed-time-end"`   // minutes after midnight
+	AltSpeedTimeDay     int  `json:"alt-speed-time-day"`   // bitmask, Sunday = 1 ... Saturday = 64
+}
+
+// GetSession returns the session's settings
+func (ac *TransmissionClient) GetSession(ctx context.Context) (*SessionSettings, error) {
+	s := &SessionSettings{}
+	if err := ac.rpc(ctx, "session-get", nil, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
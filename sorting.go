@@ -1,6 +1,10 @@
 package transmission
 
-import "sort"
+import (
+	"cmp"
+	"math"
+	"slices"
+)
 
 type Sorting int
 
@@ -27,136 +31,125 @@ const (
 	SortRevRatio
 )
 
-// sorting types
-type (
-	byID         Torrents
-	byName       Torrents
-	byAge        Torrents
-	bySize       Torrents
-	byProgress   Torrents
-	byDownSpeed  Torrents
-	byUpSpeed    Torrents
-	byDownloaded Torrents
-	byUploaded   Torrents
-	byRatio      Torrents
-)
+// comparator returns the Comparator implementing the sorting
+func (s Sorting) comparator() Comparator {
+	var c Comparator
+	switch s &^ 1 {
+	case SortName:
+		c = ByName
+	case SortAge:
+		c = ByAddedDate
+	case SortSize:
+		c = BySize
+	case SortProgress:
+		c = ByProgress
+	case SortDownSpeed:
+		c = ByDownSpeed
+	case SortUpSpeed:
+		c = ByUpSpeed
+	case SortDownloaded:
+		c = ByDownloaded
+	case SortUploaded:
+		c = ByUploaded
+	case SortRatio:
+		c = ByRatio
+	default:
+		c = ByID
+	}
+	if s&1 == 1 {
+		return Reverse(c)
+	}
+	return c
+}
 
-func (t byID) Len() int           { return len(t) }
-func (t byID) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byID) Less(i, j int) bool { return t[i].ID < t[j].ID }
+// Comparator orders two torrents the way cmp.Compare does, so it can be
+// passed to slices.SortStableFunc
+type Comparator func(a, b *Torrent) int
+
+// Then combines comparators: ties of the first are broken by the next one
+func Then(first Comparator, next ...Comparator) Comparator {
+	return func(a, b *Torrent) int {
+		if r := first(a, b); r != 0 {
+			return r
+		}
+		for _, c := range next {
+			if r := c(a, b); r != 0 {
+				return r
+			}
+		}
+		return 0
+	}
+}
 
-func (t byName) Len() int           { return len(t) }
-func (t byName) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byName) Less(i, j int) bool { return t[i].Name < t[j].Name }
+// Reverse inverts the order of c
+func Reverse(c Comparator) Comparator {
+	return func(a, b *Torrent) int { return c(b, a) }
+}
 
-func (t byAge) Len() int           { return len(t) }
-func (t byAge) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byAge) Less(i, j int) bool { return t[i].AddedDate < t[j].AddedDate }
+// Sort sorts the torrents in place, keeping the order of equal elements
+func (t Torrents) Sort(c Comparator) {
+	slices.SortStableFunc(t, c)
+}
 
-func (t bySize) Len() int           { return len(t) }
-func (t bySize) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t bySize) Less(i, j int) bool { return t[i].SizeWhenDone < t[j].SizeWhenDone }
+// ByID orders torrents by id
+func ByID(a, b *Torrent) int { return cmp.Compare(a.ID, b.ID) }
 
-func (t byProgress) Len() int           { return len(t) }
-func (t byProgress) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byProgress) Less(i, j int) bool { return t[i].PercentDone < t[j].PercentDone }
+// ByName orders torrents by name, byte-wise
+func ByName(a, b *Torrent) int { return cmp.Compare(a.Name, b.Name) }
 
-func (t byDownSpeed) Len() int           { return len(t) }
-func (t byDownSpeed) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byDownSpeed) Less(i, j int) bool { return t[i].RateDownload < t[j].RateDownload }
+// ByAddedDate orders torrents by the time they were added
+func ByAddedDate(a, b *Torrent) int { return cmp.Compare(a.AddedDate, b.AddedDate) }
 
-func (t byUpSpeed) Len() int           { return len(t) }
-func (t byUpSpeed) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byUpSpeed) Less(i, j int) bool { return t[i].RateUpload < t[j].RateUpload }
+// BySize orders torrents by sizeWhenDone
+func BySize(a, b *Torrent) int { return cmp.Compare(a.SizeWhenDone, b.SizeWhenDone) }
 
-func (t byDownloaded) Len() int           { return len(t) }
-func (t byDownloaded) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byDownloaded) Less(i, j int) bool { return t[i].DownloadedEver < t[j].DownloadedEver }
+// ByProgress orders torrents by percentDone
+func ByProgress(a, b *Torrent) int { return cmp.Compare(a.PercentDone, b.PercentDone) }
 
-func (t byUploaded) Len() int           { return len(t) }
-func (t byUploaded) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byUploaded) Less(i, j int) bool { return t[i].UploadedEver < t[j].UploadedEver }
+// ByDownSpeed orders torrents by download rate
+func ByDownSpeed(a, b *Torrent) int { return cmp.Compare(a.RateDownload, b.RateDownload) }
 
-func (t byRatio) Len() int           { return len(t) }
-func (t byRatio) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t byRatio) Less(i, j int) bool { return t[i].UploadRatio < t[j].UploadRatio }
+// ByUpSpeed orders torrents by upload rate
+func ByUpSpeed(a, b *Torrent) int { return cmp.Compare(a.RateUpload, b.RateUpload) }
 
-func (t Torrents) SortID(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byID(t)))
-		return
-	}
-	sort.Sort(byID(t))
-}
+// ByDownloaded orders torrents by downloadedEver
+func ByDownloaded(a, b *Torrent) int { return cmp.Compare(a.DownloadedEver, b.DownloadedEver) }
 
-func (t Torrents) SortName(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byName(t)))
-		return
-	}
-	sort.Sort(byName(t))
-}
+// ByUploaded orders torrents by uploadedEver
+func ByUploaded(a, b *Torrent) int { return cmp.Compare(a.UploadedEver, b.UploadedEver) }
 
-func (t Torrents) SortAge(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byAge(t)))
-		return
-	}
-	sort.Sort(byAge(t))
-}
-
-func (t Torrents) SortSize(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(bySize(t)))
-		return
-	}
-	sort.Sort(bySize(t))
-}
-
-func (t Torrents) SortProgress(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byProgress(t)))
-		return
-	}
-	sort.Sort(byProgress(t))
-}
-
-func (t Torrents) SortDownSpeed(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byDownSpeed(t)))
-		return
-	}
-	sort.Sort(byDownSpeed(t))
-}
-
-func (t Torrents) SortUpSpeed(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byUpSpeed(t)))
-		return
-	}
-	sort.Sort(byUpSpeed(t))
-}
+// ByQueuePosition orders torrents by queue position
+func ByQueuePosition(a, b *Torrent) int { return cmp.Compare(a.QueuePosition, b.QueuePosition) }
 
-func (t Torrents) SortDownloaded(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byDownloaded(t)))
-		return
+// ByRatio sorts infinite ratios last and unavailable ones first
+func ByRatio(a, b *Torrent) int {
+	ratio := func(t *Torrent) float64 {
+		if t.UploadRatio == RatioInfinite {
+			return math.Inf(1)
+		}
+		return t.UploadRatio
 	}
-	sort.Sort(byDownloaded(t))
+	return cmp.Compare(ratio(a), ratio(b))
 }
 
-func (t Torrents) SortUploaded(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byUploaded(t)))
-		return
+// ByETA sorts torrents without a known eta last
+func ByETA(a, b *Torrent) int {
+	eta := func(t *Torrent) int64 {
+		if t.Eta < 0 {
+			return math.MaxInt64
+		}
+		return t.Eta
 	}
-	sort.Sort(byUploaded(t))
+	return cmp.Compare(eta(a), eta(b))
 }
 
-func (t Torrents) SortRatio(reverse bool) {
-	if reverse {
-		sort.Sort(sort.Reverse(byRatio(t)))
-		return
+// ByLeftUntilDone sorts torrents with an invalid leftUntilDone last
+func ByLeftUntilDone(a, b *Torrent) int {
+	left := func(t *Torrent) uint64 {
+		if n, ok := t.BytesLeft(); ok {
+			return n
+		}
+		return math.MaxUint64
 	}
-	sort.Sort(byRatio(t))
+	return cmp.Compare(left(a), left(b))
 }
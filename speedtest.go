@@ -0,0 +1,114 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultSpeedTestDir is where SpeedTest puts its data when Dir is empty,
+// relative to the session's download-dir
+const DefaultSpeedTestDir = "go-transmission-speedtest"
+
+// ErrSpeedTestDuplicate is returned by RunSpeedTest when the test torrent is
+// already on the daemon; it is left alone rather than removed
+var ErrSpeedTestDuplicate = errors.New("speed test torrent is already on the daemon")
+
+// SpeedTest measures the download throughput of the daemon with a
+// well-seeded test torrent, e.g. the image of a Linux distribution
+type SpeedTest struct {
+	Torrent  string        // URL or magnet of the test torrent
+	Dir      string        // daemon directory for the data, DefaultSpeedTestDir if empty
+	Warmup   time.Duration // time for peers to connect before measuring
+	Window   time.Duration // measuring time
+	Interval time.Duration // sampling interval, 1s if zero
+}
+
+// SpeedTestResult is the outcome of RunSpeedTest
+type SpeedTestResult struct {
+	Downloaded uint64        // bytes downloaded during the window
+	Elapsed    time.Duration // length of the window, shorter if the download completed
+	Average    uint64        // B/s over the window
+	Peak       uint64        // highest sampled rate, B/s
+	Completed  bool          // the download completed before the end of the window
+}
+
+// RunSpeedTest adds the test torrent, measures the download rate over the
+// window after the warmup, then removes the torrent with its data, even when
+// ctx is cancelled
+func (ac *TransmissionClient) RunSpeedTest(ctx context.Context, st SpeedTest) (*SpeedTestResult, error) {
+	if st.Torrent == "" {
+		return nil, errors.New("speed test: no torrent")
+	}
+	if st.Dir == "" {
+		st.Dir = DefaultSpeedTestDir
+	}
+	if st.Interval <= 0 {
+		st.Interval = time.Second
+	}
+
+	dir, err := ac.ResolveDownloadDir(ctx, st.Dir)
+	if err != nil {
+		return nil, err
+	}
+	cmd := NewAddCmdByURL(st.Torrent)
+	cmd.SetDownloadDir(dir)
+	cmd.SetPaused(false)
+	out, err := ac.ExecuteCommandContext(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if out.Arguments.TorrentDuplicate != nil {
+		return nil, ErrSpeedTestDuplicate
+	}
+	if out.Arguments.TorrentAdded == nil {
+		return nil, errors.New("torrent-add: " + out.Result)
+	}
+	id := out.Arguments.TorrentAdded.HashString
+	defer ac.rpc(context.WithoutCancel(ctx), "torrent-remove", map[string]interface{}{
+		"ids":               []string{id},
+		"delete-local-data": true,
+	}, nil)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(st.Warmup):
+	}
+
+	fields := []string{"id", "hashString", "downloadedEver", "rateDownload", "percentDone"}
+	t, err := ac.getTorrentFields(ctx, id, fields)
+	if err != nil {
+		return nil, err
+	}
+	start, startBytes := time.Now(), t.DownloadedEver
+	res := &SpeedTestResult{}
+	ticker := time.NewTicker(st.Interval)
+	defer ticker.Stop()
+	deadline := time.After(st.Window)
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			done = true
+		case <-ticker.C:
+		}
+		if t, err = ac.getTorrentFields(ctx, id, fields); err != nil {
+			return nil, err
+		}
+		res.Peak = max(res.Peak, t.DownloadRate())
+		if t.IsCompleted() {
+			res.Completed, done = true, true
+		}
+	}
+
+	res.Elapsed = time.Since(start)
+	if t.DownloadedEver > startBytes {
+		res.Downloaded = t.DownloadedEver - startBytes
+	}
+	if secs := res.Elapsed.Seconds(); secs > 0 {
+		res.Average = uint64(float64(res.Downloaded) / secs)
+	}
+	return res, nil
+}
@@ -0,0 +1,177 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WithMaxRequestSize makes the client split the requests whose body is
+// larger than n bytes across several requests, each with part of the ids,
+// and merge the responses, as if one request was sent. Requests refused by
+// the daemon as too large (413) are split too, with or without this
+// option. Only requests with a list of ids can be split; the others are
+// sent as is. A split request changing torrents that fails after some of
+// its parts were applied returns a *SplitRequestError naming their ids.
+func WithMaxRequestSize(n int) Option {
+	return func(ac *TransmissionClient) {
+		ac.maxRequest = n
+	}
+}
+
+// SplitRequestError is returned when a part of a split request failed
+// after the parts before it were applied: the torrents of Applied were
+// changed by Method, the others weren't
+type SplitRequestError struct {
+	Method  string
+	Applied []string // the ids as sent, numbers in decimal
+	Err     error
+}
+
+func (e *SplitRequestError) Error() string {
+	return fmt.Sprintf("%s: failed after being applied to %s: %v", e.Method, strings.Join(e.Applied, ", "), e.Err)
+}
+
+func (e *SplitRequestError) Unwrap() error {
+	return e.Err
+}
+
+// post sends the marshalled request body, split when it is too large, see
+// WithMaxRequestSize
+func (ac *TransmissionClient) post(ctx context.Context, body []byte) ([]byte, error) {
+	if ac.maxRequest > 0 && len(body) > ac.maxRequest {
+		if method, halves, ok := splitRequest(body); ok {
+			return ac.postSplit(ctx, method, halves)
+		}
+	}
+	output, err := ac.send(ctx, body)
+	var pe *ProtocolError
+	if errors.As(err, &pe) && pe.StatusCode == http.StatusRequestEntityTooLarge {
+		if method, halves, ok := splitRequest(body); ok {
+			return ac.postSplit(ctx, method, halves)
+		}
+	}
+	return output, err
+}
+
+// splitHalf is a request with half of the ids of a larger one
+type splitHalf struct {
+	body []byte
+	ids  []json.RawMessage
+}
+
+// splitRequest returns the request body as two requests with half of its
+// ids each; ok is false if it has less than two ids
+func splitRequest(body []byte) (method string, halves [2]splitHalf, ok bool) {
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", halves, false
+	}
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(req["arguments"], &args); err != nil {
+		return "", halves, false
+	}
+	var ids []json.RawMessage
+	if err := json.Unmarshal(args["ids"], &ids); err != nil || len(ids) < 2 {
+		return "", halves, false
+	}
+	json.Unmarshal(req["method"], &method)
+	for i, part := range [2][]json.RawMessage{ids[:len(ids)/2], ids[len(ids)/2:]} {
+		args["ids"], _ = json.Marshal(part)
+		req["arguments"], _ = json.Marshal(args)
+		halves[i].body, _ = json.Marshal(req)
+		halves[i].ids = part
+	}
+	return method, halves, true
+}
+
+// postSplit sends the halves of a request and merges their responses: the
+// first failure is returned as is, otherwise the arrays of the arguments,
+// like torrents, are concatenated. A failure of the second half of a method
+// other than torrent-get, once the first half changed its torrents, is a
+// *SplitRequestError.
+func (ac *TransmissionClient) postSplit(ctx context.Context, method string, halves [2]splitHalf) ([]byte, error) {
+	var merged struct {
+		Result    string                     `json:"result"`
+		Arguments map[string]json.RawMessage `json:"arguments"`
+		Tag       json.RawMessage            `json:"tag,omitempty"`
+	}
+	partial := func(err error) error {
+		if method == "torrent-get" {
+			return err
+		}
+		applied := idStrings(halves[0].ids)
+		var se *SplitRequestError
+		if errors.As(err, &se) {
+			applied = append(applied, se.Applied...)
+			err = se.Err
+		}
+		return &SplitRequestError{Method: method, Applied: applied, Err: err}
+	}
+	for i, half := range halves {
+		output, err := ac.post(ctx, half.body)
+		if err != nil {
+			if i == 1 {
+				err = partial(err)
+			}
+			return output, err
+		}
+		var res struct {
+			Result    string                     `json:"result"`
+			Arguments map[string]json.RawMessage `json:"arguments"`
+			Tag       json.RawMessage            `json:"tag,omitempty"`
+		}
+		if err := json.Unmarshal(output, &res); err != nil {
+			return nil, err
+		}
+		if res.Result != "success" {
+			if i == 1 && method != "torrent-get" {
+				return nil, partial(errors.New(res.Result))
+			}
+			return output, nil
+		}
+		if i == 0 {
+			merged = res
+			continue
+		}
+		for key, v := range res.Arguments {
+			prev, ok := merged.Arguments[key]
+			if !ok {
+				if merged.Arguments == nil {
+					merged.Arguments = make(map[string]json.RawMessage)
+				}
+				merged.Arguments[key] = v
+				continue
+			}
+			if cat, ok := concatArrays(prev, v); ok {
+				merged.Arguments[key] = cat
+			}
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// idStrings returns the ids of a request as strings, hashes unquoted
+func idStrings(ids []json.RawMessage) []string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		if json.Unmarshal(id, &s[i]) != nil {
+			s[i] = string(id)
+		}
+	}
+	return s
+}
+
+// concatArrays concatenates two JSON arrays; ok is false if one of a and b
+// is not an array
+func concatArrays(a, b json.RawMessage) (json.RawMessage, bool) {
+	var x, y []json.RawMessage
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return nil, false
+	}
+	cat, err := json.Marshal(append(x, y...))
+	return cat, err == nil
+}
@@ -0,0 +1,45 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// TestSplitRequestPartial checks that a split torrent-set failing halfway
+// names the torrents it changed
+func TestSplitRequestPartial(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cmd struct {
+			Arguments struct {
+				Ids []string `json:"ids"`
+			} `json:"arguments"`
+		}
+		json.NewDecoder(r.Body).Decode(&cmd)
+		if slices.Contains(cmd.Arguments.Ids, "c") {
+			io.WriteString(w, `{"result":"torrent not found","arguments":{}}`)
+			return
+		}
+		io.WriteString(w, `{"result":"success","arguments":{}}`)
+	}))
+	defer daemon.Close()
+	c, err := New(daemon.URL, "", "", WithMaxRequestSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := map[string]interface{}{"ids": []string{"a", "b", "c", "d"}, "seedRatioLimit": 1}
+	err = c.Call(context.Background(), "torrent-set", args, nil)
+	var se *SplitRequestError
+	if !errors.As(err, &se) {
+		t.Fatalf("err = %v, want a *SplitRequestError", err)
+	}
+	if !slices.Equal(se.Applied, []string{"a", "b"}) {
+		t.Errorf("applied = %q, want [a b]", se.Applied)
+	}
+}
@@ -0,0 +1,263 @@
+package transmission
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned by Store.Get for a missing key
+var ErrNotFound = errors.New("store: not found")
+
+// Store is a small key-value store for the state of long-running
+// subsystems, each in its own namespace, so they can share a backend:
+// MemoryStore, FileStore or one of the caller's, e.g. over bolt or sqlite.
+// StoreAccounting and StoreJournal put the Accountant and Journal on one.
+type Store interface {
+	// Get returns the value of key, ErrNotFound if there is none
+	Get(namespace, key string) ([]byte, error)
+	Set(namespace, key string, value []byte) error
+	// Delete removes key; removing a missing key is not an error
+	Delete(namespace, key string) error
+	// List returns the keys of the namespace, sorted
+	List(namespace string) ([]string, error)
+}
+
+// MemoryStore is a Store in memory only
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string]map[string][]byte
+}
+
+func (s *MemoryStore) Get(namespace, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[namespace][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(v), nil
+}
+
+func (s *MemoryStore) Set(namespace, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data == nil {
+		s.data = make(map[string]map[string][]byte)
+	}
+	if s.data[namespace] == nil {
+		s.data[namespace] = make(map[string][]byte)
+	}
+	s.data[namespace][key] = slices.Clone(value)
+	return nil
+}
+
+func (s *MemoryStore) Delete(namespace, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data[namespace], key)
+	return nil
+}
+
+func (s *MemoryStore) List(namespace string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.data[namespace]))
+	for k := range s.data[namespace] {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// FileStore is a Store keeping each value in a file of Dir, under a
+// directory per namespace. Names are encoded in hex, so any key of up to
+// 127 bytes is allowed, whatever the case sensitivity of the filesystem.
+type FileStore struct {
+	Dir string
+}
+
+// fileName encodes a namespace or a key as a file name; the prefix keeps
+// the empty name apart from the directory
+func fileName(name string) string {
+	return "x" + hex.EncodeToString([]byte(name))
+}
+
+func (s FileStore) path(namespace, key string) string {
+	return filepath.Join(s.Dir, fileName(namespace), fileName(key))
+}
+
+func (s FileStore) Get(namespace, key string) ([]byte, error) {
+	b, err := os.ReadFile(s.path(namespace, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+// Set writes the value to a temporary file, synced and renamed over the
+// key's, so a crash never leaves a truncated value
+func (s FileStore) Set(namespace, key string, value []byte) error {
+	dir := filepath.Join(s.Dir, fileName(namespace))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(namespace, key))
+}
+
+func (s FileStore) Delete(namespace, key string) error {
+	err := os.Remove(s.path(namespace, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s FileStore) List(namespace string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, fileName(namespace)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, e := range entries {
+		hexKey, ok := strings.CutPrefix(e.Name(), "x")
+		if e.IsDir() || !ok {
+			continue // a temporary file or not written by Set
+		}
+		key, err := hex.DecodeString(hexKey)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, string(key))
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// StoreAccounting returns an AccountingStore keeping the state in the
+// "accounting" namespace of s
+func StoreAccounting(s Store) AccountingStore {
+	return storeAccounting{s}
+}
+
+type storeAccounting struct {
+	store Store
+}
+
+func (s storeAccounting) Load() (*AccountingState, error) {
+	b, err := s.store.Get("accounting", "state")
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &AccountingState{}
+	return state, json.Unmarshal(b, state)
+}
+
+func (s storeAccounting) Save(state *AccountingState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.store.Set("accounting", "state", b)
+}
+
+// StoreJournal returns a JournalStore keeping each entry under its own key
+// in the "journal" namespace of s. Queries read every entry, which suits
+// journals of up to some thousands of entries.
+func StoreJournal(s Store) JournalStore {
+	return &storeJournal{store: s}
+}
+
+type storeJournal struct {
+	store Store
+
+	mu   sync.Mutex
+	last string // key of the last entry appended
+}
+
+func (s *storeJournal) Append(e JournalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == "" {
+		keys, err := s.store.List("journal")
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			s.last = keys[len(keys)-1]
+		}
+	}
+	// keys sort in the order of appending: by time, then by sequence for
+	// entries of the same nanosecond or a clock going back
+	key := fmt.Sprintf("%020d-%06d", max(e.Time.UnixNano(), 0), 0)
+	if key <= s.last {
+		var nanos int64
+		var seq int
+		fmt.Sscanf(s.last, "%020d-%06d", &nanos, &seq)
+		key = fmt.Sprintf("%020d-%06d", nanos, seq+1)
+	}
+	if err := s.store.Set("journal", key, b); err != nil {
+		return err
+	}
+	s.last = key
+	return nil
+}
+
+func (s *storeJournal) Query(q JournalQuery) ([]JournalEntry, error) {
+	keys, err := s.store.List("journal")
+	if err != nil {
+		return nil, err
+	}
+	var entries []JournalEntry
+	for _, key := range keys {
+		b, err := s.store.Get("journal", key)
+		if errors.Is(err, ErrNotFound) {
+			continue // deleted meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		var e JournalEntry
+		if json.Unmarshal(b, &e) != nil {
+			continue
+		}
+		if q.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,30 @@
+package transmission
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestFileStoreKeys checks that keys the filesystem would read as special
+// or fold together are kept apart
+func TestFileStoreKeys(t *testing.T) {
+	s := FileStore{Dir: t.TempDir()}
+	keys := []string{"", ".", "..", "A", "a", "a/b", "é"} // sorted
+	for _, ns := range []string{".", ".."} {
+		for _, key := range keys {
+			if err := s.Set(ns, key, []byte(ns+key)); err != nil {
+				t.Fatalf("Set(%q, %q): %v", ns, key, err)
+			}
+		}
+		for _, key := range keys {
+			v, err := s.Get(ns, key)
+			if err != nil || string(v) != ns+key {
+				t.Errorf("Get(%q, %q) = %q, %v, want %q", ns, key, v, err, ns+key)
+			}
+		}
+		list, err := s.List(ns)
+		if err != nil || !slices.Equal(list, keys) {
+			t.Errorf("List(%q) = %q, %v, want %q", ns, list, err, keys)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package transmission
+
+import "context"
+
+// summaryFields is the minimal set of fields needed to build a Summary
+var summaryFields = []string{"id", "status", "error", "rateDownload", "rateUpload",
+	"totalSize", "sizeWhenDone", "leftUntilDone", "peers"}
+
+// Summary aggregates the state of all torrents, as shown by status bars
+type Summary struct {
+	Torrents        int            // number of torrents
+	ByStatus        map[Status]int // number of torrents per status
+	TrackerWarnings int            // torrents with error 1
+	TrackerErrors   int            // torrents with error 2
+	LocalErrors     int            // torrents with error 3
+	RateDownload    uint64         // bytes/s
+	RateUpload      uint64         // bytes/s
+	TotalSize       uint64
+	SizeWhenDone    uint64
+	LeftUntilDone   uint64
+	Peers           PeerBreakdown // connected peers of all the torrents
+}
+
+// Errored returns the number of torrents with any kind of error
+func (s *Summary) Errored() int {
+	return s.TrackerWarnings + s.TrackerErrors + s.LocalErrors
+}
+
+// Summary fetches a minimal field set of all torrents in one call and
+// aggregates it
+func (ac *TransmissionClient) Summary(ctx context.Context) (*Summary, error) {
+	cmd := NewGetTorrentsCmd()
+	cmd.Arguments.Fields = summaryFields
+
+	out, err := ac.ExecuteCommandContext(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	out.Arguments.Torrents.validate()
+	s := &Summary{ByStatus: make(map[Status]int)}
+	for _, t := range out.Arguments.Torrents {
+		s.Torrents++
+		s.ByStatus[t.Status]++
+		switch t.Error {
+		case 1:
+			s.TrackerWarnings++
+		case 2:
+			s.TrackerErrors++
+		case 3:
+			s.LocalErrors++
+		}
+		s.RateDownload += t.DownloadRate()
+		s.RateUpload += t.UploadRate()
+		s.TotalSize += t.TotalSize
+		s.SizeWhenDone += t.SizeWhenDone
+		if left, ok := t.BytesLeft(); ok {
+			s.LeftUntilDone += left
+		}
+		s.Peers.add(t.PeerBreakdown())
+	}
+	return s, nil
+}
+
+// PeerBreakdown counts connected peers by transport and encryption, e.g.
+// to see the effect of enabling uTP or requiring encryption
+type PeerBreakdown struct {
+	Peers     int
+	UTP       int // over uTP, the others over TCP
+	Encrypted int
+}
+
+// PeerBreakdown counts the connected peers of t; the peers field must
+// have been fetched
+func (t *Torrent) PeerBreakdown() PeerBreakdown {
+	b := PeerBreakdown{Peers: len(t.Peers)}
+	for _, p := range t.Peers {
+		if p.IsUTP {
+			b.UTP++
+		}
+		if p.IsEncrypted {
+			b.Encrypted++
+		}
+	}
+	return b
+}
+
+func (b *PeerBreakdown) add(o PeerBreakdown) {
+	b.Peers += o.Peers
+	b.UTP += o.UTP
+	b.Encrypted += o.Encrypted
+}
+
+// TCP returns the number of peers over TCP
+func (b PeerBreakdown) TCP() int {
+	return b.Peers - b.UTP
+}
+
+// UTPPercent returns the percentage of the peers over uTP, 0 without peers
+func (b PeerBreakdown) UTPPercent() float64 {
+	return percent(b.UTP, b.Peers)
+}
+
+// EncryptedPercent returns the percentage of the peers encrypted, 0
+// without peers
+func (b PeerBreakdown) EncryptedPercent() float64 {
+	return percent(b.Encrypted, b.Peers)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
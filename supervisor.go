@@ -0,0 +1,146 @@
+package transmission
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ConnState is the health of the connection to the daemon
+type ConnState int
+
+const (
+	StateConnected ConnState = iota // the last probe succeeded
+	StateDegraded                   // recent probes failed, retrying
+	StateDown                       // DownAfter probes in a row failed
+)
+
+func (cs ConnState) String() string {
+	switch cs {
+	case StateConnected:
+		return "Connected"
+	case StateDegraded:
+		return "Degraded"
+	case StateDown:
+		return "Down"
+	default:
+		return "unknown"
+	}
+}
+
+// Supervisor probes the daemon with session-get, refreshing the session id
+// and rpc-version, and retries with exponential backoff when probes fail
+type Supervisor struct {
+	client *TransmissionClient
+
+	Interval   time.Duration // between probes while connected
+	MinBackoff time.Duration // first retry delay after a failure
+	MaxBackoff time.Duration
+	DownAfter  int // failed probes in a row before StateDown
+
+	mu         sync.Mutex
+	state      ConnState
+	failures   int
+	err        error
+	rpcVersion int
+	handlers   []func(ConnState, error)
+}
+
+// NewSupervisor returns a supervisor of client with default settings
+func NewSupervisor(client *TransmissionClient) *Supervisor {
+	return &Supervisor{
+		client:     client,
+		Interval:   30 * time.Second,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+		DownAfter:  3,
+	}
+}
+
+// OnStateChange registers fn to be called when the state changes, with the
+// error of the last probe
+func (s *Supervisor) OnStateChange(fn func(ConnState, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, fn)
+}
+
+// State returns the current connection state
+func (s *Supervisor) State() ConnState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Err returns the error of the last probe, nil if it succeeded
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// RPCVersion returns the rpc-version reported by the last successful probe
+func (s *Supervisor) RPCVersion() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rpcVersion
+}
+
+// Check probes the daemon once and updates the state. A failed probe drops
+// the session id so that the next one authenticates from scratch.
+func (s *Supervisor) Check(ctx context.Context) error {
+	var session struct {
+		RPCVersion int `json:"rpc-version"`
+	}
+	args := map[string][]string{"fields": {"rpc-version"}}
+	err := s.client.rpc(ctx, "session-get", args, &session)
+
+	s.mu.Lock()
+	prev := s.state
+	s.err = err
+	if err != nil {
+		s.client.apiclient.ResetSession()
+		s.failures++
+		s.state = StateDegraded
+		if s.failures >= s.DownAfter {
+			s.state = StateDown
+		}
+	} else {
+		s.failures = 0
+		s.state = StateConnected
+		s.rpcVersion = session.RPCVersion
+	}
+	state := s.state
+	handlers := s.handlers
+	s.mu.Unlock()
+
+	if state != prev {
+		for _, fn := range handlers {
+			fn(state, err)
+		}
+	}
+	return err
+}
+
+// Run probes every Interval while connected, and with a backoff doubling
+// from MinBackoff to MaxBackoff while probes fail, until ctx is done
+func (s *Supervisor) Run(ctx context.Context) error {
+	backoff := s.MinBackoff
+	for {
+		wait := s.Interval
+		if err := s.Check(ctx); err != nil {
+			wait = backoff
+			backoff = min(backoff*2, s.MaxBackoff)
+		} else {
+			backoff = s.MinBackoff
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package transmission
+
+import (
+	"container/list"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTorrentCacheEntries bounds a TorrentCache created with no bound
+const DefaultTorrentCacheEntries = 256
+
+// maxTorrentFile bounds the size of a fetched .torrent file
+const maxTorrentFile = 16 << 20
+
+// TorrentCache downloads .torrent files for torrent-add and keeps them by
+// url, so feeds polled again don't fetch the same metadata each time:
+// entries are revalidated with If-None-Match and If-Modified-Since, and
+// not at all while the server's Cache-Control max-age holds. The least
+// recently used entries are evicted first.
+type TorrentCache struct {
+	Client *http.Client // http.DefaultClient if nil
+
+	max     int
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *torrentEntry, most recent first
+	order   *list.List
+	hits    int
+}
+
+type torrentEntry struct {
+	url          string
+	data         []byte
+	etag         string
+	lastModified string
+	freshUntil   time.Time
+}
+
+// NewTorrentCache returns a cache of at most maxEntries files,
+// DefaultTorrentCacheEntries if maxEntries isn't positive
+func NewTorrentCache(maxEntries int) *TorrentCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultTorrentCacheEntries
+	}
+	return &TorrentCache{max: maxEntries, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// WithTorrentCache makes the client fetch the .torrent files of http and
+// https urls given to torrent-add through c and send their content,
+// instead of having the daemon download them
+func WithTorrentCache(c *TorrentCache) Option {
+	return func(ac *TransmissionClient) {
+		ac.torrentCache = c
+	}
+}
+
+// Fetch returns the .torrent file at url, from the cache when it is fresh
+// or the server tells it didn't change
+func (c *TorrentCache) Fetch(ctx context.Context, url string) ([]byte, error) {
+	c.mu.Lock()
+	var cached *torrentEntry
+	if el, ok := c.entries[url]; ok {
+		cached = el.Value.(*torrentEntry)
+		c.order.MoveToFront(el)
+		if time.Now().Before(cached.freshUntil) {
+			c.hits++
+			c.mu.Unlock()
+			return cached.data, nil
+		}
+	}
+	c.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	e := &torrentEntry{url: url, etag: res.Header.Get("ETag"), lastModified: res.Header.Get("Last-Modified")}
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached != nil:
+		e.data = cached.data
+		if e.etag == "" {
+			e.etag = cached.etag
+		}
+		if e.lastModified == "" {
+			e.lastModified = cached.lastModified
+		}
+		c.mu.Lock()
+		c.hits++
+		c.mu.Unlock()
+	case res.StatusCode == http.StatusOK:
+		e.data, err = io.ReadAll(io.LimitReader(res.Body, maxTorrentFile+1))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+		if len(e.data) > maxTorrentFile {
+			return nil, fmt.Errorf("%s: larger than %d bytes", url, maxTorrentFile)
+		}
+	default:
+		return nil, fmt.Errorf("%s: %s", url, res.Status)
+	}
+
+	maxAge, store := cacheControl(res.Header.Get("Cache-Control"))
+	if !store {
+		c.remove(url)
+		return e.data, nil
+	}
+	e.freshUntil = time.Now().Add(maxAge)
+	c.put(e)
+	return e.data, nil
+}
+
+// AddCmd returns a torrent-add command for the .torrent file at url,
+// fetched through the cache
+func (c *TorrentCache) AddCmd(ctx context.Context, url string) (*Command, error) {
+	b, err := c.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return NewAddCmdByBytes(b)
+}
+
+// Hits returns the number of fetches the cache answered without
+// downloading the file
+func (c *TorrentCache) Hits() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits
+}
+
+// Len returns the number of cached files
+func (c *TorrentCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *TorrentCache) put(e *torrentEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[e.url]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[e.url] = c.order.PushFront(e)
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*torrentEntry).url)
+	}
+}
+
+func (c *TorrentCache) remove(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[url]; ok {
+		c.order.Remove(el)
+		delete(c.entries, url)
+	}
+}
+
+// cacheControl returns the max-age of a Cache-Control header, and false
+// when the response must not be stored
+func cacheControl(header string) (maxAge time.Duration, store bool) {
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			return 0, true // revalidate every time
+		case "max-age":
+			if s, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && s > 0 {
+				maxAge = time.Duration(s) * time.Second
+			}
+		}
+	}
+	return maxAge, true
+}
+
+// fetchCached replaces the http url of a torrent-add command by the
+// content of the file, fetched through the client's cache
+func (ac *TransmissionClient) fetchCached(ctx context.Context, cmd *Command) error {
+	u := cmd.Arguments.Filename
+	if ac.torrentCache == nil || !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return nil
+	}
+	b, err := ac.torrentCache.Fetch(ctx, u)
+	if err != nil {
+		return err
+	}
+	cmd.Arguments.Filename = ""
+	cmd.Arguments.MetaInfo = base64.StdEncoding.EncodeToString(b)
+	return nil
+}
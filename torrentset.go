@@ -0,0 +1,237 @@
+package transmission
+
+import "context"
+
+// TorrentSetArgs are the arguments of torrent-set; nil fields are left
+// untouched by the daemon
+type TorrentSetArgs struct {
+	Ids                 []string            `json:"ids"`
+	BandwidthPriority   *Priority           `json:"bandwidthPriority,omitempty"`
+	HonorsSessionLimits *bool               `json:"honorsSessionLimits,omitempty"`
+	DownloadLimit       *int                `json:"downloadLimit,omitempty"` // KB/s
+	DownloadLimited     *bool               `json:"downloadLimited,omitempty"`
+	UploadLimit         *int                `json:"uploadLimit,omitempty"` // KB/s
+	UploadLimited       *bool               `json:"uploadLimited,omitempty"`
+	SeedRatioLimit      *float64            `json:"seedRatioLimit,omitempty"`
+	SeedRatioMode       *LimitMode          `json:"seedRatioMode,omitempty"`
+	SeedIdleLimit       *int                `json:"seedIdleLimit,omitempty"` // minutes
+	SeedIdleMode        *LimitMode          `json:"seedIdleMode,omitempty"`
+	Labels              *[]string           `json:"labels,omitempty"`       // replace the labels, an empty list clears them; Transmission 4.0+
+	FilesWanted         []int               `json:"files-wanted,omitempty"` // indexes of files
+	FilesUnwanted       []int               `json:"files-unwanted,omitempty"`
+	PriorityHigh        []int               `json:"priority-high,omitempty"`
+	PriorityNormal      []int               `json:"priority-normal,omitempty"`
+	PriorityLow         []int               `json:"priority-low,omitempty"`
+	TrackerAdd          []string            `json:"trackerAdd,omitempty"`
+	TrackerRemove       []int               `json:"trackerRemove,omitempty"` // ids of Torrent.Trackers
+	TrackerReplace      TrackerReplacements `json:"trackerReplace,omitempty"`
+	TrackerList         *string             `json:"trackerList,omitempty"` // replace the trackers, see TrackerList; Transmission 4.0+
+	Group               *string             `json:"group,omitempty"`       // bandwidth group, Transmission 4.0+
+	Location            *string             `json:"location,omitempty"`    // new download dir, the data isn't moved; see SetLocation
+	PeerLimit           *int                `json:"peer-limit,omitempty"`
+	QueuePosition       *int                `json:"queuePosition,omitempty"`
+	SequentialDownload  *bool               `json:"sequentialDownload,omitempty"` // Transmission 4.1+
+}
+
+// TorrentSet changes the settings of the torrents args.Ids to the non-nil
+// fields of args, see NewTorrentSet. Without ids it does nothing, where
+// the daemon would change all the torrents.
+func (ac *TransmissionClient) TorrentSet(ctx context.Context, args *TorrentSetArgs) error {
+	return ac.torrentSet(ctx, args)
+}
+
+func (ac *TransmissionClient) torrentSet(ctx context.Context, args *TorrentSetArgs) error {
+	if len(args.Ids) == 0 {
+		return nil // no ids would mean all torrents
+	}
+	return ac.rpc(ctx, "torrent-set", args, nil)
+}
+
+// SetBandwidthPriority sets the bandwidth priority of the torrent
+func (ac *TransmissionClient) SetBandwidthPriority(ctx context.Context, id string, p Priority) error {
+	return ac.torrentSet(ctx, &TorrentSetArgs{Ids: []string{id}, BandwidthPriority: &p})
+}
+
+// SetHonorsSessionLimits sets whether the torrent is subject to the global
+// speed limits
+func (ac *TransmissionClient) SetHonorsSessionLimits(ctx context.Context, id string, honors bool) error {
+	return ac.torrentSet(ctx, &TorrentSetArgs{Ids: []string{id}, HonorsSessionLimits: &honors})
+}
+
+// TorrentSetBuilder builds the arguments of torrent-set, e.g.
+//
+//	args := NewTorrentSet(hash).DownloadLimit(500).SeedRatio(2).Args()
+//	err := client.TorrentSet(ctx, args)
+type TorrentSetBuilder struct {
+	args TorrentSetArgs
+}
+
+// NewTorrentSet returns a builder of torrent-set arguments for the torrents
+// ids, ids or hashes
+func NewTorrentSet(ids ...string) *TorrentSetBuilder {
+	return &TorrentSetBuilder{args: TorrentSetArgs{Ids: ids}}
+}
+
+// Args returns the arguments built so far
+func (b *TorrentSetBuilder) Args() *TorrentSetArgs {
+	args := b.args
+	return &args
+}
+
+// BandwidthPriority sets the bandwidth priority
+func (b *TorrentSetBuilder) BandwidthPriority(p Priority) *TorrentSetBuilder {
+	b.args.BandwidthPriority = &p
+	return b
+}
+
+// HonorsSessionLimits sets whether the global speed limits apply
+func (b *TorrentSetBuilder) HonorsSessionLimits(honors bool) *TorrentSetBuilder {
+	b.args.HonorsSessionLimits = &honors
+	return b
+}
+
+// DownloadLimit limits the download speed to kbps KB/s
+func (b *TorrentSetBuilder) DownloadLimit(kbps int) *TorrentSetBuilder {
+	limited := true
+	b.args.DownloadLimit, b.args.DownloadLimited = &kbps, &limited
+	return b
+}
+
+// NoDownloadLimit lifts the download speed limit
+func (b *TorrentSetBuilder) NoDownloadLimit() *TorrentSetBuilder {
+	limited := false
+	b.args.DownloadLimited = &limited
+	return b
+}
+
+// UploadLimit limits the upload speed to kbps KB/s
+func (b *TorrentSetBuilder) UploadLimit(kbps int) *TorrentSetBuilder {
+	limited := true
+	b.args.UploadLimit, b.args.UploadLimited = &kbps, &limited
+	return b
+}
+
+// NoUploadLimit lifts the upload speed limit
+func (b *TorrentSetBuilder) NoUploadLimit() *TorrentSetBuilder {
+	limited := false
+	b.args.UploadLimited = &limited
+	return b
+}
+
+// SeedRatio stops seeding at ratio, whatever the session's limit
+func (b *TorrentSetBuilder) SeedRatio(ratio float64) *TorrentSetBuilder {
+	mode := OverrideLimit
+	b.args.SeedRatioLimit, b.args.SeedRatioMode = &ratio, &mode
+	return b
+}
+
+// SeedRatioMode sets how the ratio limit applies, e.g. GlobalLimit
+func (b *TorrentSetBuilder) SeedRatioMode(mode LimitMode) *TorrentSetBuilder {
+	b.args.SeedRatioMode = &mode
+	return b
+}
+
+// SeedIdle stops seeding after minutes without activity, whatever the
+// session's limit
+func (b *TorrentSetBuilder) SeedIdle(minutes int) *TorrentSetBuilder {
+	mode := OverrideLimit
+	b.args.SeedIdleLimit, b.args.SeedIdleMode = &minutes, &mode
+	return b
+}
+
+// SeedIdleMode sets how the idle limit applies, e.g. GlobalLimit
+func (b *TorrentSetBuilder) SeedIdleMode(mode LimitMode) *TorrentSetBuilder {
+	b.args.SeedIdleMode = &mode
+	return b
+}
+
+// Labels replaces the labels, clearing them without any (Transmission
+// 4.0+)
+func (b *TorrentSetBuilder) Labels(labels ...string) *TorrentSetBuilder {
+	if labels == nil {
+		labels = []string{} // sent as [], not null
+	}
+	b.args.Labels = &labels
+	return b
+}
+
+// Wanted marks the files of the given indexes for download
+func (b *TorrentSetBuilder) Wanted(files ...int) *TorrentSetBuilder {
+	b.args.FilesWanted = append(b.args.FilesWanted, files...)
+	return b
+}
+
+// Unwanted marks the files of the given indexes to skip
+func (b *TorrentSetBuilder) Unwanted(files ...int) *TorrentSetBuilder {
+	b.args.FilesUnwanted = append(b.args.FilesUnwanted, files...)
+	return b
+}
+
+// FilePriority sets the priority of the files of the given indexes
+func (b *TorrentSetBuilder) FilePriority(p Priority, files ...int) *TorrentSetBuilder {
+	switch p {
+	case PriorityHigh:
+		b.args.PriorityHigh = append(b.args.PriorityHigh, files...)
+	case PriorityLow:
+		b.args.PriorityLow = append(b.args.PriorityLow, files...)
+	default:
+		b.args.PriorityNormal = append(b.args.PriorityNormal, files...)
+	}
+	return b
+}
+
+// AddTrackers adds trackers, each in a tier of its own
+func (b *TorrentSetBuilder) AddTrackers(announce ...string) *TorrentSetBuilder {
+	b.args.TrackerAdd = append(b.args.TrackerAdd, announce...)
+	return b
+}
+
+// RemoveTrackers removes the trackers of the given ids
+func (b *TorrentSetBuilder) RemoveTrackers(ids ...int) *TorrentSetBuilder {
+	b.args.TrackerRemove = append(b.args.TrackerRemove, ids...)
+	return b
+}
+
+// ReplaceTracker changes the announce url of the tracker id
+func (b *TorrentSetBuilder) ReplaceTracker(id int, announce string) *TorrentSetBuilder {
+	b.args.TrackerReplace = append(b.args.TrackerReplace, TrackerReplace{ID: id, Announce: announce})
+	return b
+}
+
+// Trackers replaces all the trackers with tiers (Transmission 4.0+)
+func (b *TorrentSetBuilder) Trackers(tiers TrackerList) *TorrentSetBuilder {
+	list := tiers.String()
+	b.args.TrackerList = &list
+	return b
+}
+
+// Group puts the torrents in a bandwidth group (Transmission 4.0+)
+func (b *TorrentSetBuilder) Group(name string) *TorrentSetBuilder {
+	b.args.Group = &name
+	return b
+}
+
+// Location sets the download dir without moving the data
+func (b *TorrentSetBuilder) Location(dir string) *TorrentSetBuilder {
+	b.args.Location = &dir
+	return b
+}
+
+// PeerLimit caps the number of peers
+func (b *TorrentSetBuilder) PeerLimit(n int) *TorrentSetBuilder {
+	b.args.PeerLimit = &n
+	return b
+}
+
+// QueuePosition moves the torrents in the queue, 0 being the first
+func (b *TorrentSetBuilder) QueuePosition(pos int) *TorrentSetBuilder {
+	b.args.QueuePosition = &pos
+	return b
+}
+
+// SequentialDownload sets whether pieces are downloaded in order
+// (Transmission 4.1+)
+func (b *TorrentSetBuilder) SequentialDownload(on bool) *TorrentSetBuilder {
+	b.args.SequentialDownload = &on
+	return b
+}
@@ -0,0 +1,86 @@
+package tracker
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/unix2dos/go-transmission/v2/bencode"
+)
+
+// HTTPClient is used for HTTP scrapes
+var HTTPClient = http.DefaultClient
+
+// maxScrapeResponse bounds the size of an HTTP scrape response
+const maxScrapeResponse = 1 << 20
+
+func scrapeHTTP(ctx context.Context, announce *url.URL, hashes [][20]byte) (map[string]ScrapeResult, error) {
+	scrape, err := ScrapeURL(announce.String())
+	if err != nil {
+		return nil, err
+	}
+	var q strings.Builder
+	for _, h := range hashes {
+		if q.Len() > 0 || strings.Contains(scrape, "?") {
+			q.WriteByte('&')
+		} else {
+			q.WriteByte('?')
+		}
+		q.WriteString("info_hash=" + url.QueryEscape(string(h[:])))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scrape+q.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tracker: scrape: %s", res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxScrapeResponse))
+	if err != nil {
+		return nil, err
+	}
+	return parseHTTPScrape(body)
+}
+
+func parseHTTPScrape(body []byte) (map[string]ScrapeResult, error) {
+	v, err := bencode.Decode(body)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tracker: scrape response is not a dictionary")
+	}
+	if reason, ok := dict["failure reason"].(string); ok {
+		return nil, fmt.Errorf("tracker: %s", reason)
+	}
+	files, _ := dict["files"].(map[string]interface{})
+
+	results := make(map[string]ScrapeResult, len(files))
+	for hash, v := range files {
+		stats, ok := v.(map[string]interface{})
+		if !ok || len(hash) != 20 {
+			continue
+		}
+		integer := func(key string) int {
+			n, _ := stats[key].(int64)
+			return int(n)
+		}
+		results[hex.EncodeToString([]byte(hash))] = ScrapeResult{
+			Seeders:   integer("complete"),
+			Leechers:  integer("incomplete"),
+			Completed: integer("downloaded"),
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,61 @@
+// Package tracker scrapes BitTorrent trackers directly, over HTTP (BEP 48)
+// and UDP (BEP 15), giving seeder and leecher counts independent of the
+// daemon's trackerStats.
+package tracker
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrNoScrape is returned for HTTP trackers whose announce url doesn't allow
+// deriving a scrape url
+var ErrNoScrape = errors.New("tracker: announce url has no scrape counterpart")
+
+// ScrapeResult is what a tracker reports for one infohash
+type ScrapeResult struct {
+	Seeders   int // peers with the complete data
+	Leechers  int // peers downloading
+	Completed int // downloads completed since the tracker started counting
+}
+
+// Scrape asks the tracker of the announce url about the infohashes, given
+// as hex strings like Torrent.InfoHash. The results are keyed by lowercase
+// hex; hashes unknown to the tracker are missing.
+func Scrape(ctx context.Context, announce string, hashes ...string) (map[string]ScrapeResult, error) {
+	raw := make([][20]byte, len(hashes))
+	for i, h := range hashes {
+		b, err := hex.DecodeString(h)
+		if err != nil || len(b) != 20 {
+			return nil, fmt.Errorf("tracker: bad infohash %q", h)
+		}
+		copy(raw[i][:], b)
+	}
+
+	u, err := url.Parse(announce)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return scrapeHTTP(ctx, u, raw)
+	case "udp":
+		return scrapeUDP(ctx, u.Host, raw)
+	default:
+		return nil, fmt.Errorf("tracker: unsupported scheme %q", u.Scheme)
+	}
+}
+
+// ScrapeURL returns the scrape url of an HTTP announce url, by convention
+// the one with the last path element "announce..." replaced by "scrape..."
+func ScrapeURL(announce string) (string, error) {
+	i := strings.LastIndexByte(announce, '/')
+	if i < 0 || !strings.HasPrefix(announce[i+1:], "announce") {
+		return "", ErrNoScrape
+	}
+	return announce[:i+1] + "scrape" + strings.TrimPrefix(announce[i+1:], "announce"), nil
+}
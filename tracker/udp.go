@@ -0,0 +1,116 @@
+package tracker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	udpProtocolID  = 0x41727101980
+	udpActConnect  = 0
+	udpActScrape   = 2
+	udpActError    = 3
+	udpMaxHashes   = 74 // per scrape request, to fit the usual MTU
+	udpTimeout     = 5 * time.Second
+	udpRetransmits = 3
+	udpMaxResponse = 8 + udpMaxHashes*12
+)
+
+func scrapeUDP(ctx context.Context, host string, hashes [][20]byte) (map[string]ScrapeResult, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	tid := transactionID()
+	req := binary.BigEndian.AppendUint64(nil, udpProtocolID)
+	req = binary.BigEndian.AppendUint32(req, udpActConnect)
+	req = binary.BigEndian.AppendUint32(req, tid)
+	res, err := udpRoundTrip(ctx, conn, req, udpActConnect, tid, 16)
+	if err != nil {
+		return nil, err
+	}
+	connID := binary.BigEndian.Uint64(res[8:16])
+
+	results := make(map[string]ScrapeResult, len(hashes))
+	for len(hashes) > 0 {
+		batch := hashes[:min(len(hashes), udpMaxHashes)]
+		hashes = hashes[len(batch):]
+
+		tid = transactionID()
+		req = binary.BigEndian.AppendUint64(nil, connID)
+		req = binary.BigEndian.AppendUint32(req, udpActScrape)
+		req = binary.BigEndian.AppendUint32(req, tid)
+		for _, h := range batch {
+			req = append(req, h[:]...)
+		}
+		res, err := udpRoundTrip(ctx, conn, req, udpActScrape, tid, 8+12*len(batch))
+		if err != nil {
+			return nil, err
+		}
+		for i, h := range batch {
+			b := res[8+12*i:]
+			results[hex.EncodeToString(h[:])] = ScrapeResult{
+				Seeders:   int(binary.BigEndian.Uint32(b[0:4])),
+				Completed: int(binary.BigEndian.Uint32(b[4:8])),
+				Leechers:  int(binary.BigEndian.Uint32(b[8:12])),
+			}
+		}
+	}
+	return results, nil
+}
+
+// udpRoundTrip sends req until a response of at least size bytes to the
+// transaction arrives, retransmitting on timeouts
+func udpRoundTrip(ctx context.Context, conn net.Conn, req []byte, action, tid uint32, size int) ([]byte, error) {
+	buf := make([]byte, udpMaxResponse)
+	for try := 0; try < udpRetransmits; try++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(udpTimeout))
+		for {
+			n, err := conn.Read(buf)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if n < 8 || binary.BigEndian.Uint32(buf[4:8]) != tid {
+				continue // stray packet
+			}
+			switch act := binary.BigEndian.Uint32(buf[0:4]); {
+			case act == udpActError:
+				return nil, fmt.Errorf("tracker: %s", buf[8:n])
+			case act != action || n < size:
+				return nil, errors.New("tracker: malformed udp response")
+			}
+			return buf[:n], nil
+		}
+	}
+	return nil, errors.New("tracker: udp tracker not responding")
+}
+
+func transactionID() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
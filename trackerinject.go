@@ -0,0 +1,68 @@
+package transmission
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// TrackerInjection is what InjectTrackers did, or would do, to one torrent
+type TrackerInjection struct {
+	Torrent *Torrent
+	Added   []string // announce urls added
+	Err     error
+}
+
+// InjectTrackers adds the announce urls to the selected torrents (all of
+// them if no id is given), skipping private torrents and the urls a torrent
+// already has in any tier. With dryRun nothing is changed and the result
+// tells what would be added.
+func (ac *TransmissionClient) InjectTrackers(ctx context.Context, urls []string, dryRun bool, ids ...string) ([]TrackerInjection, error) {
+	torrents, err := ac.GetTorrentsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []TrackerInjection
+	for _, t := range torrents {
+		if t.IsPrivate || len(ids) > 0 && !t.hasAnyID(ids) {
+			continue
+		}
+		known := make(map[string]bool, len(t.Trackers))
+		for _, tr := range t.Trackers {
+			known[normalizeAnnounce(tr.Announce)] = true
+		}
+		var add []string
+		for _, u := range urls {
+			if n := normalizeAnnounce(u); n != "" && !known[n] {
+				known[n] = true
+				add = append(add, strings.TrimSpace(u))
+			}
+		}
+		if len(add) == 0 {
+			continue
+		}
+		inj := TrackerInjection{Torrent: t, Added: add}
+		if !dryRun {
+			inj.Err = ac.torrentSet(ctx, &TorrentSetArgs{Ids: []string{t.InfoHash}, TrackerAdd: add})
+		}
+		result = append(result, inj)
+	}
+	return result, nil
+}
+
+// normalizeAnnounce returns the form of an announce url used to detect
+// duplicates
+func normalizeAnnounce(u string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(u)), "/")
+}
+
+// hasAnyID reports whether the torrent's hash or numeric id is in ids
+func (t *Torrent) hasAnyID(ids []string) bool {
+	for _, id := range ids {
+		if id == t.InfoHash || id == strconv.Itoa(t.ID) {
+			return true
+		}
+	}
+	return false
+}
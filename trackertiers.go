@@ -0,0 +1,119 @@
+package transmission
+
+import (
+	"context"
+	"slices"
+)
+
+// TrackerTier is a group of trackers of a torrent the daemon fails over
+// between; it moves to the next tier when all trackers of one are failing
+type TrackerTier struct {
+	Tier     int
+	Trackers []TrackerStat
+}
+
+// trackerFailing reports whether the last announce to the tracker failed
+func trackerFailing(ts TrackerStat) bool {
+	return ts.HasAnnounced && !ts.LastAnnounceSucceeded || ts.LastAnnounceTimedOut
+}
+
+// Failing reports whether every tracker of the tier failed its last
+// announce; trackers not announced to yet count as working
+func (tt TrackerTier) Failing() bool {
+	for _, ts := range tt.Trackers {
+		if !trackerFailing(ts) {
+			return false
+		}
+	}
+	return len(tt.Trackers) > 0
+}
+
+// TrackerTiers groups the trackerStats of the torrent by tier, in tier
+// order
+func (t *Torrent) TrackerTiers() []TrackerTier {
+	var tiers []TrackerTier
+	for _, ts := range t.TrackerStats {
+		i := slices.IndexFunc(tiers, func(tt TrackerTier) bool { return tt.Tier == ts.Tier })
+		if i < 0 {
+			tiers = append(tiers, TrackerTier{Tier: ts.Tier})
+			i = len(tiers) - 1
+		}
+		tiers[i].Trackers = append(tiers[i].Trackers, ts)
+	}
+	slices.SortFunc(tiers, func(a, b TrackerTier) int { return a.Tier - b.Tier })
+	return tiers
+}
+
+// FailingTier is a tier of a torrent whose trackers are all failing
+type FailingTier struct {
+	Torrent *Torrent
+	Tier    TrackerTier
+	Dead    bool // every tier of the torrent is failing, the daemon has nothing to fail over to
+}
+
+// FailingTiers reports the tiers of the torrents whose trackers are all
+// failing; the torrents need the "trackerStats" field
+func (ts Torrents) FailingTiers() []FailingTier {
+	var failing []FailingTier
+	for _, t := range ts {
+		tiers := t.TrackerTiers()
+		var bad []TrackerTier
+		for _, tt := range tiers {
+			if tt.Failing() {
+				bad = append(bad, tt)
+			}
+		}
+		for _, tt := range bad {
+			failing = append(failing, FailingTier{Torrent: t, Tier: tt, Dead: len(bad) == len(tiers)})
+		}
+	}
+	return failing
+}
+
+// TrackerRemoval is what RemoveFailingTrackers did, or would do, to one
+// torrent
+type TrackerRemoval struct {
+	Torrent *Torrent
+	Removed []TrackerStat
+	Err     error
+}
+
+// RemoveFailingTrackers removes the trackers of the failing tiers of the
+// selected torrents (all of them if no id is given), keeping torrents whose
+// tiers are all failing untouched so that no torrent is left without
+// trackers. Call it on a schedule spaced enough for a tracker to fail
+// persistently, not on a single outage. With dryRun nothing is changed and
+// the result tells what would be removed.
+func (ac *TransmissionClient) RemoveFailingTrackers(ctx context.Context, dryRun bool, ids ...string) ([]TrackerRemoval, error) {
+	torrents, err := ac.GetTorrentsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []TrackerRemoval
+	byTorrent := make(map[*Torrent]int)
+	for _, ft := range torrents.FailingTiers() {
+		if ft.Dead || len(ids) > 0 && !ft.Torrent.hasAnyID(ids) {
+			continue
+		}
+		i, ok := byTorrent[ft.Torrent]
+		if !ok {
+			result = append(result, TrackerRemoval{Torrent: ft.Torrent})
+			i = len(result) - 1
+			byTorrent[ft.Torrent] = i
+		}
+		result[i].Removed = append(result[i].Removed, ft.Tier.Trackers...)
+	}
+
+	if !dryRun {
+		for i := range result {
+			r := &result[i]
+			remove := make([]int, len(r.Removed))
+			for j, ts := range r.Removed {
+				remove[j] = int(ts.ID)
+			}
+			r.Err = ac.torrentSet(ctx, &TorrentSetArgs{Ids: []string{r.Torrent.InfoHash}, TrackerRemove: remove})
+		}
+	}
+	return result, nil
+}
@@ -1,23 +1,77 @@
 package transmission
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 var (
 	ErrNoTorrent = errors.New("No torrent with that id")
+	// ErrNotATorrentFile is returned by NewAddCmdByFile for a file too
+	// large for a .torrent, see MaxTorrentFileSize, or not bencoded
+	ErrNotATorrentFile = errors.New("not a torrent file")
 )
 
-//TransmissionClient to talk to transmission
+// MaxTorrentFileSize is the size of the largest file NewAddCmdByFile
+// accepts; real .torrent files stay far below
+const MaxTorrentFileSize = 64 << 20
+
+// TransmissionClient to talk to transmission
 type TransmissionClient struct {
-	apiclient *ApiClient
+	apiclient       *ApiClient
+	downloadDir     string // default for added torrents
+	dryRun          *dryRun
+	audit           AuditSink
+	probe           func(ctx context.Context, c *TransmissionClient) error // see WithProbe
+	maxRequest      int                                                    // see WithMaxRequestSize
+	torrentCache    *TorrentCache                                          // see WithTorrentCache
+	torrentFlights  *torrentFlights                                        // see WithTorrentDedupe
+	rewriteAnnounce func(string) string                                    // see WithAnnounceRewrite
+	rewriteMetaInfo func([]byte, func(string) string) ([]byte, error)      // see WithMetaInfoRewrite
+	undoLog         *undoLog                                               // see WithUndoLog
+
+	mu    sync.Mutex
+	views map[string]*Query
+}
+
+// Option configures a TransmissionClient created by New
+type Option func(*TransmissionClient)
+
+// WithDefaultDownloadDir sets the download dir of added torrents that don't
+// set one; relative dirs are resolved against the session's download-dir
+func WithDefaultDownloadDir(dir string) Option {
+	return func(ac *TransmissionClient) {
+		ac.downloadDir = dir
+	}
+}
+
+// WithHTTPClient makes the client send its requests with c, e.g. for a
+// custom transport or the recorder of the transmissiontest package
+func WithHTTPClient(c *http.Client) Option {
+	return func(ac *TransmissionClient) {
+		ac.apiclient.client = *c
+	}
+}
+
+// WithMaxResponseSize bounds the size of the responses read, see
+// ApiClient.SetMaxResponseSize
+func WithMaxResponseSize(n int64) Option {
+	return func(ac *TransmissionClient) {
+		ac.apiclient.SetMaxResponseSize(n)
+	}
 }
 
 type Command struct {
@@ -27,25 +81,28 @@ type Command struct {
 }
 
 type arguments struct {
-	Fields           []string     `json:"fields,omitempty"`
-	Torrents         Torrents     `json:"torrents,omitempty"`
-	Ids              []string     `json:"ids,omitempty"`
-	DeleteData       bool         `json:"delete-local-data,omitempty"`
-	DownloadDir      string       `json:"download-dir,omitempty"`
-	MetaInfo         string       `json:"metainfo,omitempty"`
-	Filename         string       `json:"filename,omitempty"`
-	TorrentAdded     TorrentAdded `json:"torrent-added"`
-	TorrentDuplicate TorrentAdded `json:"torrent-duplicate"`
-
-	// Stats
-	ActiveTorrentCount int             `json:"activeTorrentCount"`
-	CumulativeStats    cumulativeStats `json:"cumulative-stats"`
-	CurrentStats       currentStats    `json:"current-stats"`
-	DownloadSpeed      uint64          `json:"downloadSpeed"`
-	PausedTorrentCount int             `json:"pausedTorrentCount"`
-	TorrentCount       int             `json:"torrentCount"`
-	UploadSpeed        uint64          `json:"uploadSpeed"`
-	Version            string          `json:"version"`
+	Fields            []string      `json:"fields,omitempty"`
+	Torrents          Torrents      `json:"torrents,omitempty"`
+	Ids               []string      `json:"ids,omitempty"`
+	DeleteData        bool          `json:"delete-local-data,omitempty"`
+	DownloadDir       string        `json:"download-dir,omitempty"`
+	MetaInfo          string        `json:"metainfo,omitempty"`
+	Filename          string        `json:"filename,omitempty"`
+	BandwidthPriority *Priority     `json:"bandwidthPriority,omitempty"`
+	Paused            *bool         `json:"paused,omitempty"`
+	Labels            []string      `json:"labels,omitempty"`
+	TorrentAdded      *TorrentAdded `json:"torrent-added,omitempty"`
+	TorrentDuplicate  *TorrentAdded `json:"torrent-duplicate,omitempty"`
+
+	// Stats, only sent by the daemon
+	ActiveTorrentCount int              `json:"activeTorrentCount,omitempty"`
+	CumulativeStats    *cumulativeStats `json:"cumulative-stats,omitempty"`
+	CurrentStats       *currentStats    `json:"current-stats,omitempty"`
+	DownloadSpeed      uint64           `json:"downloadSpeed,omitempty"`
+	PausedTorrentCount int              `json:"pausedTorrentCount,omitempty"`
+	TorrentCount       int              `json:"torrentCount,omitempty"`
+	UploadSpeed        uint64           `json:"uploadSpeed,omitempty"`
+	Version            string           `json:"version,omitempty"`
 }
 
 type peer struct {
@@ -76,7 +133,8 @@ type tracker struct {
 	Tire     int    `json:"tire"`
 }
 
-type trackerStat struct {
+// TrackerStat is the state of one tracker of a torrent
+type TrackerStat struct {
 	Announce              string `json:"announce"`
 	AnnounceState         int    `json:"announceState"`
 	DownloadCount         int    `json:"downloadCount"`
@@ -107,7 +165,7 @@ type trackerStat struct {
 
 type trackers []tracker
 
-//TorrentAdded data returning
+// TorrentAdded data returning
 type TorrentAdded struct {
 	HashString string `json:"hashString"`
 	ID         int    `json:"id"`
@@ -153,40 +211,95 @@ type File struct {
 	Name      string `json:"name"`
 }
 
+// PercentDone returns the share of the file downloaded, 0...1; an empty
+// file is done
+func (f File) PercentDone() float64 {
+	if f.Size <= 0 {
+		return 1
+	}
+	return float64(min(max(f.Completed, 0), f.Size)) / float64(f.Size)
+}
+
 type Files []File
 
-//Torrent struct for torrents
+// Flags are per-file booleans, sent as true/false or as 1/0 depending on
+// the daemon's version
+type Flags []bool
+
+func (f *Flags) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	flags := make(Flags, len(raw))
+	for i, r := range raw {
+		switch string(r) {
+		case "true", "1":
+			flags[i] = true
+		case "false", "0":
+		default:
+			return fmt.Errorf("flags: %s is not a boolean", r)
+		}
+	}
+	*f = flags
+	return nil
+}
+
+// Torrent struct for torrents
 type Torrent struct {
-	ID              int           `json:"id"`
-	Name            string        `json:"name"`
-	Status          Status        `json:"status"`
-	AddedDate       int64         `json:"addedDate"` // unix timestamp
-	StartDate       int64         `json:"startDate"` // unix timestamp
-	DoneDate        int64         `json:"doneDate"`  // unix timestamp
-	LeftUntilDone   uint64        `json:"leftUntilDone"`
-	SizeWhenDone    uint64        `json:"sizeWhenDone"`
-	Eta             time.Duration `json:"eta"` // in seconds, not a valid time.Duration
-	UploadRatio     float64       `json:"uploadRatio"`
-	RateDownload    uint64        `json:"rateDownload"`
-	RateUpload      uint64        `json:"rateUpload"`
-	DownloadDir     string        `json:"downloadDir"`
-	DownloadedEver  uint64        `json:"downloadedEver"`
-	UploadedEver    uint64        `json:"uploadedEver"`
-	HaveUnchecked   uint64        `json:"haveUnchecked"`
-	HaveValid       uint64        `json:"haveValid"`
-	IsFinished      bool          `json:"isFinished"`
-	PercentDone     float32       `json:"percentDone"` // 0...1, double
-	SeedRatioMode   int           `json:"seedRatioMode"`
-	Files           Files         `json:"files"`
-	Peers           peers         `json:"peers"`
-	Trackers        trackers      `json:"trackers"`
-	TrackerStats    []trackerStat `json:"trackerStats"`
-	Error           int           `json:"error"`
-	ErrorString     string        `json:"errorString"`
-	InfoHash        string        `json:"hashString"`
-	TotalSize       uint64        `json:"totalSize"`
-	DownloadSeconds uint64        `json:"secondsDownloading"`
-	SeedSeconds     uint64        `json:"secondsSeeding"`
+	ID                      int           `json:"id"`
+	Name                    string        `json:"name"`
+	Status                  Status        `json:"status"`
+	AddedDate               int64         `json:"addedDate"`        // unix timestamp
+	StartDate               int64         `json:"startDate"`        // unix timestamp
+	DoneDate                int64         `json:"doneDate"`         // unix timestamp
+	ActivityDate            int64         `json:"activityDate"`     // unix timestamp of the last transfer
+	LeftUntilDone           int64         `json:"leftUntilDone"`    // may be negative, see BytesLeft
+	DesiredAvailable        int64         `json:"desiredAvailable"` // bytes of the wanted data available from connected peers
+	SizeWhenDone            uint64        `json:"sizeWhenDone"`
+	Eta                     int64         `json:"eta"`     // in seconds, may be negative, see TimeLeft
+	EtaIdle                 int64         `json:"etaIdle"` // seconds until the idle seeding limit stops it, may be negative, see IdleTimeLeft
+	UploadRatio             float64       `json:"uploadRatio"`
+	RateDownload            int64         `json:"rateDownload"` // B/s, may be negative, see DownloadRate
+	RateUpload              int64         `json:"rateUpload"`   // B/s, may be negative, see UploadRate
+	DownloadDir             string        `json:"downloadDir"`
+	DownloadedEver          uint64        `json:"downloadedEver"`
+	UploadedEver            uint64        `json:"uploadedEver"`
+	HaveUnchecked           uint64        `json:"haveUnchecked"`
+	HaveValid               uint64        `json:"haveValid"`
+	IsFinished              bool          `json:"isFinished"`
+	IsStalled               bool          `json:"isStalled"`
+	IsPrivate               bool          `json:"isPrivate"`
+	PercentDone             float32       `json:"percentDone"`     // 0...1 of the wanted bytes, double
+	PercentComplete         float64       `json:"percentComplete"` // 0...1 of all the bytes
+	SeedRatioMode           LimitMode     `json:"seedRatioMode"`
+	SeedRatioLimit          float64       `json:"seedRatioLimit"` // with OverrideLimit
+	SeedIdleMode            LimitMode     `json:"seedIdleMode"`
+	SeedIdleLimit           int           `json:"seedIdleLimit"` // minutes, with OverrideLimit
+	DownloadLimit           int           `json:"downloadLimit"` // KB/s, with DownloadLimited
+	DownloadLimited         bool          `json:"downloadLimited"`
+	UploadLimit             int           `json:"uploadLimit"` // KB/s, with UploadLimited
+	UploadLimited           bool          `json:"uploadLimited"`
+	QueuePosition           int           `json:"queuePosition"`
+	BandwidthPriority       Priority      `json:"bandwidthPriority"`
+	HonorsSessionLimits     bool          `json:"honorsSessionLimits"`
+	RecheckProgress         float64       `json:"recheckProgress"`         // 0...1
+	MetadataPercentComplete float64       `json:"metadataPercentComplete"` // 0...1, below 1 for magnets without metadata yet
+	Labels                  []string      `json:"labels"`                  // Transmission 4.0+
+	Files                   Files         `json:"files"`
+	Wanted                  Flags         `json:"wanted"`      // by file
+	Priorities              []Priority    `json:"priorities"`  // by file
+	TorrentFile             string        `json:"torrentFile"` // path of the .torrent file on the daemon's host
+	MagnetLink              string        `json:"magnetLink"`
+	Peers                   peers         `json:"peers"`
+	Trackers                trackers      `json:"trackers"`
+	TrackerStats            []TrackerStat `json:"trackerStats"`
+	Error                   int           `json:"error"`
+	ErrorString             string        `json:"errorString"`
+	InfoHash                string        `json:"hashString"`
+	TotalSize               uint64        `json:"totalSize"`
+	DownloadSeconds         uint64        `json:"secondsDownloading"`
+	SeedSeconds             uint64        `json:"secondsSeeding"`
 }
 
 func (t *Torrent) GetSize() uint64 {
@@ -210,7 +323,45 @@ func (t *Torrent) ETA() string {
 	if t.Eta < 0 {
 		return "∞"
 	}
-	return (time.Second * t.Eta).String()
+	return (time.Second * time.Duration(t.Eta)).String()
+}
+
+// TimeLeft returns eta as a time.Duration; ok is false when the daemon
+// sent one of the sentinels (EtaNotAvailable, EtaUnknown)
+func (t *Torrent) TimeLeft() (d time.Duration, ok bool) {
+	if t.Eta < 0 {
+		return 0, false
+	}
+	return time.Second * time.Duration(t.Eta), true
+}
+
+// IdleTimeLeft returns etaIdle as a time.Duration; ok is false when the
+// daemon sent one of the sentinels, e.g. because the torrent is uploading
+// or has no idle limit
+func (t *Torrent) IdleTimeLeft() (d time.Duration, ok bool) {
+	if t.EtaIdle < 0 {
+		return 0, false
+	}
+	return time.Second * time.Duration(t.EtaIdle), true
+}
+
+// BytesLeft returns leftUntilDone; ok is false when the daemon sent a
+// negative value
+func (t *Torrent) BytesLeft() (n uint64, ok bool) {
+	if t.LeftUntilDone < 0 {
+		return 0, false
+	}
+	return uint64(t.LeftUntilDone), true
+}
+
+// DownloadRate returns rateDownload in B/s, negative values read as 0
+func (t *Torrent) DownloadRate() uint64 {
+	return uint64(max(t.RateDownload, 0))
+}
+
+// UploadRate returns rateUpload in B/s, negative values read as 0
+func (t *Torrent) UploadRate() uint64 {
+	return uint64(max(t.RateUpload, 0))
 }
 
 // GetTrackers combines the torrent's trackers in one string
@@ -228,6 +379,26 @@ func (t *Torrent) Have() uint64 {
 	return t.HaveValid + t.HaveUnchecked
 }
 
+// WantedBytes returns the size of the wanted files, sizeWhenDone when the
+// files or their wanted flags weren't requested
+func (t *Torrent) WantedBytes() uint64 {
+	if len(t.Files) == 0 || len(t.Wanted) != len(t.Files) {
+		return t.SizeWhenDone
+	}
+	var n uint64
+	for i, f := range t.Files {
+		if t.Wanted[i] {
+			n += uint64(max(f.Size, 0))
+		}
+	}
+	return n
+}
+
+// UnwantedBytes returns the size of the files not to download
+func (t *Torrent) UnwantedBytes() uint64 {
+	return t.TotalSize - min(t.WantedBytes(), t.TotalSize)
+}
+
 func (t *Torrent) IsCompleted() bool {
 	return t.PercentDone == 1
 }
@@ -235,6 +406,17 @@ func (t *Torrent) IsCompleted() bool {
 // Torrents represent []Torrent
 type Torrents []*Torrent
 
+// UnmarshalJSON decodes a torrents array, dropping null entries so that a
+// malformed response can't leave nil torrents in the list
+func (t *Torrents) UnmarshalJSON(b []byte) error {
+	var torrents []*Torrent
+	if err := json.Unmarshal(b, &torrents); err != nil {
+		return err
+	}
+	*t = slices.DeleteFunc(torrents, func(t *Torrent) bool { return t == nil })
+	return nil
+}
+
 // GetIDs returns []int of all the ids
 func (t Torrents) GetIDs() []string {
 	ids := make([]string, 0, len(t))
@@ -244,6 +426,12 @@ func (t Torrents) GetIDs() []string {
 	return ids
 }
 
+// SetCoalescing enables or disables merging of concurrent identical calls
+// of an RPC method, e.g. "torrent-get", into one request to the daemon
+func (ac *TransmissionClient) SetCoalescing(method string, enabled bool) {
+	ac.apiclient.SetCoalescing(method, enabled)
+}
+
 // sortType keeps track of which sorting we are using
 var sortType = SortID // SortID is transmission's default
 
@@ -252,75 +440,60 @@ func (ac *TransmissionClient) SetSort(st Sorting) {
 	sortType = st
 }
 
-//New create new transmission torrent
-func New(url string, username string, password string) (*TransmissionClient, error) {
-	apiclient := NewClient(url, username, password)
-	client := &TransmissionClient{apiclient: apiclient}
+// New create new transmission torrent, checking that the daemon answers
+// with the probe, see WithProbe; the client is nil when it doesn't
+func New(url string, username string, password string, opts ...Option) (*TransmissionClient, error) {
+	client := NewLazy(url, username, password, opts...)
 
 	// test that we have a working client
-	cmd := Command{Method: "session-get"}
-	_, err := client.sendCommand(cmd)
-	if err != nil {
-		return client, err
+	if err := client.probeOnce(context.Background()); err != nil {
+		return nil, err
 	}
 
 	return client, nil
 
 }
 
-//GetTorrents get a list of torrents
+// MustNew is like New but panics when the daemon doesn't answer, for tests
+// and examples
+func MustNew(url string, username string, password string, opts ...Option) *TransmissionClient {
+	client, err := New(url, username, password, opts...)
+	if err != nil {
+		panic("transmission: " + err.Error())
+	}
+	return client
+}
+
+// NewLazy is like New but doesn't contact the daemon, which may not be
+// running yet; see WaitReady
+func NewLazy(url string, username string, password string, opts ...Option) *TransmissionClient {
+	apiclient := NewClient(url, username, password)
+	client := &TransmissionClient{apiclient: apiclient}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
+
+// GetTorrents get a list of torrents
 func (ac *TransmissionClient) GetTorrents() (Torrents, error) {
+	return ac.GetTorrentsContext(context.Background())
+}
+
+// GetTorrentsContext is like GetTorrents but binds the request to ctx
+func (ac *TransmissionClient) GetTorrentsContext(ctx context.Context) (Torrents, error) {
 	cmd := NewGetTorrentsCmd()
 
-	out, err := ac.ExecuteCommand(cmd)
+	out, err := ac.ExecuteCommandContext(ctx, cmd)
 	if err != nil {
 		return nil, err
 	}
 
 	torrents := out.Arguments.Torrents
+	torrents.validate()
 
-	// sorting
-	switch sortType {
-	case SortID:
-		return torrents, nil // already sorted by ID
-	case SortRevID:
-		torrents.SortID(true)
-	case SortName:
-		torrents.SortName(false)
-	case SortRevName:
-		torrents.SortName(true)
-	case SortAge:
-		torrents.SortAge(false)
-	case SortRevAge:
-		torrents.SortAge(true)
-	case SortSize:
-		torrents.SortSize(false)
-	case SortRevSize:
-		torrents.SortSize(true)
-	case SortProgress:
-		torrents.SortProgress(false)
-	case SortRevProgress:
-		torrents.SortProgress(true)
-	case SortDownSpeed:
-		torrents.SortDownSpeed(false)
-	case SortRevDownSpeed:
-		torrents.SortDownSpeed(true)
-	case SortUpSpeed:
-		torrents.SortUpSpeed(false)
-	case SortRevUpSpeed:
-		torrents.SortUpSpeed(true)
-	case SortDownloaded:
-		torrents.SortDownloaded(false)
-	case SortRevDownloaded:
-		torrents.SortDownloaded(true)
-	case SortUploaded:
-		torrents.SortUploaded(false)
-	case SortRevUploaded:
-		torrents.SortUploaded(true)
-	case SortRatio:
-		torrents.SortRatio(false)
-	case SortRevRatio:
-		torrents.SortRatio(true)
+	if sortType != SortID { // already sorted by ID
+		torrents.Sort(sortType.comparator())
 	}
 
 	return torrents, nil
@@ -328,15 +501,35 @@ func (ac *TransmissionClient) GetTorrents() (Torrents, error) {
 
 // GetTorrent takes an id and returns *Torrent
 func (ac *TransmissionClient) GetTorrent(id string) (*Torrent, error) {
+	return ac.GetTorrentContext(context.Background(), id)
+}
+
+// GetTorrentContext is like GetTorrent but binds the request to ctx
+func (ac *TransmissionClient) GetTorrentContext(ctx context.Context, id string) (*Torrent, error) {
+	if ac.torrentFlights != nil {
+		return ac.torrentFlights.do(ctx, id, func(ctx context.Context) (*Torrent, error) {
+			return ac.getTorrentFields(ctx, id, nil)
+		})
+	}
+	return ac.getTorrentFields(ctx, id, nil)
+}
+
+// getTorrentFields fetches one torrent with the given fields, or the
+// default ones if fields is nil
+func (ac *TransmissionClient) getTorrentFields(ctx context.Context, id string, fields []string) (*Torrent, error) {
 	cmd := NewGetTorrentsCmd()
 	cmd.Arguments.Ids = append(cmd.Arguments.Ids, id)
+	if fields != nil {
+		cmd.Arguments.Fields = fields
+	}
 
-	out, err := ac.ExecuteCommand(cmd)
+	out, err := ac.ExecuteCommandContext(ctx, cmd)
 	if err != nil {
 		return &Torrent{}, err
 	}
 
 	if len(out.Arguments.Torrents) > 0 {
+		out.Arguments.Torrents.validate()
 		return out.Arguments.Torrents[0], nil
 	}
 	return &Torrent{}, ErrNoTorrent
@@ -362,32 +555,42 @@ func (ac *TransmissionClient) DeleteTorrent(id string, withData bool) (string, e
 
 // GetStats returns "session-stats"
 func (ac *TransmissionClient) GetStats() (*Stats, error) {
+	return ac.GetStatsContext(context.Background())
+}
+
+// GetStatsContext is like GetStats but binds the request to ctx
+func (ac *TransmissionClient) GetStatsContext(ctx context.Context) (*Stats, error) {
 	cmd := &Command{
 		Method: "session-stats",
 	}
 
-	out, err := ac.ExecuteCommand(cmd)
+	out, err := ac.ExecuteCommandContext(ctx, cmd)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Stats{
+	stats := &Stats{
 		ActiveTorrentCount: out.Arguments.ActiveTorrentCount,
-		CumulativeStats:    out.Arguments.CumulativeStats,
-		CurrentStats:       out.Arguments.CurrentStats,
 		DownloadSpeed:      out.Arguments.DownloadSpeed,
 		PausedTorrentCount: out.Arguments.PausedTorrentCount,
 		TorrentCount:       out.Arguments.TorrentCount,
 		UploadSpeed:        out.Arguments.UploadSpeed,
-	}, nil
+	}
+	if out.Arguments.CumulativeStats != nil {
+		stats.CumulativeStats = *out.Arguments.CumulativeStats
+	}
+	if out.Arguments.CurrentStats != nil {
+		stats.CurrentStats = *out.Arguments.CurrentStats
+	}
+	return stats, nil
 }
 
-//StartTorrent start the torrent
+// StartTorrent start the torrent
 func (ac *TransmissionClient) StartTorrent(ids ...string) (string, error) {
 	return ac.sendSimpleCommand("torrent-start", ids...)
 }
 
-//StopTorrent start the torrent
+// StopTorrent start the torrent
 func (ac *TransmissionClient) StopTorrent(ids ...string) (string, error) {
 	return ac.sendSimpleCommand("torrent-stop", ids...)
 }
@@ -399,51 +602,77 @@ func (ac *TransmissionClient) VerifyTorrent(ids ...string) (string, error) {
 
 // StartAll starts all the torrents
 func (ac *TransmissionClient) StartAll() error {
-	cmd := Command{Method: "torrent-start"}
-	torrents, err := ac.GetTorrents()
-	if err != nil {
-		return err
-	}
-
-	cmd.Arguments.Ids = torrents.GetIDs()
-	if _, err := ac.sendCommand(cmd); err != nil {
-		return err
-	}
-
-	return nil
+	_, err := ac.StartAllContext(context.Background())
+	return err
 }
 
 // StopAll stops all torrents
 func (ac *TransmissionClient) StopAll() error {
-	cmd := Command{Method: "torrent-stop"}
-	torrents, err := ac.GetTorrents()
-	if err != nil {
-		return err
-	}
-
-	cmd.Arguments.Ids = torrents.GetIDs()
-	if _, err := ac.sendCommand(cmd); err != nil {
-		return err
-	}
-
-	return nil
+	_, err := ac.StopAllContext(context.Background())
+	return err
 }
 
 // VerifyAll verfies all torrents
 func (ac *TransmissionClient) VerifyAll() error {
-	cmd := Command{Method: "torrent-verify"}
+	_, err := ac.VerifyAllContext(context.Background())
+	return err
+}
+
+// StartAllContext is like StartAll but binds the requests to ctx and tells
+// which torrents were started
+func (ac *TransmissionClient) StartAllContext(ctx context.Context) (*BulkResult, error) {
+	return ac.allAction(ctx, "torrent-start")
+}
+
+// StopAllContext is like StopAll but binds the requests to ctx and tells
+// which torrents were stopped
+func (ac *TransmissionClient) StopAllContext(ctx context.Context) (*BulkResult, error) {
+	return ac.allAction(ctx, "torrent-stop")
+}
+
+// VerifyAllContext is like VerifyAll but binds the requests to ctx and
+// tells which torrents were verified
+func (ac *TransmissionClient) VerifyAllContext(ctx context.Context) (*BulkResult, error) {
+	return ac.allAction(ctx, "torrent-verify")
+}
 
-	torrents, err := ac.GetTorrents()
+// allActionParallelism is the number of requests allAction sends at once
+const allActionParallelism = 4
+
+// allAction sends method for all the torrents, DefaultChunkSize at a time
+// so the requests stay small on large daemons. The error is the Err of
+// the result, or the failure to list the torrents.
+func (ac *TransmissionClient) allAction(ctx context.Context, method string) (*BulkResult, error) {
+	var ids []string
+	err := ac.ForEachTorrent(ctx, []string{"hashString"}, func(t *Torrent) error {
+		ids = append(ids, t.InfoHash)
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return ac.chunkedAction(ctx, method, ids)
+}
 
-	cmd.Arguments.Ids = torrents.GetIDs()
-	if _, err := ac.sendCommand(cmd); err != nil {
-		return err
+// chunkedAction sends method for the torrents of ids, DefaultChunkSize at
+// a time and allActionParallelism requests at once
+func (ac *TransmissionClient) chunkedAction(ctx context.Context, method string, ids []string) (*BulkResult, error) {
+	result := &BulkResult{}
+	ops := NewOperations(ctx, allActionParallelism)
+	ops.KeepGoing = true
+	for i := 0; i < len(ids); i += DefaultChunkSize {
+		chunk := ids[i:min(i+DefaultChunkSize, len(ids))]
+		ops.Go(fmt.Sprintf("%s of torrents %d-%d", method, i+1, i+len(chunk)), func(ctx context.Context) error {
+			if err := ac.torrentAction(ctx, method, chunk); err != nil {
+				result.fail(err, chunk...)
+				return err
+			}
+			result.succeed(chunk...)
+			return nil
+		})
 	}
-
-	return nil
+	ops.Wait()
+	return result, result.Err()
 }
 
 func NewGetTorrentsCmd() *Command {
@@ -451,10 +680,12 @@ func NewGetTorrentsCmd() *Command {
 
 	cmd.Method = "torrent-get"
 	cmd.Arguments.Fields = []string{"id", "name", "hashString", "status", "addedDate", "startDate", "doneDate",
-		"leftUntilDone", "sizeWhenDone", "haveValid", "haveUnchecked", "isFinished", "percentDone", "eta",
+		"leftUntilDone", "sizeWhenDone", "haveValid", "haveUnchecked", "isFinished", "percentDone", "percentComplete", "eta", "etaIdle",
 		"rateDownload", "rateUpload", "downloadDir", "downloadedEver", "uploadRatio", "uploadedEver",
-		"seedRatioMode", "error", "errorString", "files", "peers", "trackers", "trackerStats", "totalSize",
-		"secondsDownloading", "secondsSeeding"}
+		"seedRatioMode", "seedRatioLimit", "seedIdleMode", "seedIdleLimit", "error", "errorString", "files", "wanted", "peers", "trackers", "trackerStats", "totalSize",
+		"secondsDownloading", "secondsSeeding", "queuePosition", "bandwidthPriority",
+		"honorsSessionLimits", "recheckProgress", "metadataPercentComplete",
+		"isStalled", "isPrivate", "desiredAvailable", "activityDate", "labels"}
 	// cmd.Arguments.Fields = []string{
 	// 	"activityDate",
 	// 	"addedDate",
@@ -548,15 +779,18 @@ func NewAddCmdByFilename(filename string) *Command {
 	return cmd
 }
 
+// NewAddCmdByFile returns a torrent-add command sending the .torrent file;
+// a file that can't be one fails with ErrNotATorrentFile before being read
+// whole
 func NewAddCmdByFile(file string) (*Command, error) {
 	cmd := NewAddCmd()
 
-	fileData, err := ioutil.ReadFile(file)
+	metainfo, err := encodeFile(file)
 	if err != nil {
 		return nil, err
 	}
 
-	cmd.Arguments.MetaInfo = base64.StdEncoding.EncodeToString(fileData)
+	cmd.Arguments.MetaInfo = metainfo
 
 	return cmd, nil
 }
@@ -573,6 +807,16 @@ func (cmd *Command) SetDownloadDir(dir string) {
 	cmd.Arguments.DownloadDir = dir
 }
 
+// SetPaused sets whether the torrent to add starts paused
+func (cmd *Command) SetPaused(paused bool) {
+	cmd.Arguments.Paused = &paused
+}
+
+// SetBandwidthPriority sets the bandwidth priority of the torrent to add
+func (cmd *Command) SetBandwidthPriority(p Priority) {
+	cmd.Arguments.BandwidthPriority = &p
+}
+
 func newDelCmd(id string, removeFile bool) *Command {
 	cmd := &Command{}
 	cmd.Method = "torrent-remove"
@@ -582,13 +826,18 @@ func newDelCmd(id string, removeFile bool) *Command {
 }
 
 func (ac *TransmissionClient) ExecuteCommand(cmd *Command) (*Command, error) {
+	return ac.ExecuteCommandContext(context.Background(), cmd)
+}
+
+// ExecuteCommandContext is like ExecuteCommand but binds the request to ctx
+func (ac *TransmissionClient) ExecuteCommandContext(ctx context.Context, cmd *Command) (*Command, error) {
 	out := &Command{}
 
 	body, err := json.Marshal(cmd)
 	if err != nil {
 		return out, err
 	}
-	output, err := ac.apiclient.Post(string(body))
+	output, err := ac.post(ctx, body)
 	if err != nil {
 		return out, err
 	}
@@ -602,23 +851,101 @@ func (ac *TransmissionClient) ExecuteCommand(cmd *Command) (*Command, error) {
 }
 
 func (ac *TransmissionClient) ExecuteAddCommand(addCmd *Command) (TorrentAdded, error) {
-	outCmd, err := ac.ExecuteCommand(addCmd)
+	return ac.ExecuteAddCommandContext(context.Background(), addCmd)
+}
+
+// ExecuteAddCommandContext is like ExecuteAddCommand but binds the requests
+// to ctx. The client's default download dir is used when addCmd has none,
+// and a relative download dir is resolved with ResolveDownloadDir. With
+// WithTorrentCache, http urls are fetched through the cache.
+func (ac *TransmissionClient) ExecuteAddCommandContext(ctx context.Context, addCmd *Command) (TorrentAdded, error) {
+	added, _, err := ac.executeAdd(ctx, addCmd)
+	return added, err
+}
+
+// executeAdd is ExecuteAddCommandContext also returning the daemon's
+// result, which is not "success" when nothing was added
+func (ac *TransmissionClient) executeAdd(ctx context.Context, addCmd *Command) (TorrentAdded, string, error) {
+	cmd := *addCmd
+	if err := ac.fetchCached(ctx, &cmd); err != nil {
+		return TorrentAdded{}, "", err
+	}
+	replaceTrackers, err := ac.rewriteAdd(&cmd)
 	if err != nil {
-		return TorrentAdded{}, err
+		return TorrentAdded{}, "", err
 	}
-	if outCmd.Arguments.TorrentDuplicate.HashString != "" {
-		return outCmd.Arguments.TorrentDuplicate, nil
+	if cmd.Arguments.DownloadDir == "" {
+		cmd.Arguments.DownloadDir = ac.downloadDir
 	}
-	return outCmd.Arguments.TorrentAdded, nil
+	if cmd.Arguments.DownloadDir != "" {
+		dir, err := ac.ResolveDownloadDir(ctx, cmd.Arguments.DownloadDir)
+		if err != nil {
+			return TorrentAdded{}, "", err
+		}
+		cmd.Arguments.DownloadDir = dir
+	}
+
+	outCmd, err := ac.ExecuteCommandContext(ctx, &cmd)
+	if err != nil {
+		return TorrentAdded{}, "", err
+	}
+	if d := outCmd.Arguments.TorrentDuplicate; d != nil && d.HashString != "" {
+		return *d, outCmd.Result, nil
+	}
+	if a := outCmd.Arguments.TorrentAdded; a != nil {
+		if replaceTrackers {
+			if err := ac.replaceTrackers(ctx, a.HashString); err != nil {
+				return *a, outCmd.Result, err
+			}
+		}
+		return *a, outCmd.Result, nil
+	}
+	return TorrentAdded{}, outCmd.Result, nil
 }
 
+// encodeFile returns the .torrent file in base64, checking its size and
+// its first bytes, a bencoded dictionary, before encoding it chunk by chunk
 func encodeFile(file string) (string, error) {
-	fileData, err := ioutil.ReadFile(file)
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
 	if err != nil {
 		return "", err
 	}
+	if fi.Mode().IsRegular() && fi.Size() > MaxTorrentFileSize {
+		return "", fmt.Errorf("%s: %w, %d bytes exceed %d", file, ErrNotATorrentFile, fi.Size(), MaxTorrentFileSize)
+	}
+
+	r := bufio.NewReader(io.LimitReader(f, MaxTorrentFileSize+1)) // for pipes and growing files
+	head, err := r.Peek(2)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	// a dictionary whose first key is a string, its length first
+	if len(head) < 2 || head[0] != 'd' || head[1] < '0' || head[1] > '9' {
+		return "", fmt.Errorf("%s: %w, no bencoded dictionary", file, ErrNotATorrentFile)
+	}
 
-	return base64.StdEncoding.EncodeToString(fileData), nil
+	var b strings.Builder
+	if fi.Mode().IsRegular() {
+		b.Grow(base64.StdEncoding.EncodedLen(int(fi.Size())))
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, &b)
+	n, err := io.Copy(enc, r)
+	if err != nil {
+		return "", err
+	}
+	if n > MaxTorrentFileSize {
+		return "", fmt.Errorf("%s: %w, more than %d bytes", file, ErrNotATorrentFile, MaxTorrentFileSize)
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
 }
 
 // Version returns transmission's version
@@ -642,7 +969,7 @@ func (ac *TransmissionClient) sendCommand(cmd Command) (response Command, err er
 	if err != nil {
 		return
 	}
-	output, err = ac.apiclient.Post(string(body))
+	output, err = ac.post(context.Background(), body)
 	if err != nil {
 		return
 	}
@@ -653,3 +980,50 @@ func (ac *TransmissionClient) sendCommand(cmd Command) (response Command, err er
 	}
 	return response, nil
 }
+
+// rpc sends method with args marshalled as is and decodes the response
+// arguments into out, unless it is nil; a result other than "success" is
+// returned as an error
+func (ac *TransmissionClient) rpc(ctx context.Context, method string, args, out interface{}) error {
+	body, err := json.Marshal(struct {
+		Method    string      `json:"method"`
+		Arguments interface{} `json:"arguments,omitempty"`
+	}{method, args})
+	if err != nil {
+		return err
+	}
+	output, err := ac.post(ctx, body)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Result    string          `json:"result"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(output, &resp); err != nil {
+		return err
+	}
+	if resp.Result != "success" {
+		return fmt.Errorf("%s: %s", method, resp.Result)
+	}
+	if out != nil && len(resp.Arguments) > 0 {
+		return json.Unmarshal(resp.Arguments, out)
+	}
+	return nil
+}
+
+// Call sends an RPC method this package doesn't wrap, see rpc for args and
+// out; it goes through dry-run and auditing like the other methods
+func (ac *TransmissionClient) Call(ctx context.Context, method string, args, out interface{}) error {
+	return ac.rpc(ctx, method, args, out)
+}
+
+// torrentAction sends one of the torrent-start/stop/verify/reannounce family
+// of methods for ids; it does nothing for no ids, which the daemon would
+// read as all torrents
+func (ac *TransmissionClient) torrentAction(ctx context.Context, method string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return ac.rpc(ctx, method, map[string][]string{"ids": ids}, nil)
+}
@@ -0,0 +1,851 @@
+package transmissiontest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+	"github.com/unix2dos/go-transmission/v2/metainfo"
+)
+
+// FakeTorrent scripts a torrent of a FakeClient
+type FakeTorrent struct {
+	Name         string
+	Size         uint64  // bytes, 1 GiB if 0
+	Done         float64 // progress at the start, 0...1
+	DownloadRate uint64  // bytes/s while downloading
+	UploadRate   uint64  // bytes/s while started
+	Labels       []string
+	Trackers     []string // announce urls, one tier each
+	Private      bool
+	Stopped      bool
+	ErrorString  string // tracker error reported from the start, cleared by a start
+	DownloadDir  string // the session's if empty
+}
+
+// defaultFakeSize is the size of scripted torrents without one and of
+// added magnets
+const defaultFakeSize = 1 << 30
+
+// fakeCheckDuration is how long a verification takes
+const fakeCheckDuration = 5 * time.Second
+
+// FakeClient is a TransmissionClient talking to a daemon simulated in
+// process: scripted torrents download and seed at their rates as time
+// passes, torrents can be added, started, stopped, verified, changed and
+// removed. It lets UIs and demos run against realistic, changing data
+// without a daemon.
+//
+//	fake := transmissiontest.NewFakeClient([]transmissiontest.FakeTorrent{
+//		{Name: "Debian netinst", Size: 600 << 20, DownloadRate: 2 << 20, UploadRate: 100 << 10},
+//		{Name: "Arch Linux", Done: 1, UploadRate: 50 << 10},
+//	})
+//	fake.SetSpeed(10) // ten simulated seconds per second
+//
+// Peers and pieces are not simulated; methods the fake doesn't know fail
+// as with an older daemon.
+type FakeClient struct {
+	*transmission.TransmissionClient
+	daemon *fakeDaemon
+}
+
+var _ transmission.TorrentService = (*FakeClient)(nil)
+
+// NewFakeClient returns a client for a simulated daemon holding torrents,
+// the first one having id 1
+func NewFakeClient(torrents []FakeTorrent, opts ...transmission.Option) *FakeClient {
+	now := time.Now()
+	d := &fakeDaemon{
+		realBase: now,
+		simBase:  now,
+		speed:    1,
+		started:  now,
+		nextID:   1,
+		session: map[string]interface{}{
+			"version":             "4.0.5 (fake)",
+			"rpc-version":         17,
+			"rpc-version-minimum": 14,
+			"config-dir":          "/config",
+
+			"download-dir":                 "/downloads",
+			"incomplete-dir":               "/downloads/incomplete",
+			"incomplete-dir-enabled":       false,
+			"rename-partial-files":         true,
+			"start-added-torrents":         true,
+			"trash-original-torrent-files": false,
+			"cache-size-mb":                4,
+
+			"speed-limit-down":         100,
+			"speed-limit-down-enabled": false,
+			"speed-limit-up":           100,
+			"speed-limit-up-enabled":   false,
+
+			"peer-port":                 51413,
+			"peer-port-random-on-start": false,
+			"port-forwarding-enabled":   true,
+			"peer-limit-global":         200,
+			"peer-limit-per-torrent":    50,
+			"encryption":                "preferred",
+			"dht-enabled":               true,
+			"pex-enabled":               true,
+			"lpd-enabled":               false,
+			"utp-enabled":               true,
+			"blocklist-enabled":         false,
+			"blocklist-url":             "http://www.example.com/blocklist",
+			"blocklist-size":            0,
+
+			"download-queue-enabled": true,
+			"download-queue-size":    5,
+			"seed-queue-enabled":     false,
+			"seed-queue-size":        10,
+			"queue-stalled-enabled":  true,
+			"queue-stalled-minutes":  30,
+
+			"script-torrent-done-enabled":  false,
+			"script-torrent-done-filename": "",
+
+			"seedRatioLimit":             2.0,
+			"seedRatioLimited":           false,
+			"idle-seeding-limit":         30,
+			"idle-seeding-limit-enabled": false,
+			"default-trackers":           "",
+		},
+	}
+	for _, ft := range torrents {
+		d.addScripted(ft, now)
+	}
+	opts = append(opts, transmission.WithHTTPClient(&http.Client{Transport: d}))
+	client := transmission.NewLazy("http://fake.invalid/transmission/rpc", "", "", opts...)
+	return &FakeClient{TransmissionClient: client, daemon: d}
+}
+
+// Add adds a scripted torrent and returns its id
+func (f *FakeClient) Add(ft FakeTorrent) int {
+	f.daemon.mu.Lock()
+	defer f.daemon.mu.Unlock()
+	return f.daemon.addScripted(ft, f.daemon.now()).id
+}
+
+// SetSpeed makes the simulated time run factor times faster than the real
+// time, e.g. so downloads finish during a demo; 0 freezes it
+func (f *FakeClient) SetSpeed(factor float64) {
+	d := f.daemon
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.simBase = d.now()
+	d.realBase = time.Now()
+	d.speed = factor
+}
+
+// Advance moves the simulated time forward, for deterministic tests run
+// with SetSpeed(0)
+func (f *FakeClient) Advance(dt time.Duration) {
+	d := f.daemon
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.simBase = d.simBase.Add(dt)
+}
+
+// Now returns the simulated time
+func (f *FakeClient) Now() time.Time {
+	f.daemon.mu.Lock()
+	defer f.daemon.mu.Unlock()
+	return f.daemon.now()
+}
+
+// fakeDaemon is an http.RoundTripper answering RPC requests from the
+// simulated state
+type fakeDaemon struct {
+	mu       sync.Mutex
+	realBase time.Time // real time when the speed last changed
+	simBase  time.Time // simulated time at realBase
+	speed    float64
+	started  time.Time // simulated start of the daemon
+	nextID   int
+	torrents []*fakeTorrent
+	removed  []int // ids removed since the last recently-active request
+	session  map[string]interface{}
+}
+
+type fakeTorrent struct {
+	id          int
+	hash        string
+	name        string
+	files       []metainfo.FileInfo
+	size        float64
+	have        float64
+	downloaded  float64
+	uploaded    float64
+	dlRate      float64
+	ulRate      float64
+	labels      []string
+	trackers    []string
+	private     bool
+	status      transmission.Status
+	errorString string
+	downloadDir string
+	priority    transmission.Priority
+	honors      bool
+	limits      fakeLimits
+	added       time.Time
+	startDate   time.Time
+	doneDate    time.Time
+	activity    time.Time
+	checkUntil  time.Time           // end of the running verification
+	afterCheck  transmission.Status // status once verified
+	secondsDL   float64
+	secondsSeed float64
+	last        time.Time // simulated time the state was computed for
+}
+
+// now returns the simulated time, d.mu held
+func (d *fakeDaemon) now() time.Time {
+	elapsed := float64(time.Since(d.realBase)) * d.speed
+	return d.simBase.Add(time.Duration(elapsed))
+}
+
+// addScripted adds ft, d.mu held unless d is not shared yet
+func (d *fakeDaemon) addScripted(ft FakeTorrent, now time.Time) *fakeTorrent {
+	size := ft.Size
+	if size == 0 {
+		size = defaultFakeSize
+	}
+	t := d.newTorrent(ft.Name, []metainfo.FileInfo{{Length: int64(size), Path: []string{ft.Name}}}, now)
+	h := sha1.Sum([]byte(ft.Name + "\x00" + strconv.Itoa(t.id)))
+	t.hash = hex.EncodeToString(h[:])
+	t.have = min(max(ft.Done, 0), 1) * t.size
+	t.dlRate, t.ulRate = float64(ft.DownloadRate), float64(ft.UploadRate)
+	t.labels = slices.Clone(ft.Labels)
+	t.trackers = slices.Clone(ft.Trackers)
+	t.private = ft.Private
+	if ft.DownloadDir != "" {
+		t.downloadDir = ft.DownloadDir
+	}
+	if t.have >= t.size {
+		t.doneDate = now
+	}
+	if !ft.Stopped {
+		t.start(now)
+	}
+	t.errorString = ft.ErrorString
+	return t
+}
+
+func (d *fakeDaemon) newTorrent(name string, files []metainfo.FileInfo, now time.Time) *fakeTorrent {
+	dir, _ := d.session["download-dir"].(string)
+	t := &fakeTorrent{
+		id:          d.nextID,
+		name:        name,
+		files:       files,
+		status:      transmission.TrStopped,
+		downloadDir: dir,
+		priority:    transmission.PriorityNormal,
+		honors:      true,
+		added:       now,
+		last:        now,
+	}
+	for _, f := range files {
+		t.size += float64(f.Length)
+	}
+	d.nextID++
+	d.torrents = append(d.torrents, t)
+	return t
+}
+
+func (t *fakeTorrent) start(now time.Time) {
+	t.advance(now)
+	if t.status != transmission.TrStopped {
+		return
+	}
+	t.errorString = ""
+	t.startDate = now
+	t.status = transmission.TrDownloading
+	if t.have >= t.size {
+		t.status = transmission.TrSeeding
+	}
+}
+
+func (t *fakeTorrent) stop(now time.Time) {
+	t.advance(now)
+	t.status = transmission.TrStopped
+}
+
+func (t *fakeTorrent) verify(now time.Time) {
+	t.advance(now)
+	if t.status != transmission.TrChecking {
+		t.afterCheck = t.status
+	}
+	t.status = transmission.TrChecking
+	t.checkUntil = now.Add(fakeCheckDuration)
+}
+
+// advance brings the torrent to the simulated time now
+func (t *fakeTorrent) advance(now time.Time) {
+	for now.After(t.last) {
+		dt := now.Sub(t.last).Seconds()
+		switch t.status {
+		case transmission.TrChecking:
+			if now.Before(t.checkUntil) {
+				t.last = now
+				break
+			}
+			t.last = t.checkUntil
+			t.status = t.afterCheck
+			if t.status != transmission.TrStopped {
+				t.status = transmission.TrDownloading
+				if t.have >= t.size {
+					t.status = transmission.TrSeeding
+				}
+			}
+		case transmission.TrDownloading:
+			if t.dlRate <= 0 {
+				t.secondsDL += dt
+				t.last = now
+				break
+			}
+			need := (t.size - t.have) / t.dlRate
+			step := min(dt, need)
+			t.have += step * t.dlRate
+			t.downloaded += step * t.dlRate
+			t.uploaded += step * t.ulRate
+			t.secondsDL += step
+			t.last = t.last.Add(time.Duration(step * float64(time.Second)))
+			t.activity = t.last
+			if step == need {
+				t.have = t.size
+				t.doneDate = t.last
+				t.status = transmission.TrSeeding
+			}
+		case transmission.TrSeeding:
+			t.uploaded += dt * t.ulRate
+			t.secondsSeed += dt
+			if t.ulRate > 0 {
+				t.activity = now
+			}
+			t.last = now
+		default:
+			t.last = now
+		}
+	}
+}
+
+// fields returns the torrent in the RPC format
+func (t *fakeTorrent) fields(now time.Time) map[string]interface{} {
+	left := t.size - t.have
+	eta := -1.0
+	rateDL, rateUL := 0.0, 0.0
+	switch t.status {
+	case transmission.TrDownloading:
+		rateDL, rateUL = t.dlRate, t.ulRate
+		if t.dlRate > 0 {
+			eta = left / t.dlRate
+		} else {
+			eta = -2
+		}
+	case transmission.TrSeeding:
+		rateUL = t.ulRate
+	}
+	ratio := -1.0
+	if t.downloaded > 0 {
+		ratio = t.uploaded / t.downloaded
+	} else if t.have > 0 {
+		ratio = t.uploaded / t.have
+	}
+	errCode := 0
+	if t.errorString != "" {
+		errCode = 2
+	}
+	recheck := 0.0
+	if t.status == transmission.TrChecking {
+		recheck = 1 - t.checkUntil.Sub(now).Seconds()/fakeCheckDuration.Seconds()
+	}
+
+	files := make([]map[string]interface{}, len(t.files))
+	wanted := make([]bool, len(t.files))
+	remaining := t.have
+	for i, f := range t.files {
+		completed := min(remaining, float64(f.Length))
+		remaining -= completed
+		wanted[i] = true
+		files[i] = map[string]interface{}{
+			"name":           path.Join(f.Path...),
+			"length":         f.Length,
+			"bytesCompleted": int64(completed),
+		}
+	}
+	trackers := make([]map[string]interface{}, len(t.trackers))
+	trackerStats := make([]map[string]interface{}, len(t.trackers))
+	for i, announce := range t.trackers {
+		host := announce
+		if u, err := url.Parse(announce); err == nil {
+			host = u.Host
+		}
+		trackers[i] = map[string]interface{}{"id": i, "announce": announce, "scrape": "", "tier": i}
+		trackerStats[i] = map[string]interface{}{
+			"id":                    i,
+			"announce":              announce,
+			"host":                  host,
+			"tier":                  i,
+			"hasAnnounced":          t.status != transmission.TrStopped,
+			"lastAnnounceSucceeded": t.errorString == "",
+			"lastAnnounceResult":    firstNonEmpty(t.errorString, "Success"),
+			"seederCount":           10,
+			"leecherCount":          5,
+		}
+	}
+
+	return map[string]interface{}{
+		"id":                      t.id,
+		"name":                    t.name,
+		"hashString":              t.hash,
+		"status":                  t.status,
+		"addedDate":               unix(t.added),
+		"startDate":               unix(t.startDate),
+		"doneDate":                unix(t.doneDate),
+		"activityDate":            unix(t.activity),
+		"leftUntilDone":           int64(left),
+		"sizeWhenDone":            int64(t.size),
+		"totalSize":               int64(t.size),
+		"desiredAvailable":        int64(left),
+		"haveValid":               int64(t.have),
+		"haveUnchecked":           0,
+		"percentDone":             t.have / t.size,
+		"percentComplete":         t.have / t.size,
+		"isFinished":              false,
+		"isStalled":               t.status == transmission.TrDownloading && t.dlRate == 0,
+		"isPrivate":               t.private,
+		"eta":                     int64(eta),
+		"etaIdle":                 -1, // the simulation doesn't enforce the idle limit
+		"rateDownload":            int64(rateDL),
+		"rateUpload":              int64(rateUL),
+		"downloadDir":             t.downloadDir,
+		"downloadedEver":          int64(t.downloaded),
+		"uploadedEver":            int64(t.uploaded),
+		"uploadRatio":             ratio,
+		"seedRatioMode":           t.limits.SeedRatioMode,
+		"seedRatioLimit":          t.limits.SeedRatioLimit,
+		"seedIdleMode":            t.limits.SeedIdleMode,
+		"seedIdleLimit":           t.limits.SeedIdleLimit,
+		"downloadLimit":           t.limits.DownloadLimit,
+		"downloadLimited":         t.limits.DownloadLimited,
+		"uploadLimit":             t.limits.UploadLimit,
+		"uploadLimited":           t.limits.UploadLimited,
+		"error":                   errCode,
+		"errorString":             t.errorString,
+		"files":                   files,
+		"wanted":                  wanted,
+		"peers":                   []interface{}{},
+		"trackers":                trackers,
+		"trackerStats":            trackerStats,
+		"secondsDownloading":      int64(t.secondsDL),
+		"secondsSeeding":          int64(t.secondsSeed),
+		"queuePosition":           t.id - 1,
+		"bandwidthPriority":       t.priority,
+		"honorsSessionLimits":     t.honors,
+		"recheckProgress":         recheck,
+		"metadataPercentComplete": 1,
+		"labels":                  t.labels,
+	}
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// firstNonEmpty returns the first of its arguments that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (d *fakeDaemon) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	if len(body) == 0 {
+		return response(req, http.StatusConflict, nil), nil
+	}
+	var rpc struct {
+		Method    string          `json:"method"`
+		Arguments json.RawMessage `json:"arguments"`
+		Tag       json.RawMessage `json:"tag,omitempty"`
+	}
+	if err := json.Unmarshal(body, &rpc); err != nil {
+		return response(req, http.StatusBadRequest, []byte(err.Error())), nil
+	}
+	if len(rpc.Arguments) == 0 {
+		rpc.Arguments = []byte("{}")
+	}
+
+	d.mu.Lock()
+	now := d.now()
+	for _, t := range d.torrents {
+		t.advance(now)
+	}
+	args, err := d.handle(rpc.Method, rpc.Arguments, now)
+	d.mu.Unlock()
+
+	res := struct {
+		Result    string          `json:"result"`
+		Arguments interface{}     `json:"arguments"`
+		Tag       json.RawMessage `json:"tag,omitempty"`
+	}{"success", args, rpc.Tag}
+	if err != nil {
+		res.Result = err.Error()
+	}
+	if res.Arguments == nil {
+		res.Arguments = struct{}{}
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return response(req, http.StatusOK, b), nil
+}
+
+// handle runs one method, d.mu held
+func (d *fakeDaemon) handle(method string, raw json.RawMessage, now time.Time) (interface{}, error) {
+	var args struct {
+		IDs      json.RawMessage `json:"ids"`
+		Fields   []string        `json:"fields"`
+		Location string          `json:"location"`
+		Path     string          `json:"path"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	switch method {
+	case "session-get":
+		return d.session, nil
+	case "session-set":
+		var set map[string]interface{}
+		if err := json.Unmarshal(raw, &set); err != nil {
+			return nil, err
+		}
+		for k, v := range set {
+			d.session[k] = v
+		}
+		return nil, nil
+	case "session-stats":
+		return d.stats(now), nil
+	case "free-space":
+		return map[string]interface{}{"path": args.Path, "size-bytes": int64(1) << 40, "total_size": int64(2) << 40}, nil
+	case "port-test":
+		return map[string]interface{}{"port-is-open": true}, nil // a 4.0 daemon, testing IPv4 only
+	case "torrent-get":
+		return d.get(args.IDs, args.Fields, now), nil
+	case "torrent-add":
+		return d.add(raw, now)
+	}
+
+	torrents, err := d.selectTorrents(args.IDs)
+	if err != nil {
+		return nil, err
+	}
+	switch method {
+	case "torrent-start", "torrent-start-now":
+		for _, t := range torrents {
+			t.start(now)
+		}
+	case "torrent-stop":
+		for _, t := range torrents {
+			t.stop(now)
+		}
+	case "torrent-verify":
+		for _, t := range torrents {
+			t.verify(now)
+		}
+	case "torrent-reannounce", "queue-move-top", "queue-move-up", "queue-move-down", "queue-move-bottom":
+	case "torrent-remove":
+		d.torrents = slices.DeleteFunc(d.torrents, func(t *fakeTorrent) bool {
+			if slices.Contains(torrents, t) {
+				d.removed = append(d.removed, t.id)
+				return true
+			}
+			return false
+		})
+	case "torrent-set-location":
+		for _, t := range torrents {
+			t.downloadDir = args.Location
+		}
+	case "torrent-set":
+		return nil, d.set(torrents, raw)
+	default:
+		return nil, fmt.Errorf("method name not recognized")
+	}
+	return nil, nil
+}
+
+// selectTorrents returns the torrents designated by ids: all of them when
+// ids is absent or "recently-active", else the ones matching an id or hash.
+// As with the daemon, numbers are ids and strings are hashes, "1" matches
+// nothing.
+func (d *fakeDaemon) selectTorrents(raw json.RawMessage) ([]*fakeTorrent, error) {
+	if len(raw) == 0 || string(raw) == `"recently-active"` {
+		return slices.Clone(d.torrents), nil
+	}
+	var ids []interface{}
+	if err := json.Unmarshal(raw, &ids); err != nil {
+		var one float64
+		if json.Unmarshal(raw, &one) != nil {
+			return nil, fmt.Errorf("invalid ids")
+		}
+		ids = []interface{}{one}
+	}
+	var selected []*fakeTorrent
+	for _, t := range d.torrents {
+		for _, id := range ids {
+			match := false
+			switch id := id.(type) {
+			case float64:
+				match = int(id) == t.id
+			case string:
+				match = strings.EqualFold(id, t.hash)
+			}
+			if match {
+				selected = append(selected, t)
+				break
+			}
+		}
+	}
+	return selected, nil
+}
+
+func (d *fakeDaemon) get(ids json.RawMessage, fields []string, now time.Time) interface{} {
+	torrents, err := d.selectTorrents(ids)
+	if err != nil {
+		torrents = nil
+	}
+	out := make([]map[string]interface{}, 0, len(torrents))
+	for _, t := range torrents {
+		all := t.fields(now)
+		m := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				m[f] = v
+			}
+		}
+		out = append(out, m)
+	}
+	res := map[string]interface{}{"torrents": out}
+	if string(ids) == `"recently-active"` {
+		res["removed"] = d.removed
+		d.removed = nil
+	}
+	return res
+}
+
+func (d *fakeDaemon) add(raw json.RawMessage, now time.Time) (interface{}, error) {
+	var args struct {
+		Filename    string   `json:"filename"`
+		MetaInfo    string   `json:"metainfo"`
+		DownloadDir string   `json:"download-dir"`
+		Paused      bool     `json:"paused"`
+		Labels      []string `json:"labels"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	name, hash := "", ""
+	var files []metainfo.FileInfo
+	var trackers []string
+	private := false
+	switch {
+	case args.MetaInfo != "":
+		b, err := base64.StdEncoding.DecodeString(args.MetaInfo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid or corrupt torrent file")
+		}
+		mi, err := metainfo.Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid or corrupt torrent file")
+		}
+		name, hash, files, private = mi.Info.Name, mi.HashString(), mi.Info.FileList(), mi.Info.Private
+		for _, tier := range mi.AnnounceList {
+			trackers = append(trackers, tier...)
+		}
+		if len(trackers) == 0 && mi.Announce != "" {
+			trackers = []string{mi.Announce}
+		}
+	case strings.HasPrefix(args.Filename, "magnet:"):
+		u, err := url.Parse(args.Filename)
+		if err != nil {
+			return nil, fmt.Errorf("invalid or corrupt torrent file")
+		}
+		q := u.Query()
+		hash = strings.ToLower(strings.TrimPrefix(q.Get("xt"), "urn:btih:"))
+		name = firstNonEmpty(q.Get("dn"), hash)
+		trackers = q["tr"]
+	case args.Filename != "":
+		name = strings.TrimSuffix(path.Base(args.Filename), ".torrent")
+		h := sha1.Sum([]byte(args.Filename))
+		hash = hex.EncodeToString(h[:])
+	default:
+		return nil, fmt.Errorf("no filename or metainfo specified")
+	}
+	for _, t := range d.torrents {
+		if t.hash == hash {
+			return map[string]interface{}{"torrent-duplicate": transmission.TorrentAdded{HashString: t.hash, ID: t.id, Name: t.name}}, nil
+		}
+	}
+	if files == nil {
+		files = []metainfo.FileInfo{{Length: defaultFakeSize, Path: []string{name}}}
+	}
+	t := d.newTorrent(name, files, now)
+	t.hash = hash
+	t.trackers = trackers
+	t.private = private
+	t.labels = args.Labels
+	t.dlRate, t.ulRate = 1<<20, 64<<10
+	if args.DownloadDir != "" {
+		t.downloadDir = args.DownloadDir
+	}
+	if !args.Paused {
+		t.start(now)
+	}
+	return map[string]interface{}{"torrent-added": transmission.TorrentAdded{HashString: t.hash, ID: t.id, Name: t.name}}, nil
+}
+
+func (d *fakeDaemon) set(torrents []*fakeTorrent, raw json.RawMessage) error {
+	var args struct {
+		Labels              *[]string               `json:"labels"`
+		BandwidthPriority   *transmission.Priority  `json:"bandwidthPriority"`
+		HonorsSessionLimits *bool                   `json:"honorsSessionLimits"`
+		TrackerAdd          []string                `json:"trackerAdd"`
+		TrackerRemove       []int                   `json:"trackerRemove"`
+		TrackerList         *string                 `json:"trackerList"`
+		TrackerReplace      []interface{}           `json:"trackerReplace"` // id and url pairs
+		Location            *string                 `json:"location"`
+		DownloadLimit       *int                    `json:"downloadLimit"`
+		DownloadLimited     *bool                   `json:"downloadLimited"`
+		UploadLimit         *int                    `json:"uploadLimit"`
+		UploadLimited       *bool                   `json:"uploadLimited"`
+		SeedRatioLimit      *float64                `json:"seedRatioLimit"`
+		SeedRatioMode       *transmission.LimitMode `json:"seedRatioMode"`
+		SeedIdleLimit       *int                    `json:"seedIdleLimit"`
+		SeedIdleMode        *transmission.LimitMode `json:"seedIdleMode"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return err
+	}
+	for _, t := range torrents {
+		setIf(&t.limits.DownloadLimit, args.DownloadLimit)
+		setIf(&t.limits.DownloadLimited, args.DownloadLimited)
+		setIf(&t.limits.UploadLimit, args.UploadLimit)
+		setIf(&t.limits.UploadLimited, args.UploadLimited)
+		setIf(&t.limits.SeedRatioLimit, args.SeedRatioLimit)
+		setIf(&t.limits.SeedRatioMode, args.SeedRatioMode)
+		setIf(&t.limits.SeedIdleLimit, args.SeedIdleLimit)
+		setIf(&t.limits.SeedIdleMode, args.SeedIdleMode)
+		if args.Labels != nil {
+			t.labels = slices.Clone(*args.Labels)
+		}
+		if args.BandwidthPriority != nil {
+			t.priority = *args.BandwidthPriority
+		}
+		if args.HonorsSessionLimits != nil {
+			t.honors = *args.HonorsSessionLimits
+		}
+		if args.TrackerList != nil {
+			t.trackers = strings.Fields(*args.TrackerList)
+		}
+		for i := 0; i+1 < len(args.TrackerReplace); i += 2 {
+			id, _ := args.TrackerReplace[i].(float64)
+			announce, _ := args.TrackerReplace[i+1].(string)
+			if id >= 0 && int(id) < len(t.trackers) {
+				t.trackers[int(id)] = announce
+			}
+		}
+		if args.Location != nil {
+			t.downloadDir = *args.Location
+		}
+		var kept []string
+		for i, announce := range t.trackers {
+			if !slices.Contains(args.TrackerRemove, i) {
+				kept = append(kept, announce)
+			}
+		}
+		t.trackers = append(kept, args.TrackerAdd...)
+	}
+	return nil
+}
+
+// fakeLimits are the per-torrent limits torrent-set changes; the simulation
+// doesn't enforce them
+type fakeLimits struct {
+	DownloadLimit   int
+	DownloadLimited bool
+	UploadLimit     int
+	UploadLimited   bool
+	SeedRatioLimit  float64
+	SeedRatioMode   transmission.LimitMode
+	SeedIdleLimit   int
+	SeedIdleMode    transmission.LimitMode
+}
+
+// setIf sets *dst to *v when v is not nil
+func setIf[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func (d *fakeDaemon) stats(now time.Time) interface{} {
+	var active, paused int
+	var dl, ul, downloaded, uploaded float64
+	for _, t := range d.torrents {
+		switch t.status {
+		case transmission.TrStopped:
+			paused++
+		case transmission.TrDownloading:
+			active++
+			dl += t.dlRate
+			ul += t.ulRate
+		case transmission.TrSeeding:
+			active++
+			ul += t.ulRate
+		}
+		downloaded += t.downloaded
+		uploaded += t.uploaded
+	}
+	total := map[string]interface{}{
+		"downloadedBytes": int64(downloaded),
+		"uploadedBytes":   int64(uploaded),
+		"filesAdded":      len(d.torrents),
+		"secondsActive":   int64(now.Sub(d.started).Seconds()),
+		"sessionCount":    1,
+	}
+	return map[string]interface{}{
+		"activeTorrentCount": active,
+		"pausedTorrentCount": paused,
+		"torrentCount":       len(d.torrents),
+		"downloadSpeed":      int64(dl),
+		"uploadSpeed":        int64(ul),
+		"cumulative-stats":   total,
+		"current-stats":      total,
+	}
+}
@@ -0,0 +1,265 @@
+// Package transmissiontest records the HTTP exchanges between a client and
+// a real Transmission daemon into fixture files, then replays them, so the
+// tests of code using the client run deterministically without a daemon:
+//
+//	rec, err := transmissiontest.NewRecorder("testdata/list.json", transmissiontest.ModeFromEnv())
+//	...
+//	defer rec.Stop()
+//	client := rec.NewClient("http://127.0.0.1:9091/transmission/rpc", "user", "password")
+//
+// Run the tests once with TRANSMISSION_RECORD=1 against a daemon to write the
+// fixtures, then commit them.
+//
+// FakeClient goes further and simulates a daemon whose torrents progress
+// over time, for demos and UI work without any daemon.
+package transmissiontest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// Mode tells a Recorder what to do
+type Mode int
+
+const (
+	ModeReplay Mode = iota // answer from the fixture file, failing on unknown requests
+	ModeRecord             // forward to the daemon and write the fixture file on Stop
+)
+
+// ModeFromEnv returns ModeRecord when $TRANSMISSION_RECORD is set to
+// anything but "" or "0", ModeReplay otherwise
+func ModeFromEnv() Mode {
+	if v := os.Getenv("TRANSMISSION_RECORD"); v != "" && v != "0" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Interaction is a recorded RPC request and the daemon's response
+type Interaction struct {
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// fixture is the content of a fixture file
+type fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// fakeSessionID is the session id handed out in replay mode
+const fakeSessionID = "transmissiontest"
+
+// Recorder is an http.RoundTripper recording the RPC exchanges into a
+// fixture file or replaying them. Session id handshakes are not recorded:
+// in replay mode they are answered with a fixed id. Authorization headers
+// are never recorded.
+type Recorder struct {
+	Path      string
+	Mode      Mode
+	Transport http.RoundTripper // for recording, http.DefaultTransport if nil
+	// Sanitize, if set, is applied to every interaction before it is
+	// written, after the tracker urls are redacted
+	Sanitize func(*Interaction)
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool // replayed interactions
+}
+
+// NewRecorder returns a recorder for the fixture file at path, which is read
+// in replay mode
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode}
+	if mode == ModeRecord {
+		return r, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f fixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("transmissiontest: %s: %w", path, err)
+	}
+	r.interactions = f.Interactions
+	r.used = make([]bool, len(f.Interactions))
+	return r, nil
+}
+
+// Client returns an http.Client using the recorder
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// NewClient returns a TransmissionClient using the recorder; in replay mode
+// the url and credentials are not used
+func (r *Recorder) NewClient(url, username, password string, opts ...transmission.Option) *transmission.TransmissionClient {
+	opts = append(opts, transmission.WithHTTPClient(r.Client()))
+	return transmission.NewLazy(url, username, password, opts...)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	if r.Mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	res, err := transport.RoundTrip(req)
+	if err != nil || len(body) == 0 || res.StatusCode == http.StatusConflict {
+		return res, err // handshakes are not recorded
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	in := Interaction{Method: rpcMethod(body), Request: jsonOrString(body), Status: res.StatusCode, Response: jsonOrString(resBody)}
+	redactTrackers(&in)
+	if r.Sanitize != nil {
+		r.Sanitize(&in)
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+	return res, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	if len(body) == 0 {
+		return response(req, http.StatusConflict, nil), nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if !r.used[i] && sameJSON(in.Request, body) {
+			r.used[i] = true
+			res := []byte(in.Response)
+			if isJSONString(in.Response) {
+				var s string
+				json.Unmarshal(in.Response, &s)
+				res = []byte(s)
+			}
+			return response(req, in.Status, res), nil
+		}
+	}
+	return nil, fmt.Errorf("transmissiontest: no recorded interaction for %s in %s", body, r.Path)
+}
+
+// Unused returns the recorded interactions not replayed yet, to check that
+// the code under test sent everything it was expected to; it is always
+// empty in record mode
+func (r *Recorder) Unused() []Interaction {
+	if r.Mode == ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, in := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+// Stop writes the fixture file in record mode
+func (r *Recorder) Stop() error {
+	if r.Mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := fixture{Interactions: r.interactions}
+	if f.Interactions == nil {
+		f.Interactions = []Interaction{}
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, append(b, '\n'), 0o644)
+}
+
+func response(req *http.Request, status int, body []byte) *http.Response {
+	h := make(http.Header)
+	h.Set("X-Transmission-Session-Id", fakeSessionID)
+	h.Set("Content-Type", "application/json")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// rpcMethod returns the method of an RPC request body
+func rpcMethod(body []byte) string {
+	var req struct {
+		Method string `json:"method"`
+	}
+	json.Unmarshal(body, &req)
+	return req.Method
+}
+
+// jsonOrString returns b if it is JSON, b as a JSON string otherwise, so
+// fixtures stay readable either way; the daemon never answers with a JSON
+// string, so they can be told apart
+func jsonOrString(b []byte) json.RawMessage {
+	if json.Valid(b) {
+		return b
+	}
+	s, _ := json.Marshal(string(b))
+	return s
+}
+
+func isJSONString(b json.RawMessage) bool {
+	b = bytes.TrimSpace(b)
+	return len(b) > 0 && b[0] == '"'
+}
+
+// sameJSON reports whether a and b are the same JSON value, ignoring
+// formatting and key order
+func sameJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if err := errors.Join(json.Unmarshal(a, &va), json.Unmarshal(b, &vb)); err != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,85 @@
+package transmissiontest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// trackerKeys are the response fields holding tracker urls, which carry
+// the passkeys of private trackers
+var trackerKeys = map[string]bool{
+	"announce":    true,
+	"scrape":      true,
+	"trackerList": true,
+}
+
+// redactTrackers removes the credentials, queries and passkey path
+// segments from the tracker urls of the response of in. Requests are kept
+// as they are: the code under test sends them again when replaying.
+func redactTrackers(in *Interaction) {
+	dec := json.NewDecoder(bytes.NewReader(in.Response))
+	dec.UseNumber()
+	var v interface{}
+	if dec.Decode(&v) != nil {
+		return
+	}
+	if !redactValue(v) {
+		return
+	}
+	if b, err := json.Marshal(v); err == nil {
+		in.Response = b
+	}
+}
+
+// redactValue redacts the tracker urls found in v, reporting whether it
+// changed anything
+func redactValue(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if s, ok := e.(string); ok && trackerKeys[k] {
+				if r := redactLines(s); r != s {
+					v[k], changed = r, true
+				}
+				continue
+			}
+			changed = redactValue(e) || changed
+		}
+	case []interface{}:
+		for _, e := range v {
+			changed = redactValue(e) || changed
+		}
+	}
+	return changed
+}
+
+// redactLines redacts the urls of a trackerList, one per line, or of a
+// single url
+func redactLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = RedactURL(l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RedactURL keeps the scheme, host and last path segment of a tracker url,
+// e.g. "https://tracker.example/announce" for
+// "https://tracker.example/0123abcd/announce?passkey=0123abcd"
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	if base := path.Base(u.Path); base != "." && base != "/" {
+		u.Path = "/" + base
+	}
+	return u.String()
+}
@@ -0,0 +1,164 @@
+package transmission
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashLabelPrefix prefixes the label of the torrents moved to the trash
+// by TrashTorrent, followed by the unix time they were, and by ":started"
+// for those that were running
+const TrashLabelPrefix = "trash:"
+
+// DefaultTrashGrace is the time a Purger leaves trashed torrents to be
+// restored when its Grace is 0
+const DefaultTrashGrace = 7 * 24 * time.Hour
+
+// TrashTorrent stops the torrent and labels it for deletion, to be removed
+// with its data by a Purger after a grace period; RestoreTorrent undoes it
+// until then (Transmission 4.0+)
+func (ac *TransmissionClient) TrashTorrent(ctx context.Context, id string) error {
+	t, err := ac.getTorrentFields(ctx, id, []string{"id", "hashString", "status", "labels"})
+	if err != nil {
+		return err
+	}
+	if _, _, ok := trashed(t); ok {
+		return nil
+	}
+	label := TrashLabelPrefix + strconv.FormatInt(time.Now().Unix(), 10)
+	if t.Status != TrStopped {
+		label += ":started"
+	}
+	if err := ac.torrentAction(ctx, "torrent-stop", []string{t.InfoHash}); err != nil {
+		return err
+	}
+	labels := append(slices.Clone(t.Labels), label)
+	return ac.torrentSet(ctx, &TorrentSetArgs{Ids: []string{t.InfoHash}, Labels: &labels})
+}
+
+// RestoreTorrent takes the torrent out of the trash, starting it again if
+// it was running when trashed
+func (ac *TransmissionClient) RestoreTorrent(ctx context.Context, id string) error {
+	t, err := ac.getTorrentFields(ctx, id, []string{"id", "hashString", "labels"})
+	if err != nil {
+		return err
+	}
+	_, started, ok := trashed(t)
+	if !ok {
+		return nil
+	}
+	labels := slices.DeleteFunc(slices.Clone(t.Labels), func(l string) bool {
+		return strings.HasPrefix(l, TrashLabelPrefix)
+	})
+	if labels == nil {
+		labels = []string{} // an empty list, not an absent one, clears them
+	}
+	if err := ac.torrentSet(ctx, &TorrentSetArgs{Ids: []string{t.InfoHash}, Labels: &labels}); err != nil {
+		return err
+	}
+	if started {
+		return ac.torrentAction(ctx, "torrent-start", []string{t.InfoHash})
+	}
+	return nil
+}
+
+// trashed returns when t was trashed and whether it was running then; ok
+// is false if it isn't in the trash
+func trashed(t *Torrent) (at time.Time, started, ok bool) {
+	for _, l := range t.Labels {
+		rest, found := strings.CutPrefix(l, TrashLabelPrefix)
+		if !found {
+			continue
+		}
+		rest, started = strings.CutSuffix(rest, ":started")
+		if secs, err := strconv.ParseInt(rest, 10, 64); err == nil {
+			return time.Unix(secs, 0), started, true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// Purger removes, with their data, the torrents trashed for longer than
+// Grace
+type Purger struct {
+	client *TransmissionClient
+
+	Grace time.Duration // DefaultTrashGrace if 0
+	// OnPurge, if not nil, is called with the torrents removed by each pass
+	OnPurge func(*BulkResult)
+}
+
+// NewPurger returns a purger of the trash of the daemon
+func NewPurger(client *TransmissionClient) *Purger {
+	return &Purger{client: client, Grace: DefaultTrashGrace}
+}
+
+// Trash returns the torrents in the trash, the earliest trashed first
+func (p *Purger) Trash(ctx context.Context) (Torrents, error) {
+	var trash Torrents
+	err := p.client.ForEachTorrent(ctx, []string{"id", "name", "hashString", "labels", "totalSize"}, func(t *Torrent) error {
+		if _, _, ok := trashed(t); ok {
+			trash = append(trash, t)
+		}
+		return nil
+	})
+	slices.SortFunc(trash, func(a, b *Torrent) int {
+		at, _, _ := trashed(a)
+		bt, _, _ := trashed(b)
+		return at.Compare(bt)
+	})
+	return trash, err
+}
+
+// Purge removes the torrents whose grace period is over; the others in
+// the trash are reported as skipped
+func (p *Purger) Purge(ctx context.Context) (*BulkResult, error) {
+	trash, err := p.Trash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	grace := p.Grace
+	if grace <= 0 {
+		grace = DefaultTrashGrace
+	}
+	now := time.Now()
+	result := &BulkResult{}
+	for _, t := range trash {
+		at, _, _ := trashed(t)
+		if left := at.Add(grace).Sub(now); left > 0 {
+			result.skip("purged in "+left.Round(time.Minute).String(), t.InfoHash)
+			continue
+		}
+		err := p.client.rpc(ctx, "torrent-remove", map[string]interface{}{
+			"ids":               []string{t.InfoHash},
+			"delete-local-data": true,
+		}, nil)
+		if err != nil {
+			result.fail(err, t.InfoHash)
+		} else {
+			result.succeed(t.InfoHash)
+		}
+	}
+	if p.OnPurge != nil && len(result.Succeeded)+len(result.Failed) > 0 {
+		p.OnPurge(result)
+	}
+	return result, result.Err()
+}
+
+// Run purges every interval until ctx is done; failures are retried at the
+// next tick
+func (p *Purger) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.Purge(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUndoLogSize is the number of changes kept by WithUndoLog when
+// given a size <= 0
+const DefaultUndoLogSize = 100
+
+// UndoEntry is a change of settings recorded by the undo log
+type UndoEntry struct {
+	Time   time.Time
+	Method string   // torrent-set or session-set
+	Ids    []string // hashes of the torrents changed
+	Keys   []string // arguments undone by Undo
+	// Lost are the arguments whose previous values can't be read back,
+	// e.g. trackerAdd, which Undo leaves as they are
+	Lost []string
+}
+
+// undoRecord is an entry with the arguments reverting it, by hash, the
+// empty hash for session-set
+type undoRecord struct {
+	UndoEntry
+	revert map[string]map[string]json.RawMessage
+}
+
+// undoLog keeps the last changes of a client created WithUndoLog
+type undoLog struct {
+	size int
+
+	mu      sync.Mutex
+	records []*undoRecord
+}
+
+// WithUndoLog makes the client record the previous values of the settings
+// it changes with torrent-set and session-set, the last size changes, so
+// Undo can revert them, e.g. a bulk limit change gone wrong. Each change
+// costs a read of the values before it.
+func WithUndoLog(size int) Option {
+	return func(ac *TransmissionClient) {
+		if size <= 0 {
+			size = DefaultUndoLogSize
+		}
+		ac.undoLog = &undoLog{size: size}
+	}
+}
+
+// UndoHistory returns the changes Undo can revert, the latest last
+func (ac *TransmissionClient) UndoHistory() []UndoEntry {
+	if ac.undoLog == nil {
+		return nil
+	}
+	ac.undoLog.mu.Lock()
+	defer ac.undoLog.mu.Unlock()
+	entries := make([]UndoEntry, len(ac.undoLog.records))
+	for i, r := range ac.undoLog.records {
+		entries[i] = r.UndoEntry
+	}
+	return entries
+}
+
+type undoingKey struct{}
+
+// Undo reverts the last n changes, the latest first, restoring the values
+// the settings had before each of them; the reverting calls aren't
+// recorded. It stops at the first failure, the changes left staying in
+// the log.
+func (ac *TransmissionClient) Undo(ctx context.Context, n int) error {
+	if ac.undoLog == nil {
+		return errors.New("undo: the client has no undo log, see WithUndoLog")
+	}
+	ctx = context.WithValue(ctx, undoingKey{}, true)
+	for ; n > 0; n-- {
+		ac.undoLog.mu.Lock()
+		if len(ac.undoLog.records) == 0 {
+			ac.undoLog.mu.Unlock()
+			return nil
+		}
+		r := ac.undoLog.records[len(ac.undoLog.records)-1]
+		ac.undoLog.mu.Unlock()
+
+		hashes := make([]string, 0, len(r.revert))
+		for hash := range r.revert {
+			hashes = append(hashes, hash)
+		}
+		slices.Sort(hashes)
+		for _, hash := range hashes {
+			args := maps.Clone(r.revert[hash])
+			if hash != "" {
+				args["ids"], _ = json.Marshal([]string{hash})
+			}
+			if err := ac.rpc(ctx, r.Method, args, nil); err != nil {
+				return fmt.Errorf("undo %s of %s: %w", r.Method, r.Time.Format(time.DateTime), err)
+			}
+		}
+		ac.undoLog.mu.Lock()
+		ac.undoLog.records = slices.DeleteFunc(ac.undoLog.records, func(x *undoRecord) bool { return x == r })
+		ac.undoLog.mu.Unlock()
+	}
+	return nil
+}
+
+// undoFields are the torrent-get fields holding the previous values of the
+// torrent-set arguments not named like their field
+var undoFields = map[string]string{
+	"files-wanted":    "wanted",
+	"files-unwanted":  "wanted",
+	"priority-high":   "priorities",
+	"priority-normal": "priorities",
+	"priority-low":    "priorities",
+	"location":        "downloadDir",
+}
+
+// before reads the values the settings changed by a request have, nil if
+// the request changes none or is an Undo
+func (l *undoLog) before(ctx context.Context, ac *TransmissionClient, method string, rawArgs json.RawMessage) (*undoRecord, error) {
+	if ctx.Value(undoingKey{}) != nil || method != "torrent-set" && method != "session-set" {
+		return nil, nil
+	}
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(rawArgs, &args); err != nil {
+		return nil, err
+	}
+	ids := args["ids"]
+	delete(args, "ids")
+	if len(args) == 0 {
+		return nil, nil
+	}
+	r := &undoRecord{
+		UndoEntry: UndoEntry{Time: time.Now(), Method: method},
+		revert:    make(map[string]map[string]json.RawMessage),
+	}
+
+	fields := []string{"hashString"}
+	for key := range args {
+		switch {
+		case strings.HasPrefix(key, "tracker"):
+			r.Lost = append(r.Lost, key)
+		case undoFields[key] != "":
+			fields = append(fields, undoFields[key])
+		default:
+			fields = append(fields, key)
+		}
+	}
+	slices.Sort(r.Lost)
+
+	if method == "session-set" {
+		var prev map[string]json.RawMessage
+		if err := ac.rpc(ctx, "session-get", map[string][]string{"fields": fields[1:]}, &prev); err != nil {
+			return nil, err
+		}
+		r.revert[""] = r.inverse(args, prev)
+		return r, nil
+	}
+
+	get := map[string]interface{}{"fields": fields}
+	if len(ids) > 0 {
+		get["ids"] = ids // absent, all the torrents are changed
+	}
+	var out struct {
+		Torrents []map[string]json.RawMessage `json:"torrents"`
+	}
+	if err := ac.rpc(ctx, "torrent-get", get, &out); err != nil {
+		return nil, err
+	}
+	for _, prev := range out.Torrents {
+		var hash string
+		json.Unmarshal(prev["hashString"], &hash)
+		r.Ids = append(r.Ids, hash)
+		r.revert[hash] = r.inverse(args, prev)
+	}
+	return r, nil
+}
+
+// inverse returns the arguments setting back the values prev of a torrent
+// or of the session; the keys missing from prev are added to Lost
+func (r *undoRecord) inverse(args, prev map[string]json.RawMessage) map[string]json.RawMessage {
+	inv := make(map[string]json.RawMessage)
+	for key := range args {
+		field := undoFields[key]
+		if field == "" {
+			field = key
+		}
+		value, ok := prev[field]
+		switch {
+		case strings.HasPrefix(key, "tracker"):
+			continue
+		case !ok:
+			if !slices.Contains(r.Lost, key) {
+				r.Lost = append(r.Lost, key)
+			}
+		case field == "wanted":
+			var wanted Flags
+			json.Unmarshal(value, &wanted)
+			setIndexes(inv, "files-wanted", wanted, true)
+			setIndexes(inv, "files-unwanted", wanted, false)
+		case field == "priorities":
+			var priorities []Priority
+			json.Unmarshal(value, &priorities)
+			setIndexes(inv, "priority-high", priorities, PriorityHigh)
+			setIndexes(inv, "priority-normal", priorities, PriorityNormal)
+			setIndexes(inv, "priority-low", priorities, PriorityLow)
+		default:
+			inv[key] = value
+		}
+	}
+	if r.Keys == nil {
+		for key := range inv {
+			r.Keys = append(r.Keys, key)
+		}
+		slices.Sort(r.Keys)
+	}
+	return inv
+}
+
+// setIndexes sets the argument key to the list of the indexes of v in s.
+// Without any, key is left out: the daemon reads an empty list as all the
+// files.
+func setIndexes[T comparable](args map[string]json.RawMessage, key string, s []T, v T) {
+	var indexes []int
+	for i, x := range s {
+		if x == v {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) > 0 {
+		args[key], _ = json.Marshal(indexes)
+	}
+}
+
+// add keeps r once its change succeeded, dropping the oldest records past
+// the size of the log
+func (l *undoLog) add(r *undoRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, r)
+	if len(l.records) > l.size {
+		l.records = slices.Delete(l.records, 0, len(l.records)-l.size)
+	}
+}
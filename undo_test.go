@@ -0,0 +1,39 @@
+package transmission
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestUndoInverseFileLists checks that the inverse of a change of files
+// never sends an empty index list, which the daemon reads as all the files
+func TestUndoInverseFileLists(t *testing.T) {
+	args := map[string]json.RawMessage{
+		"files-unwanted": json.RawMessage(`[3]`),
+		"priority-high":  json.RawMessage(`[0]`),
+	}
+	prev := map[string]json.RawMessage{
+		"wanted":     json.RawMessage(`[1,1,1,1]`),
+		"priorities": json.RawMessage(`[0,0,0,-1]`),
+	}
+	inv := (&undoRecord{}).inverse(args, prev)
+
+	want := map[string]string{
+		"files-wanted":    `[0,1,2,3]`,
+		"priority-normal": `[0,1,2]`,
+		"priority-low":    `[3]`,
+	}
+	if len(inv) != len(want) {
+		t.Errorf("inverse has %d arguments, want %d: %s", len(inv), len(want), inv)
+	}
+	for key, list := range want {
+		if string(inv[key]) != list {
+			t.Errorf("%s = %s, want %s", key, inv[key], list)
+		}
+	}
+	for _, key := range []string{"files-unwanted", "priority-high"} {
+		if list, ok := inv[key]; ok {
+			t.Errorf("%s = %s, want it left out", key, list)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package transmission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Sentinels the daemon uses for eta and uploadRatio
+const (
+	EtaNotAvailable = -1
+	EtaUnknown      = -2
+	RatioNA         = -1
+	RatioInfinite   = -2
+)
+
+// ValidationError describes an inconsistent value found by Validate
+type ValidationError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %v: %s", e.Field, e.Value, e.Reason)
+}
+
+// ValidationErrors is the list of problems returned by Validate
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for i := range e {
+		msgs = append(msgs, e[i].Error())
+	}
+	return "invalid torrent data: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the problems, for errors.As to find a ValidationError
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i := range e {
+		errs[i] = e[i]
+	}
+	return errs
+}
+
+// Validate checks the decoded torrent for inconsistent values and
+// normalizes them in place; it returns ValidationErrors describing what was
+// changed, or nil if the torrent was consistent
+func (t *Torrent) Validate() error {
+	var errs ValidationErrors
+	flag := func(field string, value interface{}, reason string) {
+		errs = append(errs, ValidationError{Field: field, Value: value, Reason: reason})
+	}
+
+	// negative values of unsigned fields are decoded wrapped to huge
+	// numbers, see UnmarshalJSON
+	unsigned := []struct {
+		name string
+		v    *uint64
+	}{
+		{"sizeWhenDone", &t.SizeWhenDone},
+		{"totalSize", &t.TotalSize},
+		{"downloadedEver", &t.DownloadedEver},
+		{"uploadedEver", &t.UploadedEver},
+		{"haveValid", &t.HaveValid},
+		{"haveUnchecked", &t.HaveUnchecked},
+	}
+	for _, f := range unsigned {
+		if *f.v > math.MaxInt64 {
+			flag(f.name, *f.v, "wrapped negative value, set to 0")
+			*f.v = 0
+		}
+	}
+	if left, ok := t.BytesLeft(); ok && left > t.SizeWhenDone {
+		flag("leftUntilDone", t.LeftUntilDone, "larger than sizeWhenDone, clamped")
+		t.LeftUntilDone = int64(t.SizeWhenDone)
+	}
+
+	if t.Eta < EtaUnknown {
+		flag("eta", t.Eta, "unknown sentinel, set to -1")
+		t.Eta = EtaNotAvailable
+	}
+	if t.EtaIdle < EtaUnknown {
+		flag("etaIdle", t.EtaIdle, "unknown sentinel, set to -1")
+		t.EtaIdle = EtaNotAvailable
+	}
+
+	if t.UploadRatio < RatioInfinite {
+		flag("uploadRatio", t.UploadRatio, "unknown sentinel, set to -1")
+		t.UploadRatio = RatioNA
+	}
+
+	switch {
+	case math.IsNaN(float64(t.PercentDone)) || t.PercentDone < 0:
+		flag("percentDone", t.PercentDone, "out of range, set to 0")
+		t.PercentDone = 0
+	case t.PercentDone > 1:
+		flag("percentDone", t.PercentDone, "out of range, set to 1")
+		t.PercentDone = 1
+	}
+	switch {
+	case math.IsNaN(t.PercentComplete) || t.PercentComplete < 0:
+		flag("percentComplete", t.PercentComplete, "out of range, set to 0")
+		t.PercentComplete = 0
+	case t.PercentComplete > 1:
+		flag("percentComplete", t.PercentComplete, "out of range, set to 1")
+		t.PercentComplete = 1
+	}
+
+	for i := range t.Files {
+		f := &t.Files[i]
+		if f.Size < 0 {
+			flag("files", f.Size, "negative length, set to 0")
+			f.Size = 0
+		}
+		if f.Completed < 0 || f.Completed > f.Size {
+			flag("files", f.Completed, "bytesCompleted out of range, clamped")
+			f.Completed = min(max(f.Completed, 0), f.Size)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// wrappingUint64 decodes a negative number as the uint64 it wraps to,
+// where encoding/json fails the whole response
+type wrappingUint64 uint64
+
+func (n *wrappingUint64) UnmarshalJSON(b []byte) error {
+	if !bytes.HasPrefix(b, []byte("-")) {
+		return json.Unmarshal(b, (*uint64)(n))
+	}
+	v, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: "number " + string(b), Type: reflect.TypeOf(uint64(0))}
+	}
+	*n = wrappingUint64(v)
+	return nil
+}
+
+// UnmarshalJSON decodes a torrent, wrapping the negative values some
+// daemons send for its unsigned sizes and counters for Validate to reset
+func (t *Torrent) UnmarshalJSON(b []byte) error {
+	type torrent Torrent // without this method
+	aux := struct {
+		*torrent
+		SizeWhenDone   *wrappingUint64 `json:"sizeWhenDone"`
+		TotalSize      *wrappingUint64 `json:"totalSize"`
+		DownloadedEver *wrappingUint64 `json:"downloadedEver"`
+		UploadedEver   *wrappingUint64 `json:"uploadedEver"`
+		HaveValid      *wrappingUint64 `json:"haveValid"`
+		HaveUnchecked  *wrappingUint64 `json:"haveUnchecked"`
+	}{
+		torrent:        (*torrent)(t),
+		SizeWhenDone:   (*wrappingUint64)(&t.SizeWhenDone),
+		TotalSize:      (*wrappingUint64)(&t.TotalSize),
+		DownloadedEver: (*wrappingUint64)(&t.DownloadedEver),
+		UploadedEver:   (*wrappingUint64)(&t.UploadedEver),
+		HaveValid:      (*wrappingUint64)(&t.HaveValid),
+		HaveUnchecked:  (*wrappingUint64)(&t.HaveUnchecked),
+	}
+	return json.Unmarshal(b, &aux)
+}
+
+// validate normalizes every torrent; the findings are dropped, callers
+// wanting them can run Validate on raw ExecuteCommand results
+func (t Torrents) validate() {
+	for i := range t {
+		t[i].Validate()
+	}
+}
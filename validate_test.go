@@ -0,0 +1,31 @@
+package transmission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidateErrors(t *testing.T) {
+	var tor Torrent
+	if err := json.Unmarshal([]byte(`{"id":1,"sizeWhenDone":-1,"totalSize":10}`), &tor); err != nil {
+		t.Fatal(err)
+	}
+	err := fmt.Errorf("torrent 1: %w", tor.Validate())
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("errors.As(ValidationErrors) = %v, want one problem", errs)
+	}
+	var problem ValidationError
+	if !errors.As(err, &problem) || problem.Field != "sizeWhenDone" {
+		t.Errorf("errors.As(ValidationError) = %+v, want sizeWhenDone", problem)
+	}
+	if !errors.Is(err, errs[0]) {
+		t.Errorf("errors.Is doesn't find %v", errs[0])
+	}
+	if tor.SizeWhenDone != 0 {
+		t.Errorf("sizeWhenDone = %d, want 0", tor.SizeWhenDone)
+	}
+}
@@ -0,0 +1,172 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNoView is returned by RunView for an unregistered name
+var ErrNoView = errors.New("no view with that name")
+
+// Duration is a time.Duration encoded in JSON as a string like "24h"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// Query selects torrents; it is meant to be kept in config files as JSON.
+// Every set condition must match; a zero Query matches every torrent. For
+// example "stuck", errored or stalled for a day:
+//
+//	{"any": [{"errored": true}, {"stalled": true, "inactiveFor": "24h"}]}
+type Query struct {
+	All []Query `json:"all,omitempty"` // every subquery matches
+	Any []Query `json:"any,omitempty"` // at least one subquery matches
+	Not *Query  `json:"not,omitempty"` // the subquery doesn't match
+
+	Name        string    `json:"name,omitempty"`    // case-insensitive substring of the name
+	Status      []Status  `json:"status,omitempty"`  // one of the statuses
+	Label       string    `json:"label,omitempty"`   // carries the label
+	Tracker     string    `json:"tracker,omitempty"` // substring of an announce url
+	Errored     *bool     `json:"errored,omitempty"`
+	Stalled     *bool     `json:"stalled,omitempty"`
+	Completed   *bool     `json:"completed,omitempty"`
+	Private     *bool     `json:"private,omitempty"`
+	MinRatio    *float64  `json:"minRatio,omitempty"`
+	MaxRatio    *float64  `json:"maxRatio,omitempty"`
+	InactiveFor *Duration `json:"inactiveFor,omitempty"` // no transfer for at least that long
+	AddedWithin *Duration `json:"addedWithin,omitempty"` // added at most that long ago
+}
+
+// Match reports whether the torrent is selected by q at now
+func (q *Query) Match(t *Torrent, now time.Time) bool {
+	for i := range q.All {
+		if !q.All[i].Match(t, now) {
+			return false
+		}
+	}
+	if len(q.Any) > 0 && !q.matchAny(t, now) {
+		return false
+	}
+	if q.Not != nil && q.Not.Match(t, now) {
+		return false
+	}
+
+	is := func(want *bool, v bool) bool { return want == nil || *want == v }
+	switch {
+	case q.Name != "" && !strings.Contains(strings.ToLower(t.Name), strings.ToLower(q.Name)):
+		return false
+	case len(q.Status) > 0 && !slices.Contains(q.Status, t.Status):
+		return false
+	case q.Label != "" && !slices.Contains(t.Labels, q.Label):
+		return false
+	case q.Tracker != "" && !t.hasTracker(q.Tracker):
+		return false
+	case !is(q.Errored, t.Error != 0), !is(q.Stalled, t.IsStalled),
+		!is(q.Completed, t.IsCompleted()), !is(q.Private, t.IsPrivate):
+		return false
+	case q.MinRatio != nil && t.UploadRatio < *q.MinRatio && t.UploadRatio != RatioInfinite,
+		q.MaxRatio != nil && (t.UploadRatio > *q.MaxRatio || t.UploadRatio == RatioInfinite):
+		return false
+	case q.InactiveFor != nil && now.Sub(time.Unix(t.ActivityDate, 0)) < time.Duration(*q.InactiveFor):
+		return false
+	case q.AddedWithin != nil && now.Sub(time.Unix(t.AddedDate, 0)) > time.Duration(*q.AddedWithin):
+		return false
+	}
+	return true
+}
+
+func (q *Query) matchAny(t *Torrent, now time.Time) bool {
+	for i := range q.Any {
+		if q.Any[i].Match(t, now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Torrent) hasTracker(s string) bool {
+	for _, tr := range t.Trackers {
+		if strings.Contains(tr.Announce, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the torrents selected by q at now
+func (ts Torrents) Filter(q *Query, now time.Time) Torrents {
+	var selected Torrents
+	for _, t := range ts {
+		if q.Match(t, now) {
+			selected = append(selected, t)
+		}
+	}
+	return selected
+}
+
+// SetView registers q under name, replacing any view with that name; a nil
+// q removes it
+func (ac *TransmissionClient) SetView(name string, q *Query) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if q == nil {
+		delete(ac.views, name)
+		return
+	}
+	if ac.views == nil {
+		ac.views = make(map[string]*Query)
+	}
+	ac.views[name] = q
+}
+
+// Views returns the registered views, which can be marshalled to JSON as is
+// for a config file
+func (ac *TransmissionClient) Views() map[string]*Query {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	views := make(map[string]*Query, len(ac.views))
+	for name, q := range ac.views {
+		views[name] = q
+	}
+	return views
+}
+
+// WithViews registers views, e.g. loaded from a config file, see SetView
+func WithViews(views map[string]*Query) Option {
+	return func(ac *TransmissionClient) {
+		for name, q := range views {
+			ac.SetView(name, q)
+		}
+	}
+}
+
+// RunView fetches the torrents and returns those selected by the view
+func (ac *TransmissionClient) RunView(ctx context.Context, name string) (Torrents, error) {
+	ac.mu.Lock()
+	q, ok := ac.views[name]
+	ac.mu.Unlock()
+	if !ok {
+		return nil, ErrNoView
+	}
+	torrents, err := ac.GetTorrentsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return torrents.Filter(q, time.Now()), nil
+}
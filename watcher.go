@@ -0,0 +1,396 @@
+package transmission
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// EventType tells what changed in a watcher Event
+type EventType int
+
+const (
+	EventAdded            EventType = iota // torrent appeared
+	EventRemoved                           // torrent disappeared
+	EventUpdated                           // any field changed
+	EventStatusChanged                     // Status changed
+	EventCompleted                         // PercentDone reached 1
+	EventErrored                           // Error went from 0 to non-zero
+	EventDisconnected                      // first failed poll, after a successful one or at start
+	EventReconnected                       // a poll succeeded after EventDisconnected
+	EventMetadataComplete                  // metadataPercentComplete reached 1, name, size and files are known
+	EventSpeedDegraded                     // aggregate download rate collapsed, see AlertOnSpeedDrop
+	EventSpeedRecovered                    // aggregate download rate is back after EventSpeedDegraded
+	EventGroupCompleted                    // every torrent of a watched group is complete, see WatchGroup
+)
+
+func (et EventType) String() string {
+	switch et {
+	case EventAdded:
+		return "added"
+	case EventRemoved:
+		return "removed"
+	case EventUpdated:
+		return "updated"
+	case EventStatusChanged:
+		return "status-changed"
+	case EventCompleted:
+		return "completed"
+	case EventErrored:
+		return "errored"
+	case EventDisconnected:
+		return "disconnected"
+	case EventReconnected:
+		return "reconnected"
+	case EventMetadataComplete:
+		return "metadata-complete"
+	case EventSpeedDegraded:
+		return "speed-degraded"
+	case EventSpeedRecovered:
+		return "speed-recovered"
+	case EventGroupCompleted:
+		return "group-completed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is emitted by a Watcher when a poll finds a difference, when the
+// daemon becomes unreachable or reachable again, when the download rate
+// collapses, or when a watched group completes
+type Event struct {
+	Type     EventType
+	Torrent  *Torrent // state after the change, last known state for EventRemoved, nil for daemon events
+	Previous *Torrent // state before the change, nil for EventAdded and daemon events
+	Time     time.Time
+	Err      error         // the poll error for EventDisconnected
+	Rate     uint64        // aggregate download rate in B/s for the speed events
+	Baseline uint64        // usual aggregate download rate in B/s for the speed events
+	Group    *TorrentGroup // the group for EventGroupCompleted
+}
+
+// speedBaselineAlpha is the weight of a new sample in the usual download
+// rate of AlertOnSpeedDrop
+const speedBaselineAlpha = 0.1
+
+// speedBaselineDecay is the weight of a new sample in the usual download
+// rate once EventSpeedDegraded was emitted, so that a lasting drop slowly
+// becomes the usual rate and the alert clears
+const speedBaselineDecay = 0.01
+
+// Snapshot is the last known state of the torrents
+type Snapshot struct {
+	Torrents Torrents
+	Updated  time.Time // time of the last successful poll
+	Stale    bool      // the last poll failed, Torrents may be outdated
+}
+
+// Watcher polls the daemon and keeps the last known state of every torrent,
+// keyed by hash, emitting events for the differences between two polls.
+// Torrents handed out by the watcher are shared and must not be modified.
+type Watcher struct {
+	client   *TransmissionClient
+	interval time.Duration
+	eta      *EtaEstimator
+
+	mu       sync.RWMutex
+	torrents map[string]*Torrent
+	updated  time.Time
+	down     bool // the last poll failed
+	handlers []func(Event)
+	errors   []func(error)
+
+	speedDrop    float64 // 0 when AlertOnSpeedDrop is off
+	speedAfter   time.Duration
+	speedBase    float64   // smoothed aggregate download rate
+	speedBelow   time.Time // since when the rate is below the threshold
+	speedAlerted bool
+
+	groups map[string]bool // watched group dirs, whether they were complete
+}
+
+// NewWatcher returns a watcher polling client every interval
+func NewWatcher(client *TransmissionClient, interval time.Duration) *Watcher {
+	return &Watcher{
+		client:   client,
+		interval: interval,
+		eta:      NewEtaEstimator(DefaultEtaAlpha),
+		torrents: make(map[string]*Torrent),
+	}
+}
+
+// OnEvent registers fn to be called, from the polling goroutine, for every
+// event
+func (w *Watcher) OnEvent(fn func(Event)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, fn)
+}
+
+// OnError registers fn to be called when a poll fails
+func (w *Watcher) OnError(fn func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errors = append(w.errors, fn)
+}
+
+// AlertOnSpeedDrop makes the watcher emit EventSpeedDegraded when, with
+// torrents downloading, the aggregate download rate stays more than drop
+// (0...1) below its usual, smoothed, value for the after duration; it emits
+// EventSpeedRecovered when the rate comes back, or once a lasting drop has
+// become the usual rate. Only the torrents downloading count, so torrents
+// completing don't read as a drop. This catches VPN drops and tracker bans.
+func (w *Watcher) AlertOnSpeedDrop(drop float64, after time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.speedDrop, w.speedAfter = drop, after
+}
+
+// WatchGroup makes the watcher emit EventGroupCompleted when the last
+// incomplete torrent under dir completes, see TorrentGroup. A group
+// complete at the first poll emits no event; one completing again, after
+// a torrent was added to it, does.
+func (w *Watcher) WatchGroup(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.groups == nil {
+		w.groups = make(map[string]bool)
+	}
+	if _, ok := w.groups[dir]; ok {
+		return
+	}
+	if len(w.torrents) == 0 { // not polled yet
+		w.groups[dir] = true
+		return
+	}
+	torrents := make(Torrents, 0, len(w.torrents))
+	for _, t := range w.torrents {
+		torrents = append(torrents, t)
+	}
+	w.groups[dir] = GroupByFolder(torrents, dir).IsCompleted()
+}
+
+// Run polls until ctx is done; failed polls are reported to the OnError
+// handlers and retried at the next tick
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Poll(ctx); err != nil && ctx.Err() == nil {
+			w.mu.RLock()
+			handlers := w.errors
+			w.mu.RUnlock()
+			for _, fn := range handlers {
+				fn(err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the torrents once, updates the state and emits the events.
+// When it fails the last known state is kept, flagged as stale.
+func (w *Watcher) Poll(ctx context.Context) error {
+	torrents, err := w.client.GetTorrentsContext(ctx)
+	if err != nil {
+		w.setDown(true, err)
+		return err
+	}
+	w.setDown(false, nil)
+
+	now := time.Now()
+	current := make(map[string]*Torrent, len(torrents))
+	for _, t := range torrents {
+		current[t.InfoHash] = t
+		w.eta.Observe(t)
+	}
+
+	w.mu.Lock()
+	var events []Event
+	for hash, t := range current {
+		events = append(events, diffTorrent(w.torrents[hash], t, now)...)
+	}
+	for hash, prev := range w.torrents {
+		if _, ok := current[hash]; !ok {
+			events = append(events, Event{Type: EventRemoved, Torrent: prev, Previous: prev, Time: now})
+			w.eta.Forget(hash)
+		}
+	}
+	w.torrents = current
+	w.updated = now
+	events = append(events, w.checkSpeed(torrents, now)...)
+	events = append(events, w.checkGroups(torrents, now)...)
+	handlers := w.handlers
+	w.mu.Unlock()
+
+	for _, e := range events {
+		for _, fn := range handlers {
+			fn(e)
+		}
+	}
+	return nil
+}
+
+// setDown records the outcome of a poll, emitting a connection event when
+// it differs from the previous one
+func (w *Watcher) setDown(down bool, err error) {
+	w.mu.Lock()
+	if w.down == down {
+		w.mu.Unlock()
+		return
+	}
+	w.down = down
+	handlers := w.handlers
+	w.mu.Unlock()
+
+	e := Event{Type: EventReconnected, Time: time.Now()}
+	if down {
+		e = Event{Type: EventDisconnected, Time: e.Time, Err: err}
+	}
+	for _, fn := range handlers {
+		fn(e)
+	}
+}
+
+// checkSpeed updates the download rate baseline with a poll and returns the
+// speed events it causes; w.mu must be held
+func (w *Watcher) checkSpeed(torrents Torrents, now time.Time) []Event {
+	if w.speedDrop <= 0 {
+		return nil
+	}
+	var rate uint64
+	active := false
+	for _, t := range torrents {
+		if t.Status == TrDownloading {
+			rate += t.DownloadRate()
+			active = true
+		}
+	}
+	event := func(et EventType) []Event {
+		return []Event{{Type: et, Time: now, Rate: rate, Baseline: uint64(w.speedBase)}}
+	}
+	if !active {
+		var events []Event
+		if w.speedAlerted {
+			events = event(EventSpeedRecovered) // nothing left to be slow
+		}
+		w.speedBase, w.speedBelow, w.speedAlerted = 0, time.Time{}, false
+		return events
+	}
+	if float64(rate) >= w.speedBase*(1-w.speedDrop) {
+		if w.speedBase == 0 {
+			w.speedBase = float64(rate)
+		} else {
+			w.speedBase = speedBaselineAlpha*float64(rate) + (1-speedBaselineAlpha)*w.speedBase
+		}
+		w.speedBelow = time.Time{}
+		if w.speedAlerted {
+			w.speedAlerted = false
+			return event(EventSpeedRecovered)
+		}
+		return nil
+	}
+	if w.speedBelow.IsZero() {
+		w.speedBelow = now
+	}
+	if w.speedAlerted {
+		w.speedBase = speedBaselineDecay*float64(rate) + (1-speedBaselineDecay)*w.speedBase
+		return nil
+	}
+	if now.Sub(w.speedBelow) >= w.speedAfter {
+		w.speedAlerted = true
+		return event(EventSpeedDegraded)
+	}
+	return nil
+}
+
+// checkGroups updates the completion of the watched groups with a poll and
+// returns the events it causes; w.mu must be held
+func (w *Watcher) checkGroups(torrents Torrents, now time.Time) []Event {
+	var events []Event
+	for dir, was := range w.groups {
+		g := GroupByFolder(torrents, dir)
+		done := g.IsCompleted()
+		if done && !was {
+			events = append(events, Event{Type: EventGroupCompleted, Time: now, Group: g})
+		}
+		w.groups[dir] = done
+	}
+	return events
+}
+
+// diffTorrent returns the events describing the change from prev to cur
+func diffTorrent(prev, cur *Torrent, now time.Time) []Event {
+	if prev == nil {
+		return []Event{{Type: EventAdded, Torrent: cur, Time: now}}
+	}
+	if reflect.DeepEqual(prev, cur) {
+		return nil
+	}
+
+	event := func(et EventType) Event {
+		return Event{Type: et, Torrent: cur, Previous: prev, Time: now}
+	}
+	events := []Event{event(EventUpdated)}
+	if prev.Status != cur.Status {
+		events = append(events, event(EventStatusChanged))
+	}
+	if !prev.IsCompleted() && cur.IsCompleted() {
+		events = append(events, event(EventCompleted))
+	}
+	if prev.Error == 0 && cur.Error != 0 {
+		events = append(events, event(EventErrored))
+	}
+	if prev.MetadataPercentComplete < 1 && cur.MetadataPercentComplete == 1 {
+		events = append(events, event(EventMetadataComplete))
+	}
+	return events
+}
+
+// Torrents returns the last known torrents, sorted by id
+func (w *Watcher) Torrents() Torrents {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	torrents := make(Torrents, 0, len(w.torrents))
+	for _, t := range w.torrents {
+		torrents = append(torrents, t)
+	}
+	torrents.Sort(ByID)
+	return torrents
+}
+
+// Snapshot returns the last known torrents, sorted by id, with their
+// staleness
+func (w *Watcher) Snapshot() Snapshot {
+	torrents := w.Torrents()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return Snapshot{Torrents: torrents, Updated: w.updated, Stale: w.down}
+}
+
+// Torrent returns the last known state of the torrent with the given hash
+func (w *Watcher) Torrent(hash string) (*Torrent, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	t, ok := w.torrents[hash]
+	return t, ok
+}
+
+// Updated returns the time of the last successful poll
+func (w *Watcher) Updated() time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.updated
+}
+
+// ETA returns the smoothed ETA of the torrent with the given hash, see
+// EtaEstimator
+func (w *Watcher) ETA(hash string) (time.Duration, bool) {
+	return w.eta.ETA(hash)
+}
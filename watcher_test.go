@@ -0,0 +1,42 @@
+package transmission
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWatcherSpeedDrop checks that a lasting drop of the download rate is
+// reported once and clears as the usual rate follows it
+func TestWatcherSpeedDrop(t *testing.T) {
+	w := NewWatcher(nil, time.Minute)
+	w.AlertOnSpeedDrop(0.5, 0)
+	now := time.Now()
+	poll := func(rate int64) []Event {
+		now = now.Add(time.Minute)
+		torrents := Torrents{
+			{InfoHash: "a", Status: TrDownloading, RateDownload: rate},
+			{InfoHash: "b", Status: TrSeeding, RateDownload: 1 << 20}, // not counted
+		}
+		return w.checkSpeed(torrents, now)
+	}
+
+	for range 10 {
+		if events := poll(1000); len(events) != 0 {
+			t.Fatalf("events at the usual rate: %v", events)
+		}
+	}
+	if events := poll(100); len(events) != 1 || events[0].Type != EventSpeedDegraded {
+		t.Fatalf("events after the drop = %v, want EventSpeedDegraded", events)
+	}
+	for range 1000 {
+		events := poll(100)
+		if len(events) == 0 {
+			continue
+		}
+		if events[0].Type != EventSpeedRecovered {
+			t.Fatalf("events while degraded = %v, want EventSpeedRecovered", events)
+		}
+		return
+	}
+	t.Error("no EventSpeedRecovered after 1000 polls at the new rate")
+}
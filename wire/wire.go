@@ -0,0 +1,252 @@
+// Package wire defines stable, versioned JSON encodings of torrents,
+// statistics and watcher events, to publish them on message queues such as
+// Kafka or NATS and decode them in other programs, possibly built with
+// another version of this module or in another language.
+//
+// Every message is an envelope carrying the schema version of its writer.
+// Within a schema version fields are only ever added, so readers ignore
+// the fields they don't know and decode messages of newer versions too;
+// a field is never renamed or given another meaning.
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	transmission "github.com/unix2dos/go-transmission/v2"
+)
+
+// SchemaVersion is the version of the encodings written by this package
+const SchemaVersion = 1
+
+// Kinds of message
+const (
+	KindTorrent = "torrent"
+	KindStats   = "stats"
+	KindEvent   = "event"
+)
+
+// ErrUnknownKind is returned when decoding a message of a kind this version
+// doesn't know, which consumers would usually skip
+var ErrUnknownKind = errors.New("wire: unknown message kind")
+
+// Envelope is the outer object of every message
+type Envelope struct {
+	Schema int             `json:"schema"` // SchemaVersion of the writer
+	Kind   string          `json:"kind"`
+	Time   time.Time       `json:"time"` // when the message was created
+	Data   json.RawMessage `json:"data"`
+}
+
+// Message is a decoded envelope; one of Torrent, Stats and Event is set,
+// according to Kind
+type Message struct {
+	Schema  int
+	Kind    string
+	Time    time.Time
+	Torrent *Torrent
+	Stats   *Stats
+	Event   *Event
+}
+
+// Torrent is the encoding of a transmission.Torrent. Dates are unix
+// seconds, 0 when unknown.
+type Torrent struct {
+	ID           int      `json:"id"`
+	Hash         string   `json:"hash"`
+	Name         string   `json:"name"`
+	Status       string   `json:"status"`
+	PercentDone  float64  `json:"percentDone"` // 0...1
+	SizeWhenDone uint64   `json:"sizeWhenDone"`
+	TotalSize    uint64   `json:"totalSize"`
+	Downloaded   uint64   `json:"downloaded"`
+	Uploaded     uint64   `json:"uploaded"`
+	Ratio        float64  `json:"ratio"` // -1 not available, -2 infinite
+	RateDownload uint64   `json:"rateDownload"`
+	RateUpload   uint64   `json:"rateUpload"`
+	ETA          int64    `json:"eta"` // seconds, -1 unknown
+	DownloadDir  string   `json:"downloadDir"`
+	Labels       []string `json:"labels"`
+	Private      bool     `json:"private"`
+	AddedDate    int64    `json:"addedDate"`
+	DoneDate     int64    `json:"doneDate"`
+	Error        int      `json:"error"` // 0 none, 1 tracker warning, 2 tracker error, 3 local error
+	ErrorString  string   `json:"errorString"`
+}
+
+// Stats is the encoding of transmission.Stats
+type Stats struct {
+	Torrents          int    `json:"torrents"`
+	Active            int    `json:"active"`
+	Paused            int    `json:"paused"`
+	RateDownload      uint64 `json:"rateDownload"`
+	RateUpload        uint64 `json:"rateUpload"`
+	SessionDownloaded uint64 `json:"sessionDownloaded"`
+	SessionUploaded   uint64 `json:"sessionUploaded"`
+	TotalDownloaded   uint64 `json:"totalDownloaded"`
+	TotalUploaded     uint64 `json:"totalUploaded"`
+}
+
+// Event is the encoding of a transmission.Event
+type Event struct {
+	Type     string    `json:"type"` // EventType.String(), e.g. "completed"
+	Time     time.Time `json:"time"`
+	Torrent  *Torrent  `json:"torrent,omitempty"`
+	Previous *Torrent  `json:"previous,omitempty"`
+	Error    string    `json:"error,omitempty"`    // for "disconnected"
+	Rate     uint64    `json:"rate,omitempty"`     // B/s, for the speed events
+	Baseline uint64    `json:"baseline,omitempty"` // B/s, for the speed events
+}
+
+// EventType returns the type of the event, false for types this version
+// doesn't know
+func (e *Event) EventType() (transmission.EventType, bool) {
+	for et := transmission.EventAdded; et.String() != "unknown"; et++ {
+		if et.String() == e.Type {
+			return et, true
+		}
+	}
+	return 0, false
+}
+
+// NewTorrent returns the encoding of t
+func NewTorrent(t *transmission.Torrent) *Torrent {
+	eta := int64(-1)
+	if left, ok := t.TimeLeft(); ok {
+		eta = int64(left.Seconds())
+	}
+	labels := t.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	return &Torrent{
+		ID:           t.ID,
+		Hash:         t.InfoHash,
+		Name:         t.Name,
+		Status:       t.Status.String(),
+		PercentDone:  math.Round(float64(t.PercentDone)*1e4) / 1e4,
+		SizeWhenDone: t.SizeWhenDone,
+		TotalSize:    t.TotalSize,
+		Downloaded:   t.DownloadedEver,
+		Uploaded:     t.UploadedEver,
+		Ratio:        t.UploadRatio,
+		RateDownload: t.DownloadRate(),
+		RateUpload:   t.UploadRate(),
+		ETA:          eta,
+		DownloadDir:  t.DownloadDir,
+		Labels:       labels,
+		Private:      t.IsPrivate,
+		AddedDate:    t.AddedDate,
+		DoneDate:     t.DoneDate,
+		Error:        t.Error,
+		ErrorString:  t.ErrorString,
+	}
+}
+
+// NewStats returns the encoding of s
+func NewStats(s *transmission.Stats) *Stats {
+	return &Stats{
+		Torrents:          s.TorrentCount,
+		Active:            s.ActiveTorrentCount,
+		Paused:            s.PausedTorrentCount,
+		RateDownload:      s.DownloadSpeed,
+		RateUpload:        s.UploadSpeed,
+		SessionDownloaded: s.CurrentStats.DownloadedBytes,
+		SessionUploaded:   s.CurrentStats.UploadedBytes,
+		TotalDownloaded:   s.CumulativeStats.DownloadedBytes,
+		TotalUploaded:     s.CumulativeStats.UploadedBytes,
+	}
+}
+
+// NewEvent returns the encoding of e
+func NewEvent(e transmission.Event) *Event {
+	w := &Event{Type: e.Type.String(), Time: e.Time, Rate: e.Rate, Baseline: e.Baseline}
+	if e.Torrent != nil {
+		w.Torrent = NewTorrent(e.Torrent)
+	}
+	if e.Previous != nil {
+		w.Previous = NewTorrent(e.Previous)
+	}
+	if e.Err != nil {
+		w.Error = e.Err.Error()
+	}
+	return w
+}
+
+// Marshal encodes v, a *transmission.Torrent, *transmission.Stats,
+// transmission.Event or one of the types of this package, in an envelope
+func Marshal(v interface{}) ([]byte, error) {
+	var kind string
+	switch t := v.(type) {
+	case *transmission.Torrent:
+		kind, v = KindTorrent, NewTorrent(t)
+	case *transmission.Stats:
+		kind, v = KindStats, NewStats(t)
+	case transmission.Event:
+		kind, v = KindEvent, NewEvent(t)
+	case *Torrent:
+		kind = KindTorrent
+	case *Stats:
+		kind = KindStats
+	case *Event:
+		kind = KindEvent
+	default:
+		return nil, fmt.Errorf("wire: can't encode %T", v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{Schema: SchemaVersion, Kind: kind, Time: time.Now().UTC(), Data: data})
+}
+
+// Unmarshal decodes a message written by Marshal, of any schema version;
+// the fields unknown to this version are ignored
+func Unmarshal(b []byte) (*Message, error) {
+	var env Envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, fmt.Errorf("wire: %w", err)
+	}
+	if env.Schema < 1 {
+		return nil, fmt.Errorf("wire: not a message, schema %d", env.Schema)
+	}
+	m := &Message{Schema: env.Schema, Kind: env.Kind, Time: env.Time}
+	var v interface{}
+	switch env.Kind {
+	case KindTorrent:
+		m.Torrent = new(Torrent)
+		v = m.Torrent
+	case KindStats:
+		m.Stats = new(Stats)
+		v = m.Stats
+	case KindEvent:
+		m.Event = new(Event)
+		v = m.Event
+	default:
+		return m, fmt.Errorf("%w %q", ErrUnknownKind, env.Kind)
+	}
+	if err := json.Unmarshal(env.Data, v); err != nil {
+		return nil, fmt.Errorf("wire: %s: %w", env.Kind, err)
+	}
+	return m, nil
+}
+
+// Codec encodes messages for a transport; JSON is the default, other
+// encodings can be plugged into the publishers using a Codec
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte) (*Message, error)
+	ContentType() string
+}
+
+// JSON is the Codec of Marshal and Unmarshal
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return Marshal(v) }
+func (jsonCodec) Unmarshal(b []byte) (*Message, error)  { return Unmarshal(b) }
+func (jsonCodec) ContentType() string                   { return "application/json" }